@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"FeatherProxy/app/internal/database"
+	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/stats"
+
+	"github.com/google/uuid"
+)
+
+// runExport implements "app export": it streams proxy stats from the configured database to a file or
+// stdout, e.g. to archive them before the stats vacuum deletes them.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", stats.ExportCSV, "output format: csv or ndjson")
+	since := fs.String("since", "", "export stats at or after this time (RFC3339); default: now minus -window")
+	until := fs.String("until", "", "export stats before this time (RFC3339); default: now")
+	window := fs.Duration("window", 24*time.Hour, "time range ending at -until, used when -since is not set")
+	source := fs.String("source-server", "", "only stats of this source server UUID")
+	route := fs.String("route", "", "only stats of this route UUID")
+	target := fs.String("target-server", "", "only stats of this target server UUID")
+	outcome := fs.String("outcome", "", "only stats with this outcome")
+	out := fs.String("out", "", "output file; default: stdout")
+	compress := fs.Bool("gzip", false, "gzip-compress the output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if _, ok := stats.ExportContentTypes[*format]; !ok {
+		return errors.New("-format must be csv or ndjson")
+	}
+
+	var filter schema.StatsFilter
+	end := time.Now().UTC()
+	if *until != "" {
+		t, err := time.Parse(time.RFC3339, *until)
+		if err != nil {
+			return fmt.Errorf("-until: %w", err)
+		}
+		end = t
+		filter.Until = &end
+	}
+	start := end.Add(-*window)
+	if *since != "" {
+		t, err := time.Parse(time.RFC3339, *since)
+		if err != nil {
+			return fmt.Errorf("-since: %w", err)
+		}
+		start = t
+	}
+	filter.Since = &start
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   **uuid.UUID
+	}{
+		{"source-server", *source, &filter.SourceServerUUID},
+		{"route", *route, &filter.RouteUUID},
+		{"target-server", *target, &filter.TargetServerUUID},
+	} {
+		if f.value == "" {
+			continue
+		}
+		id, err := uuid.Parse(f.value)
+		if err != nil {
+			return fmt.Errorf("-%s: %w", f.name, err)
+		}
+		*f.dst = &id
+	}
+	if *outcome != "" {
+		known := false
+		for _, o := range schema.Outcomes {
+			known = known || o == *outcome
+		}
+		if !known {
+			return fmt.Errorf("-outcome: unknown outcome %q", *outcome)
+		}
+		filter.Outcome = *outcome
+	}
+
+	db, err := database.NewHandler()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	repo := database.NewRepository(db.DB(), nil, 0)
+
+	var dst io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		dst = f
+	}
+	bw := bufio.NewWriter(dst)
+	var w io.Writer = bw
+	var gz *gzip.Writer
+	if *compress {
+		gz = gzip.NewWriter(bw)
+		w = gz
+	}
+	ew, err := stats.NewExportWriter(w, *format)
+	if err != nil {
+		return err
+	}
+	rows := 0
+	if err := repo.StreamProxyStats(filter, func(s schema.ProxyStat) error {
+		rows++
+		return ew.Write(s)
+	}); err != nil {
+		return err
+	}
+	if err := ew.Close(); err != nil {
+		return err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d stats\n", rows)
+	return nil
+}
@@ -0,0 +1,210 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEntry() *Entry {
+	return &Entry{
+		Time:      time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*3600)),
+		ClientIP:  "127.0.0.1",
+		User:      "frank",
+		Method:    "GET",
+		URI:       "/apache_pb.gif?x=1",
+		Proto:     "HTTP/1.0",
+		Status:    200,
+		Bytes:     2326,
+		Duration:  1500 * time.Microsecond,
+		Referer:   "http://www.example.com/start.html",
+		UserAgent: `Mozilla/4.08 "evil"`,
+		RequestID: "req-1",
+	}
+}
+
+func TestFormatters(t *testing.T) {
+	e := testEntry()
+	common, _ := NewFormatter(FormatCommon, "")
+	want := `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif?x=1 HTTP/1.0" 200 2326`
+	if got := string(common(e)); got != want {
+		t.Errorf("common = %q, want %q", got, want)
+	}
+	combined, _ := NewFormatter(FormatCombined, "")
+	if got := string(combined(e)); got != want+` "http://www.example.com/start.html" "Mozilla/4.08 \"evil\""` {
+		t.Errorf("combined = %q", got)
+	}
+	js, _ := NewFormatter(FormatJSON, "")
+	var m map[string]any
+	if err := json.Unmarshal(js(e), &m); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if m["status"] != float64(200) || m["request_id"] != "req-1" || m["duration_ms"] != 1.5 {
+		t.Errorf("json = %v", m)
+	}
+	tmpl, err := NewFormatter(FormatTemplate, "{{.Method}} {{.URI}} {{.Status}} {{.DurationMs}}ms\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(tmpl(e)); got != "GET /apache_pb.gif?x=1 200 1.5ms" {
+		t.Errorf("template = %q", got)
+	}
+	if _, err := NewFormatter(FormatTemplate, ""); err == nil {
+		t.Error("empty template: want error")
+	}
+	if _, err := NewFormatter("xml", ""); err == nil {
+		t.Error("unknown format: want error")
+	}
+}
+
+func TestFormatCommon_escapesControlCharacters(t *testing.T) {
+	e := testEntry()
+	e.URI = "/a\n1.2.3.4 - - [forged]"
+	e.Bytes = 0
+	got := string(formatCommon(e))
+	if strings.Contains(got, "\n") || !strings.Contains(got, `/a\x0a1.2.3.4`) || !strings.HasSuffix(got, " 200 -") {
+		t.Errorf("common = %q", got)
+	}
+}
+
+func TestRotatingFile_sizeAndBackups(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	rf, err := OpenRotatingFile(&RotatingFile{
+		Path:       filepath.Join(dir, "access.log"),
+		MaxSize:    10,
+		MaxBackups: 2,
+		now:        func() time.Time { now = now.Add(time.Second); return now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+	for _, line := range []string{"one", "two", "three", "four", "five"} {
+		if err := rf.Write([]byte(line + "-line")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups, _ := filepath.Glob(filepath.Join(dir, "access.log.*"))
+	if len(backups) != 2 {
+		t.Fatalf("backups = %v, want 2", backups)
+	}
+	cur, _ := os.ReadFile(filepath.Join(dir, "access.log"))
+	if string(cur) != "five-line\n" {
+		t.Errorf("current file = %q", cur)
+	}
+	newest, _ := os.ReadFile(backups[1])
+	if string(newest) != "four-line\n" {
+		t.Errorf("newest backup = %q", newest)
+	}
+}
+
+func TestRotatingFile_interval(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	rf, err := OpenRotatingFile(&RotatingFile{
+		Path:     filepath.Join(dir, "access.log"),
+		Interval: time.Hour,
+		now:      func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+	_ = rf.Write([]byte("a"))
+	_ = rf.Write([]byte("b"))
+	now = now.Add(time.Hour)
+	_ = rf.Write([]byte("c"))
+	backups, _ := filepath.Glob(filepath.Join(dir, "access.log.*"))
+	if len(backups) != 1 {
+		t.Fatalf("backups = %v, want 1", backups)
+	}
+	old, _ := os.ReadFile(backups[0])
+	if string(old) != "a\nb\n" {
+		t.Errorf("backup = %q", old)
+	}
+}
+
+func TestRotatingFile_failedRotateKeepsWriting(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	path := filepath.Join(dir, "access.log")
+	rf, err := OpenRotatingFile(&RotatingFile{Path: path, MaxSize: 3, now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+	// A directory where the backup should go makes the rename fail.
+	if err := os.MkdirAll(filepath.Join(path+"."+now.Format(backupTimeLayout), "x"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	_ = rf.Write([]byte("a"))
+	for _, line := range []string{"b", "c"} {
+		if err := rf.Write([]byte(line)); err == nil || errors.Is(err, os.ErrClosed) {
+			t.Errorf("write %s: err = %v, want a rotate error on an open file", line, err)
+		}
+	}
+	cur, _ := os.ReadFile(path)
+	if string(cur) != "a\nb\nc\n" {
+		t.Errorf("current file = %q, want every line", cur)
+	}
+}
+
+func TestSyslogWriter_udp(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("udp not available:", err)
+	}
+	defer pc.Close()
+	sw, err := DialSyslog(&SyslogWriter{Network: "udp", Address: pc.LocalAddr().String(), Facility: DefaultSyslogFacility, AppName: "fp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+	if err := sw.Write([]byte("GET / 200")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	_ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+	// local0 (16) * 8 + info (6) = 134
+	if !strings.HasPrefix(msg, "<134>1 ") || !strings.Contains(msg, " fp ") || !strings.HasSuffix(msg, " access - GET / 200") {
+		t.Errorf("message = %q", msg)
+	}
+}
+
+func TestSyslogWriter_tcpOctetCounting(t *testing.T) {
+	sw := &SyslogWriter{Network: "tcp", Facility: 1, AppName: "fp", hostname: "h", pid: "1"}
+	msg := string(sw.message([]byte("x"), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	body := "<14>1 2024-01-01T00:00:00.000000Z h fp 1 access - x"
+	if want := strconv.Itoa(len(body)) + " " + body; msg != want {
+		t.Errorf("message = %q, want %q", msg, want)
+	}
+}
+
+func TestLogger_fileOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	l, err := New(Config{Format: FormatCommon, Output: OutputFile, FilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Log(testEntry())
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	l.Log(testEntry()) // after Close: dropped, must not panic
+	b, _ := os.ReadFile(path)
+	if strings.Count(string(b), "\n") != 1 || !strings.HasPrefix(string(b), "127.0.0.1 - frank ") {
+		t.Errorf("file = %q", b)
+	}
+}
@@ -0,0 +1,142 @@
+package accesslog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimeLayout is appended to the file name of rotated files; it sorts chronologically.
+const backupTimeLayout = "20060102-150405.000000"
+
+// RotatingFile is a Writer that appends to a file and rotates it when it grows past MaxSize or when
+// Interval has elapsed since it was opened. Rotated files are renamed to <path>.<timestamp>; at most
+// MaxBackups of them are kept (0 = unlimited) and those older than MaxAge are removed (0 = forever).
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64
+	Interval   time.Duration
+	MaxBackups int
+	MaxAge     time.Duration
+
+	now      func() time.Time
+	f        *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// OpenRotatingFile opens (or creates) the file at rf.Path for appending.
+func OpenRotatingFile(rf *RotatingFile) (*RotatingFile, error) {
+	if rf.now == nil {
+		rf.now = time.Now
+	}
+	if err := os.MkdirAll(filepath.Dir(rf.Path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) open() error {
+	f, err := os.OpenFile(rf.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	rf.f = f
+	rf.size = info.Size()
+	rf.openedAt = rf.now()
+	return nil
+}
+
+// RotateError is returned by Write when the line was written but the rotation before it failed.
+type RotateError struct {
+	Path string
+	Err  error
+}
+
+func (e *RotateError) Error() string { return fmt.Sprintf("accesslog: rotate %s: %v", e.Path, e.Err) }
+
+func (e *RotateError) Unwrap() error { return e.Err }
+
+// Write appends line and a newline, rotating first if needed. When rotation fails the line is still
+// written to the current file and a *RotateError is returned.
+func (rf *RotatingFile) Write(line []byte) error {
+	n := int64(len(line) + 1)
+	var rotateErr error
+	if rf.size > 0 && ((rf.MaxSize > 0 && rf.size+n > rf.MaxSize) || (rf.Interval > 0 && rf.now().Sub(rf.openedAt) >= rf.Interval)) {
+		if err := rf.rotate(); err != nil {
+			rotateErr = &RotateError{Path: rf.Path, Err: err}
+		}
+	}
+	written, err := rf.f.Write(append(line, '\n'))
+	rf.size += int64(written)
+	if err != nil {
+		return err
+	}
+	return rotateErr
+}
+
+// Close closes the current file.
+func (rf *RotatingFile) Close() error {
+	if rf.f == nil {
+		return nil
+	}
+	return rf.f.Close()
+}
+
+// rotate renames the current file to a backup and opens a new one. The current file stays open until
+// the new one is, so a failed rotation leaves the writer on the current file rather than a closed one.
+func (rf *RotatingFile) rotate() error {
+	backup := rf.Path + "." + rf.now().Format(backupTimeLayout)
+	if err := os.Rename(rf.Path, backup); err != nil {
+		return err
+	}
+	old := rf.f
+	if err := rf.open(); err != nil {
+		_ = os.Rename(backup, rf.Path)
+		return err
+	}
+	_ = old.Close()
+	rf.prune()
+	return nil
+}
+
+// prune removes rotated files beyond MaxBackups or older than MaxAge.
+func (rf *RotatingFile) prune() {
+	if rf.MaxBackups <= 0 && rf.MaxAge <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(rf.Path + ".*")
+	if err != nil {
+		return
+	}
+	prefix := filepath.Base(rf.Path) + "."
+	var backups []string
+	for _, m := range matches {
+		if _, err := time.Parse(backupTimeLayout, strings.TrimPrefix(filepath.Base(m), prefix)); err == nil {
+			backups = append(backups, m)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(backups))) // newest first
+	cutoff := rf.now().Add(-rf.MaxAge)
+	for i, b := range backups {
+		expired := false
+		if rf.MaxAge > 0 {
+			ts, _ := time.ParseInLocation(backupTimeLayout, strings.TrimPrefix(filepath.Base(b), prefix), time.Local)
+			expired = ts.Before(cutoff)
+		}
+		if (rf.MaxBackups > 0 && i >= rf.MaxBackups) || expired {
+			_ = os.Remove(b)
+		}
+	}
+}
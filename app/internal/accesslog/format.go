@@ -0,0 +1,192 @@
+// Package accesslog writes one line per proxied request in Common/Combined Log Format, JSON or a custom
+// template, to stdout, a rotating file or syslog (RFC 5424). It is independent of the stats pipeline.
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Formats.
+const (
+	FormatCommon   = "common"
+	FormatCombined = "combined"
+	FormatJSON     = "json"
+	FormatTemplate = "template"
+)
+
+// clfTimeLayout is the Apache/NCSA timestamp layout.
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// Entry is one access log record.
+type Entry struct {
+	Time             time.Time // when the request was received
+	ClientIP         string
+	User             string // basic auth user, if any
+	Method           string
+	URI              string // request URI as sent by the client (path and query)
+	Proto            string
+	Host             string
+	Status           int
+	Bytes            int64 // response body bytes
+	Duration         time.Duration
+	Referer          string
+	UserAgent        string
+	RequestID        string
+	SourceServerUUID string
+	RouteUUID        string // empty when no route matched
+	TargetServerUUID string // empty when the request was not forwarded
+}
+
+// CLFTime returns Time in Common Log Format, e.g. 10/Oct/2000:13:55:36 -0700.
+func (e *Entry) CLFTime() string { return e.Time.Format(clfTimeLayout) }
+
+// DurationMs returns the duration in milliseconds.
+func (e *Entry) DurationMs() float64 { return float64(e.Duration.Microseconds()) / 1000 }
+
+// Formatter renders an entry as one line, without the trailing newline.
+type Formatter func(e *Entry) []byte
+
+// NewFormatter returns the formatter for format. tmpl is a text/template over Entry and is used only
+// with FormatTemplate.
+func NewFormatter(format, tmpl string) (Formatter, error) {
+	switch format {
+	case FormatCommon, "":
+		return formatCommon, nil
+	case FormatCombined:
+		return formatCombined, nil
+	case FormatJSON:
+		return formatJSON, nil
+	case FormatTemplate:
+		t, err := ParseTemplate(tmpl)
+		if err != nil {
+			return nil, err
+		}
+		return func(e *Entry) []byte {
+			var buf bytes.Buffer
+			if err := t.Execute(&buf, e); err != nil {
+				return []byte("accesslog: template error: " + err.Error())
+			}
+			return bytes.TrimRight(buf.Bytes(), "\n")
+		}, nil
+	default:
+		return nil, fmt.Errorf("accesslog: unknown format %q", format)
+	}
+}
+
+// ParseTemplate parses a custom line template.
+func ParseTemplate(tmpl string) (*template.Template, error) {
+	if strings.TrimSpace(tmpl) == "" {
+		return nil, fmt.Errorf("accesslog: template required for format %q", FormatTemplate)
+	}
+	return template.New("accesslog").Option("missingkey=zero").Parse(tmpl)
+}
+
+// formatCommon: host ident authuser [date] "request" status bytes
+func formatCommon(e *Entry) []byte {
+	var b strings.Builder
+	writeCommon(&b, e)
+	return []byte(b.String())
+}
+
+// formatCombined: Common Log Format plus "referer" "user-agent"
+func formatCombined(e *Entry) []byte {
+	var b strings.Builder
+	writeCommon(&b, e)
+	b.WriteString(` "`)
+	b.WriteString(escapeQuoted(orDash(e.Referer)))
+	b.WriteString(`" "`)
+	b.WriteString(escapeQuoted(orDash(e.UserAgent)))
+	b.WriteByte('"')
+	return []byte(b.String())
+}
+
+func writeCommon(b *strings.Builder, e *Entry) {
+	b.WriteString(orDash(e.ClientIP))
+	b.WriteString(" - ")
+	b.WriteString(orDash(strings.ReplaceAll(e.User, " ", "_")))
+	b.WriteString(" [")
+	b.WriteString(e.CLFTime())
+	b.WriteString(`] "`)
+	b.WriteString(escapeQuoted(e.Method + " " + e.URI + " " + e.Proto))
+	b.WriteString(`" `)
+	b.WriteString(strconv.Itoa(e.Status))
+	b.WriteByte(' ')
+	if e.Bytes > 0 {
+		b.WriteString(strconv.FormatInt(e.Bytes, 10))
+	} else {
+		b.WriteByte('-')
+	}
+}
+
+type jsonEntry struct {
+	Time             string  `json:"time"`
+	ClientIP         string  `json:"client_ip"`
+	User             string  `json:"user,omitempty"`
+	Method           string  `json:"method"`
+	URI              string  `json:"uri"`
+	Proto            string  `json:"proto"`
+	Host             string  `json:"host"`
+	Status           int     `json:"status"`
+	Bytes            int64   `json:"bytes"`
+	DurationMs       float64 `json:"duration_ms"`
+	Referer          string  `json:"referer,omitempty"`
+	UserAgent        string  `json:"user_agent,omitempty"`
+	RequestID        string  `json:"request_id,omitempty"`
+	SourceServerUUID string  `json:"source_server_uuid,omitempty"`
+	RouteUUID        string  `json:"route_uuid,omitempty"`
+	TargetServerUUID string  `json:"target_server_uuid,omitempty"`
+}
+
+func formatJSON(e *Entry) []byte {
+	b, _ := json.Marshal(jsonEntry{
+		Time:             e.Time.Format(time.RFC3339Nano),
+		ClientIP:         e.ClientIP,
+		User:             e.User,
+		Method:           e.Method,
+		URI:              e.URI,
+		Proto:            e.Proto,
+		Host:             e.Host,
+		Status:           e.Status,
+		Bytes:            e.Bytes,
+		DurationMs:       e.DurationMs(),
+		Referer:          e.Referer,
+		UserAgent:        e.UserAgent,
+		RequestID:        e.RequestID,
+		SourceServerUUID: e.SourceServerUUID,
+		RouteUUID:        e.RouteUUID,
+		TargetServerUUID: e.TargetServerUUID,
+	})
+	return b
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// escapeQuoted escapes quotes, backslashes and control characters so client-supplied values cannot
+// break out of a quoted CLF field or forge extra lines.
+func escapeQuoted(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package accesslog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"FeatherProxy/app/internal/logging"
+)
+
+var logger = logging.For(logging.Proxy)
+
+// Outputs.
+const (
+	OutputStdout = "stdout"
+	OutputFile   = "file"
+	OutputSyslog = "syslog"
+)
+
+// queueSize bounds lines waiting to be written; when full, lines are dropped rather than slowing requests.
+const queueSize = 4096
+
+// Writer receives formatted lines (without trailing newline).
+type Writer interface {
+	Write(line []byte) error
+	Close() error
+}
+
+// Config describes one access log.
+type Config struct {
+	Format         string // FormatCommon, FormatCombined, FormatJSON or FormatTemplate
+	Template       string // text/template over Entry, for FormatTemplate
+	Output         string // OutputStdout, OutputFile or OutputSyslog
+	FilePath       string
+	MaxSizeBytes   int64
+	RotateInterval time.Duration
+	MaxBackups     int
+	MaxAge         time.Duration
+	SyslogNetwork  string
+	SyslogAddress  string
+	SyslogFacility int
+	SyslogAppName  string
+}
+
+// Logger formats entries and writes them from a background goroutine.
+type Logger struct {
+	format  Formatter
+	w       Writer
+	ch      chan []byte
+	done    chan struct{}
+	mu      sync.RWMutex // guards closed against Log racing Close
+	closed  bool
+	dropped atomic.Int64
+}
+
+// New opens the output described by config and starts the writer goroutine.
+func New(config Config) (*Logger, error) {
+	format, err := NewFormatter(config.Format, config.Template)
+	if err != nil {
+		return nil, err
+	}
+	var w Writer
+	switch config.Output {
+	case OutputStdout, "":
+		w = stdoutWriter{}
+	case OutputFile:
+		w, err = OpenRotatingFile(&RotatingFile{
+			Path:       config.FilePath,
+			MaxSize:    config.MaxSizeBytes,
+			Interval:   config.RotateInterval,
+			MaxBackups: config.MaxBackups,
+			MaxAge:     config.MaxAge,
+		})
+	case OutputSyslog:
+		w, err = DialSyslog(&SyslogWriter{
+			Network:  config.SyslogNetwork,
+			Address:  config.SyslogAddress,
+			Facility: config.SyslogFacility,
+			AppName:  config.SyslogAppName,
+		})
+	default:
+		return nil, fmt.Errorf("accesslog: unknown output %q", config.Output)
+	}
+	if err != nil {
+		return nil, err
+	}
+	l := &Logger{format: format, w: w, ch: make(chan []byte, queueSize), done: make(chan struct{})}
+	go l.run()
+	return l, nil
+}
+
+// Log formats e and queues the line. Non-blocking; drops the line when the queue is full.
+func (l *Logger) Log(e *Entry) {
+	line := l.format(e)
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return
+	}
+	select {
+	case l.ch <- line:
+	default:
+		if n := l.dropped.Add(1); n == 1 || n%1000 == 0 {
+			logger.Warn("access log queue full, dropping lines", "dropped", n)
+		}
+	}
+}
+
+// Close writes the queued lines and closes the output.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	close(l.ch)
+	l.mu.Unlock()
+	<-l.done
+	return l.w.Close()
+}
+
+func (l *Logger) run() {
+	defer close(l.done)
+	for line := range l.ch {
+		if err := l.w.Write(line); err != nil {
+			logger.Error("access log write failed", "error", err)
+		}
+	}
+}
+
+type stdoutWriter struct{}
+
+func (stdoutWriter) Write(line []byte) error {
+	_, err := os.Stdout.Write(append(line, '\n'))
+	return err
+}
+
+func (stdoutWriter) Close() error { return nil }
@@ -0,0 +1,92 @@
+package accesslog
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// syslogSeverityInfo is the RFC 5424 severity used for access log lines.
+const syslogSeverityInfo = 6
+
+// DefaultSyslogFacility is local0.
+const DefaultSyslogFacility = 16
+
+// SyslogWriter is a Writer that sends each line as an RFC 5424 message over UDP, TCP or a unix socket.
+// TCP uses octet-counting framing (RFC 6587). The connection is redialled after a write error.
+type SyslogWriter struct {
+	Network  string // "udp", "tcp", "unix" or "unixgram"
+	Address  string
+	Facility int
+	AppName  string
+
+	hostname string
+	pid      string
+	conn     net.Conn
+}
+
+// DialSyslog connects sw to its collector.
+func DialSyslog(sw *SyslogWriter) (*SyslogWriter, error) {
+	if sw.AppName == "" {
+		sw.AppName = "featherproxy"
+	}
+	sw.hostname, _ = os.Hostname()
+	if sw.hostname == "" {
+		sw.hostname = "-"
+	}
+	sw.pid = strconv.Itoa(os.Getpid())
+	if err := sw.dial(); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+func (sw *SyslogWriter) dial() error {
+	conn, err := net.DialTimeout(sw.Network, sw.Address, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("accesslog: dial syslog %s %s: %w", sw.Network, sw.Address, err)
+	}
+	sw.conn = conn
+	return nil
+}
+
+// Write sends line as one syslog message.
+func (sw *SyslogWriter) Write(line []byte) error {
+	msg := sw.message(line, time.Now())
+	if sw.conn == nil {
+		if err := sw.dial(); err != nil {
+			return err
+		}
+	}
+	if _, err := sw.conn.Write(msg); err != nil {
+		// One retry on a fresh connection (collector restarted, stream closed).
+		_ = sw.conn.Close()
+		sw.conn = nil
+		if err := sw.dial(); err != nil {
+			return err
+		}
+		_, err = sw.conn.Write(msg)
+		return err
+	}
+	return nil
+}
+
+// message formats an RFC 5424 message: <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG.
+func (sw *SyslogWriter) message(line []byte, t time.Time) []byte {
+	pri := sw.Facility*8 + syslogSeverityInfo
+	msg := fmt.Sprintf("<%d>1 %s %s %s %s access - %s", pri, t.UTC().Format("2006-01-02T15:04:05.000000Z"), sw.hostname, sw.AppName, sw.pid, line)
+	if sw.Network == "tcp" {
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	}
+	return []byte(msg)
+}
+
+// Close closes the connection.
+func (sw *SyslogWriter) Close() error {
+	if sw.conn == nil {
+		return nil
+	}
+	return sw.conn.Close()
+}
@@ -0,0 +1,136 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/metrics"
+)
+
+// Notification statuses.
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Notification is what a webhook receives: the JSON body by default, or the data of the webhook's template.
+type Notification struct {
+	Status   string           `json:"status"` // StatusFiring or StatusResolved
+	Rule     schema.AlertRule `json:"rule"`
+	Value    float64          `json:"value"`    // last measured value
+	Requests int64            `json:"requests"` // requests in the last window
+	StartsAt time.Time        `json:"starts_at"`
+	EndsAt   *time.Time       `json:"ends_at,omitempty"` // set when resolved
+	Summary  string           `json:"summary"`           // one line, e.g. for chat messages
+}
+
+func newNotification(status string, st *ruleState, now time.Time) Notification {
+	n := Notification{Status: status, Rule: st.rule, Value: st.status.Value, Requests: st.status.Requests, StartsAt: now}
+	if st.status.FiringSince != nil {
+		n.StartsAt = *st.status.FiringSince
+	}
+	if status == StatusResolved {
+		n.EndsAt = &now
+	}
+	window := (time.Duration(st.rule.WindowSeconds) * time.Second).String()
+	n.Summary = fmt.Sprintf("[%s] %s: %s %s %s %s over %s", status, st.rule.Name, st.rule.Metric,
+		strconv.FormatFloat(n.Value, 'g', 4, 64), st.rule.Operator, strconv.FormatFloat(st.rule.Threshold, 'g', 4, 64), window)
+	return n
+}
+
+// templateFuncs are available in webhook templates in addition to the text/template builtins.
+var templateFuncs = template.FuncMap{
+	// json renders v as JSON, e.g. {"text": {{json .Summary}}} quotes and escapes the summary.
+	"json": func(v any) (string, error) {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false) // keep "<" and ">" readable in chat messages
+		if err := enc.Encode(v); err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(buf.String(), "\n"), nil
+	},
+}
+
+// ParseTemplate parses a webhook body template. An empty template is valid (the notification JSON is sent).
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("webhook").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+}
+
+// render returns the request body for n.
+func render(hook schema.AlertWebhook, n Notification) ([]byte, error) {
+	if hook.Template == "" {
+		return json.Marshal(n)
+	}
+	tmpl, err := ParseTemplate(hook.Template)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, n); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// notify sends each notification to the rule's webhooks (every webhook when the rule names none).
+// Failures are logged and counted; they are not retried.
+func (s *Service) notify(ctx context.Context, due []Notification) {
+	hooks, err := s.repo.ListAlertWebhooks()
+	if err != nil {
+		logger.Error("alerting: list webhooks failed", "error", err)
+		return
+	}
+	for _, n := range due {
+		logger.Info("alert "+n.Status, "rule", n.Rule.AlertRuleUUID, "name", n.Rule.Name, "value", n.Value)
+		for _, hook := range hooks {
+			if len(n.Rule.WebhookUUIDs) > 0 && !slices.Contains(n.Rule.WebhookUUIDs, hook.AlertWebhookUUID) {
+				continue
+			}
+			result := "ok"
+			if err := s.send(ctx, hook, n); err != nil {
+				result = "error"
+				logger.Warn("alerting: webhook failed", "webhook", hook.AlertWebhookUUID, "name", hook.Name, "rule", n.Rule.AlertRuleUUID, "error", err)
+			}
+			metrics.AlertNotifications.With(n.Status, result).Inc()
+		}
+	}
+}
+
+// send POSTs one notification to hook. Any non-2xx answer is an error.
+func (s *Service) send(ctx context.Context, hook schema.AlertWebhook, n Notification) error {
+	body, err := render(hook, n)
+	if err != nil {
+		return fmt.Errorf("render template: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	contentType := hook.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "FeatherProxy-Alerting")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+// Package alerting evaluates alert rules on recorded proxy stats and notifies webhooks when a rule starts
+// or stops firing.
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"FeatherProxy/app/internal/database"
+	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/logging"
+	"FeatherProxy/app/internal/metrics"
+
+	"github.com/google/uuid"
+)
+
+var logger = logging.For(logging.Stats)
+
+const (
+	defaultInterval       = 30 * time.Second
+	defaultWebhookTimeout = 10 * time.Second
+)
+
+// Config holds alerting configuration (from env or defaults).
+type Config struct {
+	Disabled       bool          // do not evaluate rules
+	Interval       time.Duration // how often rules are evaluated
+	WebhookTimeout time.Duration // per webhook request
+}
+
+// ConfigFromEnv returns config from environment (ALERTING, ALERTING_INTERVAL, ALERT_WEBHOOK_TIMEOUT).
+func ConfigFromEnv() Config {
+	c := Config{Interval: defaultInterval, WebhookTimeout: defaultWebhookTimeout}
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("ALERTING"))) {
+	case "off", "false", "0":
+		c.Disabled = true
+	}
+	if v := os.Getenv("ALERTING_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			c.Interval = d
+		}
+	}
+	if v := os.Getenv("ALERT_WEBHOOK_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			c.WebhookTimeout = d
+		}
+	}
+	return c
+}
+
+// Service evaluates the enabled alert rules every Config.Interval. Rule state is kept in memory: after a
+// restart a rule that is still firing is notified again.
+type Service struct {
+	repo   database.Repository
+	config Config
+	client *http.Client
+
+	mu     sync.Mutex
+	states map[uuid.UUID]*ruleState
+}
+
+// ruleState is the alerting state of one rule between evaluations.
+type ruleState struct {
+	rule     schema.AlertRule
+	status   schema.AlertStatus
+	notified bool // a firing notification was sent for the current firing period
+}
+
+// NewService creates an alerting service reading rules and stats from repo.
+func NewService(repo database.Repository, config Config) *Service {
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	if config.WebhookTimeout <= 0 {
+		config.WebhookTimeout = defaultWebhookTimeout
+	}
+	return &Service{
+		repo:   repo,
+		config: config,
+		client: &http.Client{Timeout: config.WebhookTimeout},
+		states: make(map[uuid.UUID]*ruleState),
+	}
+}
+
+// Run evaluates the rules until ctx is cancelled. Returns at once when alerting is disabled.
+func (s *Service) Run(ctx context.Context) {
+	if s.config.Disabled {
+		logger.Info("alerting disabled")
+		return
+	}
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.evaluate(ctx, time.Now())
+		}
+	}
+}
+
+// Statuses returns the last evaluation of every enabled rule, firing rules first.
+func (s *Service) Statuses() []schema.AlertStatus {
+	s.mu.Lock()
+	out := make([]schema.AlertStatus, 0, len(s.states))
+	for _, st := range s.states {
+		out = append(out, st.status)
+	}
+	s.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if fi, fj := out[i].State == schema.AlertStateFiring, out[j].State == schema.AlertStateFiring; fi != fj {
+			return fi
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// evaluate measures every enabled rule at now, updates its state and sends the notifications due:
+// firing when a rule starts firing (or its silence ends while it still fires) and resolved when a
+// notified rule stops firing, is disabled or is deleted.
+func (s *Service) evaluate(ctx context.Context, now time.Time) {
+	rules, err := s.repo.ListAlertRules()
+	if err != nil {
+		logger.Error("alerting: list rules failed", "error", err)
+		return
+	}
+	silences, err := s.repo.ListAlertSilences(now)
+	if err != nil {
+		logger.Error("alerting: list silences failed", "error", err)
+	}
+	if n, err := s.repo.DeleteAlertSilencesEndedBefore(now); err != nil {
+		logger.Warn("alerting: delete ended silences failed", "error", err)
+	} else if n > 0 {
+		logger.Debug("alerting: ended silences deleted", "removed", n)
+	}
+
+	var due []Notification
+	seen := make(map[uuid.UUID]bool, len(rules))
+	firing := 0
+	s.mu.Lock()
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		seen[rule.AlertRuleUUID] = true
+		st := s.states[rule.AlertRuleUUID]
+		if st == nil {
+			st = &ruleState{}
+			s.states[rule.AlertRuleUUID] = st
+		}
+		st.rule = rule
+		prev := st.status
+		st.status = s.measure(rule, now)
+		st.status.Silenced = silenced(silences, rule.AlertRuleUUID, now)
+		switch {
+		case st.status.State == schema.AlertStateFiring:
+			firing++
+			since := now
+			if prev.FiringSince != nil {
+				since = *prev.FiringSince
+			}
+			st.status.FiringSince = &since
+			if !st.notified && !st.status.Silenced {
+				st.notified = true
+				due = append(due, newNotification(StatusFiring, st, now))
+			}
+		case st.status.State == schema.AlertStateUnknown && prev.FiringSince != nil:
+			// Evaluation failed: keep the firing period open rather than flap.
+			st.status.FiringSince = prev.FiringSince
+		case st.notified:
+			due = append(due, newNotification(StatusResolved, st, now))
+			st.notified = false
+		}
+	}
+	for id, st := range s.states {
+		if seen[id] {
+			continue
+		}
+		if st.notified {
+			due = append(due, newNotification(StatusResolved, st, now))
+		}
+		delete(s.states, id)
+	}
+	s.mu.Unlock()
+	metrics.AlertsFiring.With().Set(float64(firing))
+
+	if len(due) > 0 {
+		s.notify(ctx, due)
+	}
+}
+
+// measure computes the rule's metric over its window ending at now.
+func (s *Service) measure(rule schema.AlertRule, now time.Time) schema.AlertStatus {
+	status := schema.AlertStatus{AlertRuleUUID: rule.AlertRuleUUID, Name: rule.Name, EvaluatedAt: now}
+	since := now.Add(-time.Duration(rule.WindowSeconds) * time.Second)
+	filter := schema.StatsFilter{
+		Since:            &since,
+		Until:            &now,
+		SourceServerUUID: rule.SourceServerUUID,
+		RouteUUID:        rule.RouteUUID,
+		TargetServerUUID: rule.TargetServerUUID,
+	}
+	minRequests := max(rule.MinRequests, 1)
+	switch rule.Metric {
+	case schema.AlertMetricRequestCount, schema.AlertMetricErrorRate:
+		counts, err := s.repo.StatsStatusCounts(filter)
+		if err != nil {
+			return failed(status, err)
+		}
+		status.Requests = counts.Total
+		if rule.Metric == schema.AlertMetricRequestCount {
+			status.Value = float64(counts.Total)
+		} else if counts.Total < minRequests {
+			status.State = schema.AlertStateNoData
+			return status
+		} else {
+			status.Value = float64(counts.Status5xx) / float64(counts.Total)
+		}
+	case schema.AlertMetricLatencyP95, schema.AlertMetricLatencyP99:
+		lat, err := s.repo.StatsLatency(schema.StatsGroupNone, filter, 1)
+		if err != nil {
+			return failed(status, err)
+		}
+		if len(lat) > 0 {
+			status.Requests = lat[0].Count
+		}
+		if status.Requests < minRequests {
+			status.State = schema.AlertStateNoData
+			return status
+		}
+		status.Value = lat[0].P95Ms
+		if rule.Metric == schema.AlertMetricLatencyP99 {
+			status.Value = lat[0].P99Ms
+		}
+	default:
+		return failed(status, fmt.Errorf("unknown metric %q", rule.Metric))
+	}
+	status.State = schema.AlertStateOK
+	if compare(status.Value, rule.Operator, rule.Threshold) {
+		status.State = schema.AlertStateFiring
+	}
+	return status
+}
+
+func failed(status schema.AlertStatus, err error) schema.AlertStatus {
+	logger.Error("alerting: evaluation failed", "rule", status.AlertRuleUUID, "error", err)
+	status.State = schema.AlertStateUnknown
+	status.Error = err.Error()
+	return status
+}
+
+func compare(value float64, op string, threshold float64) bool {
+	switch op {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	}
+	return false
+}
+
+func silenced(silences []schema.AlertSilence, rule uuid.UUID, now time.Time) bool {
+	for _, s := range silences {
+		if s.Active(rule, now) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"FeatherProxy/app/internal/database"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+// fakeRepo serves rules, silences, webhooks and status counts from memory; other methods are not used.
+type fakeRepo struct {
+	database.Repository
+	mu       sync.Mutex
+	rules    []schema.AlertRule
+	silences []schema.AlertSilence
+	webhooks []schema.AlertWebhook
+	counts   schema.StatusCounts
+}
+
+func (f *fakeRepo) ListAlertRules() ([]schema.AlertRule, error) { return f.rules, nil }
+func (f *fakeRepo) ListAlertSilences(time.Time) ([]schema.AlertSilence, error) {
+	return f.silences, nil
+}
+func (f *fakeRepo) DeleteAlertSilencesEndedBefore(time.Time) (int64, error) { return 0, nil }
+func (f *fakeRepo) ListAlertWebhooks() ([]schema.AlertWebhook, error)       { return f.webhooks, nil }
+func (f *fakeRepo) StatsStatusCounts(schema.StatsFilter) (schema.StatusCounts, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counts, nil
+}
+
+func TestEvaluate_fireSilenceResolve(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	hookSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(b))
+		mu.Unlock()
+	}))
+	defer hookSrv.Close()
+	received := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), bodies...)
+	}
+
+	rule := schema.AlertRule{
+		AlertRuleUUID: uuid.New(), Name: "checkout 5xx", Enabled: true, Metric: schema.AlertMetricErrorRate,
+		Operator: ">", Threshold: 0.05, WindowSeconds: 300, MinRequests: 10,
+	}
+	repo := &fakeRepo{
+		rules: []schema.AlertRule{rule},
+		webhooks: []schema.AlertWebhook{
+			{AlertWebhookUUID: uuid.New(), Name: "chat", URL: hookSrv.URL, Template: `{"text": {{json .Summary}}}`},
+		},
+		counts: schema.StatusCounts{Total: 100, Status2xx: 90, Status5xx: 10},
+	}
+	svc := NewService(repo, Config{})
+	ctx := context.Background()
+	now := time.Now()
+
+	svc.evaluate(ctx, now)
+	got := received()
+	if len(got) != 1 || !strings.Contains(got[0], `[firing] checkout 5xx: error_rate 0.1 > 0.05 over 5m0s`) {
+		t.Fatalf("after first evaluation: %q", got)
+	}
+	var payload map[string]string
+	if err := json.Unmarshal([]byte(got[0]), &payload); err != nil {
+		t.Errorf("template output is not JSON: %v", err)
+	}
+	svc.evaluate(ctx, now.Add(time.Minute))
+	if len(received()) != 1 {
+		t.Fatalf("still firing must not notify again: %q", received())
+	}
+	if st := svc.Statuses(); len(st) != 1 || st[0].State != schema.AlertStateFiring || st[0].FiringSince == nil || !st[0].FiringSince.Equal(now) {
+		t.Errorf("statuses = %+v", st)
+	}
+
+	repo.counts = schema.StatusCounts{Total: 100, Status2xx: 100}
+	svc.evaluate(ctx, now.Add(2*time.Minute))
+	if got := received(); len(got) != 2 || !strings.Contains(got[1], "[resolved]") {
+		t.Fatalf("after recovery: %q", got)
+	}
+
+	// Firing while silenced is not notified until the silence ends; the resolve that follows is.
+	repo.counts = schema.StatusCounts{Total: 100, Status5xx: 50}
+	repo.silences = []schema.AlertSilence{{AlertRuleUUID: &rule.AlertRuleUUID, StartsAt: now, EndsAt: now.Add(5 * time.Minute)}}
+	svc.evaluate(ctx, now.Add(3*time.Minute))
+	if st := svc.Statuses(); len(received()) != 2 || !st[0].Silenced || st[0].State != schema.AlertStateFiring {
+		t.Fatalf("silenced: %q %+v", received(), st)
+	}
+	svc.evaluate(ctx, now.Add(6*time.Minute))
+	if got := received(); len(got) != 3 || !strings.Contains(got[2], "[firing]") {
+		t.Fatalf("after silence ended: %q", got)
+	}
+
+	// Too little traffic is no data, not an alert: the firing rule resolves.
+	repo.counts = schema.StatusCounts{Total: 3, Status5xx: 3}
+	svc.evaluate(ctx, now.Add(7*time.Minute))
+	if st := svc.Statuses(); st[0].State != schema.AlertStateNoData || len(received()) != 4 {
+		t.Errorf("no data: %+v %q", st, received())
+	}
+}
+
+func TestEvaluate_zeroTrafficAndDeletedRule(t *testing.T) {
+	var n int
+	var mu sync.Mutex
+	var last Notification
+	hookSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		n++
+		_ = json.NewDecoder(r.Body).Decode(&last)
+	}))
+	defer hookSrv.Close()
+	source := uuid.New()
+	rule := schema.AlertRule{
+		AlertRuleUUID: uuid.New(), Name: "no traffic", Enabled: true, Metric: schema.AlertMetricRequestCount,
+		Operator: "<", Threshold: 1, WindowSeconds: 600, SourceServerUUID: &source,
+	}
+	hook := schema.AlertWebhook{AlertWebhookUUID: uuid.New(), Name: "default", URL: hookSrv.URL}
+	other := schema.AlertWebhook{AlertWebhookUUID: uuid.New(), Name: "unused", URL: "http://127.0.0.1:1/"}
+	rule.WebhookUUIDs = []uuid.UUID{hook.AlertWebhookUUID}
+	repo := &fakeRepo{rules: []schema.AlertRule{rule}, webhooks: []schema.AlertWebhook{hook, other}}
+	svc := NewService(repo, Config{})
+	now := time.Now()
+
+	svc.evaluate(context.Background(), now)
+	mu.Lock()
+	if n != 1 || last.Status != StatusFiring || last.Rule.Name != "no traffic" || last.Value != 0 {
+		t.Fatalf("zero traffic: n = %d last = %+v", n, last)
+	}
+	mu.Unlock()
+
+	repo.rules = nil
+	svc.evaluate(context.Background(), now.Add(time.Minute))
+	mu.Lock()
+	defer mu.Unlock()
+	if n != 2 || last.Status != StatusResolved || last.EndsAt == nil {
+		t.Errorf("deleted rule: n = %d last = %+v", n, last)
+	}
+	if len(svc.Statuses()) != 0 {
+		t.Errorf("statuses of deleted rule kept: %+v", svc.Statuses())
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	if _, err := ParseTemplate(""); err != nil {
+		t.Errorf("empty template: %v", err)
+	}
+	if _, err := ParseTemplate("{{.Summary"); err == nil {
+		t.Error("unterminated action: want error")
+	}
+	if _, err := ParseTemplate("{{nosuchfunc .Summary}}"); err == nil {
+		t.Error("unknown function: want error")
+	}
+}
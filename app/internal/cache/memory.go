@@ -2,12 +2,16 @@ package cache
 
 import (
 	"context"
-	"log"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"FeatherProxy/app/internal/logging"
+	"FeatherProxy/app/internal/metrics"
 )
 
+var logger = logging.For(logging.Cache)
+
 type memoryItem struct {
 	value    []byte
 	expireAt time.Time
@@ -55,9 +59,11 @@ func (m *Memory) Get(ctx context.Context, key string) ([]byte, bool) {
 	m.mu.Unlock()
 	if !ok {
 		m.misses.Add(1)
+		metrics.CacheMisses.With("memory").Inc()
 		return nil, false
 	}
 	m.hits.Add(1)
+	metrics.CacheHits.With("memory").Inc()
 	return item.value, true
 }
 
@@ -126,8 +132,7 @@ func (m *Memory) logStats() {
 	m.mu.RLock()
 	entries := len(m.items)
 	m.mu.RUnlock()
-	log.Printf("cache/memory: stats hits=%d misses=%d sets=%d deletes=%d evictions=%d entries=%d",
-		h, miss, s, d, e, entries)
+	logger.Debug("memory cache stats", "hits", h, "misses", miss, "sets", s, "deletes", d, "evictions", e, "entries", entries)
 	m.hits.Store(0)
 	m.misses.Store(0)
 	m.sets.Store(0)
@@ -146,7 +151,7 @@ func (m *Memory) evictExpired() {
 		}
 	}
 	m.evictionsOperations.Add(uint64(deleted))
-	log.Printf("cache/memory: evicted %d expired entries", deleted)
+	logger.Debug("memory cache evicted expired entries", "count", deleted)
 	m.mu.Unlock()
 }
 
@@ -3,6 +3,8 @@ package cache
 import (
 	"context"
 	"time"
+
+	"FeatherProxy/app/internal/metrics"
 )
 
 // Redis is a stub implementation of Cache for CACHING_STRATEGY=redis.
@@ -12,6 +14,7 @@ type Redis struct{}
 
 // Get always returns (nil, false).
 func (Redis) Get(_ context.Context, _ string) ([]byte, bool) {
+	metrics.CacheMisses.With("redis").Inc()
 	return nil, false
 }
 
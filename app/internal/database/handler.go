@@ -61,12 +61,23 @@ func (h *Handler) AutoMigrate() error {
 		&objects.SourceServer{},
 		&objects.ServerOptions{},
 		&objects.ACLOptions{},
+		&objects.ErrorPages{},
+		&objects.AccessLogOptions{},
 		&objects.TargetServer{},
 		&objects.Route{},
 		&objects.Authentication{},
 		&objects.RouteSourceAuth{},
 		&objects.RouteTargetAuth{},
+		&objects.TrafficSplit{},
+		&objects.RouteBalancing{},
+		&objects.Maintenance{},
 		&objects.ProxyStat{},
+		&objects.StatsRollupMinute{},
+		&objects.StatsRollupHour{},
+		&objects.StatsRollupWatermark{},
+		&objects.AlertRule{},
+		&objects.AlertWebhook{},
+		&objects.AlertSilence{},
 	)
 }
 
@@ -0,0 +1,53 @@
+package impl
+
+import (
+	"time"
+
+	"FeatherProxy/app/internal/database/objects"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+func (r *repository) GetAccessLogOptions(sourceServerUUID uuid.UUID) (schema.AccessLogOptions, error) {
+	return getCachedOptional(r, keyAccessLogOptions(sourceServerUUID), func() (schema.AccessLogOptions, error) {
+		var obj objects.AccessLogOptions
+		if err := r.db.Where("source_server_uuid = ?", sourceServerUUID).First(&obj).Error; err != nil {
+			return schema.AccessLogOptions{}, err
+		}
+		return objects.AccessLogOptionsToSchema(&obj), nil
+	})
+}
+
+func (r *repository) SetAccessLogOptions(opts schema.AccessLogOptions) error {
+	now := time.Now()
+	var obj objects.AccessLogOptions
+	err := r.db.Where("source_server_uuid = ?", opts.SourceServerUUID).First(&obj).Error
+	if err != nil {
+		// Create new
+		obj = objects.SchemaToAccessLogOptions(opts)
+		if obj.CreatedAt.IsZero() {
+			obj.CreatedAt = now
+		}
+		if obj.UpdatedAt.IsZero() {
+			obj.UpdatedAt = now
+		}
+		return r.invalidate(r.db.Create(&obj).Error, []string{keyAccessLogOptions(opts.SourceServerUUID)}, nil)
+	}
+	// Update existing
+	obj.Enabled = opts.Enabled
+	obj.Format = opts.Format
+	obj.Template = opts.Template
+	obj.Output = opts.Output
+	obj.FilePath = opts.FilePath
+	obj.MaxSizeMB = opts.MaxSizeMB
+	obj.RotateInterval = opts.RotateInterval
+	obj.MaxBackups = opts.MaxBackups
+	obj.MaxAgeDays = opts.MaxAgeDays
+	obj.SyslogNetwork = opts.SyslogNetwork
+	obj.SyslogAddress = opts.SyslogAddress
+	obj.SyslogFacility = opts.SyslogFacility
+	obj.SyslogAppName = opts.SyslogAppName
+	obj.UpdatedAt = now
+	return r.invalidate(r.db.Save(&obj).Error, []string{keyAccessLogOptions(opts.SourceServerUUID)}, nil)
+}
@@ -10,7 +10,7 @@ import (
 )
 
 func (r *repository) GetACLOptions(sourceServerUUID uuid.UUID) (schema.ACLOptions, error) {
-	return getCached(r, keyACLOptions(sourceServerUUID), func() (schema.ACLOptions, error) {
+	return getCachedOptional(r, keyACLOptions(sourceServerUUID), func() (schema.ACLOptions, error) {
 		var obj objects.ACLOptions
 		if err := r.db.Where("source_server_uuid = ?", sourceServerUUID).First(&obj).Error; err != nil {
 			return schema.ACLOptions{}, err
@@ -40,6 +40,7 @@ func (r *repository) SetACLOptions(opts schema.ACLOptions) error {
 	obj.ClientIPHeader = updated.ClientIPHeader
 	obj.AllowListJSON = updated.AllowListJSON
 	obj.DenyListJSON = updated.DenyListJSON
+	obj.TrustedProxiesJSON = updated.TrustedProxiesJSON
 	obj.UpdatedAt = now
 	return r.invalidate(r.db.Save(&obj).Error, []string{keyACLOptions(opts.SourceServerUUID)}, nil)
 }
@@ -0,0 +1,141 @@
+package impl
+
+import (
+	"time"
+
+	"FeatherProxy/app/internal/database/objects"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+// Alert rules, webhooks and silences are read by the alerting service on every evaluation and are not
+// cached: they are few, and edits must take effect on the next evaluation on every instance.
+
+func (r *repository) ListAlertRules() ([]schema.AlertRule, error) {
+	var list []objects.AlertRule
+	if err := r.db.Order("name").Find(&list).Error; err != nil {
+		repoLogger.Error("ListAlertRules failed", "error", err)
+		return nil, err
+	}
+	out := make([]schema.AlertRule, len(list))
+	for i := range list {
+		out[i] = objects.AlertRuleToSchema(&list[i])
+	}
+	return out, nil
+}
+
+func (r *repository) GetAlertRule(id uuid.UUID) (schema.AlertRule, error) {
+	var obj objects.AlertRule
+	if err := r.db.Where("alert_rule_uuid = ?", id).First(&obj).Error; err != nil {
+		return schema.AlertRule{}, err
+	}
+	return objects.AlertRuleToSchema(&obj), nil
+}
+
+func (r *repository) CreateAlertRule(rule schema.AlertRule) error {
+	repoLogger.Debug("CreateAlertRule", "rule", rule.AlertRuleUUID, "name", rule.Name)
+	obj := objects.SchemaToAlertRule(rule)
+	now := time.Now()
+	obj.CreatedAt, obj.UpdatedAt = now, now
+	return r.db.Create(&obj).Error
+}
+
+func (r *repository) UpdateAlertRule(rule schema.AlertRule) error {
+	repoLogger.Debug("UpdateAlertRule", "rule", rule.AlertRuleUUID)
+	var obj objects.AlertRule
+	if err := r.db.Where("alert_rule_uuid = ?", rule.AlertRuleUUID).First(&obj).Error; err != nil {
+		return err
+	}
+	updated := objects.SchemaToAlertRule(rule)
+	updated.CreatedAt = obj.CreatedAt
+	updated.UpdatedAt = time.Now()
+	return r.db.Save(&updated).Error
+}
+
+// DeleteAlertRule deletes the rule and its silences.
+func (r *repository) DeleteAlertRule(id uuid.UUID) error {
+	repoLogger.Debug("DeleteAlertRule", "rule", id)
+	if err := r.db.Where("alert_rule_uuid = ?", id).Delete(&objects.AlertSilence{}).Error; err != nil {
+		return err
+	}
+	return r.db.Delete(&objects.AlertRule{AlertRuleUUID: id}).Error
+}
+
+func (r *repository) ListAlertWebhooks() ([]schema.AlertWebhook, error) {
+	var list []objects.AlertWebhook
+	if err := r.db.Order("name").Find(&list).Error; err != nil {
+		repoLogger.Error("ListAlertWebhooks failed", "error", err)
+		return nil, err
+	}
+	out := make([]schema.AlertWebhook, len(list))
+	for i := range list {
+		out[i] = objects.AlertWebhookToSchema(&list[i])
+	}
+	return out, nil
+}
+
+func (r *repository) GetAlertWebhook(id uuid.UUID) (schema.AlertWebhook, error) {
+	var obj objects.AlertWebhook
+	if err := r.db.Where("alert_webhook_uuid = ?", id).First(&obj).Error; err != nil {
+		return schema.AlertWebhook{}, err
+	}
+	return objects.AlertWebhookToSchema(&obj), nil
+}
+
+func (r *repository) CreateAlertWebhook(hook schema.AlertWebhook) error {
+	repoLogger.Debug("CreateAlertWebhook", "webhook", hook.AlertWebhookUUID, "name", hook.Name)
+	obj := objects.SchemaToAlertWebhook(hook)
+	now := time.Now()
+	obj.CreatedAt, obj.UpdatedAt = now, now
+	return r.db.Create(&obj).Error
+}
+
+func (r *repository) UpdateAlertWebhook(hook schema.AlertWebhook) error {
+	repoLogger.Debug("UpdateAlertWebhook", "webhook", hook.AlertWebhookUUID)
+	var obj objects.AlertWebhook
+	if err := r.db.Where("alert_webhook_uuid = ?", hook.AlertWebhookUUID).First(&obj).Error; err != nil {
+		return err
+	}
+	updated := objects.SchemaToAlertWebhook(hook)
+	updated.CreatedAt = obj.CreatedAt
+	updated.UpdatedAt = time.Now()
+	return r.db.Save(&updated).Error
+}
+
+func (r *repository) DeleteAlertWebhook(id uuid.UUID) error {
+	repoLogger.Debug("DeleteAlertWebhook", "webhook", id)
+	return r.db.Delete(&objects.AlertWebhook{AlertWebhookUUID: id}).Error
+}
+
+// ListAlertSilences returns the silences that have not ended by now, soonest ending first.
+func (r *repository) ListAlertSilences(now time.Time) ([]schema.AlertSilence, error) {
+	var list []objects.AlertSilence
+	if err := r.db.Where("ends_at > ?", now).Order("ends_at").Find(&list).Error; err != nil {
+		repoLogger.Error("ListAlertSilences failed", "error", err)
+		return nil, err
+	}
+	out := make([]schema.AlertSilence, len(list))
+	for i := range list {
+		out[i] = objects.AlertSilenceToSchema(&list[i])
+	}
+	return out, nil
+}
+
+func (r *repository) CreateAlertSilence(s schema.AlertSilence) error {
+	repoLogger.Debug("CreateAlertSilence", "silence", s.AlertSilenceUUID, "ends_at", s.EndsAt)
+	obj := objects.SchemaToAlertSilence(s)
+	obj.CreatedAt = time.Now()
+	return r.db.Create(&obj).Error
+}
+
+func (r *repository) DeleteAlertSilence(id uuid.UUID) error {
+	repoLogger.Debug("DeleteAlertSilence", "silence", id)
+	return r.db.Delete(&objects.AlertSilence{AlertSilenceUUID: id}).Error
+}
+
+// DeleteAlertSilencesEndedBefore removes silences that ended before t and returns how many were removed.
+func (r *repository) DeleteAlertSilencesEndedBefore(t time.Time) (int64, error) {
+	res := r.db.Where("ends_at <= ?", t).Delete(&objects.AlertSilence{})
+	return res.RowsAffected, res.Error
+}
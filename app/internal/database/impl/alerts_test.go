@@ -0,0 +1,91 @@
+package impl
+
+import (
+	"testing"
+	"time"
+
+	"FeatherProxy/app/internal/database/objects"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAlertRepository(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:alerts?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&objects.AlertRule{}, &objects.AlertWebhook{}, &objects.AlertSilence{}, &objects.ProxyStat{}); err != nil {
+		t.Fatal(err)
+	}
+	r := New(db)
+
+	hook := schema.AlertWebhook{AlertWebhookUUID: uuid.New(), Name: "ops", URL: "https://hooks.example.com/x"}
+	if err := r.CreateAlertWebhook(hook); err != nil {
+		t.Fatal(err)
+	}
+	route := uuid.New()
+	rule := schema.AlertRule{
+		AlertRuleUUID: uuid.New(), Name: "slow", Enabled: true, Metric: schema.AlertMetricLatencyP95, Operator: ">",
+		Threshold: 1000, WindowSeconds: 300, RouteUUID: &route, WebhookUUIDs: []uuid.UUID{hook.AlertWebhookUUID},
+	}
+	if err := r.CreateAlertRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.GetAlertRule(rule.AlertRuleUUID)
+	if err != nil || got.RouteUUID == nil || *got.RouteUUID != route || len(got.WebhookUUIDs) != 1 || got.CreatedAt.IsZero() {
+		t.Fatalf("GetAlertRule = %+v, %v", got, err)
+	}
+	got.Enabled = false
+	got.WebhookUUIDs = nil
+	if err := r.UpdateAlertRule(got); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := r.ListAlertRules(); len(list) != 1 || list[0].Enabled || len(list[0].WebhookUUIDs) != 0 || !list[0].CreatedAt.Equal(got.CreatedAt) {
+		t.Errorf("after update: %+v", list)
+	}
+
+	now := time.Now()
+	for _, s := range []schema.AlertSilence{
+		{AlertSilenceUUID: uuid.New(), AlertRuleUUID: &rule.AlertRuleUUID, StartsAt: now, EndsAt: now.Add(time.Hour)},
+		{AlertSilenceUUID: uuid.New(), StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour)},
+	} {
+		if err := r.CreateAlertSilence(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if list, _ := r.ListAlertSilences(now); len(list) != 1 || !list[0].Active(rule.AlertRuleUUID, now) || list[0].Active(uuid.New(), now) {
+		t.Errorf("active silences = %+v", list)
+	}
+	if n, err := r.DeleteAlertSilencesEndedBefore(now); err != nil || n != 1 {
+		t.Errorf("DeleteAlertSilencesEndedBefore = %d, %v", n, err)
+	}
+	if err := r.DeleteAlertRule(rule.AlertRuleUUID); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := r.ListAlertSilences(now); len(list) != 0 {
+		t.Errorf("silences of deleted rule kept: %+v", list)
+	}
+
+	code := func(c int) *int { return &c }
+	stats := []schema.ProxyStat{
+		{ID: uuid.New(), Timestamp: now, RouteUUID: route, Method: "GET", Path: "/", StatusCode: code(200)},
+		{ID: uuid.New(), Timestamp: now, RouteUUID: route, Method: "GET", Path: "/", StatusCode: code(503)},
+		{ID: uuid.New(), Timestamp: now, RouteUUID: route, Method: "GET", Path: "/", Outcome: schema.OutcomeACLDenied},
+		{ID: uuid.New(), Timestamp: now, RouteUUID: uuid.New(), Method: "GET", Path: "/", StatusCode: code(500)},
+	}
+	if err := r.CreateProxyStats(stats); err != nil {
+		t.Fatal(err)
+	}
+	since := now.Add(-time.Minute)
+	counts, err := r.StatsStatusCounts(schema.StatsFilter{Since: &since, RouteUUID: &route})
+	if err != nil || counts != (schema.StatusCounts{Total: 3, Status2xx: 1, Status5xx: 1}) {
+		t.Errorf("StatsStatusCounts = %+v, %v", counts, err)
+	}
+	empty := uuid.New()
+	if counts, err := r.StatsStatusCounts(schema.StatsFilter{RouteUUID: &empty}); err != nil || counts.Total != 0 {
+		t.Errorf("StatsStatusCounts(no rows) = %+v, %v", counts, err)
+	}
+}
@@ -2,7 +2,6 @@ package impl
 
 import (
 	"errors"
-	"log"
 
 	"FeatherProxy/app/internal/database/objects"
 	"FeatherProxy/app/internal/database/schema"
@@ -14,7 +13,7 @@ import (
 const tokenMaskedPlaceholder = "***"
 
 func (r *repository) CreateAuthentication(a schema.Authentication) error {
-	log.Printf("auth/repo: CreateAuthentication id=%s name=%q token_type=%s", a.AuthenticationUUID, a.Name, a.TokenType)
+	repoLogger.Debug("CreateAuthentication", "auth", a.AuthenticationUUID, "name", a.Name, "token_type", a.TokenType)
 	if a.Token == "" {
 		return errors.New("token is required for create")
 	}
@@ -25,19 +24,19 @@ func (r *repository) CreateAuthentication(a schema.Authentication) error {
 	obj := objects.SchemaToAuthentication(a, encrypted, salt)
 	err = r.db.Create(&obj).Error
 	if err != nil {
-		log.Printf("auth/repo: CreateAuthentication db error: %v", err)
+		repoLogger.Error("CreateAuthentication failed", "error", err)
 		return err
 	}
-	log.Printf("auth/repo: CreateAuthentication ok id=%s", a.AuthenticationUUID)
+	repoLogger.Debug("CreateAuthentication ok", "auth", a.AuthenticationUUID)
 	return r.invalidate(nil, []string{keyAuth(a.AuthenticationUUID), keyListAuthentications}, nil)
 }
 
 func (r *repository) GetAuthentication(id uuid.UUID) (schema.Authentication, error) {
 	return getCached(r, keyAuth(id), func() (schema.Authentication, error) {
-		log.Printf("auth/repo: GetAuthentication id=%s", id)
+		repoLogger.Debug("GetAuthentication", "auth", id)
 		var obj objects.Authentication
 		if err := r.db.Where("authentication_uuid = ?", id).First(&obj).Error; err != nil {
-			log.Printf("auth/repo: GetAuthentication not found or error: %v", err)
+			repoLogger.Debug("GetAuthentication not found or failed", "auth", id, "error", err)
 			return schema.Authentication{}, err
 		}
 		out := objects.AuthenticationToSchema(&obj)
@@ -48,15 +47,15 @@ func (r *repository) GetAuthentication(id uuid.UUID) (schema.Authentication, err
 
 // GetAuthenticationWithPlainToken is not cached (security: decrypted token).
 func (r *repository) GetAuthenticationWithPlainToken(id uuid.UUID) (schema.Authentication, error) {
-	log.Printf("auth/repo: GetAuthenticationWithPlainToken id=%s (for proxy)", id)
+	repoLogger.Debug("GetAuthenticationWithPlainToken", "auth", id)
 	var obj objects.Authentication
 	if err := r.db.Where("authentication_uuid = ?", id).First(&obj).Error; err != nil {
-		log.Printf("auth/repo: GetAuthenticationWithPlainToken not found: %v", err)
+		repoLogger.Debug("GetAuthenticationWithPlainToken not found", "auth", id, "error", err)
 		return schema.Authentication{}, err
 	}
 	plain, err := token.DecryptToken(obj.TokenEncrypted, obj.TokenSalt)
 	if err != nil {
-		log.Printf("auth/repo: GetAuthenticationWithPlainToken decrypt error: %v", err)
+		repoLogger.Error("GetAuthenticationWithPlainToken decrypt failed", "auth", id, "error", err)
 		return schema.Authentication{}, err
 	}
 	out := objects.AuthenticationToSchema(&obj)
@@ -65,10 +64,10 @@ func (r *repository) GetAuthenticationWithPlainToken(id uuid.UUID) (schema.Authe
 }
 
 func (r *repository) UpdateAuthentication(a schema.Authentication) error {
-	log.Printf("auth/repo: UpdateAuthentication id=%s name=%q token_provided=%v", a.AuthenticationUUID, a.Name, a.Token != "")
+	repoLogger.Debug("UpdateAuthentication", "auth", a.AuthenticationUUID, "name", a.Name, "token_provided", a.Token != "")
 	var obj objects.Authentication
 	if err := r.db.Where("authentication_uuid = ?", a.AuthenticationUUID).First(&obj).Error; err != nil {
-		log.Printf("auth/repo: UpdateAuthentication not found: %v", err)
+		repoLogger.Debug("UpdateAuthentication not found", "auth", a.AuthenticationUUID, "error", err)
 		return err
 	}
 	obj.Name = a.Name
@@ -83,25 +82,25 @@ func (r *repository) UpdateAuthentication(a schema.Authentication) error {
 	}
 	err := r.db.Save(&obj).Error
 	if err != nil {
-		log.Printf("auth/repo: UpdateAuthentication save error: %v", err)
+		repoLogger.Error("UpdateAuthentication failed", "auth", a.AuthenticationUUID, "error", err)
 		return err
 	}
-	log.Printf("auth/repo: UpdateAuthentication ok id=%s", a.AuthenticationUUID)
+	repoLogger.Debug("UpdateAuthentication ok", "auth", a.AuthenticationUUID)
 	return r.invalidate(nil, []string{keyAuth(a.AuthenticationUUID), keyListAuthentications}, []string{keyPrefixTargetAuthForRoute})
 }
 
 func (r *repository) DeleteAuthentication(id uuid.UUID) error {
-	log.Printf("auth/repo: DeleteAuthentication id=%s", id)
+	repoLogger.Debug("DeleteAuthentication", "auth", id)
 	return r.invalidate(r.db.Delete(&objects.Authentication{AuthenticationUUID: id}).Error,
 		[]string{keyAuth(id), keyListAuthentications}, []string{keyPrefixTargetAuthForRoute})
 }
 
 func (r *repository) ListAuthentications() ([]schema.Authentication, error) {
 	return getCached(r, keyListAuthentications, func() ([]schema.Authentication, error) {
-		log.Printf("auth/repo: ListAuthentications")
+		repoLogger.Debug("ListAuthentications")
 		var list []objects.Authentication
 		if err := r.db.Find(&list).Error; err != nil {
-			log.Printf("auth/repo: ListAuthentications error: %v", err)
+			repoLogger.Error("ListAuthentications failed", "error", err)
 			return nil, err
 		}
 		out := make([]schema.Authentication, len(list))
@@ -109,7 +108,7 @@ func (r *repository) ListAuthentications() ([]schema.Authentication, error) {
 			out[i] = objects.AuthenticationToSchema(&list[i])
 			out[i].TokenMasked = tokenMaskedPlaceholder
 		}
-		log.Printf("auth/repo: ListAuthentications ok count=%d", len(out))
+		repoLogger.Debug("ListAuthentications ok", "count", len(out))
 		return out, nil
 	})
 }
@@ -3,11 +3,13 @@ package impl
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"FeatherProxy/app/internal/cache"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // Cache key prefixes and builders. Keep in sync with invalidation in mutation methods.
@@ -17,7 +19,7 @@ const (
 	keyPrefixRoute              = "route:"
 	keyPrefixRouteSourcePath     = "route:source_path:"
 	keyPrefixRouteTargetPath     = "route:target_path:"
-	keyPrefixRouteMethodPath    = "route:method_path:"
+	keyPrefixRouteMethodTable   = "route:method_table:"
 	keyPrefixAuth               = "auth:"
 	keyListSourceServers         = "list:source_servers"
 	keyListTargetServers         = "list:target_servers"
@@ -27,6 +29,11 @@ const (
 	keyPrefixTargetAuthForRoute  = "target_auth_for_route:"
 	keyPrefixServerOptions       = "server_options:"
 	keyPrefixACLOptions          = "acl_options:"
+	keyPrefixTrafficSplit        = "traffic_split:"
+	keyPrefixRouteBalancing      = "route_balancing:"
+	keyPrefixErrorPages          = "error_pages:"
+	keyPrefixMaintenance         = "maintenance:"
+	keyPrefixAccessLogOptions    = "access_log_options:"
 )
 
 func keySourceServer(id uuid.UUID) string              { return keyPrefixSourceServer + id.String() }
@@ -34,14 +41,23 @@ func keyTargetServer(id uuid.UUID) string              { return keyPrefixTargetS
 func keyRoute(id uuid.UUID) string                     { return keyPrefixRoute + id.String() }
 func keyRouteSourcePath(path string) string            { return keyPrefixRouteSourcePath + path }
 func keyRouteTargetPath(path string) string             { return keyPrefixRouteTargetPath + path }
-func keyRouteMethodPath(sourceID uuid.UUID, method, path string) string {
-	return keyPrefixRouteMethodPath + sourceID.String() + ":" + method + ":" + path
+func keyRouteMethodTable(sourceID uuid.UUID, method string) string {
+	return keyPrefixRouteMethodTable + sourceID.String() + ":" + method
 }
 func keyAuth(id uuid.UUID) string                   { return keyPrefixAuth + id.String() }
 func keyRouteSourceAuths(routeID uuid.UUID) string   { return keyPrefixRouteSourceAuths + routeID.String() }
 func keyTargetAuthForRoute(routeID uuid.UUID) string { return keyPrefixTargetAuthForRoute + routeID.String() }
 func keyServerOptions(sourceID uuid.UUID) string    { return keyPrefixServerOptions + sourceID.String() }
 func keyACLOptions(sourceID uuid.UUID) string      { return keyPrefixACLOptions + sourceID.String() }
+func keyTrafficSplit(routeID uuid.UUID) string     { return keyPrefixTrafficSplit + routeID.String() }
+func keyRouteBalancing(routeID uuid.UUID) string   { return keyPrefixRouteBalancing + routeID.String() }
+func keyErrorPages(sourceID uuid.UUID) string      { return keyPrefixErrorPages + sourceID.String() }
+func keyAccessLogOptions(sourceID uuid.UUID) string {
+	return keyPrefixAccessLogOptions + sourceID.String()
+}
+func keyMaintenance(scope string, ownerID uuid.UUID) string {
+	return keyPrefixMaintenance + scope + ":" + ownerID.String()
+}
 
 func (r *repository) cacheCtx() context.Context { return context.Background() }
 
@@ -70,6 +86,33 @@ func getCached[T any](r *repository, key string, delegate func() (T, error)) (T,
 	return val, nil
 }
 
+// optionalCached is the cached shape for optional rows (per-source options, per-route split and
+// balancing, maintenance windows): Found is false when no row exists.
+type optionalCached[T any] struct {
+	Value T    `json:"value"`
+	Found bool `json:"found"`
+}
+
+// getCachedOptional is getCached for optional rows. A missing row is cached like a present one, with the
+// same TTL, and reported as gorm.ErrRecordNotFound until the row's setter invalidates the key; otherwise
+// every request to an unconfigured source server or route would query the database.
+func getCachedOptional[T any](r *repository, key string, delegate func() (T, error)) (T, error) {
+	v, err := getCached(r, key, func() (optionalCached[T], error) {
+		val, err := delegate()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return optionalCached[T]{}, nil
+		}
+		if err != nil {
+			return optionalCached[T]{}, err
+		}
+		return optionalCached[T]{Value: val, Found: true}, nil
+	})
+	if err == nil && !v.Found {
+		err = gorm.ErrRecordNotFound
+	}
+	return v.Value, err
+}
+
 // invalidate runs the mutation; on success deletes the given keys and prefixes and returns nil.
 func (r *repository) invalidate(err error, keys []string, prefixes []string) error {
 	if err != nil {
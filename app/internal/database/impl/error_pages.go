@@ -0,0 +1,43 @@
+package impl
+
+import (
+	"time"
+
+	"FeatherProxy/app/internal/database/objects"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+func (r *repository) GetErrorPages(sourceServerUUID uuid.UUID) (schema.ErrorPages, error) {
+	return getCachedOptional(r, keyErrorPages(sourceServerUUID), func() (schema.ErrorPages, error) {
+		var obj objects.ErrorPages
+		if err := r.db.Where("source_server_uuid = ?", sourceServerUUID).First(&obj).Error; err != nil {
+			return schema.ErrorPages{}, err
+		}
+		return objects.ErrorPagesToSchema(&obj), nil
+	})
+}
+
+func (r *repository) SetErrorPages(pages schema.ErrorPages) error {
+	now := time.Now()
+	var obj objects.ErrorPages
+	err := r.db.Where("source_server_uuid = ?", pages.SourceServerUUID).First(&obj).Error
+	if err != nil {
+		// Create new
+		obj = objects.SchemaToErrorPages(pages)
+		if obj.CreatedAt.IsZero() {
+			obj.CreatedAt = now
+		}
+		if obj.UpdatedAt.IsZero() {
+			obj.UpdatedAt = now
+		}
+		return r.invalidate(r.db.Create(&obj).Error, []string{keyErrorPages(pages.SourceServerUUID)}, nil)
+	}
+	// Update existing
+	updated := objects.SchemaToErrorPages(pages)
+	obj.PagesJSON = updated.PagesJSON
+	obj.ReplaceUpstream5xx = updated.ReplaceUpstream5xx
+	obj.UpdatedAt = now
+	return r.invalidate(r.db.Save(&obj).Error, []string{keyErrorPages(pages.SourceServerUUID)}, nil)
+}
@@ -1,13 +1,16 @@
 package impl
 
 import (
+	"errors"
 	"testing"
 	"time"
 
 	"FeatherProxy/app/internal/cache"
 	"FeatherProxy/app/internal/database/objects"
 	"FeatherProxy/app/internal/database/repo"
+	"FeatherProxy/app/internal/database/schema"
 
+	"github.com/google/uuid"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -109,3 +112,77 @@ func TestNewWithCache_nilCache_usesNoOp(t *testing.T) {
 		t.Logf("list length = %d", len(list))
 	}
 }
+
+func TestFindRouteBySourceMethodPath_pattern(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:route_pattern?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrateDB(t, db)
+	r := New(db)
+	sourceID := uuid.New()
+	if err := r.CreateSourceServer(schema.SourceServer{SourceServerUUID: sourceID, Protocol: "http", Host: "localhost", Port: 8080}); err != nil {
+		t.Fatal(err)
+	}
+	routes := []schema.Route{
+		{RouteUUID: uuid.New(), SourceServerUUID: sourceID, Method: "GET", SourcePath: "/users/{id}", Kind: schema.RouteKindStatic, Response: &schema.RouteResponse{Status: 200}},
+		{RouteUUID: uuid.New(), SourceServerUUID: sourceID, Method: "GET", SourcePath: "/users/me", Kind: schema.RouteKindStatic, Response: &schema.RouteResponse{Status: 204}},
+	}
+	for _, rt := range routes {
+		if err := r.CreateRoute(rt); err != nil {
+			t.Fatalf("CreateRoute: %v", err)
+		}
+	}
+	got, err := r.FindRouteBySourceMethodPath(sourceID, "GET", "/users/42")
+	if err != nil || got.RouteUUID != routes[0].RouteUUID {
+		t.Errorf("pattern lookup = %+v, %v", got, err)
+	}
+	if got.Response == nil || got.Response.Status != 200 {
+		t.Errorf("response not persisted: %+v", got.Response)
+	}
+	got, err = r.FindRouteBySourceMethodPath(sourceID, "GET", "/users/me")
+	if err != nil || got.RouteUUID != routes[1].RouteUUID {
+		t.Errorf("exact lookup = %+v, %v", got, err)
+	}
+	if _, err := r.FindRouteBySourceMethodPath(sourceID, "GET", "/orders/1"); err == nil {
+		t.Error("want not found for unmatched path")
+	}
+	// The cached route table is invalidated when a route is added.
+	orders := schema.Route{RouteUUID: uuid.New(), SourceServerUUID: sourceID, Method: "GET", SourcePath: "/orders/{id}", Kind: schema.RouteKindStatic, Response: &schema.RouteResponse{Status: 200}}
+	if err := r.CreateRoute(orders); err != nil {
+		t.Fatalf("CreateRoute: %v", err)
+	}
+	if got, err := r.FindRouteBySourceMethodPath(sourceID, "GET", "/orders/1"); err != nil || got.RouteUUID != orders.RouteUUID {
+		t.Errorf("lookup after create = %+v, %v", got, err)
+	}
+}
+
+func TestGetCachedOptional_cachesNotFound(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:optional_cache?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrateDB(t, db)
+	mem := cache.NewMemory(5 * time.Minute)
+	defer mem.Close()
+	r := NewWithCache(db, mem, time.Minute)
+	sourceID := uuid.New()
+	if _, err := r.GetServerOptions(sourceID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("GetServerOptions before set = %v, want ErrRecordNotFound", err)
+	}
+	// The miss is cached: a row written behind the repository's back is not seen yet.
+	if err := db.Create(&objects.ServerOptions{SourceServerUUID: sourceID, RequestIDHeader: "X-Hidden"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.GetServerOptions(sourceID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetServerOptions after direct write = %v, want cached ErrRecordNotFound", err)
+	}
+	// The setter invalidates the cached miss.
+	if err := r.SetServerOptions(schema.ServerOptions{SourceServerUUID: sourceID, RequestIDHeader: "X-Req"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.GetServerOptions(sourceID)
+	if err != nil || got.RequestIDHeader != "X-Req" {
+		t.Errorf("GetServerOptions after set = %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,285 @@
+package impl
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"FeatherProxy/app/internal/database/objects"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// statsGroupColumns maps a stats grouping (StatsGroup*) to its proxy_stats column.
+var statsGroupColumns = map[string]string{
+	schema.StatsGroupRoute:        "route_uuid",
+	schema.StatsGroupTargetServer: "target_server_uuid",
+	schema.StatsGroupSourceServer: "source_server_uuid",
+}
+
+// applyStatsFilter adds the filter's conditions to q.
+func applyStatsFilter(q *gorm.DB, f schema.StatsFilter) *gorm.DB {
+	if f.Since != nil {
+		q = q.Where("timestamp >= ?", *f.Since)
+	}
+	if f.Until != nil {
+		q = q.Where("timestamp < ?", *f.Until)
+	}
+	if f.SourceServerUUID != nil {
+		q = q.Where("source_server_uuid = ?", *f.SourceServerUUID)
+	}
+	if f.RouteUUID != nil {
+		q = q.Where("route_uuid = ?", *f.RouteUUID)
+	}
+	if f.TargetServerUUID != nil {
+		q = q.Where("target_server_uuid = ?", *f.TargetServerUUID)
+	}
+	if f.Outcome != "" {
+		q = q.Where("outcome = ?", f.Outcome)
+	}
+	return q
+}
+
+// StatsLatency returns latency percentiles, mean, min and max per group (or one overall row when groupBy is
+// StatsGroupNone), ordered by request count. Durations are streamed into a quantile sketch per group, so the
+// result is the same on SQLite and Postgres and memory does not grow with the number of rows. Whole
+// rolled-up buckets are read from the rollup tables (see planStats): count, mean and max stay exact there,
+// while percentiles and min are only as precise as the rollup histogram (schema.RollupLatencyBoundsMs).
+func (r *repository) StatsLatency(groupBy string, filter schema.StatsFilter, limit int) ([]schema.LatencyStats, error) {
+	col := "NULL"
+	if groupBy != schema.StatsGroupNone {
+		var ok bool
+		if col, ok = statsGroupColumns[groupBy]; !ok {
+			return nil, fmt.Errorf("stats: unknown latency grouping %q", groupBy)
+		}
+	}
+	sketches := map[uuid.UUID]*latencySketch{}
+	sketch := func(group uuid.UUID) *latencySketch {
+		sk := sketches[group]
+		if sk == nil {
+			sk = newLatencySketch()
+			sketches[group] = sk
+		}
+		return sk
+	}
+	plan := r.planStats(filter.Since, filter.Until, 0)
+	q := r.db.Model(&objects.ProxyStat{}).Select(col + " AS group_uuid, duration_ms, sample_weight").Where("duration_ms IS NOT NULL")
+	rows, err := plan.excludeRollup(applyStatsFilter(q, filter)).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var group uuid.NullUUID
+		var ms, weight int64
+		if err := rows.Scan(&group, &ms, &weight); err != nil {
+			return nil, err
+		}
+		sketch(group.UUID).addN(ms, weight)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if plan.ok {
+		var buckets []struct {
+			GroupUUID        uuid.NullUUID
+			DurationSumMs    int64
+			DurationMaxMs    int64
+			LatencyHistogram string
+		}
+		q := plan.rollupQuery(r.db, filter).Select(col + " AS group_uuid, duration_sum_ms, duration_max_ms, latency_histogram").Where("duration_count > 0")
+		if err := q.Scan(&buckets).Error; err != nil {
+			return nil, err
+		}
+		for _, b := range buckets {
+			sketch(b.GroupUUID.UUID).addRollup(b.DurationSumMs, b.DurationMaxMs, decodeRollupHistogram(b.LatencyHistogram))
+		}
+	}
+	out := make([]schema.LatencyStats, 0, len(sketches))
+	for id, sk := range sketches {
+		out = append(out, schema.LatencyStats{
+			UUID:   id,
+			Count:  sk.count,
+			MeanMs: math.Round(sk.sum/float64(sk.count)*100) / 100,
+			MinMs:  sk.min,
+			MaxMs:  sk.max,
+			P50Ms:  sk.quantile(0.50),
+			P90Ms:  sk.quantile(0.90),
+			P95Ms:  sk.quantile(0.95),
+			P99Ms:  sk.quantile(0.99),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].UUID.String() < out[j].UUID.String()
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// StatsLatencyHistogram counts requests per latency bucket. boundsMs are the bucket upper bounds (sorted,
+// positive); a final unbounded bucket is always added. The counting is portable SQL (SUM of CASE). When the
+// bounds are compatible with the rollup histogram (schema.RollupHistogramCompatible), whole rolled-up
+// buckets are counted from the rollup tables, as in StatsLatency; otherwise only raw stats are counted.
+func (r *repository) StatsLatencyHistogram(filter schema.StatsFilter, boundsMs []int64) ([]schema.LatencyBucket, error) {
+	var plan statsPlan
+	if schema.RollupHistogramCompatible(boundsMs) {
+		plan = r.planStats(filter.Since, filter.Until, 0)
+	}
+	var sel strings.Builder
+	args := make([]interface{}, 0, 2*len(boundsMs))
+	var lower int64 = -1
+	for i, upper := range boundsMs {
+		fmt.Fprintf(&sel, "SUM(CASE WHEN duration_ms > ? AND duration_ms <= ? THEN sample_weight ELSE 0 END) AS b%d, ", i)
+		args = append(args, lower, upper)
+		lower = upper
+	}
+	fmt.Fprintf(&sel, "SUM(CASE WHEN duration_ms > ? THEN sample_weight ELSE 0 END) AS b%d", len(boundsMs))
+	args = append(args, lower)
+
+	q := r.db.Model(&objects.ProxyStat{}).Select(sel.String(), args...).Where("duration_ms IS NOT NULL")
+	counts := make([]*int64, len(boundsMs)+1) // SUM over no rows is NULL
+	dest := make([]interface{}, len(counts))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	if err := plan.excludeRollup(applyStatsFilter(q, filter)).Row().Scan(dest...); err != nil {
+		return nil, err
+	}
+	out := make([]schema.LatencyBucket, len(counts))
+	lower = 0
+	for i := range counts {
+		b := schema.LatencyBucket{LowerMs: lower}
+		if i < len(boundsMs) {
+			upper := boundsMs[i]
+			b.UpperMs = &upper
+			lower = upper
+		}
+		if counts[i] != nil {
+			b.Count = *counts[i]
+		}
+		out[i] = b
+	}
+	if plan.ok {
+		var hists []string
+		if err := plan.rollupQuery(r.db, filter).Where("duration_count > 0").Pluck("latency_histogram", &hists).Error; err != nil {
+			return nil, err
+		}
+		for _, h := range hists {
+			for i, n := range decodeRollupHistogram(h) {
+				// Rollup bucket i ends at RollupLatencyBoundsMs[i] (the last is unbounded); it lies inside the
+				// first requested bucket whose bound reaches that far.
+				j := len(boundsMs)
+				if i < len(schema.RollupLatencyBoundsMs) {
+					j = sort.Search(len(boundsMs), func(k int) bool { return boundsMs[k] >= schema.RollupLatencyBoundsMs[i] })
+				}
+				out[j].Count += n
+			}
+		}
+	}
+	return out, nil
+}
+
+// sketchAccuracy is the relative accuracy of latencySketch quantiles.
+const sketchAccuracy = 0.01
+
+var (
+	sketchGamma    = (1 + sketchAccuracy) / (1 - sketchAccuracy)
+	sketchLogGamma = math.Log(sketchGamma)
+)
+
+// latencySketch is a DDSketch-style quantile sketch for millisecond durations: values fall into
+// logarithmic buckets whose width is 2% of their value, so any quantile is within 1% of the true one
+// while memory is bounded by the value range (a few hundred buckets), not by the number of values.
+type latencySketch struct {
+	buckets  map[int]int64
+	zeros    int64
+	count    int64
+	sum      float64
+	min, max int64
+}
+
+func newLatencySketch() *latencySketch {
+	return &latencySketch{buckets: make(map[int]int64)}
+}
+
+func (s *latencySketch) add(ms int64) { s.addN(ms, 1) }
+
+// addN adds n occurrences of ms (a sampled stat's weight).
+func (s *latencySketch) addN(ms, n int64) {
+	if n < 1 {
+		n = 1
+	}
+	if s.count == 0 || ms < s.min {
+		s.min = ms
+	}
+	if s.count == 0 || ms > s.max {
+		s.max = ms
+	}
+	s.count += n
+	s.sum += float64(ms) * float64(n)
+	if ms <= 0 {
+		s.zeros += n
+		return
+	}
+	s.buckets[int(math.Ceil(math.Log(float64(ms))/sketchLogGamma))] += n
+}
+
+// addRollup adds the durations of one rollup row. Count, sum and max are exact; each histogram bucket's
+// requests are placed at the bucket's upper bound (capped at the row's max), so quantiles over rolled-up
+// ranges are as precise as schema.RollupLatencyBoundsMs and err on the high side.
+func (s *latencySketch) addRollup(sumMs, maxMs int64, hist []int64) {
+	var placed float64
+	for i, n := range hist {
+		if n <= 0 {
+			continue
+		}
+		v := maxMs
+		if i < len(schema.RollupLatencyBoundsMs) {
+			v = min(schema.RollupLatencyBoundsMs[i], maxMs)
+		}
+		s.addN(v, n)
+		placed += float64(v) * float64(n)
+	}
+	if placed > 0 {
+		s.sum += float64(sumMs) - placed
+	}
+	s.max = max(s.max, maxMs)
+}
+
+// quantile returns the estimated q-quantile (0..1), rounded to 0.01ms and clamped to [min, max].
+func (s *latencySketch) quantile(q float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(s.count))) // nearest-rank
+	if rank < 1 {
+		rank = 1
+	}
+	if rank <= s.zeros {
+		return 0
+	}
+	keys := make([]int, 0, len(s.buckets))
+	for k := range s.buckets {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	seen := s.zeros
+	v := float64(s.max)
+	for _, k := range keys {
+		seen += s.buckets[k]
+		if seen >= rank {
+			v = 2 * math.Pow(sketchGamma, float64(k)) / (sketchGamma + 1)
+			break
+		}
+	}
+	v = math.Max(float64(s.min), math.Min(float64(s.max), v))
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,659 @@
+package impl
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"FeatherProxy/app/internal/database/objects"
+	"FeatherProxy/app/internal/database/repo"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestLatencySketch_quantileAccuracy(t *testing.T) {
+	sk := newLatencySketch()
+	values := make([]int64, 0, 1000)
+	for i := int64(1); i <= 1000; i++ {
+		v := i * i % 9973 // spread values, not in order
+		values = append(values, v)
+		sk.add(v)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	for _, q := range []float64{0.5, 0.9, 0.95, 0.99} {
+		want := float64(values[int(math.Ceil(q*float64(len(values))))-1])
+		got := sk.quantile(q)
+		if math.Abs(got-want) > want*sketchAccuracy+0.01 {
+			t.Errorf("q%.2f = %v, want %v ±1%%", q, got, want)
+		}
+	}
+	if sk.quantile(0) != float64(values[0]) || sk.quantile(1) != float64(values[len(values)-1]) {
+		t.Errorf("extremes = %v, %v", sk.quantile(0), sk.quantile(1))
+	}
+}
+
+func TestStatsLatency_and_histogram(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:stats_latency?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&objects.ProxyStat{}); err != nil {
+		t.Fatal(err)
+	}
+	r := New(db)
+	routeA, routeB, source := uuid.New(), uuid.New(), uuid.New()
+	now := time.Now().UTC()
+	var stats []schema.ProxyStat
+	for i := int64(1); i <= 100; i++ {
+		d := i
+		stats = append(stats, schema.ProxyStat{ID: uuid.New(), Timestamp: now, SourceServerUUID: source, RouteUUID: routeA, Method: "GET", Path: "/a", DurationMs: &d})
+	}
+	for i := 0; i < 10; i++ {
+		d := int64(2000)
+		stats = append(stats, schema.ProxyStat{ID: uuid.New(), Timestamp: now, SourceServerUUID: source, RouteUUID: routeB, Method: "GET", Path: "/b", DurationMs: &d})
+	}
+	old := int64(5)
+	stats = append(stats, schema.ProxyStat{ID: uuid.New(), Timestamp: now.Add(-48 * time.Hour), SourceServerUUID: source, RouteUUID: routeA, Method: "GET", Path: "/a", DurationMs: &old})
+	if err := r.CreateProxyStats(stats); err != nil {
+		t.Fatal(err)
+	}
+	since := now.Add(-time.Hour)
+	filter := schema.StatsFilter{Since: &since}
+
+	got, err := r.StatsLatency(schema.StatsGroupRoute, filter, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].UUID != routeA || got[1].UUID != routeB {
+		t.Fatalf("groups = %+v", got)
+	}
+	a := got[0]
+	if a.Count != 100 || a.MinMs != 1 || a.MaxMs != 100 || a.MeanMs != 50.5 {
+		t.Errorf("route A = %+v", a)
+	}
+	if math.Abs(a.P50Ms-50) > 1 || math.Abs(a.P99Ms-99) > 1 {
+		t.Errorf("route A percentiles = p50 %v p99 %v", a.P50Ms, a.P99Ms)
+	}
+	if got[1].P50Ms != 2000 || got[1].P99Ms != 2000 {
+		t.Errorf("route B = %+v", got[1])
+	}
+
+	overall, err := r.StatsLatency(schema.StatsGroupNone, filter, 0)
+	if err != nil || len(overall) != 1 || overall[0].Count != 110 || overall[0].UUID != uuid.Nil {
+		t.Errorf("overall = %+v, %v", overall, err)
+	}
+	if _, err := r.StatsLatency("bogus", filter, 0); err == nil {
+		t.Error("want error for unknown grouping")
+	}
+
+	buckets, err := r.StatsLatencyHistogram(schema.StatsFilter{Since: &since, RouteUUID: &routeA}, []int64{10, 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 3 || buckets[0].Count != 10 || buckets[1].Count != 40 || buckets[2].Count != 50 || buckets[2].UpperMs != nil {
+		t.Errorf("histogram = %+v", buckets)
+	}
+	until := since
+	empty, err := r.StatsLatencyHistogram(schema.StatsFilter{Since: &since, Until: &until}, []int64{10})
+	if err != nil || len(empty) != 2 || empty[0].Count != 0 || empty[1].Count != 0 {
+		t.Errorf("empty histogram = %+v, %v", empty, err)
+	}
+}
+
+func TestStatsByOutcome(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:stats_outcome?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&objects.ProxyStat{}); err != nil {
+		t.Fatal(err)
+	}
+	r := New(db)
+	sourceA, sourceB := uuid.New(), uuid.New()
+	now := time.Now().UTC()
+	stat := func(source uuid.UUID, outcome string) schema.ProxyStat {
+		return schema.ProxyStat{ID: uuid.New(), Timestamp: now, SourceServerUUID: source, Method: "GET", Path: "/", Outcome: outcome}
+	}
+	stats := []schema.ProxyStat{
+		stat(sourceA, schema.OutcomeProxied), stat(sourceA, schema.OutcomeProxied), stat(sourceA, schema.OutcomeACLDenied),
+		stat(sourceB, schema.OutcomeNoRoute),
+	}
+	if err := r.CreateProxyStats(stats); err != nil {
+		t.Fatal(err)
+	}
+	// Rows written before outcomes existed get the column default.
+	if err := db.Exec("INSERT INTO proxy_stats (id, timestamp, source_server_uuid, route_uuid, target_server_uuid, method, path) VALUES (?, ?, ?, ?, ?, 'GET', '/')",
+		uuid.New(), now, sourceB, uuid.Nil, uuid.Nil).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	overall, err := r.StatsByOutcome(schema.StatsGroupNone, schema.StatsFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(overall) != 3 || overall[0].Outcome != schema.OutcomeProxied || overall[0].Count != 3 {
+		t.Errorf("overall = %+v", overall)
+	}
+	perSource, err := r.StatsByOutcome(schema.StatsGroupSourceServer, schema.StatsFilter{SourceServerUUID: &sourceA})
+	if err != nil || len(perSource) != 2 || perSource[0].UUID != sourceA || perSource[0].Count != 2 || perSource[1].Outcome != schema.OutcomeACLDenied {
+		t.Errorf("per source = %+v, %v", perSource, err)
+	}
+	denied, err := r.StatsByOutcome(schema.StatsGroupNone, schema.StatsFilter{Outcome: schema.OutcomeNoRoute})
+	if err != nil || len(denied) != 1 || denied[0].Count != 1 {
+		t.Errorf("outcome filter = %+v, %v", denied, err)
+	}
+
+	summary, err := r.StatsSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.ByOutcome[schema.OutcomeProxied] != 3 || summary.ByOutcome[schema.OutcomeACLDenied] != 1 || summary.ByOutcome[schema.OutcomeNoRoute] != 1 {
+		t.Errorf("summary by outcome = %v", summary.ByOutcome)
+	}
+}
+
+func TestStatsTPS_bucketsAndZeroFill(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:stats_tps?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&objects.ProxyStat{}); err != nil {
+		t.Fatal(err)
+	}
+	r := New(db)
+	bucket := 5 * time.Minute
+	now := time.Now().UTC()
+	current := now.Truncate(bucket) // epoch-aligned, like the repository's buckets
+	stat := func(at time.Time, status int, ms int64) schema.ProxyStat {
+		return schema.ProxyStat{ID: uuid.New(), Timestamp: at, Method: "GET", Path: "/", StatusCode: &status, DurationMs: &ms}
+	}
+	stats := []schema.ProxyStat{
+		stat(current, 200, 10), stat(current, 503, 30), stat(current, 404, 20), stat(current, 302, 40),
+		stat(current.Add(-2*bucket).Add(time.Second), 200, 7),
+	}
+	if err := r.CreateProxyStats(stats); err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.StatsTPS(current.Add(-3*bucket), bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("buckets = %d, want 4 (zero-filled): %+v", len(got), got)
+	}
+	for i, b := range got {
+		if want := current.Add(time.Duration(i-3) * bucket); !b.At.Equal(want) {
+			t.Errorf("bucket %d at %v, want %v", i, b.At, want)
+		}
+	}
+	if got[0].Count != 0 || got[2].Count != 0 || got[1].Count != 1 || got[1].AvgLatencyMs != 7 {
+		t.Errorf("older buckets = %+v", got[:3])
+	}
+	last := got[3]
+	if last.Count != 4 || last.Status2xx != 1 || last.Status3xx != 1 || last.Status4xx != 1 || last.Status5xx != 1 || last.ErrorRate != 0.25 || last.AvgLatencyMs != 25 {
+		t.Errorf("current bucket = %+v", last)
+	}
+}
+
+func TestRollupProxyStats_tiersAndPlan(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:stats_rollup?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&objects.ProxyStat{}, &objects.StatsRollupMinute{}, &objects.StatsRollupHour{}, &objects.StatsRollupWatermark{}); err != nil {
+		t.Fatal(err)
+	}
+	r := New(db).(*repository)
+	source := uuid.New()
+	hour := time.Now().UTC().Truncate(time.Hour).Add(-3 * time.Hour)
+	stat := func(at time.Time, status int, ms int64) schema.ProxyStat {
+		return schema.ProxyStat{ID: uuid.New(), Timestamp: at, SourceServerUUID: source, Method: "GET", Path: "/", StatusCode: &status, DurationMs: &ms, Outcome: schema.OutcomeProxied}
+	}
+	stats := []schema.ProxyStat{
+		stat(hour.Add(time.Minute), 200, 4), stat(hour.Add(time.Minute+time.Second), 500, 3000),
+		stat(hour.Add(30*time.Minute), 200, 20),
+		stat(hour.Add(90*time.Minute), 404, 8),
+	}
+	if err := r.CreateProxyStats(stats); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RollupProxyStats(hour.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	var minutes []objects.StatsRollupMinute
+	if err := db.Order("bucket_start").Find(&minutes).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(minutes) != 3 || minutes[0].Count != 2 || minutes[0].Status5xx != 1 || minutes[0].DurationSumMs != 3004 || minutes[0].DurationMaxMs != 3000 {
+		t.Fatalf("minute rollups = %+v", minutes)
+	}
+	if minutes[0].LatencyHistogram != "1,0,0,0,0,0,0,0,0,1,0,0" {
+		t.Errorf("histogram = %s", minutes[0].LatencyHistogram)
+	}
+	var hours []objects.StatsRollupHour
+	if err := db.Order("bucket_start").Find(&hours).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(hours) != 2 || hours[0].Count != 3 || hours[1].Count != 1 || hours[0].LatencyHistogram != "1,0,1,0,0,0,0,0,0,1,0,0" {
+		t.Fatalf("hour rollups = %+v", hours)
+	}
+	// Re-running is idempotent.
+	if err := r.RollupProxyStats(hour.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	db.Model(&objects.StatsRollupMinute{}).Count(&n)
+	if n != 3 {
+		t.Errorf("minute rows after rerun = %d, want 3", n)
+	}
+
+	// With the raw rows gone, aggregations still see the rolled-up hours.
+	if _, err := r.DeleteProxyStatsOlderThan(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	since := hour.Add(-time.Hour)
+	if plan := r.planStats(&since, nil, 0); !plan.ok || plan.table != "proxy_stats_hour" {
+		t.Errorf("plan = %+v, want hour rollup", plan)
+	}
+	if plan := r.planStats(&since, nil, 5*time.Minute); !plan.ok || plan.table != "proxy_stats_minute" {
+		t.Errorf("5m plan = %+v, want minute rollup", plan)
+	}
+	bySource, err := r.StatsBySourceServer(&since)
+	if err != nil || len(bySource) != 1 || bySource[0].Count != 4 {
+		t.Errorf("by source = %+v, %v", bySource, err)
+	}
+	tps, err := r.StatsTPS(since, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tps) < 3 || tps[1].Count != 3 || tps[1].Status5xx != 1 || tps[2].Count != 1 {
+		t.Errorf("tps = %+v", tps)
+	}
+	// Raw rows newer than the watermark are added to the rollups.
+	if err := r.CreateProxyStats([]schema.ProxyStat{stat(time.Now().UTC(), 200, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	outcomes, err := r.StatsByOutcome(schema.StatsGroupNone, schema.StatsFilter{Since: &since})
+	if err != nil || len(outcomes) != 1 || outcomes[0].Count != 5 {
+		t.Errorf("by outcome = %+v, %v", outcomes, err)
+	}
+
+	if _, err := r.DeleteStatsRollupsOlderThan(schema.RollupMinute, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&objects.StatsRollupMinute{}).Count(&n)
+	if n != 0 {
+		t.Errorf("minute rows after vacuum = %d", n)
+	}
+}
+
+func TestStats_rolledUpPastRawRetention(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:stats_rollup_retention?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&objects.ProxyStat{}, &objects.Route{}, &objects.StatsRollupMinute{}, &objects.StatsRollupHour{}, &objects.StatsRollupWatermark{}); err != nil {
+		t.Fatal(err)
+	}
+	r := New(db).(*repository)
+	source, route := uuid.New(), uuid.New()
+	if err := db.Create(&objects.Route{RouteUUID: route, SourceServerUUID: source, Method: "GET", SourcePath: "/users/{id}"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	hour := time.Now().UTC().Truncate(time.Hour).Add(-3 * time.Hour)
+	var stats []schema.ProxyStat
+	for i, ms := range []int64{3, 40, 40, 200, 2000} {
+		status, ms := 200, ms
+		if i == 4 {
+			status = 502
+		}
+		stats = append(stats, schema.ProxyStat{ID: uuid.New(), Timestamp: hour.Add(time.Duration(i) * time.Minute), SourceServerUUID: source, RouteUUID: route,
+			Method: "GET", Path: "/users/" + string(rune('a'+i)), StatusCode: &status, DurationMs: &ms, Outcome: schema.OutcomeProxied})
+	}
+	if err := r.CreateProxyStats(stats); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RollupProxyStats(hour.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.DeleteProxyStatsOlderThan(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	lat, err := r.StatsLatency(schema.StatsGroupRoute, schema.StatsFilter{}, 0)
+	if err != nil || len(lat) != 1 {
+		t.Fatalf("latency = %+v, %v", lat, err)
+	}
+	if l := lat[0]; l.UUID != route || l.Count != 5 || l.MeanMs != 456.6 || l.MaxMs != 2000 || math.Abs(l.P50Ms-50) > 1 || l.P99Ms != 2000 {
+		t.Errorf("latency = %+v, want count 5, mean 456.6, max 2000, p50 at the 50ms bound, p99 2000", l)
+	}
+	hist, err := r.StatsLatencyHistogram(schema.StatsFilter{RouteUUID: &route}, []int64{10, 100, 1000})
+	if err != nil || len(hist) != 4 || hist[0].Count != 1 || hist[1].Count != 2 || hist[2].Count != 1 || hist[3].Count != 1 {
+		t.Errorf("histogram = %+v, %v; want 1, 2, 1, 1 from the rollups", hist, err)
+	}
+	// Bounds that split rollup buckets can only be counted from raw stats, which are gone.
+	if hist, err := r.StatsLatencyHistogram(schema.StatsFilter{}, []int64{30}); err != nil || hist[0].Count != 0 || hist[1].Count != 0 {
+		t.Errorf("incompatible histogram = %+v, %v; want empty", hist, err)
+	}
+	sum, err := r.StatsSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Total != 5 || sum.Last24h != 5 || sum.Status2xx != 4 || sum.Status5xx != 1 {
+		t.Errorf("summary = %+v", sum)
+	}
+	byRoute, err := r.StatsByRoute(nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []schema.RouteCount{{RouteUUID: route, Method: "GET", SourcePath: "/users/{id}", Count: 5}}
+	if !reflect.DeepEqual(byRoute, want) {
+		t.Errorf("by route = %+v, want %+v", byRoute, want)
+	}
+	if oldest, err := r.OldestProxyStat(); err != nil || oldest != nil {
+		t.Errorf("oldest raw stat = %v, %v; want none", oldest, err)
+	}
+}
+
+func TestRollupProxyStats_rerollsReplayedStats(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:stats_rollup_replay?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&objects.ProxyStat{}, &objects.StatsRollupMinute{}, &objects.StatsRollupHour{}, &objects.StatsRollupWatermark{}); err != nil {
+		t.Fatal(err)
+	}
+	r := New(db).(*repository)
+	source := uuid.New()
+	hour := time.Now().UTC().Truncate(time.Hour).Add(-3 * time.Hour)
+	stat := func(at time.Time) schema.ProxyStat {
+		status, ms := 200, int64(10)
+		return schema.ProxyStat{ID: uuid.New(), Timestamp: at, SourceServerUUID: source, Method: "GET", Path: "/", StatusCode: &status, DurationMs: &ms, Outcome: schema.OutcomeProxied}
+	}
+	stored := []schema.ProxyStat{stat(hour), stat(hour.Add(10 * time.Minute))}
+	if err := r.CreateProxyStats(stored); err != nil {
+		t.Fatal(err)
+	}
+	// These failed to write and wait in the spill queue while rollups move past them.
+	spilled := []schema.ProxyStat{stat(hour.Add(10*time.Minute + time.Second)), stat(hour.Add(20 * time.Minute))}
+	if err := r.RollupProxyStats(hour.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// The replay repeats a stat already stored (at least once delivery).
+	if err := r.CreateProxyStats(append(spilled, stored[1])); err != nil {
+		t.Fatal(err)
+	}
+	if wm, _, _ := r.rollupWatermark(schema.RollupMinute); !wm.Equal(hour.Add(10 * time.Minute)) {
+		t.Errorf("minute watermark after replay = %v, want the replayed stat's minute %v", wm, hour.Add(10*time.Minute))
+	}
+	if err := r.RollupProxyStats(hour.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"proxy_stats_minute", "proxy_stats_hour"} {
+		var total int64
+		if err := db.Table(table).Select("COALESCE(SUM(count), 0)").Row().Scan(&total); err != nil {
+			t.Fatal(err)
+		}
+		if total != 4 {
+			t.Errorf("%s total = %d, want 4", table, total)
+		}
+	}
+	if wm, _, _ := r.rollupWatermark(schema.RollupHour); !wm.Equal(hour.Add(2 * time.Hour)) {
+		t.Errorf("hour watermark = %v, want %v", wm, hour.Add(2*time.Hour))
+	}
+	// Once raw rows are vacuumed the rollups still hold the replayed stats.
+	if _, err := r.DeleteProxyStatsOlderThan(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	since := hour.Add(-time.Hour)
+	counts, err := r.StatsStatusCounts(schema.StatsFilter{Since: &since})
+	if err != nil || counts.Total != 4 {
+		t.Errorf("status counts = %+v, %v; want 4", counts, err)
+	}
+}
+
+func TestStreamProxyStats(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:stats_stream?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&objects.ProxyStat{}); err != nil {
+		t.Fatal(err)
+	}
+	r := New(db)
+	route := uuid.New()
+	now := time.Now().UTC()
+	var stats []schema.ProxyStat
+	for i := 0; i < 5; i++ {
+		stats = append(stats, schema.ProxyStat{ID: uuid.New(), Timestamp: now.Add(-time.Duration(i) * time.Minute), RouteUUID: route, Method: "GET", Path: "/a", Outcome: schema.OutcomeProxied})
+	}
+	stats = append(stats, schema.ProxyStat{ID: uuid.New(), Timestamp: now, RouteUUID: uuid.New(), Method: "GET", Path: "/b", Outcome: schema.OutcomeProxied})
+	stats = append(stats, schema.ProxyStat{ID: uuid.New(), Timestamp: now.Add(-48 * time.Hour), RouteUUID: route, Method: "GET", Path: "/a", Outcome: schema.OutcomeProxied})
+	if err := r.CreateProxyStats(stats); err != nil {
+		t.Fatal(err)
+	}
+	since := now.Add(-time.Hour)
+	var got []schema.ProxyStat
+	err = r.StreamProxyStats(schema.StatsFilter{Since: &since, RouteUUID: &route}, func(s schema.ProxyStat) error {
+		got = append(got, s)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 5 {
+		t.Fatalf("streamed %d stats, want 5", len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Timestamp.Before(got[i-1].Timestamp) {
+			t.Fatalf("not oldest first: %v before %v", got[i].Timestamp, got[i-1].Timestamp)
+		}
+	}
+	stop := errors.New("stop")
+	n := 0
+	if err := r.StreamProxyStats(schema.StatsFilter{}, func(schema.ProxyStat) error { n++; return stop }); err != stop || n != 1 {
+		t.Errorf("callback error: err = %v after %d rows", err, n)
+	}
+}
+
+func TestListProxyStats_filtersAndCursor(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:stats_list?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&objects.ProxyStat{}); err != nil {
+		t.Fatal(err)
+	}
+	r := New(db)
+	route := uuid.New()
+	now := time.Now().UTC().Truncate(time.Second)
+	var stats []schema.ProxyStat
+	for i := 0; i < 25; i++ {
+		code, d := 200, int64(i*10)
+		if i%5 == 0 {
+			code = 502
+		}
+		path := "/api/orders"
+		if i%2 == 0 {
+			path = "/api_orders" // "_" must not act as a LIKE wildcard
+		}
+		// Pairs of stats share a timestamp, so paging must break ties by id.
+		stats = append(stats, schema.ProxyStat{ID: uuid.New(), Timestamp: now.Add(-time.Duration(i/2) * time.Second), RouteUUID: route,
+			Method: "GET", Path: path, StatusCode: &code, DurationMs: &d, ClientIP: "10.0.0.1"})
+	}
+	stats = append(stats, schema.ProxyStat{ID: uuid.New(), Timestamp: now, RouteUUID: uuid.New(), Method: "POST", Path: "/other"})
+	if err := r.CreateProxyStats(stats); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[uuid.UUID]bool{}
+	var last time.Time
+	q := schema.StatsListQuery{Filter: schema.StatsFilter{RouteUUID: &route}, Limit: 4}
+	for pages := 0; ; pages++ {
+		page, err := r.ListProxyStats(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 25 || pages > 7 {
+			t.Fatalf("page %d: total = %d", pages, page.Total)
+		}
+		for _, s := range page.Stats {
+			if seen[s.ID] || (!last.IsZero() && s.Timestamp.After(last)) {
+				t.Fatalf("page %d: %s repeated or out of order", pages, s.ID)
+			}
+			seen[s.ID], last = true, s.Timestamp
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	if len(seen) != 25 {
+		t.Errorf("paged through %d stats, want 25", len(seen))
+	}
+
+	min := int64(100)
+	for _, tt := range []struct {
+		name string
+		q    schema.StatsListQuery
+		want int
+	}{
+		{"status class", schema.StatsListQuery{StatusClass: 5}, 5},
+		{"status code", schema.StatsListQuery{StatusCode: 200, PathPrefix: "/api/"}, 10},
+		{"path prefix", schema.StatsListQuery{PathPrefix: "/api_"}, 13},
+		{"method and ip", schema.StatsListQuery{Method: "POST", ClientIP: "10.0.0.1"}, 0},
+		{"min duration", schema.StatsListQuery{MinDurationMs: &min}, 15},
+		{"until", schema.StatsListQuery{Filter: schema.StatsFilter{Until: &now}}, 23},
+	} {
+		page, err := r.ListProxyStats(tt.q)
+		if err != nil || page.Total != int64(tt.want) || len(page.Stats) != tt.want {
+			t.Errorf("%s: total = %d, stats = %d, err = %v; want %d", tt.name, page.Total, len(page.Stats), err, tt.want)
+		}
+	}
+
+	page, err := r.ListProxyStats(schema.StatsListQuery{Sort: schema.StatsSortSlowest, Limit: 3})
+	if err != nil || len(page.Stats) != 3 || *page.Stats[0].DurationMs != 240 || *page.Stats[2].DurationMs != 220 {
+		t.Fatalf("slowest = %+v, %v", page.Stats, err)
+	}
+	next, err := r.ListProxyStats(schema.StatsListQuery{Sort: schema.StatsSortSlowest, Limit: 3, Cursor: page.NextCursor})
+	if err != nil || *next.Stats[0].DurationMs != 210 {
+		t.Errorf("slowest page 2 = %+v, %v", next.Stats, err)
+	}
+	if _, err := r.ListProxyStats(schema.StatsListQuery{Sort: schema.StatsSortOldest, Cursor: page.NextCursor}); err != repo.ErrInvalidCursor {
+		t.Errorf("cursor of another sort: err = %v", err)
+	}
+	if _, err := r.ListProxyStats(schema.StatsListQuery{Cursor: "not-a-cursor"}); err != repo.ErrInvalidCursor {
+		t.Errorf("garbage cursor: err = %v", err)
+	}
+}
+
+func TestProxyStats_sampleWeightsAndReplay(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:stats_weights?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&objects.ProxyStat{}, &objects.StatsRollupMinute{}, &objects.StatsRollupHour{}, &objects.StatsRollupWatermark{}); err != nil {
+		t.Fatal(err)
+	}
+	r := New(db).(*repository)
+	now := time.Now().UTC()
+	ok, fail := 200, 500
+	fast, slow := int64(10), int64(100)
+	stats := []schema.ProxyStat{
+		{ID: uuid.New(), Timestamp: now, Method: "GET", Path: "/", StatusCode: &ok, DurationMs: &fast, Outcome: schema.OutcomeProxied, SampleWeight: 9},
+		{ID: uuid.New(), Timestamp: now, Method: "GET", Path: "/", StatusCode: &fail, DurationMs: &slow, Outcome: schema.OutcomeProxied},
+	}
+	if err := r.CreateProxyStats(stats); err != nil {
+		t.Fatal(err)
+	}
+	// A replayed batch repeats stored IDs: they are skipped, not counted twice.
+	if err := r.CreateProxyStats(append(stats, schema.ProxyStat{ID: uuid.New(), Timestamp: now, Method: "GET", Path: "/", Outcome: schema.OutcomeNoRoute})); err != nil {
+		t.Fatalf("replayed batch: %v", err)
+	}
+
+	sum, err := r.StatsSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Total != 11 || sum.Status2xx != 9 || sum.Status5xx != 1 || sum.ByOutcome[schema.OutcomeProxied] != 10 {
+		t.Errorf("summary = %+v", sum)
+	}
+	since := now.Add(-time.Minute)
+	counts, err := r.StatsStatusCounts(schema.StatsFilter{Since: &since})
+	if err != nil || counts.Total != 11 || counts.Status5xx != 1 {
+		t.Errorf("status counts = %+v, %v", counts, err)
+	}
+	lat, err := r.StatsLatency(schema.StatsGroupNone, schema.StatsFilter{}, 0)
+	if err != nil || len(lat) != 1 || lat[0].Count != 10 || lat[0].MeanMs != 19 || lat[0].P90Ms > 11 || lat[0].P99Ms != 100 {
+		t.Errorf("latency = %+v, %v", lat, err)
+	}
+	hist, err := r.StatsLatencyHistogram(schema.StatsFilter{}, []int64{50})
+	if err != nil || hist[0].Count != 9 || hist[1].Count != 1 {
+		t.Errorf("histogram = %+v, %v", hist, err)
+	}
+	tps, err := r.StatsTPS(now.Add(-time.Minute), time.Hour)
+	var total int64
+	for _, b := range tps {
+		total += b.Count
+	}
+	if err != nil || total != 11 {
+		t.Errorf("tps total = %d, %v", total, err)
+	}
+	page, err := r.ListProxyStats(schema.StatsListQuery{Sort: schema.StatsSortSlowest})
+	if err != nil || len(page.Stats) != 2 || page.Stats[1].SampleWeight != 9 || page.Stats[0].SampleWeight != 0 {
+		t.Errorf("listing = %+v, %v", page.Stats, err)
+	}
+}
+
+func TestStatsByCountryAndASN(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:stats_geo?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&objects.ProxyStat{}); err != nil {
+		t.Fatal(err)
+	}
+	r := New(db)
+	now := time.Now().UTC()
+	stat := func(country string, asn int64, org string, weight int64) schema.ProxyStat {
+		return schema.ProxyStat{ID: uuid.New(), Timestamp: now, Method: "GET", Path: "/", Outcome: schema.OutcomeProxied,
+			Country: country, Region: "SP", ASN: asn, ASOrg: org, SampleWeight: weight}
+	}
+	if err := r.CreateProxyStats([]schema.ProxyStat{
+		stat("BR", 64512, "Example Networks", 3),
+		stat("BR", 64512, "Example Networks", 0),
+		stat("DE", 64513, "Other AS", 0),
+		stat("", 0, "", 0),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	countries, err := r.StatsByCountry(nil, 0)
+	want := []schema.CountryCount{{Country: "BR", Count: 4}, {Country: "", Count: 1}, {Country: "DE", Count: 1}}
+	if err != nil || !reflect.DeepEqual(countries, want) {
+		t.Errorf("by country = %+v, %v; want %+v", countries, err, want)
+	}
+	asns, err := r.StatsByASN(nil, 1)
+	if err != nil || len(asns) != 1 || asns[0] != (schema.ASNCount{ASN: 64512, ASOrg: "Example Networks", Count: 4}) {
+		t.Errorf("by ASN = %+v, %v", asns, err)
+	}
+	future := now.Add(time.Hour)
+	if asns, err := r.StatsByASN(&future, 0); err != nil || len(asns) != 0 {
+		t.Errorf("by ASN since the future = %+v, %v", asns, err)
+	}
+	page, err := r.ListProxyStats(schema.StatsListQuery{})
+	if err != nil || len(page.Stats) != 4 {
+		t.Fatalf("listing = %+v, %v", page, err)
+	}
+	for _, s := range page.Stats {
+		if s.Country == "BR" && (s.Region != "SP" || s.ASN != 64512 || s.ASOrg != "Example Networks") {
+			t.Errorf("stored location = %+v", s)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package impl
+
+import (
+	"time"
+
+	"FeatherProxy/app/internal/database/objects"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+func (r *repository) GetMaintenance(scope string, ownerUUID uuid.UUID) (schema.Maintenance, error) {
+	return getCachedOptional(r, keyMaintenance(scope, ownerUUID), func() (schema.Maintenance, error) {
+		var obj objects.Maintenance
+		if err := r.db.Where("owner_uuid = ? AND scope = ?", ownerUUID, scope).First(&obj).Error; err != nil {
+			return schema.Maintenance{}, err
+		}
+		return objects.MaintenanceToSchema(&obj), nil
+	})
+}
+
+func (r *repository) SetMaintenance(m schema.Maintenance) error {
+	now := time.Now()
+	key := keyMaintenance(m.Scope, m.OwnerUUID)
+	var obj objects.Maintenance
+	err := r.db.Where("owner_uuid = ? AND scope = ?", m.OwnerUUID, m.Scope).First(&obj).Error
+	if err != nil {
+		// Create new
+		obj = objects.SchemaToMaintenance(m)
+		if obj.CreatedAt.IsZero() {
+			obj.CreatedAt = now
+		}
+		if obj.UpdatedAt.IsZero() {
+			obj.UpdatedAt = now
+		}
+		return r.invalidate(r.db.Create(&obj).Error, []string{key}, nil)
+	}
+	// Update existing
+	updated := objects.SchemaToMaintenance(m)
+	obj.Enabled = updated.Enabled
+	obj.Body = updated.Body
+	obj.ContentType = updated.ContentType
+	obj.RetryAfterSeconds = updated.RetryAfterSeconds
+	obj.StartsAt = updated.StartsAt
+	obj.EndsAt = updated.EndsAt
+	obj.AllowListJSON = updated.AllowListJSON
+	obj.UpdatedAt = now
+	return r.invalidate(r.db.Save(&obj).Error, []string{key}, nil)
+}
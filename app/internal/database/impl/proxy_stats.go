@@ -1,51 +1,259 @@
 package impl
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"FeatherProxy/app/internal/database/objects"
+	"FeatherProxy/app/internal/database/repo"
 	"FeatherProxy/app/internal/database/schema"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// Aggregates over proxy_stats sum sample_weight instead of counting rows: a row kept by sampling stands for
+// that many requests (see schema.ProxyStat.SampleWeight).
+const weightedCount = "COALESCE(SUM(sample_weight), 0)"
+
+// weightedWhen sums sample_weight over the rows matching cond.
+func weightedWhen(cond string) string {
+	return "COALESCE(SUM(CASE WHEN " + cond + " THEN sample_weight ELSE 0 END), 0)"
+}
+
+// weightedTotal returns the weighted count of the rows q selects.
+func weightedTotal(q *gorm.DB) (int64, error) {
+	var n int64
+	err := q.Select(weightedCount).Row().Scan(&n)
+	return n, err
+}
+
+// CreateProxyStats inserts the stats, skipping IDs already stored, so a batch replayed after a failed or
+// interrupted write (see the stats spill queue) does not fail on, or double count, the rows that made it.
+// Replayed stats keep their timestamps; when some are older than the minute rollup watermark, the
+// watermarks are lowered so the next RollupProxyStats re-rolls their buckets (see lowerRollupWatermarks).
 func (r *repository) CreateProxyStats(stats []schema.ProxyStat) error {
 	if len(stats) == 0 {
 		return nil
 	}
 	objs := make([]objects.ProxyStat, len(stats))
+	oldest := stats[0].Timestamp
 	for i := range stats {
 		s := &stats[i]
 		if s.ID == uuid.Nil {
 			s.ID = uuid.New()
 		}
 		objs[i] = objects.SchemaToProxyStat(*s)
+		if s.Timestamp.Before(oldest) {
+			oldest = s.Timestamp
+		}
+	}
+	// Like planStats, an unreadable watermark counts as no rollup yet: it must not fail the insert.
+	wm, rolled, err := r.rollupWatermark(schema.RollupMinute)
+	late := err == nil && rolled && oldest.Before(wm)
+	var oldestRaw []time.Time
+	if late {
+		if err := r.db.Model(&objects.ProxyStat{}).Order("timestamp").Limit(1).Pluck("timestamp", &oldestRaw).Error; err != nil {
+			return err
+		}
+	}
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(objs, 100).Error; err != nil {
+		return err
+	}
+	if !late {
+		return nil
 	}
-	return r.db.CreateInBatches(objs, 100).Error
+	if len(oldestRaw) == 0 {
+		repoLogger.Warn("stats older than the rollup watermark not rolled up: no raw stats to re-roll with", "oldest", oldest)
+		return nil
+	}
+	return r.lowerRollupWatermarks(oldest, oldestRaw[0])
 }
 
-func (r *repository) ListProxyStats(limit, offset int, since *time.Time) ([]schema.ProxyStat, int64, error) {
-	var total int64
-	q := r.db.Model(&objects.ProxyStat{})
-	if since != nil {
-		q = q.Where("timestamp >= ?", *since)
+// lowerRollupWatermarks moves the rollup watermarks back to the bucket of from, so the next rollup
+// rebuilds the buckets stats were added to after they were rolled up. Rebuilding a minute drops what it
+// had for rows no longer stored, so the minute watermark never goes back before oldestRaw (the oldest raw
+// stat before the insert): buckets past it hold all their raw rows, older ones are left as they are (their
+// raw rows are about to be vacuumed anyway). Hours are rebuilt from the minute rollups, which outlive raw
+// rows.
+func (r *repository) lowerRollupWatermarks(from, oldestRaw time.Time) error {
+	minute := schema.RollupSizes[schema.RollupMinute]
+	floor := oldestRaw.UTC().Truncate(minute)
+	if floor.Before(oldestRaw.UTC()) {
+		floor = floor.Add(minute)
+	}
+	if from = from.UTC().Truncate(minute); from.Before(floor) {
+		from = floor
+	}
+	for _, res := range []string{schema.RollupMinute, schema.RollupHour} {
+		to := from.Truncate(schema.RollupSizes[res])
+		err := r.db.Model(&objects.StatsRollupWatermark{}).Where("resolution = ? AND watermark > ?", res, to).Update("watermark", to).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultStatsPageSize is the page size of ListProxyStats when the query has no limit.
+const defaultStatsPageSize = 100
+
+// statsCursor is the position after the last stat of a ListProxyStats page, for the page's sort order.
+// It is handed out base64url-encoded and opaque.
+type statsCursor struct {
+	Sort       string    `json:"s"`
+	Timestamp  time.Time `json:"t"`
+	DurationMs int64     `json:"d,omitempty"`
+	ID         uuid.UUID `json:"id"`
+}
+
+func (c statsCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeStatsCursor(s, sortBy string) (statsCursor, error) {
+	var c statsCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(b, &c) != nil || c.Sort != sortBy {
+		return c, repo.ErrInvalidCursor
 	}
-	if err := q.Count(&total).Error; err != nil {
-		return nil, 0, err
+	return c, nil
+}
+
+// ListProxyStats returns one page of stats matching the query plus the total count. Pages are keyset
+// paginated on (timestamp, id) or (duration_ms, id), so deep pages cost the same as the first and rows
+// recorded while paging do not shift later pages.
+func (r *repository) ListProxyStats(lq schema.StatsListQuery) (schema.StatsPage, error) {
+	sortBy := lq.Sort
+	if sortBy == "" {
+		sortBy = schema.StatsSortNewest
+	}
+	limit := lq.Limit
+	if limit <= 0 {
+		limit = defaultStatsPageSize
+	}
+	q := applyStatsListQuery(r.db.Model(&objects.ProxyStat{}), lq)
+	if sortBy == schema.StatsSortSlowest {
+		q = q.Where("duration_ms IS NOT NULL")
+	}
+	q = q.Session(&gorm.Session{})
+	var page schema.StatsPage
+	if err := q.Count(&page.Total).Error; err != nil {
+		return page, err
 	}
+
+	var after *statsCursor
+	if lq.Cursor != "" {
+		c, err := decodeStatsCursor(lq.Cursor, sortBy)
+		if err != nil {
+			return page, err
+		}
+		after = &c
+	}
+	switch sortBy {
+	case schema.StatsSortNewest:
+		if after != nil {
+			q = q.Where("timestamp < ? OR (timestamp = ? AND id < ?)", after.Timestamp, after.Timestamp, after.ID)
+		}
+		q = q.Order("timestamp DESC, id DESC")
+	case schema.StatsSortOldest:
+		if after != nil {
+			q = q.Where("timestamp > ? OR (timestamp = ? AND id > ?)", after.Timestamp, after.Timestamp, after.ID)
+		}
+		q = q.Order("timestamp ASC, id ASC")
+	case schema.StatsSortSlowest:
+		if after != nil {
+			q = q.Where("duration_ms < ? OR (duration_ms = ? AND id < ?)", after.DurationMs, after.DurationMs, after.ID)
+		}
+		q = q.Order("duration_ms DESC, id DESC")
+	default:
+		return page, fmt.Errorf("stats: unknown sort %q", sortBy)
+	}
+
 	var list []objects.ProxyStat
-	q = r.db.Order("timestamp DESC").Limit(limit).Offset(offset)
-	if since != nil {
-		q = q.Where("timestamp >= ?", *since)
+	if err := q.Limit(limit + 1).Find(&list).Error; err != nil {
+		return page, err
+	}
+	if len(list) > limit {
+		list = list[:limit]
+		last := list[limit-1]
+		c := statsCursor{Sort: sortBy, Timestamp: last.Timestamp, ID: last.ID}
+		if last.DurationMs != nil {
+			c.DurationMs = *last.DurationMs
+		}
+		page.NextCursor = c.encode()
 	}
-	if err := q.Find(&list).Error; err != nil {
-		return nil, 0, err
+	page.Stats = make([]schema.ProxyStat, len(list))
+	for i := range list {
+		page.Stats[i] = objects.ProxyStatToSchema(&list[i])
+	}
+	return page, nil
+}
+
+// applyStatsListQuery adds the query's filters (not its sort or cursor) to q.
+func applyStatsListQuery(q *gorm.DB, lq schema.StatsListQuery) *gorm.DB {
+	q = applyStatsFilter(q, lq.Filter)
+	if lq.Method != "" {
+		q = q.Where("method = ?", lq.Method)
+	}
+	if lq.StatusCode != 0 {
+		q = q.Where("status_code = ?", lq.StatusCode)
+	}
+	if lq.StatusClass != 0 {
+		q = q.Where("status_code >= ? AND status_code < ?", lq.StatusClass*100, lq.StatusClass*100+100)
+	}
+	if lq.ClientIP != "" {
+		q = q.Where("client_ip = ?", lq.ClientIP)
+	}
+	if lq.PathPrefix != "" {
+		q = q.Where(`path LIKE ? ESCAPE '\'`, likeEscaper.Replace(lq.PathPrefix)+"%")
+	}
+	if lq.MinDurationMs != nil {
+		q = q.Where("duration_ms >= ?", *lq.MinDurationMs)
+	}
+	return q
+}
+
+// likeEscaper escapes the LIKE wildcards of a literal pattern (used with ESCAPE '\').
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (r *repository) GetProxyStatsByRequestID(requestID string) ([]schema.ProxyStat, error) {
+	var list []objects.ProxyStat
+	if err := r.db.Where("request_id = ?", requestID).Order("timestamp ASC").Find(&list).Error; err != nil {
+		return nil, err
 	}
 	out := make([]schema.ProxyStat, len(list))
 	for i := range list {
 		out[i] = objects.ProxyStatToSchema(&list[i])
 	}
-	return out, total, nil
+	return out, nil
+}
+
+// StreamProxyStats calls fn for each stat matching the filter, oldest first, reading rows from the database
+// one at a time instead of loading the result. It stops at the first error from fn.
+func (r *repository) StreamProxyStats(filter schema.StatsFilter, fn func(schema.ProxyStat) error) error {
+	rows, err := applyStatsFilter(r.db.Model(&objects.ProxyStat{}), filter).Order("timestamp, id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var row objects.ProxyStat
+		if err := r.db.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		if err := fn(objects.ProxyStatToSchema(&row)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
 
 func (r *repository) DeleteProxyStatsOlderThan(until time.Time) (int64, error) {
@@ -54,62 +262,218 @@ func (r *repository) DeleteProxyStatsOlderThan(until time.Time) (int64, error) {
 }
 
 func (r *repository) ClearAllProxyStats() error {
-	return r.db.Where("1 = 1").Delete(&objects.ProxyStat{}).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&objects.ProxyStat{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("1 = 1").Delete(&objects.StatsRollupMinute{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("1 = 1").Delete(&objects.StatsRollupHour{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("1 = 1").Delete(&objects.StatsRollupWatermark{}).Error
+	})
 }
 
+// StatsSummary returns the dashboard totals. Total and the last 24h counts include rolled-up buckets (see
+// StatsStatusCounts), so they keep counting requests past raw retention.
 func (r *repository) StatsSummary() (schema.StatsSummary, error) {
 	var out schema.StatsSummary
 	now := time.Now()
 	last24h := now.Add(-24 * time.Hour)
 	last1min := now.Add(-1 * time.Minute)
 
-	if err := r.db.Model(&objects.ProxyStat{}).Count(&out.Total).Error; err != nil {
+	total, err := r.StatsStatusCounts(schema.StatsFilter{})
+	if err != nil {
 		return out, err
 	}
-	if err := r.db.Model(&objects.ProxyStat{}).Where("timestamp >= ?", last24h).Count(&out.Last24h).Error; err != nil {
+	out.Total = total.Total
+	day, err := r.StatsStatusCounts(schema.StatsFilter{Since: &last24h})
+	if err != nil {
 		return out, err
 	}
-	if err := r.db.Model(&objects.ProxyStat{}).Where("timestamp >= ?", last24h).Where("status_code >= ? AND status_code < ?", 200, 300).Count(&out.Status2xx).Error; err != nil {
+	out.Last24h, out.Status2xx, out.Status4xx, out.Status5xx = day.Total, day.Status2xx, day.Status4xx, day.Status5xx
+	if out.TpsLastMinute, err = weightedTotal(r.db.Model(&objects.ProxyStat{}).Where("timestamp >= ?", last1min)); err != nil {
 		return out, err
 	}
-	if err := r.db.Model(&objects.ProxyStat{}).Where("timestamp >= ?", last24h).Where("status_code >= ? AND status_code < ?", 400, 500).Count(&out.Status4xx).Error; err != nil {
+	byOutcome, err := r.StatsByOutcome(schema.StatsGroupNone, schema.StatsFilter{Since: &last24h})
+	if err != nil {
 		return out, err
 	}
-	if err := r.db.Model(&objects.ProxyStat{}).Where("timestamp >= ?", last24h).Where("status_code >= ? AND status_code < ?", 500, 600).Count(&out.Status5xx).Error; err != nil {
-		return out, err
+	out.ByOutcome = make(map[string]int64, len(byOutcome))
+	for _, c := range byOutcome {
+		out.ByOutcome[c.Outcome] = c.Count
 	}
-	if err := r.db.Model(&objects.ProxyStat{}).Where("timestamp >= ?", last1min).Count(&out.TpsLastMinute).Error; err != nil {
+	return out, nil
+}
+
+// StatsStatusCounts counts the requests matching the filter, in total and per status class. Whole
+// rolled-up buckets are read from the rollup tables (see planStats).
+func (r *repository) StatsStatusCounts(filter schema.StatsFilter) (schema.StatusCounts, error) {
+	var out schema.StatusCounts
+	plan := r.planStats(filter.Since, filter.Until, 0)
+	q := r.db.Model(&objects.ProxyStat{}).Select(weightedCount + " AS total, " +
+		weightedWhen("status_code >= 200 AND status_code < 300") + " AS status2xx, " +
+		weightedWhen("status_code >= 300 AND status_code < 400") + " AS status3xx, " +
+		weightedWhen("status_code >= 400 AND status_code < 500") + " AS status4xx, " +
+		weightedWhen("status_code >= 500 AND status_code < 600") + " AS status5xx")
+	err := plan.excludeRollup(applyStatsFilter(q, filter)).Row().Scan(&out.Total, &out.Status2xx, &out.Status3xx, &out.Status4xx, &out.Status5xx)
+	if err != nil || !plan.ok {
 		return out, err
 	}
+	var rolled schema.StatusCounts
+	err = plan.rollupQuery(r.db, filter).Select("COALESCE(SUM(count), 0), COALESCE(SUM(status2xx), 0), COALESCE(SUM(status3xx), 0), "+
+		"COALESCE(SUM(status4xx), 0), COALESCE(SUM(status5xx), 0)").Row().
+		Scan(&rolled.Total, &rolled.Status2xx, &rolled.Status3xx, &rolled.Status4xx, &rolled.Status5xx)
+	out.Total += rolled.Total
+	out.Status2xx += rolled.Status2xx
+	out.Status3xx += rolled.Status3xx
+	out.Status4xx += rolled.Status4xx
+	out.Status5xx += rolled.Status5xx
+	return out, err
+}
+
+// StatsByOutcome counts requests per outcome, per group (route, source or target server) or overall when
+// groupBy is StatsGroupNone. Rows are ordered by group, then count. Whole rolled-up buckets are read from
+// the rollup tables (see planStats).
+func (r *repository) StatsByOutcome(groupBy string, filter schema.StatsFilter) ([]schema.OutcomeCount, error) {
+	col := "NULL"
+	if groupBy != schema.StatsGroupNone {
+		var ok bool
+		if col, ok = statsGroupColumns[groupBy]; !ok {
+			return nil, fmt.Errorf("stats: unknown outcome grouping %q", groupBy)
+		}
+	}
+	group := "outcome"
+	if groupBy != schema.StatsGroupNone {
+		group = col + ", outcome"
+	}
+	plan := r.planStats(filter.Since, filter.Until, 0)
+	var rows []struct {
+		GroupUUID uuid.NullUUID
+		Outcome   string
+		Count     int64
+	}
+	q := r.db.Model(&objects.ProxyStat{}).Select(col + " AS group_uuid, outcome, " + weightedCount + " AS count").Group(group)
+	if err := plan.excludeRollup(applyStatsFilter(q, filter)).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	type key struct {
+		group   uuid.UUID
+		outcome string
+	}
+	counts := map[key]int64{}
+	for _, row := range rows {
+		counts[key{row.GroupUUID.UUID, row.Outcome}] += row.Count
+	}
+	if plan.ok {
+		rows = rows[:0]
+		if err := plan.rollupQuery(r.db, filter).Select(col + " AS group_uuid, outcome, SUM(count) AS count").Group(group).Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			counts[key{row.GroupUUID.UUID, row.Outcome}] += row.Count
+		}
+	}
+	out := make([]schema.OutcomeCount, 0, len(counts))
+	for k, n := range counts {
+		out = append(out, schema.OutcomeCount{UUID: k.group, Outcome: k.outcome, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if gi, gj := out[i].UUID.String(), out[j].UUID.String(); gi != gj {
+			return gi < gj
+		}
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Outcome < out[j].Outcome
+	})
 	return out, nil
 }
 
+// StatsByRoute counts requests per route, method and path, most first. Rollups keep the route but not the
+// request's method and path, so rolled-up requests of a route are reported under the route's configured
+// method and source path (empty for unmatched requests and deleted routes).
 func (r *repository) StatsByRoute(since *time.Time, limit int) ([]schema.RouteCount, error) {
-	q := r.db.Model(&objects.ProxyStat{}).Select("route_uuid, method, path as source_path, count(*) as count").Group("route_uuid, method, path").Order("count DESC")
+	plan := r.planStats(since, nil, 0)
+	q := r.db.Model(&objects.ProxyStat{}).Select("route_uuid, method, path as source_path, " + weightedCount + " as count").Group("route_uuid, method, path").Order("count DESC")
 	if since != nil {
 		q = q.Where("timestamp >= ?", *since)
 	}
-	if limit > 0 {
+	if limit > 0 && !plan.ok {
 		q = q.Limit(limit)
 	}
-	var rows []struct {
-		RouteUUID  uuid.UUID
-		Method     string
-		SourcePath string
-		Count      int64
-	}
-	if err := q.Find(&rows).Error; err != nil {
+	var rows []schema.RouteCount
+	if err := plan.excludeRollup(q).Find(&rows).Error; err != nil {
 		return nil, err
 	}
-	out := make([]schema.RouteCount, len(rows))
-	for i := range rows {
-		out[i] = schema.RouteCount{RouteUUID: rows[i].RouteUUID, Method: rows[i].Method, SourcePath: rows[i].SourcePath, Count: rows[i].Count}
+	if plan.ok {
+		var rolled []struct {
+			RouteUUID uuid.UUID
+			Count     int64
+		}
+		if err := plan.rollupQuery(r.db, schema.StatsFilter{}).Select("route_uuid, SUM(count) as count").Group("route_uuid").Find(&rolled).Error; err != nil {
+			return nil, err
+		}
+		ids := make([]uuid.UUID, 0, len(rolled))
+		for _, row := range rolled {
+			if row.RouteUUID != uuid.Nil {
+				ids = append(ids, row.RouteUUID)
+			}
+		}
+		var routes []objects.Route
+		if len(ids) > 0 {
+			if err := r.db.Where("route_uuid IN ?", ids).Find(&routes).Error; err != nil {
+				return nil, err
+			}
+		}
+		type routeKey struct {
+			route        uuid.UUID
+			method, path string
+		}
+		keys := make(map[uuid.UUID]routeKey, len(routes))
+		for _, rt := range routes {
+			keys[rt.RouteUUID] = routeKey{rt.RouteUUID, rt.Method, rt.SourcePath}
+		}
+		index := make(map[routeKey]int, len(rows))
+		for i, row := range rows {
+			index[routeKey{row.RouteUUID, row.Method, row.SourcePath}] = i
+		}
+		for _, row := range rolled {
+			k, ok := keys[row.RouteUUID]
+			if !ok {
+				k = routeKey{route: row.RouteUUID}
+			}
+			if i, ok := index[k]; ok {
+				rows[i].Count += row.Count
+				continue
+			}
+			index[k] = len(rows)
+			rows = append(rows, schema.RouteCount{RouteUUID: k.route, Method: k.method, SourcePath: k.path, Count: row.Count})
+		}
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].Count > rows[j].Count })
+		if limit > 0 && len(rows) > limit {
+			rows = rows[:limit]
+		}
 	}
-	return out, nil
+	return rows, nil
+}
+
+// OldestProxyStat returns the timestamp of the oldest raw stat, or nil when there is none. Raw-only
+// aggregates (by caller, country, ASN and variant) cover nothing before it.
+func (r *repository) OldestProxyStat() (*time.Time, error) {
+	var list []objects.ProxyStat
+	if err := r.db.Select("timestamp").Order("timestamp").Limit(1).Find(&list).Error; err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0].Timestamp, nil
 }
 
+// StatsByCaller counts requests per client IP, most first. Rollups do not keep the client, so only raw
+// stats are counted: the window is limited to raw retention (STATS_RETENTION_DAYS) whatever since asks for.
 func (r *repository) StatsByCaller(since *time.Time, limit int) ([]schema.CallerCount, error) {
-	q := r.db.Model(&objects.ProxyStat{}).Select("client_ip, count(*) as count").Group("client_ip").Order("count DESC")
+	q := r.db.Model(&objects.ProxyStat{}).Select("client_ip, " + weightedCount + " as count").Group("client_ip").Order("count DESC")
 	if since != nil {
 		q = q.Where("timestamp >= ?", *since)
 	}
@@ -130,27 +494,52 @@ func (r *repository) StatsByCaller(since *time.Time, limit int) ([]schema.Caller
 	return out, nil
 }
 
-func (r *repository) StatsBySourceServer(since *time.Time) ([]schema.ServerCount, error) {
-	q := r.db.Model(&objects.ProxyStat{}).Select("source_server_uuid as server_uuid, count(*) as count").Group("source_server_uuid").Order("count DESC")
+// StatsByCountry counts requests per client country (GeoIP enrichment), most first. Like StatsByCaller it
+// only counts raw stats.
+func (r *repository) StatsByCountry(since *time.Time, limit int) ([]schema.CountryCount, error) {
+	q := r.db.Model(&objects.ProxyStat{}).Select("country, " + weightedCount + " as count").Group("country").Order("count DESC, country")
 	if since != nil {
 		q = q.Where("timestamp >= ?", *since)
 	}
-	var rows []struct {
-		ServerUUID uuid.UUID
-		Count      int64
+	if limit > 0 {
+		q = q.Limit(limit)
 	}
-	if err := q.Find(&rows).Error; err != nil {
+	var out []schema.CountryCount
+	if err := q.Scan(&out).Error; err != nil {
 		return nil, err
 	}
-	out := make([]schema.ServerCount, len(rows))
-	for i := range rows {
-		out[i] = schema.ServerCount{ServerUUID: rows[i].ServerUUID, Count: rows[i].Count}
+	return out, nil
+}
+
+// StatsByASN counts requests per client autonomous system (GeoIP enrichment), most first. Raw stats only. AS names can
+// change between database versions; one stored name is reported per ASN.
+func (r *repository) StatsByASN(since *time.Time, limit int) ([]schema.ASNCount, error) {
+	q := r.db.Model(&objects.ProxyStat{}).Select("asn, MAX(as_org) as as_org, " + weightedCount + " as count").Group("asn").Order("count DESC, asn")
+	if since != nil {
+		q = q.Where("timestamp >= ?", *since)
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	var out []schema.ASNCount
+	if err := q.Scan(&out).Error; err != nil {
+		return nil, err
 	}
 	return out, nil
 }
 
+func (r *repository) StatsBySourceServer(since *time.Time) ([]schema.ServerCount, error) {
+	return r.statsByServer("source_server_uuid", since)
+}
+
 func (r *repository) StatsByTargetServer(since *time.Time) ([]schema.ServerCount, error) {
-	q := r.db.Model(&objects.ProxyStat{}).Select("target_server_uuid as server_uuid, count(*) as count").Group("target_server_uuid").Order("count DESC")
+	return r.statsByServer("target_server_uuid", since)
+}
+
+// statsByServer counts requests per value of a server column, combining rollups and raw rows (see planStats).
+func (r *repository) statsByServer(col string, since *time.Time) ([]schema.ServerCount, error) {
+	plan := r.planStats(since, nil, 0)
+	q := r.db.Model(&objects.ProxyStat{}).Select(col + " as server_uuid, " + weightedCount + " as count").Group(col)
 	if since != nil {
 		q = q.Where("timestamp >= ?", *since)
 	}
@@ -158,71 +547,151 @@ func (r *repository) StatsByTargetServer(since *time.Time) ([]schema.ServerCount
 		ServerUUID uuid.UUID
 		Count      int64
 	}
-	if err := q.Find(&rows).Error; err != nil {
+	if err := plan.excludeRollup(q).Find(&rows).Error; err != nil {
 		return nil, err
 	}
-	out := make([]schema.ServerCount, len(rows))
-	for i := range rows {
-		out[i] = schema.ServerCount{ServerUUID: rows[i].ServerUUID, Count: rows[i].Count}
+	counts := map[uuid.UUID]int64{}
+	for _, row := range rows {
+		counts[row.ServerUUID] += row.Count
 	}
+	if plan.ok {
+		rows = rows[:0]
+		if err := plan.rollupQuery(r.db, schema.StatsFilter{}).Select(col + " as server_uuid, SUM(count) as count").Group(col).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			counts[row.ServerUUID] += row.Count
+		}
+	}
+	out := make([]schema.ServerCount, 0, len(counts))
+	for id, n := range counts {
+		out = append(out, schema.ServerCount{ServerUUID: id, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].ServerUUID.String() < out[j].ServerUUID.String()
+	})
 	return out, nil
 }
 
+// StatsTPS returns one bucket per bucketDuration from since to now (zero-filled), with request counts per
+// status class, the 5xx error rate and the average latency. Buckets are aligned to the Unix epoch
+// (multiples of the bucket size), computed in SQL on both dialects; sub-second sizes are rounded up to 1s.
+// Whole minutes or hours are read from the rollup tables when the bucket size allows (see planStats).
 func (r *repository) StatsTPS(since time.Time, bucketDuration time.Duration) ([]schema.BucketCount, error) {
-	// Use raw SQL for time bucketing: PostgreSQL date_trunc, SQLite strftime
-	dialect := r.db.Dialector.Name()
-	var rows []struct {
-		At    time.Time
-		Count int64
-	}
-	// Query: group by bucket, order by bucket
-	switch dialect {
-	case "postgres", "postgresql":
-		err := r.db.Raw(`
-			SELECT date_trunc('minute', timestamp) AS at, COUNT(*) AS count
-			FROM proxy_stats WHERE timestamp >= ? AND timestamp <= ?
-			GROUP BY 1 ORDER BY 1`,
-			since, time.Now()).Scan(&rows).Error
-		if err != nil {
-			return nil, err
-		}
-	case "sqlite":
-		var sqliteRows []struct {
-			AtStr string `gorm:"column:at_str"`
-			Count int64  `gorm:"column:count"`
-		}
-		err := r.db.Raw(`
-			SELECT strftime('%Y-%m-%d %H:%M:00', timestamp) AS at_str, COUNT(*) AS count
-			FROM proxy_stats WHERE timestamp >= ? AND timestamp <= ?
-			GROUP BY at_str ORDER BY at_str`, since, time.Now()).Scan(&sqliteRows).Error
+	size := int64(bucketDuration / time.Second)
+	if size < 1 {
+		size = 1
+	}
+	now := time.Now()
+	plan := r.planStats(&since, &now, time.Duration(size)*time.Second)
+	type tpsRow struct {
+		Bucket        int64
+		Count         int64
+		Status2xx     int64 `gorm:"column:status2xx"`
+		Status3xx     int64 `gorm:"column:status3xx"`
+		Status4xx     int64 `gorm:"column:status4xx"`
+		Status5xx     int64 `gorm:"column:status5xx"`
+		DurationSum   int64
+		DurationCount int64
+	}
+	var rows []tpsRow
+	q := r.db.Model(&objects.ProxyStat{}).Select(r.epochExpr("timestamp")+" / ? AS bucket, "+weightedCount+" AS count, "+
+		weightedWhen("status_code >= 200 AND status_code < 300")+" AS status2xx, "+
+		weightedWhen("status_code >= 300 AND status_code < 400")+" AS status3xx, "+
+		weightedWhen("status_code >= 400 AND status_code < 500")+" AS status4xx, "+
+		weightedWhen("status_code >= 500 AND status_code < 600")+" AS status5xx, "+
+		"COALESCE(SUM(duration_ms * sample_weight), 0) AS duration_sum, "+weightedWhen("duration_ms IS NOT NULL")+" AS duration_count", size).
+		Where("timestamp >= ? AND timestamp <= ?", since, now).Group("bucket")
+	if err := plan.excludeRollup(q).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if plan.ok {
+		var rolled []tpsRow
+		err := plan.rollupQuery(r.db, schema.StatsFilter{}).Select(r.epochExpr("bucket_start")+` / ? AS bucket, SUM(count) AS count,
+			SUM(status2xx) AS status2xx, SUM(status3xx) AS status3xx, SUM(status4xx) AS status4xx, SUM(status5xx) AS status5xx,
+			SUM(duration_sum_ms) AS duration_sum, SUM(duration_count) AS duration_count`, size).
+			Group("bucket").Scan(&rolled).Error
 		if err != nil {
 			return nil, err
 		}
-		rows = make([]struct {
-			At    time.Time
-			Count int64
-		}, len(sqliteRows))
-		for i := range sqliteRows {
-			t, _ := time.ParseInLocation("2006-01-02 15:04:05", sqliteRows[i].AtStr, time.UTC)
-			rows[i] = struct {
-				At    time.Time
-				Count int64
-			}{At: t, Count: sqliteRows[i].Count}
+		rows = append(rows, rolled...)
+	}
+	merged := make(map[int64]*tpsRow, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		m := merged[row.Bucket]
+		if m == nil {
+			merged[row.Bucket] = row
+			continue
 		}
-	default:
-		// Fallback: same as postgres
-		err := r.db.Raw(`
-			SELECT date_trunc('minute', timestamp) AS at, COUNT(*) AS count
-			FROM proxy_stats WHERE timestamp >= ? AND timestamp <= ?
-			GROUP BY 1 ORDER BY 1`,
-			since, time.Now()).Scan(&rows).Error
-		if err != nil {
-			return nil, err
+		m.Count += row.Count
+		m.Status2xx += row.Status2xx
+		m.Status3xx += row.Status3xx
+		m.Status4xx += row.Status4xx
+		m.Status5xx += row.Status5xx
+		m.DurationSum += row.DurationSum
+		m.DurationCount += row.DurationCount
+	}
+	first, last := since.Unix()/size, now.Unix()/size
+	out := make([]schema.BucketCount, 0, last-first+1)
+	for b := first; b <= last; b++ {
+		bc := schema.BucketCount{At: time.Unix(b*size, 0).UTC()}
+		if row := merged[b]; row != nil {
+			bc.Count = row.Count
+			bc.Status2xx, bc.Status3xx, bc.Status4xx, bc.Status5xx = row.Status2xx, row.Status3xx, row.Status4xx, row.Status5xx
+			if row.Count > 0 {
+				bc.ErrorRate = math.Round(float64(row.Status5xx)/float64(row.Count)*10000) / 10000
+			}
+			if row.DurationCount > 0 {
+				bc.AvgLatencyMs = math.Round(float64(row.DurationSum)/float64(row.DurationCount)*100) / 100
+			}
 		}
+		out = append(out, bc)
+	}
+	return out, nil
+}
+
+// StatsByVariant counts requests per traffic split variant. Rollups do not keep the variant, so only raw
+// stats are counted: the window is limited to raw retention (STATS_RETENTION_DAYS) whatever since asks for.
+func (r *repository) StatsByVariant(routeUUID *uuid.UUID, since *time.Time) ([]schema.VariantCount, error) {
+	q := r.db.Model(&objects.ProxyStat{}).
+		Select("route_uuid, variant, " + weightedCount + " as count, " +
+			weightedWhen("status_code >= 200 AND status_code < 300") + " as status2xx, " +
+			weightedWhen("status_code >= 400 AND status_code < 500") + " as status4xx, " +
+			weightedWhen("status_code >= 500 AND status_code < 600") + " as status5xx").
+		Where("variant <> ''").
+		Group("route_uuid, variant").
+		Order("route_uuid, count DESC")
+	if routeUUID != nil {
+		q = q.Where("route_uuid = ?", *routeUUID)
+	}
+	if since != nil {
+		q = q.Where("timestamp >= ?", *since)
+	}
+	var rows []struct {
+		RouteUUID uuid.UUID
+		Variant   string
+		Count     int64
+		Status2xx int64 `gorm:"column:status2xx"`
+		Status4xx int64 `gorm:"column:status4xx"`
+		Status5xx int64 `gorm:"column:status5xx"`
 	}
-	out := make([]schema.BucketCount, len(rows))
+	if err := q.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]schema.VariantCount, len(rows))
 	for i := range rows {
-		out[i] = schema.BucketCount{At: rows[i].At, Count: rows[i].Count}
+		out[i] = schema.VariantCount{
+			RouteUUID: rows[i].RouteUUID,
+			Variant:   rows[i].Variant,
+			Count:     rows[i].Count,
+			Status2xx: rows[i].Status2xx,
+			Status4xx: rows[i].Status4xx,
+			Status5xx: rows[i].Status5xx,
+		}
 	}
 	return out, nil
 }
@@ -5,10 +5,13 @@ import (
 
 	"FeatherProxy/app/internal/cache"
 	"FeatherProxy/app/internal/database/repo"
+	"FeatherProxy/app/internal/logging"
 
 	"gorm.io/gorm"
 )
 
+var repoLogger = logging.For(logging.Repo)
+
 // protocolsCompatible returns true if source and target protocols can be linked (http and https are allowed together).
 func protocolsCompatible(source, target string) bool {
 	if source == target {
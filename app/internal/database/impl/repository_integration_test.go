@@ -102,6 +102,7 @@ func TestRepositoryIntegration_CRUD_and_FindRoute(t *testing.T) {
 		Mode:             "allow_only",
 		ClientIPHeader:   "X-Forwarded-For",
 		AllowList:        []string{"192.168.1.0/24"},
+		TrustedProxies:   []string{"10.0.0.0/8"},
 	}
 	if err := r.SetACLOptions(acl); err != nil {
 		t.Fatalf("SetACLOptions: %v", err)
@@ -113,4 +114,7 @@ func TestRepositoryIntegration_CRUD_and_FindRoute(t *testing.T) {
 	if gotACL.Mode != "allow_only" || gotACL.ClientIPHeader != "X-Forwarded-For" || len(gotACL.AllowList) != 1 || gotACL.AllowList[0] != "192.168.1.0/24" {
 		t.Errorf("GetACLOptions: got %+v", gotACL)
 	}
+	if len(gotACL.TrustedProxies) != 1 || gotACL.TrustedProxies[0] != "10.0.0.0/8" {
+		t.Errorf("GetACLOptions trusted proxies: got %v", gotACL.TrustedProxies)
+	}
 }
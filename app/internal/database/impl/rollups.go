@@ -0,0 +1,315 @@
+package impl
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"FeatherProxy/app/internal/database/objects"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// rollupTables describes each rollup: its table, the table it is built from, and how much source time one
+// rollup transaction covers (bounding memory and lock time when catching up).
+var rollupTables = map[string]struct {
+	table, source string
+	chunk         time.Duration
+}{
+	schema.RollupMinute: {"proxy_stats_minute", "proxy_stats", 6 * time.Hour},
+	schema.RollupHour:   {"proxy_stats_hour", "proxy_stats_minute", 7 * 24 * time.Hour},
+}
+
+type rollupKey struct {
+	bucket                time.Time
+	source, route, target uuid.UUID
+	outcome               string
+}
+
+// rollupAcc accumulates one rollup row; hist is decoded while accumulating.
+type rollupAcc struct {
+	row  objects.StatsRollup
+	hist []int64
+}
+
+// RollupProxyStats aggregates raw stats before until into minute rollups, then complete hours of minute
+// rollups into hour rollups. Each resolution resumes from its watermark; the first run starts at the
+// oldest source row. Buckets are rewritten whole, so a run interrupted mid-way is safe to repeat.
+func (r *repository) RollupProxyStats(until time.Time) error {
+	if err := r.rollup(schema.RollupMinute, until); err != nil {
+		return err
+	}
+	wm, ok, err := r.rollupWatermark(schema.RollupMinute)
+	if err != nil || !ok {
+		return err
+	}
+	return r.rollup(schema.RollupHour, wm)
+}
+
+// DeleteStatsRollupsOlderThan deletes rollup rows of the resolution whose bucket starts before until.
+func (r *repository) DeleteStatsRollupsOlderThan(resolution string, until time.Time) (int64, error) {
+	t, ok := rollupTables[resolution]
+	if !ok {
+		return 0, errors.New("stats: unknown rollup resolution " + resolution)
+	}
+	result := r.db.Table(t.table).Where("bucket_start < ?", until.UTC()).Delete(&objects.StatsRollup{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *repository) rollupWatermark(resolution string) (time.Time, bool, error) {
+	var w []objects.StatsRollupWatermark // Find, not Take: no rollup yet is normal and should not be logged
+	if err := r.db.Where("resolution = ?", resolution).Limit(1).Find(&w).Error; err != nil {
+		return time.Time{}, false, err
+	}
+	if len(w) == 0 {
+		return time.Time{}, false, nil
+	}
+	return w[0].Watermark.UTC(), true, nil
+}
+
+func (r *repository) rollup(resolution string, until time.Time) error {
+	t := rollupTables[resolution]
+	size := schema.RollupSizes[resolution]
+	end := until.UTC().Truncate(size)
+	start, ok, err := r.rollupWatermark(resolution)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		col := "bucket_start"
+		if t.source == "proxy_stats" {
+			col = "timestamp"
+		}
+		var oldest []time.Time
+		if err := r.db.Table(t.source).Order(col).Limit(1).Pluck(col, &oldest).Error; err != nil {
+			return err
+		}
+		start = end
+		if len(oldest) > 0 && oldest[0].Before(end) {
+			start = oldest[0].UTC().Truncate(size)
+		}
+	}
+	for {
+		chunkEnd := start.Add(t.chunk)
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+		var rows []objects.StatsRollup
+		lowered := false
+		if start.Before(chunkEnd) {
+			if rows, err = r.aggregateRollup(resolution, start, chunkEnd); err != nil {
+				return err
+			}
+		}
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Table(t.table).Where("bucket_start >= ? AND bucket_start < ?", start, chunkEnd).Delete(&objects.StatsRollup{}).Error; err != nil {
+				return err
+			}
+			if len(rows) > 0 {
+				if err := tx.Table(t.table).CreateInBatches(rows, 500).Error; err != nil {
+					return err
+				}
+			}
+			if !ok {
+				ok = true
+				return tx.Create(&objects.StatsRollupWatermark{Resolution: resolution, Watermark: chunkEnd}).Error
+			}
+			// Advance only from start: CreateProxyStats may have lowered the watermark for late stats
+			// meanwhile, and the lowered one must stay so the next run rebuilds their buckets.
+			res := tx.Model(&objects.StatsRollupWatermark{}).Where("resolution = ? AND watermark = ?", resolution, start).Update("watermark", chunkEnd)
+			if res.Error == nil && res.RowsAffected == 0 {
+				lowered = true
+			}
+			return res.Error
+		})
+		if err != nil {
+			return err
+		}
+		repoLogger.Debug("stats rolled up", "resolution", resolution, "from", start, "to", chunkEnd, "rows", len(rows))
+		if lowered {
+			return nil
+		}
+		if !chunkEnd.Before(end) {
+			return nil
+		}
+		start = chunkEnd
+	}
+}
+
+// aggregateRollup builds the rollup rows of [start, end) from the resolution's source table.
+func (r *repository) aggregateRollup(resolution string, start, end time.Time) ([]objects.StatsRollup, error) {
+	size := schema.RollupSizes[resolution]
+	acc := map[rollupKey]*rollupAcc{}
+	get := func(k rollupKey) *rollupAcc {
+		a := acc[k]
+		if a == nil {
+			a = &rollupAcc{
+				row:  objects.StatsRollup{BucketStart: k.bucket, SourceServerUUID: k.source, RouteUUID: k.route, TargetServerUUID: k.target, Outcome: k.outcome},
+				hist: make([]int64, len(schema.RollupLatencyBoundsMs)+1),
+			}
+			acc[k] = a
+		}
+		return a
+	}
+
+	if resolution == schema.RollupMinute {
+		rows, err := r.db.Model(&objects.ProxyStat{}).
+			Select("timestamp, source_server_uuid, route_uuid, target_server_uuid, outcome, status_code, duration_ms, sample_weight").
+			Where("timestamp >= ? AND timestamp < ?", start, end).Rows()
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var k rollupKey
+			var ts time.Time
+			var status, dur sql.NullInt64
+			var w int64 // sample weight
+			if err := rows.Scan(&ts, &k.source, &k.route, &k.target, &k.outcome, &status, &dur, &w); err != nil {
+				return nil, err
+			}
+			k.bucket = ts.UTC().Truncate(size)
+			a := get(k)
+			a.row.Count += w
+			if status.Valid {
+				switch status.Int64 / 100 {
+				case 2:
+					a.row.Status2xx += w
+				case 3:
+					a.row.Status3xx += w
+				case 4:
+					a.row.Status4xx += w
+				case 5:
+					a.row.Status5xx += w
+				}
+			}
+			if dur.Valid {
+				a.row.DurationCount += w
+				a.row.DurationSumMs += dur.Int64 * w
+				a.row.DurationMaxMs = max(a.row.DurationMaxMs, dur.Int64)
+				a.hist[sort.Search(len(schema.RollupLatencyBoundsMs), func(i int) bool { return schema.RollupLatencyBoundsMs[i] >= dur.Int64 })] += w
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		var minutes []objects.StatsRollup
+		if err := r.db.Table(rollupTables[schema.RollupMinute].table).Where("bucket_start >= ? AND bucket_start < ?", start, end).Find(&minutes).Error; err != nil {
+			return nil, err
+		}
+		for _, m := range minutes {
+			a := get(rollupKey{bucket: m.BucketStart.UTC().Truncate(size), source: m.SourceServerUUID, route: m.RouteUUID, target: m.TargetServerUUID, outcome: m.Outcome})
+			a.row.Count += m.Count
+			a.row.Status2xx += m.Status2xx
+			a.row.Status3xx += m.Status3xx
+			a.row.Status4xx += m.Status4xx
+			a.row.Status5xx += m.Status5xx
+			a.row.DurationCount += m.DurationCount
+			a.row.DurationSumMs += m.DurationSumMs
+			a.row.DurationMaxMs = max(a.row.DurationMaxMs, m.DurationMaxMs)
+			for i, n := range decodeRollupHistogram(m.LatencyHistogram) {
+				if i < len(a.hist) {
+					a.hist[i] += n
+				}
+			}
+		}
+	}
+
+	out := make([]objects.StatsRollup, 0, len(acc))
+	for _, a := range acc {
+		a.row.LatencyHistogram = encodeRollupHistogram(a.hist)
+		out = append(out, a.row)
+	}
+	return out, nil
+}
+
+func encodeRollupHistogram(counts []int64) string {
+	parts := make([]string, len(counts))
+	for i, n := range counts {
+		parts[i] = strconv.FormatInt(n, 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+func decodeRollupHistogram(s string) []int64 {
+	if s == "" {
+		return nil
+	}
+	parts := strings.Split(s, ",")
+	out := make([]int64, len(parts))
+	for i, p := range parts {
+		out[i], _ = strconv.ParseInt(p, 10, 64)
+	}
+	return out
+}
+
+// statsPlan splits a stats query: buckets in [from, to) are read from a rollup table, the rest of the
+// window (before from, and from to onwards) from proxy_stats. With ok false everything is raw.
+type statsPlan struct {
+	ok       bool
+	table    string
+	from, to time.Time
+}
+
+// planStats picks the coarsest rollup that can answer a query over [since, until] (nil: unbounded) at the
+// given granularity (0: any; otherwise the rollup size must divide it) and that has rolled up at least one
+// whole bucket inside the window. Raw rows cover the partial buckets at the edges and anything newer than
+// the watermark, so results are exact where raw rows are still retained; beyond raw retention, the rollups
+// alone answer.
+func (r *repository) planStats(since, until *time.Time, granularity time.Duration) statsPlan {
+	for _, res := range []string{schema.RollupHour, schema.RollupMinute} {
+		size := schema.RollupSizes[res]
+		if granularity > 0 && granularity%size != 0 {
+			continue
+		}
+		wm, ok, err := r.rollupWatermark(res)
+		if err != nil || !ok {
+			continue
+		}
+		var from time.Time
+		if since != nil {
+			from = since.UTC().Truncate(size)
+			if from.Before(since.UTC()) {
+				from = from.Add(size)
+			}
+		}
+		to := wm
+		if until != nil && until.UTC().Before(to) {
+			to = until.UTC().Truncate(size)
+		}
+		if from.Before(to) {
+			return statsPlan{ok: true, table: rollupTables[res].table, from: from, to: to}
+		}
+	}
+	return statsPlan{}
+}
+
+// excludeRollup restricts a proxy_stats query to the rows the plan does not read from its rollup.
+func (p statsPlan) excludeRollup(q *gorm.DB) *gorm.DB {
+	if !p.ok {
+		return q
+	}
+	return q.Where("(timestamp < ? OR timestamp >= ?)", p.from, p.to)
+}
+
+// rollupQuery starts a query on the plan's rollup table over its [from, to) buckets, with the filter's
+// server, route and outcome conditions.
+func (p statsPlan) rollupQuery(db *gorm.DB, f schema.StatsFilter) *gorm.DB {
+	f.Since, f.Until = nil, nil
+	q := db.Table(p.table).Where("bucket_start >= ? AND bucket_start < ?", p.from, p.to)
+	return applyStatsFilter(q, f)
+}
+
+// epochExpr returns the SQL for a timestamp column as Unix seconds.
+func (r *repository) epochExpr(col string) string {
+	if r.db.Dialector.Name() == "sqlite" {
+		return "CAST(strftime('%s', " + col + ") AS INTEGER)"
+	}
+	return "CAST(EXTRACT(EPOCH FROM " + col + ") AS BIGINT)"
+}
@@ -1,18 +1,34 @@
 package impl
 
 import (
+	"strings"
+
 	"FeatherProxy/app/internal/database/objects"
 	"FeatherProxy/app/internal/database/repo"
 	"FeatherProxy/app/internal/database/schema"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 func (r *repository) CreateRoute(route schema.Route) error {
+	if err := r.checkRouteServers(route); err != nil {
+		return err
+	}
+	dbRoute := objects.SchemaToRoute(route)
+	return r.invalidate(r.db.Create(&dbRoute).Error, []string{keyListRoutes}, []string{keyPrefixRoute})
+}
+
+// checkRouteServers verifies that the route's source server exists and, for proxy routes, that the
+// target server exists and speaks a compatible protocol. Non-proxy routes have no target server.
+func (r *repository) checkRouteServers(route schema.Route) error {
 	source, err := r.GetSourceServer(route.SourceServerUUID)
 	if err != nil {
 		return err
 	}
+	if !route.ProxiesToTarget() {
+		return nil
+	}
 	target, err := r.GetTargetServer(route.TargetServerUUID)
 	if err != nil {
 		return err
@@ -20,8 +36,30 @@ func (r *repository) CreateRoute(route schema.Route) error {
 	if !protocolsCompatible(source.Protocol, target.Protocol) {
 		return repo.ErrProtocolMismatch
 	}
-	dbRoute := objects.SchemaToRoute(route)
-	return r.invalidate(r.db.Create(&dbRoute).Error, []string{keyListRoutes}, []string{keyPrefixRoute})
+	return nil
+}
+
+// checkRouteTargets verifies that extra targets of a route (traffic split variants, pool members) exist
+// and speak a protocol compatible with the route's source server, as checkRouteServers does for its own target.
+func (r *repository) checkRouteTargets(routeUUID uuid.UUID, targets []uuid.UUID) error {
+	route, err := r.GetRoute(routeUUID)
+	if err != nil {
+		return err
+	}
+	source, err := r.GetSourceServer(route.SourceServerUUID)
+	if err != nil {
+		return err
+	}
+	for _, id := range targets {
+		target, err := r.GetTargetServer(id)
+		if err != nil {
+			return err
+		}
+		if !protocolsCompatible(source.Protocol, target.Protocol) {
+			return repo.ErrProtocolMismatch
+		}
+	}
+	return nil
 }
 
 func (r *repository) GetRoute(routeUUID uuid.UUID) (schema.Route, error) {
@@ -35,25 +73,20 @@ func (r *repository) GetRoute(routeUUID uuid.UUID) (schema.Route, error) {
 }
 
 func (r *repository) UpdateRoute(route schema.Route) error {
-	source, err := r.GetSourceServer(route.SourceServerUUID)
-	if err != nil {
+	if err := r.checkRouteServers(route); err != nil {
 		return err
 	}
-	target, err := r.GetTargetServer(route.TargetServerUUID)
-	if err != nil {
-		return err
-	}
-	if !protocolsCompatible(source.Protocol, target.Protocol) {
-		return repo.ErrProtocolMismatch
-	}
 	dbRoute := objects.SchemaToRoute(route)
 	return r.invalidate(r.db.Save(&dbRoute).Error, []string{keyListRoutes}, []string{keyPrefixRoute})
 }
 
 func (r *repository) DeleteRoute(routeUUID uuid.UUID) error {
+	_ = r.db.Delete(&objects.TrafficSplit{RouteUUID: routeUUID})
+	_ = r.db.Delete(&objects.RouteBalancing{RouteUUID: routeUUID})
+	_ = r.db.Delete(&objects.Maintenance{OwnerUUID: routeUUID, Scope: schema.MaintenanceScopeRoute})
 	err := r.db.Delete(&objects.Route{RouteUUID: routeUUID}).Error
 	return r.invalidate(err,
-		[]string{keyListRoutes, keyRouteSourceAuths(routeUUID), keyTargetAuthForRoute(routeUUID)},
+		[]string{keyListRoutes, keyRouteSourceAuths(routeUUID), keyTargetAuthForRoute(routeUUID), keyTrafficSplit(routeUUID), keyRouteBalancing(routeUUID), keyMaintenance(schema.MaintenanceScopeRoute, routeUUID)},
 		[]string{keyPrefixRoute})
 }
 
@@ -91,12 +124,41 @@ func (r *repository) GetRouteFromTargetPath(targetPath string) (schema.Route, er
 	})
 }
 
+// FindRouteBySourceMethodPath returns the route whose source path equals sourcePath or, failing that,
+// the most specific route whose {name} pattern matches it (fewest placeholders wins). The routes of a
+// source server and method are cached as one table and matched in memory, so unmatched paths cost no query.
 func (r *repository) FindRouteBySourceMethodPath(sourceServerUUID uuid.UUID, method, sourcePath string) (schema.Route, error) {
-	return getCached(r, keyRouteMethodPath(sourceServerUUID, method, sourcePath), func() (schema.Route, error) {
-		var dbRoute objects.Route
-		if err := r.db.Where("source_server_uuid = ? AND method = ? AND source_path = ?", sourceServerUUID, method, sourcePath).First(&dbRoute).Error; err != nil {
-			return schema.Route{}, err
+	routes, err := getCached(r, keyRouteMethodTable(sourceServerUUID, method), func() ([]schema.Route, error) {
+		var list []objects.Route
+		if err := r.db.Where("source_server_uuid = ? AND method = ?", sourceServerUUID, method).Find(&list).Error; err != nil {
+			return nil, err
 		}
-		return objects.RouteToSchema(&dbRoute), nil
+		out := make([]schema.Route, len(list))
+		for i := range list {
+			out[i] = objects.RouteToSchema(&list[i])
+		}
+		return out, nil
 	})
+	if err != nil {
+		return schema.Route{}, err
+	}
+	best := -1
+	for i := range routes {
+		if routes[i].SourcePath == sourcePath {
+			return routes[i], nil
+		}
+		if !strings.Contains(routes[i].SourcePath, "{") {
+			continue
+		}
+		if _, ok := schema.MatchPath(routes[i].SourcePath, sourcePath); !ok {
+			continue
+		}
+		if best < 0 || strings.Count(routes[i].SourcePath, "{") < strings.Count(routes[best].SourcePath, "{") {
+			best = i
+		}
+	}
+	if best < 0 {
+		return schema.Route{}, gorm.ErrRecordNotFound
+	}
+	return routes[best], nil
 }
@@ -2,7 +2,6 @@ package impl
 
 import (
 	"errors"
-	"log"
 
 	"FeatherProxy/app/internal/database/objects"
 	"FeatherProxy/app/internal/database/schema"
@@ -13,25 +12,25 @@ import (
 
 func (r *repository) ListSourceAuthsForRoute(routeUUID uuid.UUID) ([]schema.RouteSourceAuth, error) {
 	return getCached(r, keyRouteSourceAuths(routeUUID), func() ([]schema.RouteSourceAuth, error) {
-		log.Printf("route_auth/repo: ListSourceAuthsForRoute route=%s", routeUUID)
+		repoLogger.Debug("ListSourceAuthsForRoute", "route", routeUUID)
 		var list []objects.RouteSourceAuth
 		if err := r.db.Where("route_uuid = ?", routeUUID).Order("position").Find(&list).Error; err != nil {
-			log.Printf("route_auth/repo: ListSourceAuthsForRoute error: %v", err)
+			repoLogger.Error("ListSourceAuthsForRoute failed", "route", routeUUID, "error", err)
 			return nil, err
 		}
 		out := make([]schema.RouteSourceAuth, len(list))
 		for i := range list {
 			out[i] = objects.RouteSourceAuthToSchema(&list[i])
 		}
-		log.Printf("route_auth/repo: ListSourceAuthsForRoute ok route=%s count=%d", routeUUID, len(out))
+		repoLogger.Debug("ListSourceAuthsForRoute ok", "route", routeUUID, "count", len(out))
 		return out, nil
 	})
 }
 
 func (r *repository) SetSourceAuthsForRoute(routeUUID uuid.UUID, authUUIDs []uuid.UUID) error {
-	log.Printf("route_auth/repo: SetSourceAuthsForRoute route=%s count=%d", routeUUID, len(authUUIDs))
+	repoLogger.Debug("SetSourceAuthsForRoute", "route", routeUUID, "count", len(authUUIDs))
 	if err := r.db.Unscoped().Where("route_uuid = ?", routeUUID).Delete(&objects.RouteSourceAuth{}).Error; err != nil {
-		log.Printf("route_auth/repo: SetSourceAuthsForRoute delete error: %v", err)
+		repoLogger.Error("SetSourceAuthsForRoute delete failed", "route", routeUUID, "error", err)
 		return err
 	}
 	for i, authUUID := range authUUIDs {
@@ -41,27 +40,27 @@ func (r *repository) SetSourceAuthsForRoute(routeUUID uuid.UUID, authUUIDs []uui
 			Position:           i,
 		}
 		if err := r.db.Create(&obj).Error; err != nil {
-			log.Printf("route_auth/repo: SetSourceAuthsForRoute create error: %v", err)
+			repoLogger.Error("SetSourceAuthsForRoute create failed", "route", routeUUID, "error", err)
 			return err
 		}
 	}
-	log.Printf("route_auth/repo: SetSourceAuthsForRoute ok route=%s", routeUUID)
+	repoLogger.Debug("SetSourceAuthsForRoute ok", "route", routeUUID)
 	return r.invalidate(nil, []string{keyRouteSourceAuths(routeUUID)}, nil)
 }
 
 func (r *repository) GetTargetAuthForRoute(routeUUID uuid.UUID) (uuid.UUID, bool, error) {
 	v, err := getCached(r, keyTargetAuthForRoute(routeUUID), func() (targetAuthCached, error) {
-		log.Printf("route_auth/repo: GetTargetAuthForRoute route=%s", routeUUID)
+		repoLogger.Debug("GetTargetAuthForRoute", "route", routeUUID)
 		var obj objects.RouteTargetAuth
 		if err := r.db.Where("route_uuid = ?", routeUUID).First(&obj).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				log.Printf("route_auth/repo: GetTargetAuthForRoute route=%s no target auth", routeUUID)
+				repoLogger.Debug("GetTargetAuthForRoute: no target auth", "route", routeUUID)
 				return targetAuthCached{}, nil
 			}
-			log.Printf("route_auth/repo: GetTargetAuthForRoute error: %v", err)
+			repoLogger.Error("GetTargetAuthForRoute failed", "route", routeUUID, "error", err)
 			return targetAuthCached{}, err
 		}
-		log.Printf("route_auth/repo: GetTargetAuthForRoute ok route=%s auth=%s", routeUUID, obj.AuthenticationUUID)
+		repoLogger.Debug("GetTargetAuthForRoute ok", "route", routeUUID, "auth", obj.AuthenticationUUID)
 		return targetAuthCached{AuthUUID: obj.AuthenticationUUID, OK: true}, nil
 	})
 	return v.AuthUUID, v.OK, err
@@ -72,9 +71,9 @@ func (r *repository) SetTargetAuthForRoute(routeUUID uuid.UUID, authUUID *uuid.U
 	if authUUID != nil && *authUUID != uuid.Nil {
 		authStr = authUUID.String()
 	}
-	log.Printf("route_auth/repo: SetTargetAuthForRoute route=%s auth=%s", routeUUID, authStr)
+	repoLogger.Debug("SetTargetAuthForRoute", "route", routeUUID, "auth", authStr)
 	if err := r.db.Unscoped().Where("route_uuid = ?", routeUUID).Delete(&objects.RouteTargetAuth{}).Error; err != nil {
-		log.Printf("route_auth/repo: SetTargetAuthForRoute delete error: %v", err)
+		repoLogger.Error("SetTargetAuthForRoute delete failed", "route", routeUUID, "error", err)
 		return err
 	}
 	if authUUID != nil && *authUUID != uuid.Nil {
@@ -83,17 +82,17 @@ func (r *repository) SetTargetAuthForRoute(routeUUID uuid.UUID, authUUID *uuid.U
 			AuthenticationUUID: *authUUID,
 		}).Error
 		if err != nil {
-			log.Printf("route_auth/repo: SetTargetAuthForRoute create error: %v", err)
+			repoLogger.Error("SetTargetAuthForRoute create failed", "route", routeUUID, "error", err)
 			return err
 		}
 	}
-	log.Printf("route_auth/repo: SetTargetAuthForRoute ok route=%s", routeUUID)
+	repoLogger.Debug("SetTargetAuthForRoute ok", "route", routeUUID)
 	return r.invalidate(nil, []string{keyTargetAuthForRoute(routeUUID)}, nil)
 }
 
 // GetTargetAuthenticationWithPlainToken is not cached (security: decrypted token).
 func (r *repository) GetTargetAuthenticationWithPlainToken(routeUUID uuid.UUID) (schema.Authentication, bool, error) {
-	log.Printf("route_auth/repo: GetTargetAuthenticationWithPlainToken route=%s", routeUUID)
+	repoLogger.Debug("GetTargetAuthenticationWithPlainToken", "route", routeUUID)
 	authUUID, ok, err := r.GetTargetAuthForRoute(routeUUID)
 	if err != nil || !ok {
 		return schema.Authentication{}, false, err
@@ -102,6 +101,6 @@ func (r *repository) GetTargetAuthenticationWithPlainToken(routeUUID uuid.UUID)
 	if err != nil {
 		return schema.Authentication{}, false, err
 	}
-	log.Printf("route_auth/repo: GetTargetAuthenticationWithPlainToken ok route=%s auth=%s", routeUUID, authUUID)
+	repoLogger.Debug("GetTargetAuthenticationWithPlainToken ok", "route", routeUUID, "auth", authUUID)
 	return auth, true, nil
 }
@@ -0,0 +1,49 @@
+package impl
+
+import (
+	"time"
+
+	"FeatherProxy/app/internal/database/objects"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+func (r *repository) GetRouteBalancing(routeUUID uuid.UUID) (schema.RouteBalancing, error) {
+	return getCachedOptional(r, keyRouteBalancing(routeUUID), func() (schema.RouteBalancing, error) {
+		var obj objects.RouteBalancing
+		if err := r.db.Where("route_uuid = ?", routeUUID).First(&obj).Error; err != nil {
+			return schema.RouteBalancing{}, err
+		}
+		return objects.RouteBalancingToSchema(&obj), nil
+	})
+}
+
+func (r *repository) SetRouteBalancing(b schema.RouteBalancing) error {
+	if err := r.checkRouteTargets(b.RouteUUID, b.TargetServerUUIDs); err != nil {
+		return err
+	}
+	now := time.Now()
+	var obj objects.RouteBalancing
+	err := r.db.Where("route_uuid = ?", b.RouteUUID).First(&obj).Error
+	if err != nil {
+		// Create new
+		obj = objects.SchemaToRouteBalancing(b)
+		if obj.CreatedAt.IsZero() {
+			obj.CreatedAt = now
+		}
+		if obj.UpdatedAt.IsZero() {
+			obj.UpdatedAt = now
+		}
+		return r.invalidate(r.db.Create(&obj).Error, []string{keyRouteBalancing(b.RouteUUID)}, nil)
+	}
+	// Update existing
+	updated := objects.SchemaToRouteBalancing(b)
+	obj.TargetServerUUIDsJSON = updated.TargetServerUUIDsJSON
+	obj.Affinity = updated.Affinity
+	obj.AffinityKey = updated.AffinityKey
+	obj.CookieName = updated.CookieName
+	obj.CookieTTLSeconds = updated.CookieTTLSeconds
+	obj.UpdatedAt = now
+	return r.invalidate(r.db.Save(&obj).Error, []string{keyRouteBalancing(b.RouteUUID)}, nil)
+}
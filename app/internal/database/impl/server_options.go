@@ -10,7 +10,7 @@ import (
 )
 
 func (r *repository) GetServerOptions(sourceServerUUID uuid.UUID) (schema.ServerOptions, error) {
-	return getCached(r, keyServerOptions(sourceServerUUID), func() (schema.ServerOptions, error) {
+	return getCachedOptional(r, keyServerOptions(sourceServerUUID), func() (schema.ServerOptions, error) {
 		var obj objects.ServerOptions
 		if err := r.db.Where("source_server_uuid = ?", sourceServerUUID).First(&obj).Error; err != nil {
 			return schema.ServerOptions{}, err
@@ -37,6 +37,12 @@ func (r *repository) SetServerOptions(opts schema.ServerOptions) error {
 	// Update existing
 	obj.TLSCertPath = opts.TLSCertPath
 	obj.TLSKeyPath = opts.TLSKeyPath
+	obj.RequestIDHeader = opts.RequestIDHeader
+	obj.ForwardedForHeader = opts.ForwardedForHeader
+	obj.ForwardedProtoHeader = opts.ForwardedProtoHeader
+	obj.ForwardedHostHeader = opts.ForwardedHostHeader
+	obj.ForwardedPortHeader = opts.ForwardedPortHeader
+	obj.ForwardedHeader = opts.ForwardedHeader
 	obj.UpdatedAt = now
 	return r.invalidate(r.db.Save(&obj).Error, []string{keyServerOptions(opts.SourceServerUUID)}, nil)
 }
@@ -30,7 +30,10 @@ func (r *repository) UpdateSourceServer(s schema.SourceServer) error {
 func (r *repository) DeleteSourceServer(id uuid.UUID) error {
 	_ = r.db.Delete(&objects.ServerOptions{SourceServerUUID: id})
 	_ = r.db.Delete(&objects.ACLOptions{SourceServerUUID: id})
-	return r.invalidate(r.db.Delete(&objects.SourceServer{SourceServerUUID: id}).Error, []string{keySourceServer(id), keyListSourceServers, keyServerOptions(id), keyACLOptions(id)}, nil)
+	_ = r.db.Delete(&objects.ErrorPages{SourceServerUUID: id})
+	_ = r.db.Delete(&objects.Maintenance{OwnerUUID: id, Scope: schema.MaintenanceScopeSourceServer})
+	_ = r.db.Delete(&objects.AccessLogOptions{SourceServerUUID: id})
+	return r.invalidate(r.db.Delete(&objects.SourceServer{SourceServerUUID: id}).Error, []string{keySourceServer(id), keyListSourceServers, keyServerOptions(id), keyACLOptions(id), keyErrorPages(id), keyMaintenance(schema.MaintenanceScopeSourceServer, id), keyAccessLogOptions(id)}, nil)
 }
 
 func (r *repository) ListSourceServers() ([]schema.SourceServer, error) {
@@ -0,0 +1,51 @@
+package impl
+
+import (
+	"time"
+
+	"FeatherProxy/app/internal/database/objects"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+func (r *repository) GetTrafficSplit(routeUUID uuid.UUID) (schema.TrafficSplit, error) {
+	return getCachedOptional(r, keyTrafficSplit(routeUUID), func() (schema.TrafficSplit, error) {
+		var obj objects.TrafficSplit
+		if err := r.db.Where("route_uuid = ?", routeUUID).First(&obj).Error; err != nil {
+			return schema.TrafficSplit{}, err
+		}
+		return objects.TrafficSplitToSchema(&obj), nil
+	})
+}
+
+func (r *repository) SetTrafficSplit(split schema.TrafficSplit) error {
+	targets := make([]uuid.UUID, 0, len(split.Rules))
+	for _, rule := range split.Rules {
+		targets = append(targets, rule.TargetServerUUID)
+	}
+	if err := r.checkRouteTargets(split.RouteUUID, targets); err != nil {
+		return err
+	}
+	now := time.Now()
+	var obj objects.TrafficSplit
+	err := r.db.Where("route_uuid = ?", split.RouteUUID).First(&obj).Error
+	if err != nil {
+		// Create new
+		obj = objects.SchemaToTrafficSplit(split)
+		if obj.CreatedAt.IsZero() {
+			obj.CreatedAt = now
+		}
+		if obj.UpdatedAt.IsZero() {
+			obj.UpdatedAt = now
+		}
+		return r.invalidate(r.db.Create(&obj).Error, []string{keyTrafficSplit(split.RouteUUID)}, nil)
+	}
+	// Update existing
+	updated := objects.SchemaToTrafficSplit(split)
+	obj.RulesJSON = updated.RulesJSON
+	obj.Sticky = updated.Sticky
+	obj.StickyCookie = updated.StickyCookie
+	obj.UpdatedAt = now
+	return r.invalidate(r.db.Save(&obj).Error, []string{keyTrafficSplit(split.RouteUUID)}, nil)
+}
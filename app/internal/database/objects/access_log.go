@@ -0,0 +1,79 @@
+package objects
+
+import (
+	"time"
+
+	"FeatherProxy/app/internal/database/schema"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AccessLogOptions is the database object (ORM entity) for the access_log_options table.
+type AccessLogOptions struct {
+	SourceServerUUID uuid.UUID      `gorm:"primaryKey"`
+	Enabled          bool           `gorm:"column:enabled;default:false"`
+	Format           string         `gorm:"column:format;default:common"`
+	Template         string         `gorm:"column:template"`
+	Output           string         `gorm:"column:output;default:stdout"`
+	FilePath         string         `gorm:"column:file_path"`
+	MaxSizeMB        int            `gorm:"column:max_size_mb;default:0"`
+	RotateInterval   string         `gorm:"column:rotate_interval"`
+	MaxBackups       int            `gorm:"column:max_backups;default:0"`
+	MaxAgeDays       int            `gorm:"column:max_age_days;default:0"`
+	SyslogNetwork    string         `gorm:"column:syslog_network"`
+	SyslogAddress    string         `gorm:"column:syslog_address"`
+	SyslogFacility   int            `gorm:"column:syslog_facility;default:16"`
+	SyslogAppName    string         `gorm:"column:syslog_app_name"`
+	CreatedAt        time.Time      `gorm:"not null"`
+	UpdatedAt        time.Time      `gorm:"not null"`
+	DeletedAt        gorm.DeletedAt `gorm:"index"`
+}
+
+// TableName overrides the default table name.
+func (AccessLogOptions) TableName() string {
+	return "access_log_options"
+}
+
+// AccessLogOptionsToSchema maps the database object to the domain schema.
+func AccessLogOptionsToSchema(o *AccessLogOptions) schema.AccessLogOptions {
+	return schema.AccessLogOptions{
+		SourceServerUUID: o.SourceServerUUID,
+		Enabled:          o.Enabled,
+		Format:           o.Format,
+		Template:         o.Template,
+		Output:           o.Output,
+		FilePath:         o.FilePath,
+		MaxSizeMB:        o.MaxSizeMB,
+		RotateInterval:   o.RotateInterval,
+		MaxBackups:       o.MaxBackups,
+		MaxAgeDays:       o.MaxAgeDays,
+		SyslogNetwork:    o.SyslogNetwork,
+		SyslogAddress:    o.SyslogAddress,
+		SyslogFacility:   o.SyslogFacility,
+		SyslogAppName:    o.SyslogAppName,
+		CreatedAt:        o.CreatedAt,
+		UpdatedAt:        o.UpdatedAt,
+	}
+}
+
+// SchemaToAccessLogOptions maps the domain schema to the database object.
+func SchemaToAccessLogOptions(s schema.AccessLogOptions) AccessLogOptions {
+	return AccessLogOptions{
+		SourceServerUUID: s.SourceServerUUID,
+		Enabled:          s.Enabled,
+		Format:           s.Format,
+		Template:         s.Template,
+		Output:           s.Output,
+		FilePath:         s.FilePath,
+		MaxSizeMB:        s.MaxSizeMB,
+		RotateInterval:   s.RotateInterval,
+		MaxBackups:       s.MaxBackups,
+		MaxAgeDays:       s.MaxAgeDays,
+		SyslogNetwork:    s.SyslogNetwork,
+		SyslogAddress:    s.SyslogAddress,
+		SyslogFacility:   s.SyslogFacility,
+		SyslogAppName:    s.SyslogAppName,
+		CreatedAt:        s.CreatedAt,
+		UpdatedAt:        s.UpdatedAt,
+	}
+}
@@ -10,16 +10,17 @@ import (
 )
 
 // ACLOptions is the database object (ORM entity) for the acl_options table.
-// AllowList and DenyList are stored as JSON strings.
+// AllowList, DenyList and TrustedProxies are stored as JSON strings.
 type ACLOptions struct {
-	SourceServerUUID uuid.UUID      `gorm:"primaryKey"`
-	Mode             string         `gorm:"column:mode;default:off"`
-	ClientIPHeader   string         `gorm:"column:client_ip_header"`
-	AllowListJSON    string         `gorm:"column:allow_list"`
-	DenyListJSON     string         `gorm:"column:deny_list"`
-	CreatedAt        time.Time      `gorm:"not null"`
-	UpdatedAt        time.Time      `gorm:"not null"`
-	DeletedAt        gorm.DeletedAt `gorm:"index"`
+	SourceServerUUID   uuid.UUID      `gorm:"primaryKey"`
+	Mode               string         `gorm:"column:mode;default:off"`
+	ClientIPHeader     string         `gorm:"column:client_ip_header"`
+	AllowListJSON      string         `gorm:"column:allow_list"`
+	DenyListJSON       string         `gorm:"column:deny_list"`
+	TrustedProxiesJSON string         `gorm:"column:trusted_proxies"`
+	CreatedAt          time.Time      `gorm:"not null"`
+	UpdatedAt          time.Time      `gorm:"not null"`
+	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
 // TableName overrides the default table name.
@@ -55,6 +56,7 @@ func ACLOptionsToSchema(o *ACLOptions) schema.ACLOptions {
 		ClientIPHeader:   o.ClientIPHeader,
 		AllowList:        parseStringList(o.AllowListJSON),
 		DenyList:         parseStringList(o.DenyListJSON),
+		TrustedProxies:   parseStringList(o.TrustedProxiesJSON),
 		CreatedAt:        o.CreatedAt,
 		UpdatedAt:        o.UpdatedAt,
 	}
@@ -63,12 +65,13 @@ func ACLOptionsToSchema(o *ACLOptions) schema.ACLOptions {
 // SchemaToACLOptions maps the domain schema to the database object.
 func SchemaToACLOptions(s schema.ACLOptions) ACLOptions {
 	return ACLOptions{
-		SourceServerUUID: s.SourceServerUUID,
-		Mode:             s.Mode,
-		ClientIPHeader:   s.ClientIPHeader,
-		AllowListJSON:    marshalStringList(s.AllowList),
-		DenyListJSON:     marshalStringList(s.DenyList),
-		CreatedAt:        s.CreatedAt,
-		UpdatedAt:        s.UpdatedAt,
+		SourceServerUUID:   s.SourceServerUUID,
+		Mode:               s.Mode,
+		ClientIPHeader:     s.ClientIPHeader,
+		AllowListJSON:      marshalStringList(s.AllowList),
+		DenyListJSON:       marshalStringList(s.DenyList),
+		TrustedProxiesJSON: marshalStringList(s.TrustedProxies),
+		CreatedAt:          s.CreatedAt,
+		UpdatedAt:          s.UpdatedAt,
 	}
 }
@@ -0,0 +1,167 @@
+package objects
+
+import (
+	"encoding/json"
+	"time"
+
+	"FeatherProxy/app/internal/database/schema"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AlertRule is the database object (ORM entity) for the alert_rules table.
+// WebhookUUIDs is stored as a JSON array.
+type AlertRule struct {
+	AlertRuleUUID    uuid.UUID      `gorm:"primaryKey"`
+	Name             string         `gorm:"not null"`
+	Enabled          bool           `gorm:"not null;default:true"`
+	Metric           string         `gorm:"not null"`
+	Operator         string         `gorm:"not null"`
+	Threshold        float64        `gorm:"not null"`
+	WindowSeconds    int            `gorm:"not null"`
+	MinRequests      int64          `gorm:"not null;default:0"`
+	SourceServerUUID *uuid.UUID     `gorm:"type:uuid"`
+	RouteUUID        *uuid.UUID     `gorm:"type:uuid"`
+	TargetServerUUID *uuid.UUID     `gorm:"type:uuid"`
+	WebhookUUIDsJSON string         `gorm:"column:webhook_uuids"`
+	CreatedAt        time.Time      `gorm:"not null"`
+	UpdatedAt        time.Time      `gorm:"not null"`
+	DeletedAt        gorm.DeletedAt `gorm:"index"`
+}
+
+// TableName overrides the default table name.
+func (AlertRule) TableName() string {
+	return "alert_rules"
+}
+
+// AlertRuleToSchema maps the database object to the domain schema.
+func AlertRuleToSchema(o *AlertRule) schema.AlertRule {
+	webhooks := []uuid.UUID{}
+	if o.WebhookUUIDsJSON != "" {
+		_ = json.Unmarshal([]byte(o.WebhookUUIDsJSON), &webhooks)
+	}
+	return schema.AlertRule{
+		AlertRuleUUID:    o.AlertRuleUUID,
+		Name:             o.Name,
+		Enabled:          o.Enabled,
+		Metric:           o.Metric,
+		Operator:         o.Operator,
+		Threshold:        o.Threshold,
+		WindowSeconds:    o.WindowSeconds,
+		MinRequests:      o.MinRequests,
+		SourceServerUUID: o.SourceServerUUID,
+		RouteUUID:        o.RouteUUID,
+		TargetServerUUID: o.TargetServerUUID,
+		WebhookUUIDs:     webhooks,
+		CreatedAt:        o.CreatedAt,
+		UpdatedAt:        o.UpdatedAt,
+	}
+}
+
+// SchemaToAlertRule maps the domain schema to the database object.
+func SchemaToAlertRule(s schema.AlertRule) AlertRule {
+	webhooksJSON := "[]"
+	if len(s.WebhookUUIDs) > 0 {
+		b, _ := json.Marshal(s.WebhookUUIDs)
+		webhooksJSON = string(b)
+	}
+	return AlertRule{
+		AlertRuleUUID:    s.AlertRuleUUID,
+		Name:             s.Name,
+		Enabled:          s.Enabled,
+		Metric:           s.Metric,
+		Operator:         s.Operator,
+		Threshold:        s.Threshold,
+		WindowSeconds:    s.WindowSeconds,
+		MinRequests:      s.MinRequests,
+		SourceServerUUID: s.SourceServerUUID,
+		RouteUUID:        s.RouteUUID,
+		TargetServerUUID: s.TargetServerUUID,
+		WebhookUUIDsJSON: webhooksJSON,
+		CreatedAt:        s.CreatedAt,
+		UpdatedAt:        s.UpdatedAt,
+	}
+}
+
+// AlertWebhook is the database object (ORM entity) for the alert_webhooks table.
+type AlertWebhook struct {
+	AlertWebhookUUID uuid.UUID      `gorm:"primaryKey"`
+	Name             string         `gorm:"not null"`
+	URL              string         `gorm:"not null;column:url"`
+	Template         string         `gorm:"column:template"`
+	ContentType      string         `gorm:"column:content_type"`
+	CreatedAt        time.Time      `gorm:"not null"`
+	UpdatedAt        time.Time      `gorm:"not null"`
+	DeletedAt        gorm.DeletedAt `gorm:"index"`
+}
+
+// TableName overrides the default table name.
+func (AlertWebhook) TableName() string {
+	return "alert_webhooks"
+}
+
+// AlertWebhookToSchema maps the database object to the domain schema.
+func AlertWebhookToSchema(o *AlertWebhook) schema.AlertWebhook {
+	return schema.AlertWebhook{
+		AlertWebhookUUID: o.AlertWebhookUUID,
+		Name:             o.Name,
+		URL:              o.URL,
+		Template:         o.Template,
+		ContentType:      o.ContentType,
+		CreatedAt:        o.CreatedAt,
+		UpdatedAt:        o.UpdatedAt,
+	}
+}
+
+// SchemaToAlertWebhook maps the domain schema to the database object.
+func SchemaToAlertWebhook(s schema.AlertWebhook) AlertWebhook {
+	return AlertWebhook{
+		AlertWebhookUUID: s.AlertWebhookUUID,
+		Name:             s.Name,
+		URL:              s.URL,
+		Template:         s.Template,
+		ContentType:      s.ContentType,
+		CreatedAt:        s.CreatedAt,
+		UpdatedAt:        s.UpdatedAt,
+	}
+}
+
+// AlertSilence is the database object (ORM entity) for the alert_silences table.
+// Expired silences are hard-deleted by the alerting service.
+type AlertSilence struct {
+	AlertSilenceUUID uuid.UUID  `gorm:"primaryKey"`
+	AlertRuleUUID    *uuid.UUID `gorm:"type:uuid;index"`
+	StartsAt         time.Time  `gorm:"not null"`
+	EndsAt           time.Time  `gorm:"not null;index"`
+	Comment          string
+	CreatedAt        time.Time `gorm:"not null"`
+}
+
+// TableName overrides the default table name.
+func (AlertSilence) TableName() string {
+	return "alert_silences"
+}
+
+// AlertSilenceToSchema maps the database object to the domain schema.
+func AlertSilenceToSchema(o *AlertSilence) schema.AlertSilence {
+	return schema.AlertSilence{
+		AlertSilenceUUID: o.AlertSilenceUUID,
+		AlertRuleUUID:    o.AlertRuleUUID,
+		StartsAt:         o.StartsAt,
+		EndsAt:           o.EndsAt,
+		Comment:          o.Comment,
+		CreatedAt:        o.CreatedAt,
+	}
+}
+
+// SchemaToAlertSilence maps the domain schema to the database object.
+func SchemaToAlertSilence(s schema.AlertSilence) AlertSilence {
+	return AlertSilence{
+		AlertSilenceUUID: s.AlertSilenceUUID,
+		AlertRuleUUID:    s.AlertRuleUUID,
+		StartsAt:         s.StartsAt,
+		EndsAt:           s.EndsAt,
+		Comment:          s.Comment,
+		CreatedAt:        s.CreatedAt,
+	}
+}
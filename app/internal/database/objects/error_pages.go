@@ -0,0 +1,57 @@
+package objects
+
+import (
+	"encoding/json"
+	"time"
+
+	"FeatherProxy/app/internal/database/schema"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrorPages is the database object (ORM entity) for the error_pages table.
+// Pages is stored as a JSON string keyed by error kind.
+type ErrorPages struct {
+	SourceServerUUID   uuid.UUID      `gorm:"primaryKey"`
+	PagesJSON          string         `gorm:"column:pages"`
+	ReplaceUpstream5xx bool           `gorm:"column:replace_upstream_5xx;default:false"`
+	CreatedAt          time.Time      `gorm:"not null"`
+	UpdatedAt          time.Time      `gorm:"not null"`
+	DeletedAt          gorm.DeletedAt `gorm:"index"`
+}
+
+// TableName overrides the default table name.
+func (ErrorPages) TableName() string {
+	return "error_pages"
+}
+
+// ErrorPagesToSchema maps the database object to the domain schema.
+func ErrorPagesToSchema(o *ErrorPages) schema.ErrorPages {
+	pages := map[string]schema.ErrorPage{}
+	if o.PagesJSON != "" {
+		_ = json.Unmarshal([]byte(o.PagesJSON), &pages)
+	}
+	return schema.ErrorPages{
+		SourceServerUUID:   o.SourceServerUUID,
+		Pages:              pages,
+		ReplaceUpstream5xx: o.ReplaceUpstream5xx,
+		CreatedAt:          o.CreatedAt,
+		UpdatedAt:          o.UpdatedAt,
+	}
+}
+
+// SchemaToErrorPages maps the domain schema to the database object.
+func SchemaToErrorPages(s schema.ErrorPages) ErrorPages {
+	pagesJSON := "{}"
+	if len(s.Pages) > 0 {
+		b, _ := json.Marshal(s.Pages)
+		pagesJSON = string(b)
+	}
+	return ErrorPages{
+		SourceServerUUID:   s.SourceServerUUID,
+		PagesJSON:          pagesJSON,
+		ReplaceUpstream5xx: s.ReplaceUpstream5xx,
+		CreatedAt:          s.CreatedAt,
+		UpdatedAt:          s.UpdatedAt,
+	}
+}
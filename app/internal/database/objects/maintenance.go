@@ -0,0 +1,69 @@
+package objects
+
+import (
+	"time"
+
+	"FeatherProxy/app/internal/database/schema"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Maintenance is the database object (ORM entity) for the maintenance table.
+// AllowList is stored as a JSON string.
+type Maintenance struct {
+	OwnerUUID         uuid.UUID      `gorm:"primaryKey"`
+	Scope             string         `gorm:"primaryKey;column:scope"`
+	Enabled           bool           `gorm:"column:enabled;default:false"`
+	Body              string         `gorm:"column:body"`
+	ContentType       string         `gorm:"column:content_type"`
+	RetryAfterSeconds int            `gorm:"column:retry_after_seconds;default:0"`
+	StartsAt          *time.Time     `gorm:"column:starts_at"`
+	EndsAt            *time.Time     `gorm:"column:ends_at"`
+	AllowListJSON     string         `gorm:"column:allow_list"`
+	CreatedAt         time.Time      `gorm:"not null"`
+	UpdatedAt         time.Time      `gorm:"not null"`
+	DeletedAt         gorm.DeletedAt `gorm:"index"`
+}
+
+// TableName overrides the default table name.
+func (Maintenance) TableName() string {
+	return "maintenance"
+}
+
+// MaintenanceToSchema maps the database object to the domain schema.
+func MaintenanceToSchema(o *Maintenance) schema.Maintenance {
+	allow := parseStringList(o.AllowListJSON)
+	if allow == nil {
+		allow = []string{}
+	}
+	return schema.Maintenance{
+		OwnerUUID:         o.OwnerUUID,
+		Scope:             o.Scope,
+		Enabled:           o.Enabled,
+		Body:              o.Body,
+		ContentType:       o.ContentType,
+		RetryAfterSeconds: o.RetryAfterSeconds,
+		StartsAt:          o.StartsAt,
+		EndsAt:            o.EndsAt,
+		AllowList:         allow,
+		CreatedAt:         o.CreatedAt,
+		UpdatedAt:         o.UpdatedAt,
+	}
+}
+
+// SchemaToMaintenance maps the domain schema to the database object.
+func SchemaToMaintenance(s schema.Maintenance) Maintenance {
+	return Maintenance{
+		OwnerUUID:         s.OwnerUUID,
+		Scope:             s.Scope,
+		Enabled:           s.Enabled,
+		Body:              s.Body,
+		ContentType:       s.ContentType,
+		RetryAfterSeconds: s.RetryAfterSeconds,
+		StartsAt:          s.StartsAt,
+		EndsAt:            s.EndsAt,
+		AllowListJSON:     marshalStringList(s.AllowList),
+		CreatedAt:         s.CreatedAt,
+		UpdatedAt:         s.UpdatedAt,
+	}
+}
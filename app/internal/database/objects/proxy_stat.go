@@ -9,17 +9,37 @@ import (
 )
 
 // ProxyStat is the database object (ORM entity) for the proxy_stats table.
+// The composite indexes back the filtered, keyset-paginated listing (ListProxyStats): (timestamp, id) for
+// paging by time, scope and status columns followed by timestamp, and (duration_ms, id) for the slowest sort.
 type ProxyStat struct {
-	ID                 uuid.UUID  `gorm:"primaryKey;type:uuid"`
-	Timestamp          time.Time  `gorm:"not null;index"`
-	SourceServerUUID   uuid.UUID  `gorm:"not null;index"`
-	RouteUUID          uuid.UUID  `gorm:"not null;index"`
-	TargetServerUUID   uuid.UUID  `gorm:"not null;index"`
+	ID                 uuid.UUID  `gorm:"primaryKey;type:uuid;index:idx_proxy_stats_time_id,priority:2;index:idx_proxy_stats_duration_id,priority:2"`
+	Timestamp          time.Time  `gorm:"not null;index;index:idx_proxy_stats_time_id,priority:1;index:idx_proxy_stats_source_time,priority:2;index:idx_proxy_stats_route_time,priority:2;index:idx_proxy_stats_target_time,priority:2;index:idx_proxy_stats_status_time,priority:2"`
+	SourceServerUUID   uuid.UUID  `gorm:"not null;index;index:idx_proxy_stats_source_time,priority:1"`
+	RouteUUID          uuid.UUID  `gorm:"not null;index;index:idx_proxy_stats_route_time,priority:1"`
+	TargetServerUUID   uuid.UUID  `gorm:"not null;index;index:idx_proxy_stats_target_time,priority:1"`
 	Method             string     `gorm:"not null"`
 	Path               string     `gorm:"not null"`
-	StatusCode         *int
-	DurationMs         *int64
+	StatusCode         *int       `gorm:"index:idx_proxy_stats_status_time,priority:1"`
+	DurationMs         *int64     `gorm:"index:idx_proxy_stats_duration_id,priority:1"`
 	ClientIP           string     `gorm:"index"`
+	Variant            string     `gorm:"index"`
+	RequestID          string     `gorm:"index"`
+	Outcome            string     `gorm:"not null;default:'proxied';index"` // rows from before outcomes were recorded were all proxied
+	RequestBytes       *int64
+	ResponseBytes      *int64
+	TTFBMs             *int64     `gorm:"column:ttfb_ms"`
+	UpstreamAddr       string
+	Proto              string
+	TLSVersion         string
+	UserAgent          string
+	Query              string
+	AuthenticationUUID *uuid.UUID `gorm:"type:uuid;index"`
+	SampleWeight       int64      `gorm:"not null;default:1"` // requests the row stands for; aggregates SUM it instead of counting rows
+	Country            string     `gorm:"index"`
+	Region             string
+	ASN                int64      `gorm:"column:asn;index"`
+	ASOrg              string     `gorm:"column:as_org"`
+	ACLRule            string     `gorm:"column:acl_rule"`
 }
 
 // TableName overrides the default table name.
@@ -27,34 +47,73 @@ func (ProxyStat) TableName() string {
 	return "proxy_stats"
 }
 
-// ProxyStatToSchema maps the database object to the domain schema.
+// ProxyStatToSchema maps the database object to the domain schema. SampleWeight is left 0 for unsampled rows.
 func ProxyStatToSchema(p *ProxyStat) schema.ProxyStat {
-	return schema.ProxyStat{
-		ID:               p.ID,
-		Timestamp:        p.Timestamp,
-		SourceServerUUID: p.SourceServerUUID,
-		RouteUUID:        p.RouteUUID,
-		TargetServerUUID: p.TargetServerUUID,
-		Method:           p.Method,
-		Path:             p.Path,
-		StatusCode:       p.StatusCode,
-		DurationMs:       p.DurationMs,
-		ClientIP:         p.ClientIP,
+	s := schema.ProxyStat{
+		ID:                 p.ID,
+		Timestamp:          p.Timestamp,
+		SourceServerUUID:   p.SourceServerUUID,
+		RouteUUID:          p.RouteUUID,
+		TargetServerUUID:   p.TargetServerUUID,
+		Method:             p.Method,
+		Path:               p.Path,
+		StatusCode:         p.StatusCode,
+		DurationMs:         p.DurationMs,
+		ClientIP:           p.ClientIP,
+		Variant:            p.Variant,
+		RequestID:          p.RequestID,
+		Outcome:            p.Outcome,
+		RequestBytes:       p.RequestBytes,
+		ResponseBytes:      p.ResponseBytes,
+		TTFBMs:             p.TTFBMs,
+		UpstreamAddr:       p.UpstreamAddr,
+		Proto:              p.Proto,
+		TLSVersion:         p.TLSVersion,
+		UserAgent:          p.UserAgent,
+		Query:              p.Query,
+		AuthenticationUUID: p.AuthenticationUUID,
+		Country:            p.Country,
+		Region:             p.Region,
+		ASN:                p.ASN,
+		ASOrg:              p.ASOrg,
+		ACLRule:            p.ACLRule,
 	}
+	if p.SampleWeight > 1 {
+		s.SampleWeight = p.SampleWeight
+	}
+	return s
 }
 
 // SchemaToProxyStat maps the domain schema to the database object.
 func SchemaToProxyStat(p schema.ProxyStat) ProxyStat {
 	return ProxyStat{
-		ID:               p.ID,
-		Timestamp:        p.Timestamp,
-		SourceServerUUID: p.SourceServerUUID,
-		RouteUUID:        p.RouteUUID,
-		TargetServerUUID: p.TargetServerUUID,
-		Method:           p.Method,
-		Path:             p.Path,
-		StatusCode:       p.StatusCode,
-		DurationMs:       p.DurationMs,
-		ClientIP:         p.ClientIP,
+		ID:                 p.ID,
+		Timestamp:          p.Timestamp,
+		SourceServerUUID:   p.SourceServerUUID,
+		RouteUUID:          p.RouteUUID,
+		TargetServerUUID:   p.TargetServerUUID,
+		Method:             p.Method,
+		Path:               p.Path,
+		StatusCode:         p.StatusCode,
+		DurationMs:         p.DurationMs,
+		ClientIP:           p.ClientIP,
+		Variant:            p.Variant,
+		RequestID:          p.RequestID,
+		Outcome:            p.Outcome,
+		RequestBytes:       p.RequestBytes,
+		ResponseBytes:      p.ResponseBytes,
+		TTFBMs:             p.TTFBMs,
+		UpstreamAddr:       p.UpstreamAddr,
+		Proto:              p.Proto,
+		TLSVersion:         p.TLSVersion,
+		UserAgent:          p.UserAgent,
+		Query:              p.Query,
+		AuthenticationUUID: p.AuthenticationUUID,
+		SampleWeight:       p.Weight(),
+		Country:            p.Country,
+		Region:             p.Region,
+		ASN:                p.ASN,
+		ASOrg:              p.ASOrg,
+		ACLRule:            p.ACLRule,
 	}
 }
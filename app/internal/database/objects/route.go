@@ -1,6 +1,7 @@
 package objects
 
 import (
+	"encoding/json"
 	"time"
 
 	"FeatherProxy/app/internal/database/schema"
@@ -17,6 +18,8 @@ type Route struct {
 	Method           string         `gorm:"not null"`
 	SourcePath       string         `gorm:"not null"`
 	TargetPath       string         `gorm:"not null"`
+	Kind             string         `gorm:"column:kind;not null;default:proxy"`
+	ResponseJSON     string         `gorm:"column:response"`
 	CreatedAt        time.Time      `gorm:"not null"`
 	UpdatedAt        time.Time      `gorm:"not null"`
 	DeletedAt        gorm.DeletedAt `gorm:"index"`
@@ -36,6 +39,8 @@ func RouteToSchema(r *Route) schema.Route {
 		Method:            r.Method,
 		SourcePath:        r.SourcePath,
 		TargetPath:        r.TargetPath,
+		Kind:              r.Kind,
+		Response:          parseRouteResponse(r.ResponseJSON),
 		CreatedAt:         r.CreatedAt,
 		UpdatedAt:         r.UpdatedAt,
 	}
@@ -43,6 +48,10 @@ func RouteToSchema(r *Route) schema.Route {
 
 // SchemaToRoute maps the domain schema to the database object (for database use).
 func SchemaToRoute(r schema.Route) Route {
+	kind := r.Kind
+	if kind == "" {
+		kind = schema.RouteKindProxy
+	}
 	return Route{
 		RouteUUID:        r.RouteUUID,
 		SourceServerUUID: r.SourceServerUUID,
@@ -50,7 +59,28 @@ func SchemaToRoute(r schema.Route) Route {
 		Method:           r.Method,
 		SourcePath:       r.SourcePath,
 		TargetPath:       r.TargetPath,
+		Kind:             kind,
+		ResponseJSON:     marshalRouteResponse(r.Response),
 		CreatedAt:        r.CreatedAt,
 		UpdatedAt:        r.UpdatedAt,
 	}
+}
+
+func parseRouteResponse(jsonStr string) *schema.RouteResponse {
+	if jsonStr == "" {
+		return nil
+	}
+	var out schema.RouteResponse
+	if err := json.Unmarshal([]byte(jsonStr), &out); err != nil {
+		return nil
+	}
+	return &out
+}
+
+func marshalRouteResponse(resp *schema.RouteResponse) string {
+	if resp == nil {
+		return ""
+	}
+	b, _ := json.Marshal(resp)
+	return string(b)
 }
\ No newline at end of file
@@ -0,0 +1,66 @@
+package objects
+
+import (
+	"encoding/json"
+	"time"
+
+	"FeatherProxy/app/internal/database/schema"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RouteBalancing is the database object (ORM entity) for the route_balancings table.
+// TargetServerUUIDs is stored as a JSON string.
+type RouteBalancing struct {
+	RouteUUID             uuid.UUID      `gorm:"primaryKey"`
+	TargetServerUUIDsJSON string         `gorm:"column:target_server_uuids"`
+	Affinity              string         `gorm:"column:affinity;default:none"`
+	AffinityKey           string         `gorm:"column:affinity_key"`
+	CookieName            string         `gorm:"column:cookie_name"`
+	CookieTTLSeconds      int            `gorm:"column:cookie_ttl_seconds;default:0"`
+	CreatedAt             time.Time      `gorm:"not null"`
+	UpdatedAt             time.Time      `gorm:"not null"`
+	DeletedAt             gorm.DeletedAt `gorm:"index"`
+}
+
+// TableName overrides the default table name.
+func (RouteBalancing) TableName() string {
+	return "route_balancings"
+}
+
+// RouteBalancingToSchema maps the database object to the domain schema.
+func RouteBalancingToSchema(o *RouteBalancing) schema.RouteBalancing {
+	ids := []uuid.UUID{}
+	if o.TargetServerUUIDsJSON != "" {
+		_ = json.Unmarshal([]byte(o.TargetServerUUIDsJSON), &ids)
+	}
+	return schema.RouteBalancing{
+		RouteUUID:         o.RouteUUID,
+		TargetServerUUIDs: ids,
+		Affinity:          o.Affinity,
+		AffinityKey:       o.AffinityKey,
+		CookieName:        o.CookieName,
+		CookieTTLSeconds:  o.CookieTTLSeconds,
+		CreatedAt:         o.CreatedAt,
+		UpdatedAt:         o.UpdatedAt,
+	}
+}
+
+// SchemaToRouteBalancing maps the domain schema to the database object.
+func SchemaToRouteBalancing(s schema.RouteBalancing) RouteBalancing {
+	idsJSON := "[]"
+	if len(s.TargetServerUUIDs) > 0 {
+		b, _ := json.Marshal(s.TargetServerUUIDs)
+		idsJSON = string(b)
+	}
+	return RouteBalancing{
+		RouteUUID:             s.RouteUUID,
+		TargetServerUUIDsJSON: idsJSON,
+		Affinity:              s.Affinity,
+		AffinityKey:           s.AffinityKey,
+		CookieName:            s.CookieName,
+		CookieTTLSeconds:      s.CookieTTLSeconds,
+		CreatedAt:             s.CreatedAt,
+		UpdatedAt:             s.UpdatedAt,
+	}
+}
@@ -10,12 +10,18 @@ import (
 
 // ServerOptions is the database object (ORM entity) for the server_options table.
 type ServerOptions struct {
-	SourceServerUUID uuid.UUID      `gorm:"primaryKey"`
-	TLSCertPath      string         `gorm:"column:tls_cert_path"`
-	TLSKeyPath       string         `gorm:"column:tls_key_path"`
-	CreatedAt        time.Time      `gorm:"not null"`
-	UpdatedAt        time.Time      `gorm:"not null"`
-	DeletedAt        gorm.DeletedAt `gorm:"index"`
+	SourceServerUUID     uuid.UUID      `gorm:"primaryKey"`
+	TLSCertPath          string         `gorm:"column:tls_cert_path"`
+	TLSKeyPath           string         `gorm:"column:tls_key_path"`
+	RequestIDHeader      string         `gorm:"column:request_id_header"`
+	ForwardedForHeader   string         `gorm:"column:forwarded_for_header"`
+	ForwardedProtoHeader string         `gorm:"column:forwarded_proto_header"`
+	ForwardedHostHeader  string         `gorm:"column:forwarded_host_header"`
+	ForwardedPortHeader  string         `gorm:"column:forwarded_port_header"`
+	ForwardedHeader      string         `gorm:"column:forwarded_header"`
+	CreatedAt            time.Time      `gorm:"not null"`
+	UpdatedAt            time.Time      `gorm:"not null"`
+	DeletedAt            gorm.DeletedAt `gorm:"index"`
 }
 
 // TableName overrides the default table name.
@@ -26,21 +32,33 @@ func (ServerOptions) TableName() string {
 // ServerOptionsToSchema maps the database object to the domain schema.
 func ServerOptionsToSchema(o *ServerOptions) schema.ServerOptions {
 	return schema.ServerOptions{
-		SourceServerUUID: o.SourceServerUUID,
-		TLSCertPath:      o.TLSCertPath,
-		TLSKeyPath:       o.TLSKeyPath,
-		CreatedAt:        o.CreatedAt,
-		UpdatedAt:        o.UpdatedAt,
+		SourceServerUUID:     o.SourceServerUUID,
+		TLSCertPath:          o.TLSCertPath,
+		TLSKeyPath:           o.TLSKeyPath,
+		RequestIDHeader:      o.RequestIDHeader,
+		ForwardedForHeader:   o.ForwardedForHeader,
+		ForwardedProtoHeader: o.ForwardedProtoHeader,
+		ForwardedHostHeader:  o.ForwardedHostHeader,
+		ForwardedPortHeader:  o.ForwardedPortHeader,
+		ForwardedHeader:      o.ForwardedHeader,
+		CreatedAt:            o.CreatedAt,
+		UpdatedAt:            o.UpdatedAt,
 	}
 }
 
 // SchemaToServerOptions maps the domain schema to the database object.
 func SchemaToServerOptions(s schema.ServerOptions) ServerOptions {
 	return ServerOptions{
-		SourceServerUUID: s.SourceServerUUID,
-		TLSCertPath:      s.TLSCertPath,
-		TLSKeyPath:       s.TLSKeyPath,
-		CreatedAt:        s.CreatedAt,
-		UpdatedAt:        s.UpdatedAt,
+		SourceServerUUID:     s.SourceServerUUID,
+		TLSCertPath:          s.TLSCertPath,
+		TLSKeyPath:           s.TLSKeyPath,
+		RequestIDHeader:      s.RequestIDHeader,
+		ForwardedForHeader:   s.ForwardedForHeader,
+		ForwardedProtoHeader: s.ForwardedProtoHeader,
+		ForwardedHostHeader:  s.ForwardedHostHeader,
+		ForwardedPortHeader:  s.ForwardedPortHeader,
+		ForwardedHeader:      s.ForwardedHeader,
+		CreatedAt:            s.CreatedAt,
+		UpdatedAt:            s.UpdatedAt,
 	}
 }
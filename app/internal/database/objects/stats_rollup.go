@@ -0,0 +1,58 @@
+package objects
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StatsRollup is one aggregated stats row: the requests of one source server, route, target server and
+// outcome within a bucket [BucketStart, BucketStart+size).
+type StatsRollup struct {
+	BucketStart      time.Time `gorm:"primaryKey"`
+	SourceServerUUID uuid.UUID `gorm:"primaryKey;type:uuid"`
+	RouteUUID        uuid.UUID `gorm:"primaryKey;type:uuid"`
+	TargetServerUUID uuid.UUID `gorm:"primaryKey;type:uuid"`
+	Outcome          string    `gorm:"primaryKey"`
+	Count            int64     `gorm:"not null"`
+	Status2xx        int64     `gorm:"column:status2xx;not null"`
+	Status3xx        int64     `gorm:"column:status3xx;not null"`
+	Status4xx        int64     `gorm:"column:status4xx;not null"`
+	Status5xx        int64     `gorm:"column:status5xx;not null"`
+	DurationCount    int64     `gorm:"not null"` // requests with a recorded duration
+	DurationSumMs    int64     `gorm:"not null"`
+	DurationMaxMs    int64     `gorm:"not null"`
+	LatencyHistogram string    `gorm:"not null"` // comma-separated counts per schema.RollupLatencyBoundsMs bucket, then +Inf
+}
+
+// StatsRollupMinute is the database object for the proxy_stats_minute table.
+type StatsRollupMinute struct {
+	StatsRollup
+}
+
+// TableName overrides the default table name.
+func (StatsRollupMinute) TableName() string {
+	return "proxy_stats_minute"
+}
+
+// StatsRollupHour is the database object for the proxy_stats_hour table.
+type StatsRollupHour struct {
+	StatsRollup
+}
+
+// TableName overrides the default table name.
+func (StatsRollupHour) TableName() string {
+	return "proxy_stats_hour"
+}
+
+// StatsRollupWatermark records, per resolution, the end of the last rolled-up bucket: everything before
+// Watermark is in the rollup table.
+type StatsRollupWatermark struct {
+	Resolution string    `gorm:"primaryKey"`
+	Watermark  time.Time `gorm:"not null"`
+}
+
+// TableName overrides the default table name.
+func (StatsRollupWatermark) TableName() string {
+	return "stats_rollup_watermarks"
+}
@@ -16,6 +16,7 @@ type TargetServer struct {
 	Host             string         `gorm:"not null"`
 	Port             int            `gorm:"not null"`
 	BasePath         string         `gorm:"not null"`
+	PreserveHost     bool           `gorm:"not null;default:false"`
 	CreatedAt        time.Time      `gorm:"not null"`
 	UpdatedAt        time.Time      `gorm:"not null"`
 	DeletedAt        gorm.DeletedAt `gorm:"index"`
@@ -35,6 +36,7 @@ func TargetServerToSchema(t *TargetServer) schema.TargetServer {
 		Host:             t.Host,
 		Port:             t.Port,
 		BasePath:         t.BasePath,
+		PreserveHost:     t.PreserveHost,
 		CreatedAt:        t.CreatedAt,
 		UpdatedAt:        t.UpdatedAt,
 	}
@@ -49,6 +51,7 @@ func SchemaToTargetServer(t schema.TargetServer) TargetServer {
 		Host:             t.Host,
 		Port:             t.Port,
 		BasePath:         t.BasePath,
+		PreserveHost:     t.PreserveHost,
 		CreatedAt:        t.CreatedAt,
 		UpdatedAt:        t.UpdatedAt,
 	}
@@ -0,0 +1,60 @@
+package objects
+
+import (
+	"encoding/json"
+	"time"
+
+	"FeatherProxy/app/internal/database/schema"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TrafficSplit is the database object (ORM entity) for the traffic_splits table.
+// Rules are stored as a JSON string.
+type TrafficSplit struct {
+	RouteUUID    uuid.UUID      `gorm:"primaryKey"`
+	RulesJSON    string         `gorm:"column:rules"`
+	Sticky       bool           `gorm:"column:sticky;default:false"`
+	StickyCookie string         `gorm:"column:sticky_cookie"`
+	CreatedAt    time.Time      `gorm:"not null"`
+	UpdatedAt    time.Time      `gorm:"not null"`
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
+
+// TableName overrides the default table name.
+func (TrafficSplit) TableName() string {
+	return "traffic_splits"
+}
+
+// TrafficSplitToSchema maps the database object to the domain schema.
+func TrafficSplitToSchema(o *TrafficSplit) schema.TrafficSplit {
+	rules := []schema.TrafficRule{}
+	if o.RulesJSON != "" {
+		_ = json.Unmarshal([]byte(o.RulesJSON), &rules)
+	}
+	return schema.TrafficSplit{
+		RouteUUID:    o.RouteUUID,
+		Rules:        rules,
+		Sticky:       o.Sticky,
+		StickyCookie: o.StickyCookie,
+		CreatedAt:    o.CreatedAt,
+		UpdatedAt:    o.UpdatedAt,
+	}
+}
+
+// SchemaToTrafficSplit maps the domain schema to the database object.
+func SchemaToTrafficSplit(s schema.TrafficSplit) TrafficSplit {
+	rulesJSON := "[]"
+	if len(s.Rules) > 0 {
+		b, _ := json.Marshal(s.Rules)
+		rulesJSON = string(b)
+	}
+	return TrafficSplit{
+		RouteUUID:    s.RouteUUID,
+		RulesJSON:    rulesJSON,
+		Sticky:       s.Sticky,
+		StickyCookie: s.StickyCookie,
+		CreatedAt:    s.CreatedAt,
+		UpdatedAt:    s.UpdatedAt,
+	}
+}
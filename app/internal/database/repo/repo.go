@@ -12,6 +12,9 @@ import (
 // ErrProtocolMismatch is returned when a route links a source and target server with incompatible protocols.
 var ErrProtocolMismatch = errors.New("source and target server must have the same protocol")
 
+// ErrInvalidCursor is returned by ListProxyStats for a cursor it did not issue or issued for another sort.
+var ErrInvalidCursor = errors.New("invalid stats cursor")
+
 // Repository defines persistence for source/target servers, routes, and authentications.
 type Repository interface {
 	// Source servers
@@ -26,6 +29,12 @@ type Repository interface {
 	// ACL options (1:1 with source server; allow/deny by client IP/CIDR)
 	GetACLOptions(sourceServerUUID uuid.UUID) (schema.ACLOptions, error)
 	SetACLOptions(opts schema.ACLOptions) error
+	// Error pages (1:1 with source server; templates for proxy-generated errors)
+	GetErrorPages(sourceServerUUID uuid.UUID) (schema.ErrorPages, error)
+	SetErrorPages(pages schema.ErrorPages) error
+	// Access log options (1:1 with source server; format and output of the access log)
+	GetAccessLogOptions(sourceServerUUID uuid.UUID) (schema.AccessLogOptions, error)
+	SetAccessLogOptions(opts schema.AccessLogOptions) error
 	// Target servers
 	CreateTargetServer(t schema.TargetServer) error
 	GetTargetServer(uuid uuid.UUID) (schema.TargetServer, error)
@@ -54,16 +63,53 @@ type Repository interface {
 	GetTargetAuthForRoute(routeUUID uuid.UUID) (uuid.UUID, bool, error)
 	SetTargetAuthForRoute(routeUUID uuid.UUID, authUUID *uuid.UUID) error
 	GetTargetAuthenticationWithPlainToken(routeUUID uuid.UUID) (schema.Authentication, bool, error) // For proxy
+	// Traffic split (1:1 with route; canary / header / cookie based variants)
+	GetTrafficSplit(routeUUID uuid.UUID) (schema.TrafficSplit, error)
+	SetTrafficSplit(split schema.TrafficSplit) error
+	// Route balancing (1:1 with route; extra targets and session affinity)
+	GetRouteBalancing(routeUUID uuid.UUID) (schema.RouteBalancing, error)
+	SetRouteBalancing(b schema.RouteBalancing) error
+	// Maintenance (1:1 with a route or a source server; scope is schema.MaintenanceScope*)
+	GetMaintenance(scope string, ownerUUID uuid.UUID) (schema.Maintenance, error)
+	SetMaintenance(m schema.Maintenance) error
 
 	// Proxy stats (no cache; write-heavy)
 	CreateProxyStats(stats []schema.ProxyStat) error
-	ListProxyStats(limit, offset int, since *time.Time) ([]schema.ProxyStat, int64, error)
+	ListProxyStats(q schema.StatsListQuery) (schema.StatsPage, error)
+	GetProxyStatsByRequestID(requestID string) ([]schema.ProxyStat, error)
+	StreamProxyStats(filter schema.StatsFilter, fn func(schema.ProxyStat) error) error
 	DeleteProxyStatsOlderThan(until time.Time) (int64, error)
 	ClearAllProxyStats() error
 	StatsSummary() (schema.StatsSummary, error)
 	StatsByRoute(since *time.Time, limit int) ([]schema.RouteCount, error)
 	StatsByCaller(since *time.Time, limit int) ([]schema.CallerCount, error)
+	StatsByCountry(since *time.Time, limit int) ([]schema.CountryCount, error)
+	StatsByASN(since *time.Time, limit int) ([]schema.ASNCount, error)
 	StatsBySourceServer(since *time.Time) ([]schema.ServerCount, error)
 	StatsByTargetServer(since *time.Time) ([]schema.ServerCount, error)
+	StatsByVariant(routeUUID *uuid.UUID, since *time.Time) ([]schema.VariantCount, error)
 	StatsTPS(since time.Time, bucketDuration time.Duration) ([]schema.BucketCount, error)
+	StatsLatency(groupBy string, filter schema.StatsFilter, limit int) ([]schema.LatencyStats, error)
+	StatsLatencyHistogram(filter schema.StatsFilter, boundsMs []int64) ([]schema.LatencyBucket, error)
+	StatsByOutcome(groupBy string, filter schema.StatsFilter) ([]schema.OutcomeCount, error)
+	RollupProxyStats(until time.Time) error
+	DeleteStatsRollupsOlderThan(resolution string, until time.Time) (int64, error)
+	StatsStatusCounts(filter schema.StatsFilter) (schema.StatusCounts, error)
+	OldestProxyStat() (*time.Time, error)
+
+	// Alerting (no cache; read by the alerting service on every evaluation)
+	ListAlertRules() ([]schema.AlertRule, error)
+	GetAlertRule(id uuid.UUID) (schema.AlertRule, error)
+	CreateAlertRule(rule schema.AlertRule) error
+	UpdateAlertRule(rule schema.AlertRule) error
+	DeleteAlertRule(id uuid.UUID) error
+	ListAlertWebhooks() ([]schema.AlertWebhook, error)
+	GetAlertWebhook(id uuid.UUID) (schema.AlertWebhook, error)
+	CreateAlertWebhook(hook schema.AlertWebhook) error
+	UpdateAlertWebhook(hook schema.AlertWebhook) error
+	DeleteAlertWebhook(id uuid.UUID) error
+	ListAlertSilences(now time.Time) ([]schema.AlertSilence, error)
+	CreateAlertSilence(s schema.AlertSilence) error
+	DeleteAlertSilence(id uuid.UUID) error
+	DeleteAlertSilencesEndedBefore(t time.Time) (int64, error)
 }
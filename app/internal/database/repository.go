@@ -17,6 +17,9 @@ type Repository = repo.Repository
 // ErrProtocolMismatch is returned when a route links a source and target server with incompatible protocols.
 var ErrProtocolMismatch = repo.ErrProtocolMismatch
 
+// ErrInvalidCursor is returned by ListProxyStats for a cursor it did not issue.
+var ErrInvalidCursor = repo.ErrInvalidCursor
+
 // NewCachedRepository returns a Repository implementation backed by the given DB and cache.
 func NewCachedRepository(db *gorm.DB, c cache.Cache, ttl time.Duration) Repository {
 	return impl.NewWithCache(db, c, ttl)
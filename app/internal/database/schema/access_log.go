@@ -0,0 +1,30 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccessLogOptions is the domain schema for a source server's access log (1:1 with source server).
+// Format is "common", "combined", "json" or "template" (Template is then a text/template over the
+// log entry). Output is "stdout", "file" (rotated by size and/or interval, with retention) or "syslog"
+// (RFC 5424 over udp, tcp, unix or unixgram).
+type AccessLogOptions struct {
+	SourceServerUUID uuid.UUID `json:"source_server_uuid"`
+	Enabled          bool      `json:"enabled"`
+	Format           string    `json:"format"`
+	Template         string    `json:"template"`
+	Output           string    `json:"output"`
+	FilePath         string    `json:"file_path"`
+	MaxSizeMB        int       `json:"max_size_mb"`     // rotate when the file would exceed this size; 0 = no size limit
+	RotateInterval   string    `json:"rotate_interval"` // Go duration, e.g. "24h"; empty = no time-based rotation
+	MaxBackups       int       `json:"max_backups"`     // rotated files to keep; 0 = all
+	MaxAgeDays       int       `json:"max_age_days"`    // delete rotated files older than this; 0 = never
+	SyslogNetwork    string    `json:"syslog_network"`
+	SyslogAddress    string    `json:"syslog_address"`
+	SyslogFacility   int       `json:"syslog_facility"` // 0-23; default 16 (local0)
+	SyslogAppName    string    `json:"syslog_app_name"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
@@ -0,0 +1,102 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Alert rule metrics, measured over the rule's window.
+const (
+	AlertMetricErrorRate    = "error_rate"     // share of 5xx responses, 0..1
+	AlertMetricLatencyP95   = "latency_p95_ms" // 95th percentile duration in ms
+	AlertMetricLatencyP99   = "latency_p99_ms" // 99th percentile duration in ms
+	AlertMetricRequestCount = "request_count"  // requests recorded; "< 1" alerts on zero traffic
+)
+
+// AlertMetrics lists every alert rule metric.
+var AlertMetrics = []string{AlertMetricErrorRate, AlertMetricLatencyP95, AlertMetricLatencyP99, AlertMetricRequestCount}
+
+// AlertOperators lists the comparisons an alert rule can use; the rule fires when "value <op> threshold".
+var AlertOperators = []string{">", ">=", "<", "<="}
+
+// Alert states reported by the alerting service.
+const (
+	AlertStateOK      = "ok"
+	AlertStateFiring  = "firing"
+	AlertStateNoData  = "no_data" // fewer requests than MinRequests in the window (rate and latency metrics)
+	AlertStateUnknown = "unknown" // not evaluated yet, or the evaluation failed
+)
+
+// AlertRule is the domain schema for an alerting rule evaluated on recorded stats over a sliding window.
+// The scope UUIDs narrow the stats to one source server, route and/or target server (nil = all).
+// WebhookUUIDs are the webhooks notified on firing and resolve; empty means every webhook.
+type AlertRule struct {
+	AlertRuleUUID    uuid.UUID   `json:"alert_rule_uuid"`
+	Name             string      `json:"name"`
+	Enabled          bool        `json:"enabled"`
+	Metric           string      `json:"metric"`   // one of AlertMetrics
+	Operator         string      `json:"operator"` // one of AlertOperators
+	Threshold        float64     `json:"threshold"`
+	WindowSeconds    int         `json:"window_seconds"`
+	MinRequests      int64       `json:"min_requests"` // rate and latency metrics are not evaluated below this count
+	SourceServerUUID *uuid.UUID  `json:"source_server_uuid,omitempty"`
+	RouteUUID        *uuid.UUID  `json:"route_uuid,omitempty"`
+	TargetServerUUID *uuid.UUID  `json:"target_server_uuid,omitempty"`
+	WebhookUUIDs     []uuid.UUID `json:"webhook_uuids"`
+	CreatedAt        time.Time   `json:"created_at"`
+	UpdatedAt        time.Time   `json:"updated_at"`
+}
+
+// AlertWebhook is the domain schema for a notification target. Notifications are POSTed to URL; the body
+// is Template rendered with the notification (Go text/template) or, when Template is empty, its JSON.
+type AlertWebhook struct {
+	AlertWebhookUUID uuid.UUID `json:"alert_webhook_uuid"`
+	Name             string    `json:"name"`
+	URL              string    `json:"url"`
+	Template         string    `json:"template"`
+	ContentType      string    `json:"content_type"` // empty = application/json
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// AlertSilence suppresses notifications for one rule (or every rule when AlertRuleUUID is nil) between
+// StartsAt and EndsAt. Rules keep being evaluated; a rule still firing when the silence ends is notified then.
+type AlertSilence struct {
+	AlertSilenceUUID uuid.UUID  `json:"alert_silence_uuid"`
+	AlertRuleUUID    *uuid.UUID `json:"alert_rule_uuid,omitempty"`
+	StartsAt         time.Time  `json:"starts_at"`
+	EndsAt           time.Time  `json:"ends_at"`
+	Comment          string     `json:"comment"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// Active reports whether the silence covers rule at t.
+func (s AlertSilence) Active(rule uuid.UUID, t time.Time) bool {
+	if s.AlertRuleUUID != nil && *s.AlertRuleUUID != rule {
+		return false
+	}
+	return !t.Before(s.StartsAt) && t.Before(s.EndsAt)
+}
+
+// AlertStatus is the last evaluation of one rule, as reported by the alerting service.
+type AlertStatus struct {
+	AlertRuleUUID uuid.UUID  `json:"alert_rule_uuid"`
+	Name          string     `json:"name"`
+	State         string     `json:"state"` // one of the AlertState* constants
+	Value         float64    `json:"value"`
+	Requests      int64      `json:"requests"` // requests in the window
+	FiringSince   *time.Time `json:"firing_since,omitempty"`
+	Silenced      bool       `json:"silenced"`
+	EvaluatedAt   time.Time  `json:"evaluated_at"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// StatusCounts is the number of requests per status class matching a StatsFilter.
+type StatusCounts struct {
+	Total     int64 `json:"total"`
+	Status2xx int64 `json:"status_2xx"`
+	Status3xx int64 `json:"status_3xx"`
+	Status4xx int64 `json:"status_4xx"`
+	Status5xx int64 `json:"status_5xx"`
+}
@@ -0,0 +1,51 @@
+package schema
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of proxy-generated errors that can have a custom page.
+const (
+	ErrorKindACLDenied          = "acl_denied"          // 403, client rejected by the source server ACL
+	ErrorKindAuthDenied         = "auth_denied"         // 403, source authentication missing or wrong
+	ErrorKindNoRoute            = "no_route"            // 404, no route matches method and path
+	ErrorKindRateLimited        = "rate_limited"        // 429, client over its request budget
+	ErrorKindInternal           = "internal_error"      // 500, proxy-side failure (e.g. auth lookup)
+	ErrorKindBadGateway         = "bad_gateway"         // 502, upstream unreachable or failed
+	ErrorKindServiceUnavailable = "service_unavailable" // 503, no upstream available
+	ErrorKindGatewayTimeout     = "gateway_timeout"     // 504, upstream did not answer in time
+)
+
+// ErrorKindStatus maps each error kind to the HTTP status the proxy answers with.
+var ErrorKindStatus = map[string]int{
+	ErrorKindACLDenied:          http.StatusForbidden,
+	ErrorKindAuthDenied:         http.StatusForbidden,
+	ErrorKindNoRoute:            http.StatusNotFound,
+	ErrorKindRateLimited:        http.StatusTooManyRequests,
+	ErrorKindInternal:           http.StatusInternalServerError,
+	ErrorKindBadGateway:         http.StatusBadGateway,
+	ErrorKindServiceUnavailable: http.StatusServiceUnavailable,
+	ErrorKindGatewayTimeout:     http.StatusGatewayTimeout,
+}
+
+// ErrorPage holds the templates for one error kind. JSON is used when the client prefers
+// application/json (or sends no preference), HTML when it prefers text/html. An empty template
+// falls back to the built-in body for that format.
+type ErrorPage struct {
+	JSON string `json:"json"`
+	HTML string `json:"html"`
+}
+
+// ErrorPages is the domain schema for per-source-server custom error pages (1:1 with source server).
+// Pages is keyed by error kind (ErrorKind*). When ReplaceUpstream5xx is set, 5xx bodies returned by
+// a target server are replaced with the matching page (502/503/504, other 5xx use bad_gateway).
+type ErrorPages struct {
+	SourceServerUUID   uuid.UUID            `json:"source_server_uuid"`
+	Pages              map[string]ErrorPage `json:"pages"`
+	ReplaceUpstream5xx bool                 `json:"replace_upstream_5xx"`
+	CreatedAt          time.Time            `json:"created_at"`
+	UpdatedAt          time.Time            `json:"updated_at"`
+}
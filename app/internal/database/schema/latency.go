@@ -0,0 +1,48 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Stats grouping keys for latency and outcome aggregations.
+const (
+	StatsGroupNone         = ""
+	StatsGroupRoute        = "route"
+	StatsGroupTargetServer = "target_server"
+	StatsGroupSourceServer = "source_server"
+)
+
+// StatsFilter narrows a stats aggregation. Nil fields are unconstrained.
+type StatsFilter struct {
+	Since            *time.Time
+	Until            *time.Time
+	SourceServerUUID *uuid.UUID
+	RouteUUID        *uuid.UUID
+	TargetServerUUID *uuid.UUID
+	Outcome          string // empty for any outcome
+}
+
+// LatencyStats is the latency distribution of one route, target server or source server over a window
+// (UUID is nil when not grouped). Percentiles are estimated with 1% relative accuracy from raw stats; over
+// rolled-up ranges they (and MinMs) are rounded up to the rollup histogram bounds (RollupLatencyBoundsMs).
+type LatencyStats struct {
+	UUID   uuid.UUID `json:"uuid"`
+	Count  int64     `json:"count"`
+	MeanMs float64   `json:"mean_ms"`
+	MinMs  int64     `json:"min_ms"`
+	MaxMs  int64     `json:"max_ms"`
+	P50Ms  float64   `json:"p50_ms"`
+	P90Ms  float64   `json:"p90_ms"`
+	P95Ms  float64   `json:"p95_ms"`
+	P99Ms  float64   `json:"p99_ms"`
+}
+
+// LatencyBucket is one latency histogram bucket: requests with LowerMs < duration <= UpperMs (the first
+// bucket also counts zero durations). The last bucket has no upper bound (UpperMs is nil).
+type LatencyBucket struct {
+	LowerMs int64  `json:"lower_ms"`
+	UpperMs *int64 `json:"upper_ms"`
+	Count   int64  `json:"count"`
+}
@@ -0,0 +1,45 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Maintenance scopes: what OwnerUUID refers to.
+const (
+	MaintenanceScopeRoute        = "route"
+	MaintenanceScopeSourceServer = "source_server"
+)
+
+// Maintenance is the domain schema for a maintenance window on a route or a whole source server
+// (1:1 with its owner). While active, the proxy answers 503 with Body and a Retry-After header instead
+// of forwarding, except for clients matching AllowList (IPs, CIDRs or hostnames, as in ACLs).
+// StartsAt and EndsAt are optional; without them the window is active whenever Enabled is set.
+type Maintenance struct {
+	OwnerUUID         uuid.UUID  `json:"owner_uuid"`
+	Scope             string     `json:"scope"` // "route" or "source_server"
+	Enabled           bool       `json:"enabled"`
+	Body              string     `json:"body"`         // empty = the source server's service_unavailable error page
+	ContentType       string     `json:"content_type"` // content type of Body; empty = text/plain
+	RetryAfterSeconds int        `json:"retry_after_seconds"`
+	StartsAt          *time.Time `json:"starts_at,omitempty"`
+	EndsAt            *time.Time `json:"ends_at,omitempty"`
+	AllowList         []string   `json:"allow_list"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// ActiveAt reports whether the maintenance window applies at t.
+func (m Maintenance) ActiveAt(t time.Time) bool {
+	if !m.Enabled {
+		return false
+	}
+	if m.StartsAt != nil && t.Before(*m.StartsAt) {
+		return false
+	}
+	if m.EndsAt != nil && !t.Before(*m.EndsAt) {
+		return false
+	}
+	return true
+}
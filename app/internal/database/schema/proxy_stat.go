@@ -6,7 +6,26 @@ import (
 	"github.com/google/uuid"
 )
 
-// ProxyStat is the domain schema for one proxied request metric.
+// Request outcomes recorded on ProxyStat.Outcome.
+const (
+	OutcomeProxied       = "proxied"        // forwarded to the target server, which answered
+	OutcomeServed        = "served"         // static, redirect or mock route answered by the proxy
+	OutcomeMaintenance   = "maintenance"    // answered with the maintenance response
+	OutcomeACLDenied     = "acl_denied"     // rejected by the source server ACL
+	OutcomeAuthDenied    = "auth_denied"    // source authentication missing or wrong
+	OutcomeNoRoute       = "no_route"       // no route matches method and path
+	OutcomeUpstreamError = "upstream_error" // target server unknown, unreachable or failed
+	OutcomeTimeout       = "timeout"        // target server did not answer in time
+	OutcomeError         = "error"          // proxy-side failure (e.g. auth lookup)
+)
+
+// Outcomes lists every outcome, in the order the API reports them.
+var Outcomes = []string{
+	OutcomeProxied, OutcomeServed, OutcomeMaintenance, OutcomeACLDenied, OutcomeAuthDenied,
+	OutcomeNoRoute, OutcomeUpstreamError, OutcomeTimeout, OutcomeError,
+}
+
+// ProxyStat is the domain schema for one request metric (proxied, answered locally, denied or failed).
 // Used by the stats service and API; do not depend on database objects.
 type ProxyStat struct {
 	ID                 uuid.UUID `json:"id"`
@@ -19,6 +38,32 @@ type ProxyStat struct {
 	StatusCode         *int     `json:"status_code,omitempty"`
 	DurationMs         *int64   `json:"duration_ms,omitempty"`
 	ClientIP           string   `json:"client_ip,omitempty"`
+	Variant            string   `json:"variant,omitempty"` // traffic split variant; empty when the route has no split
+	RequestID          string   `json:"request_id,omitempty"`
+	Outcome            string   `json:"outcome"` // one of the Outcome* constants
+	RequestBytes       *int64     `json:"request_bytes,omitempty"`       // request body size
+	ResponseBytes      *int64     `json:"response_bytes,omitempty"`      // response body size written to the client
+	TTFBMs             *int64     `json:"ttfb_ms,omitempty"`             // time from starting the upstream request to its first response byte
+	UpstreamAddr       string     `json:"upstream_addr,omitempty"`       // resolved ip:port of the upstream connection
+	Proto              string     `json:"proto,omitempty"`               // client HTTP version, e.g. "HTTP/1.1"
+	TLSVersion         string     `json:"tls_version,omitempty"`         // client TLS version, e.g. "TLS 1.3"; empty for plain HTTP
+	UserAgent          string     `json:"user_agent,omitempty"`
+	Query              string     `json:"query,omitempty"`               // raw query string, secrets redacted (see STATS_QUERY)
+	AuthenticationUUID *uuid.UUID `json:"authentication_uuid,omitempty"` // source authentication that matched
+	SampleWeight       int64      `json:"sample_weight,omitempty"`       // requests this stat stands for when sampling kept it (see STATS_SAMPLE_THRESHOLD); 0 means 1
+	Country            string     `json:"country,omitempty"`             // client IP's ISO country code, from GeoIP (GEOIP_DB)
+	Region             string     `json:"region,omitempty"`              // client IP's subdivision code (or name), from GeoIP
+	ASN                int64      `json:"asn,omitempty"`                 // client IP's autonomous system number, from GeoIP
+	ASOrg              string     `json:"as_org,omitempty"`              // autonomous system organization, from GeoIP
+	ACLRule            string     `json:"acl_rule,omitempty"`            // deny_list entry that denied the request (outcome acl_denied), e.g. "country:RU"
+}
+
+// Weight returns how many requests the stat counts for in aggregates: SampleWeight, at least 1.
+func (p ProxyStat) Weight() int64 {
+	if p.SampleWeight < 1 {
+		return 1
+	}
+	return p.SampleWeight
 }
 
 // StatsSummary holds aggregated counts for the summary endpoint.
@@ -29,6 +74,15 @@ type StatsSummary struct {
 	Status4xx      int64 `json:"status_4xx"`
 	Status5xx      int64 `json:"status_5xx"`
 	TpsLastMinute  int64 `json:"tps_last_minute,omitempty"`
+	ByOutcome      map[string]int64 `json:"by_outcome"` // last 24h, per outcome
+}
+
+// OutcomeCount is one row from StatsByOutcome: the requests with one outcome, per group when grouped
+// (UUID is nil otherwise).
+type OutcomeCount struct {
+	UUID    uuid.UUID `json:"uuid"`
+	Outcome string    `json:"outcome"`
+	Count   int64     `json:"count"`
 }
 
 // RouteCount is one row from StatsByRoute aggregation.
@@ -45,6 +99,19 @@ type CallerCount struct {
 	Count    int64  `json:"count"`
 }
 
+// CountryCount is one row from StatsByCountry aggregation; Country is empty for IPs GeoIP did not resolve.
+type CountryCount struct {
+	Country string `json:"country"`
+	Count   int64  `json:"count"`
+}
+
+// ASNCount is one row from StatsByASN aggregation; ASN is 0 for IPs GeoIP did not resolve.
+type ASNCount struct {
+	ASN   int64  `json:"asn"`
+	ASOrg string `json:"as_org"`
+	Count int64  `json:"count"`
+}
+
 // ServerCount is one row from StatsBySourceServer or StatsByTargetServer (internal aggregation).
 type ServerCount struct {
 	ServerUUID uuid.UUID `json:"server_uuid"`
@@ -63,8 +130,14 @@ type TargetServerCountItem struct {
 	Count            int64     `json:"count"`
 }
 
-// BucketCount is one time bucket for TPS.
+// BucketCount is one time bucket for TPS: requests starting in [At, At+bucket size).
 type BucketCount struct {
-	At    time.Time `json:"at"`
-	Count int64     `json:"count"`
+	At           time.Time `json:"at"`
+	Count        int64     `json:"count"`
+	Status2xx    int64     `json:"status_2xx"`
+	Status3xx    int64     `json:"status_3xx"`
+	Status4xx    int64     `json:"status_4xx"`
+	Status5xx    int64     `json:"status_5xx"`
+	ErrorRate    float64   `json:"error_rate"`     // share of 5xx responses, 0..1
+	AvgLatencyMs float64   `json:"avg_latency_ms"` // 0 for empty buckets
 }
@@ -0,0 +1,35 @@
+package schema
+
+import (
+	"slices"
+	"time"
+)
+
+// Stats rollup resolutions. Raw proxy_stats rows are aggregated per minute, and minute rollups per hour;
+// each table has its own retention.
+const (
+	RollupMinute = "minute"
+	RollupHour   = "hour"
+)
+
+// RollupSizes maps a rollup resolution to its bucket size.
+var RollupSizes = map[string]time.Duration{
+	RollupMinute: time.Minute,
+	RollupHour:   time.Hour,
+}
+
+// RollupLatencyBoundsMs are the upper bounds (ms) of the latency histogram kept on each rollup row; a final
+// unbounded bucket follows.
+var RollupLatencyBoundsMs = []int64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// RollupHistogramCompatible reports whether a latency histogram with the given upper bounds can be filled
+// from rollup rows: every bound must be one of RollupLatencyBoundsMs, so each rollup bucket falls inside a
+// single requested bucket.
+func RollupHistogramCompatible(boundsMs []int64) bool {
+	for _, b := range boundsMs {
+		if !slices.Contains(RollupLatencyBoundsMs, b) {
+			return false
+		}
+	}
+	return true
+}
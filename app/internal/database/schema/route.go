@@ -1,22 +1,106 @@
 package schema
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// Route kinds. Only RouteKindProxy forwards to a target server; the others answer from the proxy itself.
+const (
+	RouteKindProxy    = "proxy"
+	RouteKindStatic   = "static"   // fixed status, headers and body
+	RouteKindRedirect = "redirect" // redirect to Response.RedirectURL
+	RouteKindMock     = "mock"     // like static, with an optional artificial delay
+)
+
 // Route is the domain schema for a route.
 // Use this in application logic and for caching (e.g. Redis); do not depend on database objects.
 // Keeps persistence details (columns, soft delete) separate from the rest of the app.
 // Protocol is derived from the linked source/target servers; they must match.
+// SourcePath may contain {name} segments; their values are available to TargetPath and Response templates.
 type Route struct {
-	RouteUUID         uuid.UUID `json:"route_uuid"`
-	SourceServerUUID  uuid.UUID `json:"source_server_uuid"`
-	TargetServerUUID  uuid.UUID `json:"target_server_uuid"`
-	Method            string    `json:"method"`
-	SourcePath        string    `json:"source_path"`
-	TargetPath        string    `json:"target_path"`
-	CreatedAt         time.Time `json:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at"`
+	RouteUUID        uuid.UUID      `json:"route_uuid"`
+	SourceServerUUID uuid.UUID      `json:"source_server_uuid"`
+	TargetServerUUID uuid.UUID      `json:"target_server_uuid"`
+	Method           string         `json:"method"`
+	SourcePath       string         `json:"source_path"`
+	TargetPath       string         `json:"target_path"`
+	Kind             string         `json:"kind"`               // empty or RouteKindProxy = forward to target server
+	Response         *RouteResponse `json:"response,omitempty"` // for static, redirect and mock routes
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+}
+
+// RouteResponse is the configured answer of a non-proxy route. Headers, Body and RedirectURL are
+// Go templates with .Params (path params), .Query (url.Values), .Method, .Path, .Host and a json func.
+// HTML bodies (by Content-Type header, else sniffed from Body) are rendered with html/template.
+type RouteResponse struct {
+	Status      int               `json:"status"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        string            `json:"body,omitempty"`
+	RedirectURL string            `json:"redirect_url,omitempty"`
+	DelayMs     int               `json:"delay_ms,omitempty"` // mock routes only
+}
+
+// ProxiesToTarget reports whether the route forwards requests to a target server.
+func (r Route) ProxiesToTarget() bool {
+	return r.Kind == "" || r.Kind == RouteKindProxy
+}
+
+// IsPathPattern reports whether a source path contains {name} segments.
+func IsPathPattern(p string) bool {
+	return strings.Contains(p, "{")
+}
+
+// MatchPath matches path against a source path pattern whose segments may be {name} placeholders.
+// It returns the placeholder values and true on a match. Patterns without placeholders match exactly.
+// A placeholder never captures "." or "..", which would move the expanded target path out of its prefix.
+func MatchPath(pattern, path string) (map[string]string, bool) {
+	pSegs := strings.Split(strings.Trim(pattern, "/"), "/")
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(pSegs) != len(segs) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, ps := range pSegs {
+		if len(ps) > 2 && strings.HasPrefix(ps, "{") && strings.HasSuffix(ps, "}") {
+			if segs[i] == "" || segs[i] == "." || segs[i] == ".." {
+				return nil, false
+			}
+			params[ps[1:len(ps)-1]] = segs[i]
+			continue
+		}
+		if ps != segs[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// ExpandPath replaces {name} placeholders in p with values from params, in a single pass: substituted
+// values are not expanded again. Placeholders without a value are kept as they are.
+func ExpandPath(p string, params map[string]string) string {
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(p, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(p[open:], '}')
+		if end < 0 {
+			break
+		}
+		end += open
+		b.WriteString(p[:open])
+		if v, ok := params[p[open+1:end]]; ok {
+			b.WriteString(v)
+		} else {
+			b.WriteString(p[open : end+1])
+		}
+		p = p[end+1:]
+	}
+	b.WriteString(p)
+	return b.String()
 }
@@ -0,0 +1,33 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Affinity modes for RouteBalancing.
+const (
+	AffinityNone       = "none"        // no affinity; requests are spread over healthy targets
+	AffinityCookie     = "cookie"      // proxy-issued, signed cookie pins the client to a target
+	AffinityHashCookie = "hash_cookie" // hash of an existing cookie (AffinityKey) picks the target
+	AffinityHashHeader = "hash_header" // hash of a request header (AffinityKey) picks the target
+	AffinityClientIP   = "client_ip"   // hash of the client IP picks the target
+)
+
+// DefaultAffinityCookie is the proxy-issued cookie name when CookieName is empty.
+const DefaultAffinityCookie = "fp_affinity"
+
+// RouteBalancing is the domain schema for a route's target pool and session affinity (1:1 with route).
+// The route's own TargetServerUUID is always part of the pool; TargetServerUUIDs lists the additional
+// instances that serve the same paths.
+type RouteBalancing struct {
+	RouteUUID         uuid.UUID   `json:"route_uuid"`
+	TargetServerUUIDs []uuid.UUID `json:"target_server_uuids"`
+	Affinity          string      `json:"affinity"`           // one of the Affinity* modes
+	AffinityKey       string      `json:"affinity_key"`       // cookie or header name for hash_cookie / hash_header
+	CookieName        string      `json:"cookie_name"`        // cookie name for affinity "cookie"
+	CookieTTLSeconds  int         `json:"cookie_ttl_seconds"` // 0 = session cookie
+	CreatedAt         time.Time   `json:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at"`
+}
@@ -6,11 +6,22 @@ import (
 	"github.com/google/uuid"
 )
 
+// ForwardHeaderOff, as a forwarding header name in ServerOptions, stops that header from being sent.
+const ForwardHeaderOff = "-"
+
 // ServerOptions is the domain schema for protocol-specific options attached to a source server (e.g. TLS for HTTPS).
+// The Forwarded*Header fields rename the forwarding headers sent to targets; empty keeps the standard name
+// and ForwardHeaderOff disables the header.
 type ServerOptions struct {
-	SourceServerUUID uuid.UUID `json:"source_server_uuid"`
-	TLSCertPath      string    `json:"tls_cert_path"`
-	TLSKeyPath       string    `json:"tls_key_path"`
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
+	SourceServerUUID     uuid.UUID `json:"source_server_uuid"`
+	TLSCertPath          string    `json:"tls_cert_path"`
+	TLSKeyPath           string    `json:"tls_key_path"`
+	RequestIDHeader      string    `json:"request_id_header"`      // header carrying the request ID; empty = X-Request-ID
+	ForwardedForHeader   string    `json:"forwarded_for_header"`   // empty = X-Forwarded-For
+	ForwardedProtoHeader string    `json:"forwarded_proto_header"` // empty = X-Forwarded-Proto
+	ForwardedHostHeader  string    `json:"forwarded_host_header"`  // empty = X-Forwarded-Host
+	ForwardedPortHeader  string    `json:"forwarded_port_header"`  // empty = X-Forwarded-Port
+	ForwardedHeader      string    `json:"forwarded_header"`       // RFC 7239; empty = Forwarded
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
 }
@@ -0,0 +1,33 @@
+package schema
+
+// Sort orders of ListProxyStats.
+const (
+	StatsSortNewest  = "newest"  // timestamp descending (default)
+	StatsSortOldest  = "oldest"  // timestamp ascending
+	StatsSortSlowest = "slowest" // duration descending; stats without a duration are left out
+)
+
+// StatsSorts lists every ListProxyStats sort order.
+var StatsSorts = []string{StatsSortNewest, StatsSortOldest, StatsSortSlowest}
+
+// StatsListQuery selects one page of raw stats. Zero fields are unconstrained.
+type StatsListQuery struct {
+	Filter        StatsFilter // time range, source/route/target server and outcome
+	Method        string
+	StatusCode    int // exact status code
+	StatusClass   int // 1..5 for 1xx..5xx
+	ClientIP      string
+	PathPrefix    string
+	MinDurationMs *int64
+	Sort          string // one of StatsSorts; empty = StatsSortNewest
+	Limit         int
+	Cursor        string // NextCursor of the previous page; empty for the first page
+}
+
+// StatsPage is one page of ListProxyStats. NextCursor is empty on the last page; Total counts every stat
+// matching the query, across pages.
+type StatsPage struct {
+	Stats      []ProxyStat `json:"stats"`
+	Total      int64       `json:"total"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
@@ -14,6 +14,7 @@ type TargetServer struct {
 	Host             string    `json:"host"`
 	Port             int       `json:"port"`
 	BasePath         string    `json:"base_path"`
+	PreserveHost     bool      `json:"preserve_host"` // send the client's Host header instead of Host[:Port]
 	CreatedAt        time.Time `json:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at"`
 }
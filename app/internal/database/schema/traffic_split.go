@@ -0,0 +1,48 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PrimaryVariant is the variant name recorded when a request is sent to the route's own target server.
+const PrimaryVariant = "primary"
+
+// DefaultVariantCookie is the cookie used to keep a client on the same variant when Sticky is set.
+const DefaultVariantCookie = "fp_variant"
+
+// TrafficSplit is the domain schema for per-route traffic splitting (1:1 with route).
+// Rules are evaluated before target selection: the first rule whose header or cookie condition
+// matches wins; otherwise a rule is picked by Weight (percent of traffic). Any share not covered
+// by rule weights goes to the route's own target server (PrimaryVariant).
+type TrafficSplit struct {
+	RouteUUID    uuid.UUID     `json:"route_uuid"`
+	Rules        []TrafficRule `json:"rules"`
+	Sticky       bool          `json:"sticky"`        // keep a client on the variant picked by weight
+	StickyCookie string        `json:"sticky_cookie"` // cookie name; empty = DefaultVariantCookie
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+// TrafficRule sends matching traffic to TargetServerUUID under the given variant name.
+// Header/Cookie conditions are optional; an empty value means "present with any value".
+type TrafficRule struct {
+	Variant          string    `json:"variant"`
+	TargetServerUUID uuid.UUID `json:"target_server_uuid"`
+	Weight           int       `json:"weight"` // 0-100, percent of requests not matched by a condition
+	Header           string    `json:"header,omitempty"`
+	HeaderValue      string    `json:"header_value,omitempty"`
+	Cookie           string    `json:"cookie,omitempty"`
+	CookieValue      string    `json:"cookie_value,omitempty"`
+}
+
+// VariantCount is one row from StatsByVariant aggregation.
+type VariantCount struct {
+	RouteUUID uuid.UUID `json:"route_uuid"`
+	Variant   string    `json:"variant"`
+	Count     int64     `json:"count"`
+	Status2xx int64     `json:"status_2xx"`
+	Status4xx int64     `json:"status_4xx"`
+	Status5xx int64     `json:"status_5xx"`
+}
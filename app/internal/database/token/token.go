@@ -8,10 +8,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
+
+	"FeatherProxy/app/internal/logging"
 )
 
+var logger = logging.For(logging.Repo)
+
 const (
 	// SaltSize is the nonce/salt size for AES-GCM (12 bytes recommended).
 	SaltSize = 12
@@ -46,15 +49,15 @@ func encryptionKey() ([]byte, error) {
 // EncryptToken encrypts plaintext with a new random salt using AES-GCM.
 // Returns (ciphertextBase64, saltBase64, error). Salt is SaltSize bytes.
 func EncryptToken(plaintext string) (ciphertextBase64, saltBase64 string, err error) {
-	log.Printf("auth/token: EncryptToken called (plaintext len=%d)", len(plaintext))
+	logger.Debug("EncryptToken")
 	key, err := encryptionKey()
 	if err != nil {
-		log.Printf("auth/token: EncryptToken encryption key error: %v", err)
+		logger.Error("EncryptToken: encryption key unavailable", "error", err)
 		return "", "", err
 	}
 	salt := make([]byte, SaltSize)
 	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
-		log.Printf("auth/token: EncryptToken random salt error: %v", err)
+		logger.Error("EncryptToken: random salt failed", "error", err)
 		return "", "", fmt.Errorf("token: random salt: %w", err)
 	}
 	block, err := aes.NewCipher(key)
@@ -66,21 +69,21 @@ func EncryptToken(plaintext string) (ciphertextBase64, saltBase64 string, err er
 		return "", "", fmt.Errorf("token: gcm: %w", err)
 	}
 	ciphertext := aead.Seal(nil, salt, []byte(plaintext), nil)
-	log.Printf("auth/token: EncryptToken success (ciphertext len=%d)", len(ciphertext))
+	logger.Debug("EncryptToken ok")
 	return base64.StdEncoding.EncodeToString(ciphertext), base64.StdEncoding.EncodeToString(salt), nil
 }
 
 // DecryptToken decrypts ciphertext using the given salt. Both can be base64-encoded.
 func DecryptToken(ciphertextBase64, saltBase64 string) (plaintext string, err error) {
-	log.Printf("auth/token: DecryptToken called (ciphertext base64 len=%d)", len(ciphertextBase64))
+	logger.Debug("DecryptToken")
 	key, err := encryptionKey()
 	if err != nil {
-		log.Printf("auth/token: DecryptToken encryption key error: %v", err)
+		logger.Error("DecryptToken: encryption key unavailable", "error", err)
 		return "", err
 	}
 	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextBase64)
 	if err != nil {
-		log.Printf("auth/token: DecryptToken decode ciphertext error: %v", err)
+		logger.Error("DecryptToken: decode ciphertext failed", "error", err)
 		return "", fmt.Errorf("token: decode ciphertext: %w", err)
 	}
 	salt, err := base64.StdEncoding.DecodeString(saltBase64)
@@ -100,9 +103,9 @@ func DecryptToken(ciphertextBase64, saltBase64 string) (plaintext string, err er
 	}
 	plain, err := aead.Open(nil, salt, ciphertext, nil)
 	if err != nil {
-		log.Printf("auth/token: DecryptToken decrypt failed: %v", err)
+		logger.Error("DecryptToken: decrypt failed", "error", err)
 		return "", fmt.Errorf("token: decrypt: %w", err)
 	}
-	log.Printf("auth/token: DecryptToken success (plaintext len=%d)", len(plain))
+	logger.Debug("DecryptToken ok")
 	return string(plain), nil
 }
@@ -0,0 +1,218 @@
+// Package geoip resolves client IPs to country, region and autonomous system from local MaxMind DB
+// (MMDB) files, e.g. GeoLite2-City plus GeoLite2-ASN. Files are reloaded when they change on disk.
+package geoip
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"FeatherProxy/app/internal/logging"
+)
+
+var logger = logging.For(logging.Stats)
+
+const defaultReloadInterval = time.Minute
+
+// maxCachedLookups bounds the Resolver's lookup cache; it is cleared when full.
+const maxCachedLookups = 4096
+
+// locationFields are the parts of City, Country and ASN records fillLocation reads; the rest (names in
+// every language, coordinates, postal codes, …) is skipped when decoding.
+var locationFields = fields{
+	"country":                        {"iso_code": nil},
+	"registered_country":             {"iso_code": nil},
+	"subdivisions":                   {"iso_code": nil, "names": {"en": nil}},
+	"autonomous_system_number":       nil,
+	"autonomous_system_organization": nil,
+}
+
+// Location is what the databases know about an IP. Fields are empty (0) when unknown.
+type Location struct {
+	Country string // ISO 3166-1 alpha-2, e.g. "BR"
+	Region  string // first subdivision: ISO 3166-2 code without the country (e.g. "SP"), or its English name
+	ASN     int64  // autonomous system number
+	ASOrg   string // autonomous system organization
+}
+
+// Config holds GeoIP configuration (from env).
+type Config struct {
+	Paths          []string      // MMDB files; lookups merge what each knows. Empty: GeoIP off.
+	ReloadInterval time.Duration // how often files are checked for changes
+}
+
+// ConfigFromEnv reads GEOIP_DB (comma-separated MMDB paths) and GEOIP_RELOAD_INTERVAL (default 1m).
+func ConfigFromEnv() Config {
+	c := Config{ReloadInterval: defaultReloadInterval}
+	for _, p := range strings.Split(os.Getenv("GEOIP_DB"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			c.Paths = append(c.Paths, p)
+		}
+	}
+	if v := os.Getenv("GEOIP_RELOAD_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			c.ReloadInterval = d
+		}
+	}
+	return c
+}
+
+// dbFile is one loaded database and the file state it was loaded from.
+type dbFile struct {
+	reader  *Reader
+	modTime time.Time
+	size    int64
+}
+
+// Resolver looks IPs up in the configured databases. Run swaps in a database when its file changes, and
+// keeps the previous one if the new file does not load. Results are cached by IP until a reload, so the
+// stats and ACL lookups of one request decode the records once.
+type Resolver struct {
+	config Config
+	dbs    []atomic.Pointer[dbFile] // by config.Paths index; nil until the file loads
+
+	mu    sync.Mutex
+	cache map[netip.Addr]Location
+	gen   uint64 // bumped by reloads, so a lookup racing one does not cache a stale result
+}
+
+// New loads the configured databases. Files that fail to load are logged and retried by Run.
+// It returns nil when no path is configured.
+func New(config Config) *Resolver {
+	if len(config.Paths) == 0 {
+		return nil
+	}
+	if config.ReloadInterval <= 0 {
+		config.ReloadInterval = defaultReloadInterval
+	}
+	r := &Resolver{config: config, dbs: make([]atomic.Pointer[dbFile], len(config.Paths)), cache: make(map[netip.Addr]Location)}
+	r.reload()
+	return r
+}
+
+// Run checks the files every ReloadInterval until ctx is cancelled.
+func (r *Resolver) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reload()
+		}
+	}
+}
+
+// reload (re)loads every file whose modification time or size changed since it was loaded.
+func (r *Resolver) reload() {
+	for i, path := range r.config.Paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			logger.Warn("geoip database not readable", "path", path, "error", err)
+			continue
+		}
+		cur := r.dbs[i].Load()
+		if cur != nil && cur.modTime.Equal(info.ModTime()) && cur.size == info.Size() {
+			continue
+		}
+		reader, err := Open(path)
+		if err != nil {
+			logger.Error("geoip database not loaded", "path", path, "error", err)
+			continue
+		}
+		r.dbs[i].Store(&dbFile{reader: reader, modTime: info.ModTime(), size: info.Size()})
+		r.mu.Lock()
+		clear(r.cache)
+		r.gen++
+		r.mu.Unlock()
+		logger.Info("geoip database loaded", "path", path, "type", reader.DatabaseType, "reload", cur != nil)
+	}
+}
+
+// Lookup returns what the databases know about ip. The first database with a value for a field wins.
+func (r *Resolver) Lookup(ip net.IP) Location {
+	var loc Location
+	if r == nil || ip == nil {
+		return loc
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return loc
+	}
+	addr = addr.Unmap()
+	r.mu.Lock()
+	loc, cached := r.cache[addr]
+	gen := r.gen
+	r.mu.Unlock()
+	if cached {
+		return loc
+	}
+	for i := range r.dbs {
+		db := r.dbs[i].Load()
+		if db == nil {
+			continue
+		}
+		rec, found, err := db.reader.lookup(ip, locationFields)
+		if err != nil || !found {
+			continue
+		}
+		fillLocation(&loc, rec)
+	}
+	r.mu.Lock()
+	if r.gen == gen {
+		if len(r.cache) >= maxCachedLookups {
+			clear(r.cache)
+		}
+		r.cache[addr] = loc
+	}
+	r.mu.Unlock()
+	return loc
+}
+
+// fillLocation sets the fields of loc that are still empty from a City, Country or ASN record.
+func fillLocation(loc *Location, rec map[string]any) {
+	if loc.Country == "" {
+		loc.Country = str(rec, "country", "iso_code")
+		if loc.Country == "" {
+			loc.Country = str(rec, "registered_country", "iso_code")
+		}
+	}
+	if loc.Region == "" {
+		if subs, ok := rec["subdivisions"].([]any); ok && len(subs) > 0 {
+			if sub, ok := subs[0].(map[string]any); ok {
+				loc.Region = str(sub, "iso_code")
+				if loc.Region == "" {
+					loc.Region = str(sub, "names", "en")
+				}
+			}
+		}
+	}
+	if loc.ASN == 0 {
+		if n, ok := rec["autonomous_system_number"].(uint64); ok {
+			loc.ASN = int64(n)
+		}
+	}
+	if loc.ASOrg == "" {
+		loc.ASOrg = str(rec, "autonomous_system_organization")
+	}
+}
+
+// str follows keys through nested maps and returns the string found there, or "".
+func str(m map[string]any, keys ...string) string {
+	var v any = m
+	for _, k := range keys {
+		mm, ok := v.(map[string]any)
+		if !ok {
+			return ""
+		}
+		v = mm[k]
+	}
+	s, _ := v.(string)
+	return s
+}
@@ -0,0 +1,306 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// mmdbPointer makes the test writer emit a pointer to a data section offset.
+type mmdbPointer uint
+
+// mmdbUint16 makes the test writer emit a uint16 field.
+type mmdbUint16 uint16
+
+// mmdbNetwork is one network of a test database and its record.
+type mmdbNetwork struct {
+	cidr   string
+	record map[string]any
+}
+
+// writeMMDB builds a MaxMind DB (format 2) in memory: a search tree over the networks plus a data section
+// with their records.
+func writeMMDB(t *testing.T, ipVersion, recordSize int, networks []mmdbNetwork) []byte {
+	t.Helper()
+	var data bytes.Buffer
+	type child struct {
+		node int // >0: node index
+		data int // >=0 with node == 0: data offset; -1: empty
+	}
+	nodes := [][2]child{{{0, -1}, {0, -1}}}
+	for _, n := range networks {
+		_, ipnet, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ones, _ := ipnet.Mask.Size()
+		addr := []byte(ipnet.IP.To16())
+		if ipVersion == 4 {
+			addr = ipnet.IP.To4()
+		} else if ipnet.IP.To4() != nil {
+			addr = append(make([]byte, 12), ipnet.IP.To4()...)
+			ones += 96
+		}
+		offset := data.Len()
+		data.Write(mmdbEncode(t, n.record))
+		node := 0
+		for i := 0; i < ones; i++ {
+			bit := int(addr[i/8]>>(7-i%8)) & 1
+			if i == ones-1 {
+				nodes[node][bit] = child{0, offset}
+				break
+			}
+			if nodes[node][bit].node == 0 {
+				nodes = append(nodes, [2]child{{0, -1}, {0, -1}})
+				nodes[node][bit] = child{len(nodes) - 1, -1}
+			}
+			node = nodes[node][bit].node
+		}
+	}
+	nodeCount := len(nodes)
+	value := func(c child) uint32 {
+		switch {
+		case c.node > 0:
+			return uint32(c.node)
+		case c.data >= 0:
+			return uint32(nodeCount + 16 + c.data)
+		}
+		return uint32(nodeCount)
+	}
+	var out bytes.Buffer
+	for _, n := range nodes {
+		l, r := value(n[0]), value(n[1])
+		switch recordSize {
+		case 24:
+			out.Write([]byte{byte(l >> 16), byte(l >> 8), byte(l), byte(r >> 16), byte(r >> 8), byte(r)})
+		case 28:
+			out.Write([]byte{byte(l >> 16), byte(l >> 8), byte(l), byte(l>>24)<<4 | byte(r>>24)&0x0f, byte(r >> 16), byte(r >> 8), byte(r)})
+		default:
+			binary.Write(&out, binary.BigEndian, [2]uint32{l, r})
+		}
+	}
+	out.Write(make([]byte, 16))
+	out.Write(data.Bytes())
+	out.Write(metadataMarker)
+	out.Write(mmdbEncode(t, map[string]any{
+		"node_count":                  uint64(nodeCount),
+		"record_size":                 mmdbUint16(recordSize),
+		"ip_version":                  mmdbUint16(ipVersion),
+		"database_type":               "Test-City",
+		"binary_format_major_version": mmdbUint16(2),
+		"binary_format_minor_version": mmdbUint16(0),
+		"languages":                   []any{"en"},
+	}))
+	return out.Bytes()
+}
+
+func mmdbControl(typ, size int) []byte {
+	var b []byte
+	if typ <= 7 {
+		b = []byte{byte(typ << 5)}
+	} else {
+		b = []byte{0, byte(typ - 7)}
+	}
+	switch {
+	case size < 29:
+		b[0] |= byte(size)
+	case size < 285:
+		b[0] |= 29
+		b = append(b, byte(size-29))
+	case size < 65821:
+		b[0] |= 30
+		b = binary.BigEndian.AppendUint16(b, uint16(size-285))
+	default:
+		b[0] |= 31
+		s := size - 65821
+		b = append(b, byte(s>>16), byte(s>>8), byte(s))
+	}
+	return b
+}
+
+func mmdbEncode(t *testing.T, v any) []byte {
+	t.Helper()
+	switch v := v.(type) {
+	case string:
+		return append(mmdbControl(typeString, len(v)), v...)
+	case uint64:
+		b := binary.BigEndian.AppendUint64(nil, v)
+		b = bytes.TrimLeft(b, "\x00")
+		typ := typeUint32
+		if len(b) > 4 {
+			typ = typeUint64
+		}
+		return append(mmdbControl(typ, len(b)), b...)
+	case mmdbUint16:
+		b := bytes.TrimLeft(binary.BigEndian.AppendUint16(nil, uint16(v)), "\x00")
+		return append(mmdbControl(typeUint16, len(b)), b...)
+	case float64:
+		return binary.BigEndian.AppendUint64(mmdbControl(typeDouble, 8), math.Float64bits(v))
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		return mmdbControl(typeBool, size)
+	case mmdbPointer:
+		return []byte{byte(typePointer<<5) | byte(v>>8&7), byte(v)} // 1-byte form: offsets below 2048
+	case []any:
+		b := mmdbControl(typeArray, len(v))
+		for _, e := range v {
+			b = append(b, mmdbEncode(t, e)...)
+		}
+		return b
+	case map[string]any:
+		b := mmdbControl(typeMap, len(v))
+		for k, e := range v {
+			b = append(b, mmdbEncode(t, k)...)
+			b = append(b, mmdbEncode(t, e)...)
+		}
+		return b
+	}
+	t.Fatalf("mmdbEncode: unsupported %T", v)
+	return nil
+}
+
+var (
+	cityRecord = map[string]any{
+		"country":      map[string]any{"iso_code": "BR", "names": map[string]any{"en": "Brazil"}},
+		"subdivisions": []any{map[string]any{"iso_code": "SP", "names": map[string]any{"en": "São Paulo"}}},
+		"location":     map[string]any{"latitude": -23.5, "longitude": -46.6},
+		"is_anycast":   true,
+	}
+	asnRecord = map[string]any{
+		"autonomous_system_number":       uint64(64512),
+		"autonomous_system_organization": "Example Networks",
+	}
+)
+
+func TestReader_lookupAllRecordSizes(t *testing.T) {
+	for _, size := range []int{24, 28, 32} {
+		db := writeMMDB(t, 6, size, []mmdbNetwork{
+			{"203.0.113.0/24", cityRecord},
+			{"2001:db8::/32", map[string]any{"country": map[string]any{"iso_code": "DE"}}},
+		})
+		r, err := FromBytes(db)
+		if err != nil {
+			t.Fatalf("record size %d: %v", size, err)
+		}
+		rec, found, err := r.Lookup(net.ParseIP("203.0.113.9"))
+		if err != nil || !found {
+			t.Fatalf("record size %d: found %v, err %v", size, found, err)
+		}
+		if !reflect.DeepEqual(rec, cityRecord) {
+			t.Errorf("record size %d: record = %#v", size, rec)
+		}
+		if rec, found, _ := r.Lookup(net.ParseIP("2001:db8::1")); !found || str(rec, "country", "iso_code") != "DE" {
+			t.Errorf("record size %d: IPv6 record = %v (found %v)", size, rec, found)
+		}
+		if _, found, err := r.Lookup(net.ParseIP("198.51.100.1")); found || err != nil {
+			t.Errorf("record size %d: unknown IP found %v, err %v", size, found, err)
+		}
+	}
+}
+
+func TestReader_lookupDecodesSelectedFields(t *testing.T) {
+	r, err := FromBytes(writeMMDB(t, 6, 24, []mmdbNetwork{{"203.0.113.0/24", cityRecord}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, found, err := r.lookup(net.ParseIP("203.0.113.9"), locationFields)
+	if err != nil || !found {
+		t.Fatalf("found %v, err %v", found, err)
+	}
+	want := map[string]any{
+		"country":      map[string]any{"iso_code": "BR"},
+		"subdivisions": []any{map[string]any{"iso_code": "SP", "names": map[string]any{"en": "São Paulo"}}},
+	}
+	if !reflect.DeepEqual(rec, want) {
+		t.Errorf("record = %#v, want %#v", rec, want)
+	}
+
+	// A map claiming more entries than the data holds fails without allocating for the claimed size.
+	huge := []byte{0xe0 | 31, 0xff, 0xff, 0xff}
+	if _, _, err := (&decoder{data: huge}).decode(0, 0); !errors.Is(err, ErrInvalidDatabase) {
+		t.Errorf("oversized map: err = %v", err)
+	}
+}
+
+func TestReader_pointersAndInvalidFiles(t *testing.T) {
+	// The second record's organization points at the first record's value: data offset 33, after the map
+	// control byte (1) and the 30-byte key with its control and size bytes (32).
+	db := writeMMDB(t, 4, 28, []mmdbNetwork{
+		{"10.0.0.0/8", map[string]any{"autonomous_system_organization": "Shared Org"}},
+		{"192.0.2.0/24", map[string]any{"autonomous_system_organization": mmdbPointer(33)}},
+	})
+	r, err := FromBytes(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, found, err := r.Lookup(net.ParseIP("192.0.2.1"))
+	if err != nil || !found {
+		t.Fatalf("found %v, err %v", found, err)
+	}
+	if got := str(rec, "autonomous_system_organization"); got != "Shared Org" {
+		t.Errorf("pointer field = %q, want Shared Org", got)
+	}
+	if _, found, _ := r.Lookup(net.ParseIP("2001:db8::1")); found {
+		t.Error("IPv6 lookup in an IPv4 database found a record")
+	}
+
+	if _, err := FromBytes([]byte("not a database")); !errors.Is(err, ErrInvalidDatabase) {
+		t.Errorf("garbage: err = %v", err)
+	}
+	truncated := append([]byte(nil), db[:len(db)/2]...)
+	if _, err := FromBytes(truncated); err == nil {
+		t.Error("truncated database accepted")
+	}
+}
+
+func TestResolver_mergesAndReloads(t *testing.T) {
+	dir := t.TempDir()
+	city, asn := filepath.Join(dir, "city.mmdb"), filepath.Join(dir, "asn.mmdb")
+	if err := os.WriteFile(city, writeMMDB(t, 6, 24, []mmdbNetwork{{"203.0.113.0/24", cityRecord}}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(asn, writeMMDB(t, 6, 24, []mmdbNetwork{{"203.0.0.0/16", asnRecord}}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if New(Config{}) != nil {
+		t.Error("resolver without paths is not nil")
+	}
+	r := New(Config{Paths: []string{city, asn, filepath.Join(dir, "missing.mmdb")}})
+	want := Location{Country: "BR", Region: "SP", ASN: 64512, ASOrg: "Example Networks"}
+	if got := r.Lookup(net.ParseIP("203.0.113.7")); got != want {
+		t.Errorf("lookup = %+v, want %+v", got, want)
+	}
+
+	// A new file version is picked up; a broken one keeps the loaded database.
+	later := time.Now().Add(time.Hour)
+	if err := os.WriteFile(city, writeMMDB(t, 6, 24, []mmdbNetwork{{"203.0.113.0/24", map[string]any{"country": map[string]any{"iso_code": "PT"}}}}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(city, later, later)
+	r.reload()
+	if got := r.Lookup(net.ParseIP("203.0.113.7")); got.Country != "PT" || got.Region != "" || got.ASN != 64512 {
+		t.Errorf("after reload: %+v", got)
+	}
+	if err := os.WriteFile(city, []byte("corrupt"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(city, later.Add(time.Hour), later.Add(time.Hour))
+	r.reload()
+	if got := r.Lookup(net.ParseIP("203.0.113.7")); got.Country != "PT" {
+		t.Errorf("after a corrupt update: %+v, want the previous database", got)
+	}
+	var nilResolver *Resolver
+	if got := nilResolver.Lookup(net.ParseIP("203.0.113.7")); got != (Location{}) {
+		t.Errorf("nil resolver lookup = %+v", got)
+	}
+}
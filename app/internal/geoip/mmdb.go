@@ -0,0 +1,397 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// metadataMarker starts the metadata section at the end of a MaxMind DB file.
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// maxDecodeDepth bounds nesting (and pointer chains) when decoding, so a corrupt file cannot recurse forever.
+const maxDecodeDepth = 32
+
+// ErrInvalidDatabase is returned for files that are not MaxMind DB (MMDB, format version 2) databases.
+var ErrInvalidDatabase = errors.New("geoip: invalid MaxMind DB file")
+
+// Reader looks up IP addresses in a MaxMind DB file (GeoLite2 / GeoIP2 City, Country or ASN, or any
+// database in that format). The whole file is held in memory; a Reader is safe for concurrent use.
+type Reader struct {
+	buf          []byte
+	data         []byte // data section
+	nodeCount    uint
+	recordSize   uint // bits per record: 24, 28 or 32
+	nodeBytes    uint
+	ipVersion    uint
+	ipv4Start    uint // node reached after 96 zero bits in an IPv6 tree (where IPv4 lives)
+	DatabaseType string
+}
+
+// Open reads and validates a MaxMind DB file.
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromBytes(buf)
+}
+
+// FromBytes parses a MaxMind DB held in memory. buf must not be modified afterwards.
+func FromBytes(buf []byte) (*Reader, error) {
+	i := bytes.LastIndex(buf, metadataMarker)
+	if i < 0 {
+		return nil, ErrInvalidDatabase
+	}
+	metaBuf := buf[i+len(metadataMarker):]
+	v, _, err := (&decoder{data: metaBuf}).decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: metadata: %v", ErrInvalidDatabase, err)
+	}
+	meta, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", ErrInvalidDatabase)
+	}
+	r := &Reader{buf: buf}
+	r.nodeCount, _ = asUint(meta["node_count"])
+	r.recordSize, _ = asUint(meta["record_size"])
+	r.ipVersion, _ = asUint(meta["ip_version"])
+	r.DatabaseType, _ = meta["database_type"].(string)
+	if major, _ := asUint(meta["binary_format_major_version"]); major != 2 {
+		return nil, fmt.Errorf("%w: format version %d", ErrInvalidDatabase, major)
+	}
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("%w: record size %d", ErrInvalidDatabase, r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("%w: ip version %d", ErrInvalidDatabase, r.ipVersion)
+	}
+	r.nodeBytes = r.recordSize / 4
+	treeSize := r.nodeCount * r.nodeBytes
+	if treeSize+16 > uint(i) {
+		return nil, fmt.Errorf("%w: search tree larger than the file", ErrInvalidDatabase)
+	}
+	r.data = buf[treeSize+16 : i]
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for n := 0; n < 96 && node < r.nodeCount; n++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// Lookup returns the record for ip, decoded into maps, slices, strings, bools and numbers (uint64,
+// int64, float64; uint128 as []byte). found is false when the database has no entry for ip.
+func (r *Reader) Lookup(ip net.IP) (record map[string]any, found bool, err error) {
+	return r.lookup(ip, nil)
+}
+
+// lookup is Lookup decoding only the fields in f (nil: the whole record).
+func (r *Reader) lookup(ip net.IP, f fields) (record map[string]any, found bool, err error) {
+	var bits []byte
+	node := uint(0)
+	if ip4 := ip.To4(); ip4 != nil {
+		bits = ip4
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if ip16 := ip.To16(); ip16 != nil && r.ipVersion == 6 {
+		bits = ip16
+	} else {
+		return nil, false, nil
+	}
+	for i := 0; i < len(bits)*8 && node < r.nodeCount; i++ {
+		node = r.record(node, uint(bits[i/8]>>(7-i%8))&1)
+	}
+	if node == r.nodeCount {
+		return nil, false, nil
+	}
+	if node < r.nodeCount {
+		return nil, false, fmt.Errorf("%w: search tree ended inside the tree", ErrInvalidDatabase)
+	}
+	offset := node - r.nodeCount - 16
+	if offset >= uint(len(r.data)) {
+		return nil, false, fmt.Errorf("%w: data pointer out of range", ErrInvalidDatabase)
+	}
+	v, _, err := (&decoder{data: r.data}).decodeFields(offset, 0, f)
+	if err != nil {
+		return nil, false, err
+	}
+	record, ok := v.(map[string]any)
+	if !ok {
+		return nil, false, fmt.Errorf("%w: record is not a map", ErrInvalidDatabase)
+	}
+	return record, true, nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of a search tree node.
+func (r *Reader) record(node, bit uint) uint {
+	b := r.buf[node*r.nodeBytes : (node+1)*r.nodeBytes]
+	switch r.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+// Data section field types.
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// decoder decodes fields of a data section; pointers are offsets into data.
+type decoder struct {
+	data []byte
+}
+
+// fields selects the parts of a record to decode: map keys to their own selection, where nil decodes the
+// whole value. It applies to each element of an array. Keys not listed are skipped without decoding.
+type fields map[string]fields
+
+var errTruncated = fmt.Errorf("%w: field runs past the end of the data", ErrInvalidDatabase)
+
+// decode decodes the field at offset and returns it and the offset after it.
+func (d *decoder) decode(offset uint, depth int) (any, uint, error) {
+	return d.decodeFields(offset, depth, nil)
+}
+
+// decodeFields is decode keeping only the map keys selected by f (nil: all).
+func (d *decoder) decodeFields(offset uint, depth int, f fields) (any, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, fmt.Errorf("%w: nesting too deep", ErrInvalidDatabase)
+	}
+	typ, size, offset, err := d.header(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if typ == typePointer {
+		ptr, next, err := d.pointer(byte(size), offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decodeFields(ptr, depth+1, f)
+		return v, next, err
+	}
+
+	switch typ {
+	case typeMap:
+		n := min(size, 1024)
+		if f != nil {
+			n = min(n, uint(len(f)))
+		}
+		m := make(map[string]any, n)
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("%w: map key is not a string", ErrInvalidDatabase)
+			}
+			sub, want := f[key]
+			if f != nil && !want {
+				if offset, err = d.skip(next, depth+1); err != nil {
+					return nil, 0, err
+				}
+				continue
+			}
+			v, next, err := d.decodeFields(next, depth+1, sub)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+			offset = next
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, min(size, 1024))
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decodeFields(offset, depth+1, f)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	case typeContainer, typeEndMarker:
+		return nil, offset, nil
+	}
+
+	if offset+size > uint(len(d.data)) {
+		return nil, 0, errTruncated
+	}
+	b := d.data[offset : offset+size]
+	next := offset + size
+	switch typ {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes, typeUint128:
+		return append([]byte(nil), b...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("%w: double of %d bytes", ErrInvalidDatabase, size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("%w: float of %d bytes", ErrInvalidDatabase, size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("%w: integer of %d bytes", ErrInvalidDatabase, size)
+		}
+		var u uint64
+		for _, c := range b {
+			u = u<<8 | uint64(c)
+		}
+		return u, next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("%w: int32 of %d bytes", ErrInvalidDatabase, size)
+		}
+		var u uint32
+		for _, c := range b {
+			u = u<<8 | uint32(c)
+		}
+		if size == 4 {
+			return int64(int32(u)), next, nil
+		}
+		return int64(u), next, nil
+	}
+	return nil, 0, fmt.Errorf("%w: unknown field type %d", ErrInvalidDatabase, typ)
+}
+
+// header reads the control byte (and extended type and size bytes) of the field at offset. It returns the
+// field type, its size and the offset of its payload; for a pointer, size is the control byte.
+func (d *decoder) header(offset uint) (typ, size, next uint, err error) {
+	if offset >= uint(len(d.data)) {
+		return 0, 0, 0, errTruncated
+	}
+	ctrl := d.data[offset]
+	offset++
+	typ = uint(ctrl >> 5)
+	if typ == typePointer {
+		return typ, uint(ctrl), offset, nil
+	}
+	if typ == typeExtended {
+		if offset >= uint(len(d.data)) {
+			return 0, 0, 0, errTruncated
+		}
+		typ = 7 + uint(d.data[offset])
+		offset++
+	}
+	size = uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28 // 1, 2 or 3 extra size bytes
+		if offset+n > uint(len(d.data)) {
+			return 0, 0, 0, errTruncated
+		}
+		var extra uint
+		for _, b := range d.data[offset : offset+n] {
+			extra = extra<<8 | uint(b)
+		}
+		offset += n
+		switch n {
+		case 1:
+			size = 29 + extra
+		case 2:
+			size = 285 + extra
+		default:
+			size = 65821 + extra
+		}
+	}
+	return typ, size, offset, nil
+}
+
+// skip returns the offset after the field at offset without decoding it. Pointers are not followed.
+func (d *decoder) skip(offset uint, depth int) (uint, error) {
+	if depth > maxDecodeDepth {
+		return 0, fmt.Errorf("%w: nesting too deep", ErrInvalidDatabase)
+	}
+	typ, size, offset, err := d.header(offset)
+	if err != nil {
+		return 0, err
+	}
+	switch typ {
+	case typePointer:
+		_, next, err := d.pointer(byte(size), offset)
+		return next, err
+	case typeMap, typeArray:
+		n := size
+		if typ == typeMap {
+			n *= 2 // keys and values
+		}
+		for i := uint(0); i < n; i++ {
+			if offset, err = d.skip(offset, depth+1); err != nil {
+				return 0, err
+			}
+		}
+		return offset, nil
+	case typeBool, typeContainer, typeEndMarker:
+		return offset, nil
+	}
+	if offset+size > uint(len(d.data)) {
+		return 0, errTruncated
+	}
+	return offset + size, nil
+}
+
+// pointer decodes a pointer field whose control byte is ctrl and whose payload starts at offset.
+func (d *decoder) pointer(ctrl byte, offset uint) (ptr, next uint, err error) {
+	n := uint(ctrl>>3)&3 + 1
+	if offset+n > uint(len(d.data)) {
+		return 0, 0, errTruncated
+	}
+	b := d.data[offset : offset+n]
+	v := uint(ctrl & 7)
+	switch n {
+	case 1:
+		ptr = v<<8 | uint(b[0])
+	case 2:
+		ptr = (v<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+	case 3:
+		ptr = (v<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+	default:
+		ptr = uint(binary.BigEndian.Uint32(b))
+	}
+	return ptr, offset + n, nil
+}
+
+// asUint converts a decoded unsigned integer to uint.
+func asUint(v any) (uint, bool) {
+	u, ok := v.(uint64)
+	return uint(u), ok
+}
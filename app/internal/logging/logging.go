@@ -0,0 +1,239 @@
+// Package logging is the application's leveled, structured logger (log/slog). Each subsystem has its own
+// level, set from the environment at startup (LOG_LEVEL, LOG_LEVELS) and changeable at runtime through the
+// admin API. Output is text or JSON; credentials and client payloads are redacted before they are written.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Subsystems.
+const (
+	App   = "app" // startup, shutdown and anything logged through the standard log package
+	Proxy = "proxy"
+	ACL   = "acl"
+	Cache = "cache"
+	Repo  = "repo"
+	Stats = "stats"
+	UI    = "ui"
+)
+
+// Subsystems lists every subsystem with its own level.
+var Subsystems = []string{App, Proxy, ACL, Cache, Repo, Stats, UI}
+
+// LevelOff disables a subsystem's output entirely.
+const LevelOff = slog.Level(12)
+
+// Formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Config configures the process-wide output.
+type Config struct {
+	Format      string                // FormatText (default) or FormatJSON
+	Level       slog.Level            // default level of every subsystem
+	Levels      map[string]slog.Level // per-subsystem overrides
+	LogPayloads bool                  // when true, request/response bodies are not redacted (secrets always are)
+	Output      io.Writer             // default os.Stderr
+}
+
+// ConfigFromEnv reads LOG_FORMAT, LOG_LEVEL, LOG_LEVELS (e.g. "proxy=debug,cache=warn") and LOG_PAYLOADS.
+// Invalid values are ignored.
+func ConfigFromEnv() Config {
+	c := Config{Format: FormatText, Level: slog.LevelInfo, Levels: map[string]slog.Level{}}
+	if v := strings.ToLower(strings.TrimSpace(os.Getenv("LOG_FORMAT"))); v == FormatJSON {
+		c.Format = FormatJSON
+	}
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if l, err := ParseLevel(v); err == nil {
+			c.Level = l
+		}
+	}
+	for _, pair := range strings.Split(os.Getenv("LOG_LEVELS"), ",") {
+		name, level, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if l, err := ParseLevel(level); err == nil && levels[name] != nil {
+			c.Levels[name] = l
+		}
+	}
+	v := os.Getenv("LOG_PAYLOADS")
+	c.LogPayloads = v == "1" || strings.EqualFold(v, "true")
+	return c
+}
+
+var (
+	levels = map[string]*slog.LevelVar{}
+	root   atomic.Pointer[rootHandler]
+)
+
+// rootHandler is the configured output handler; subsystem handlers derive from whichever is current.
+type rootHandler struct {
+	h slog.Handler
+}
+
+func init() {
+	for _, s := range Subsystems {
+		levels[s] = new(slog.LevelVar)
+	}
+	Configure(Config{Level: slog.LevelInfo})
+}
+
+// Configure sets the output and levels. It also routes the standard log package (and slog's default
+// logger) through the App subsystem, so third-party output is leveled and redacted too.
+func Configure(c Config) {
+	out := c.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: redactor(c.LogPayloads)}
+	var h slog.Handler
+	if c.Format == FormatJSON {
+		h = slog.NewJSONHandler(out, opts)
+	} else {
+		h = slog.NewTextHandler(out, opts)
+	}
+	root.Store(&rootHandler{h: h})
+	for name, lv := range levels {
+		l := c.Level
+		if o, ok := c.Levels[name]; ok {
+			l = o
+		}
+		lv.Set(l)
+	}
+	slog.SetDefault(For(App))
+}
+
+// For returns the logger of a subsystem. Unknown subsystems log at the App level.
+func For(subsystem string) *slog.Logger {
+	lv := levels[subsystem]
+	if lv == nil {
+		lv = levels[App]
+	}
+	return slog.New(&subsystemHandler{subsystem: subsystem, level: lv})
+}
+
+// ParseLevel parses debug, info, warn, error or off (case-insensitive).
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	case "off", "none":
+		return LevelOff, nil
+	}
+	return 0, fmt.Errorf("logging: unknown level %q (want debug, info, warn, error or off)", s)
+}
+
+// LevelName returns the name ParseLevel accepts for l.
+func LevelName(l slog.Level) string {
+	if l >= LevelOff {
+		return "off"
+	}
+	return strings.ToLower(l.String())
+}
+
+// SetLevel changes a subsystem's level at runtime.
+func SetLevel(subsystem string, l slog.Level) error {
+	lv := levels[subsystem]
+	if lv == nil {
+		return fmt.Errorf("logging: unknown subsystem %q", subsystem)
+	}
+	lv.Set(l)
+	return nil
+}
+
+// Levels returns the current level name of every subsystem.
+func Levels() map[string]string {
+	out := make(map[string]string, len(levels))
+	for name, lv := range levels {
+		out[name] = LevelName(lv.Level())
+	}
+	return out
+}
+
+// SubsystemNames returns the subsystem names, sorted.
+func SubsystemNames() []string {
+	names := append([]string(nil), Subsystems...)
+	sort.Strings(names)
+	return names
+}
+
+type ctxAttrsKey struct{}
+
+// ContextWithAttrs returns a context whose log records (via the *Context logging methods) carry attrs,
+// e.g. the request ID of the request being served.
+func ContextWithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev, _ := ctx.Value(ctxAttrsKey{}).([]slog.Attr)
+	all := make([]slog.Attr, 0, len(prev)+len(attrs))
+	all = append(append(all, prev...), attrs...)
+	return context.WithValue(ctx, ctxAttrsKey{}, all)
+}
+
+// subsystemHandler filters by the subsystem's level and forwards to the current root handler, adding the
+// subsystem name and any context attrs.
+type subsystemHandler struct {
+	subsystem string
+	level     *slog.LevelVar
+	attrs     []slog.Attr
+	groups    []string
+}
+
+func (h *subsystemHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level.Level()
+}
+
+func (h *subsystemHandler) Handle(ctx context.Context, rec slog.Record) error {
+	next := root.Load().h.WithAttrs([]slog.Attr{slog.String("subsystem", h.subsystem)})
+	if len(h.attrs) > 0 {
+		next = next.WithAttrs(h.attrs)
+	}
+	for _, g := range h.groups {
+		next = next.WithGroup(g)
+	}
+	if attrs, _ := ctx.Value(ctxAttrsKey{}).([]slog.Attr); len(attrs) > 0 {
+		rec = rec.Clone()
+		rec.AddAttrs(attrs...)
+	}
+	return next.Handle(ctx, rec)
+}
+
+func (h *subsystemHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = append([]slog.Attr(nil), h.attrs...)
+	prefix := ""
+	if len(h.groups) > 0 {
+		// Attrs added inside a group are flattened into dotted keys.
+		prefix = strings.Join(h.groups, ".") + "."
+	}
+	for _, a := range attrs {
+		a.Key = prefix + a.Key
+		c.attrs = append(c.attrs, a)
+	}
+	return &c
+}
+
+func (h *subsystemHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.groups = append(append([]string(nil), h.groups...), name)
+	return &c
+}
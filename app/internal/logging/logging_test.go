@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// capture configures JSON output into a buffer for the duration of the test.
+func capture(t *testing.T, c Config) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	c.Format = FormatJSON
+	c.Output = &buf
+	Configure(c)
+	t.Cleanup(func() { Configure(Config{Level: slog.LevelInfo}) })
+	return &buf
+}
+
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestSubsystemLevels(t *testing.T) {
+	buf := capture(t, Config{Level: slog.LevelInfo, Levels: map[string]slog.Level{Proxy: slog.LevelDebug, Cache: LevelOff}})
+	For(Proxy).Debug("proxy debug")
+	For(Repo).Debug("repo debug")
+	For(Repo).Info("repo info")
+	For(Cache).Error("cache error")
+	got := records(t, buf)
+	if len(got) != 2 || got[0]["msg"] != "proxy debug" || got[1]["msg"] != "repo info" || got[1]["subsystem"] != Repo {
+		t.Fatalf("records = %v", got)
+	}
+
+	if err := SetLevel(Repo, slog.LevelWarn); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	For(Repo).Info("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("repo info logged after SetLevel(warn): %s", buf)
+	}
+	if Levels()[Repo] != "warn" || Levels()[Cache] != "off" {
+		t.Errorf("Levels() = %v", Levels())
+	}
+	if err := SetLevel("nope", slog.LevelInfo); err == nil {
+		t.Error("SetLevel(unknown): want error")
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	buf := capture(t, Config{Level: slog.LevelInfo})
+	For(Proxy).Info("req",
+		"Authorization", "Bearer abc.def.ghi",
+		"client_secret", "s3cr3t",
+		"header", "Basic dXNlcjpwYXNz",
+		"body", `{"card":"4111"}`,
+		"token_type", "bearer",
+		"route", "r1",
+		slog.Group("upstream", "password", "hunter2"),
+	)
+	got := records(t, buf)[0]
+	for _, k := range []string{"Authorization", "client_secret", "header"} {
+		if got[k] != Redacted {
+			t.Errorf("%s = %v, want redacted", k, got[k])
+		}
+	}
+	if got["body"] != "[REDACTED 15 bytes]" {
+		t.Errorf("body = %v", got["body"])
+	}
+	if got["token_type"] != "bearer" || got["route"] != "r1" {
+		t.Errorf("non-secret fields changed: %v", got)
+	}
+	if up, _ := got["upstream"].(map[string]any); up["password"] != Redacted {
+		t.Errorf("upstream = %v", got["upstream"])
+	}
+	if strings.Contains(buf.String(), "hunter2") || strings.Contains(buf.String(), "abc.def") {
+		t.Errorf("secret leaked: %s", buf)
+	}
+
+	buf = capture(t, Config{Level: slog.LevelInfo, LogPayloads: true})
+	For(Proxy).Info("req", "body", "hello", "token", "t")
+	got = records(t, buf)[0]
+	if got["body"] != "hello" || got["token"] != Redacted {
+		t.Errorf("with LogPayloads: %v", got)
+	}
+}
+
+func TestContextAttrsAndStdlibLog(t *testing.T) {
+	buf := capture(t, Config{Level: slog.LevelInfo})
+	ctx := ContextWithAttrs(context.Background(), slog.String("request_id", "req-1"))
+	For(Proxy).With("route", "r1").InfoContext(ctx, "matched")
+	log.Printf("from the log package")
+	got := records(t, buf)
+	if len(got) != 2 {
+		t.Fatalf("records = %v", got)
+	}
+	if got[0]["request_id"] != "req-1" || got[0]["route"] != "r1" {
+		t.Errorf("record = %v", got[0])
+	}
+	if got[1]["subsystem"] != App || got[1]["msg"] != "from the log package" {
+		t.Errorf("stdlib record = %v", got[1])
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("LOG_FORMAT", "JSON")
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("LOG_LEVELS", "proxy=debug, cache = error, bogus=info, repo=loud")
+	t.Setenv("LOG_PAYLOADS", "true")
+	c := ConfigFromEnv()
+	if c.Format != FormatJSON || c.Level != slog.LevelWarn || !c.LogPayloads {
+		t.Errorf("config = %+v", c)
+	}
+	if len(c.Levels) != 2 || c.Levels[Proxy] != slog.LevelDebug || c.Levels[Cache] != slog.LevelError {
+		t.Errorf("levels = %v", c.Levels)
+	}
+}
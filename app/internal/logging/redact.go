@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Redacted replaces the value of a redacted attribute.
+const Redacted = "[REDACTED]"
+
+// secretKeys are attribute keys (lower-case) whose values are always redacted. Keys ending in one of
+// secretSuffixes are redacted too (e.g. "client_secret", "refresh_token").
+var secretKeys = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"token":               true,
+	"password":            true,
+	"passwd":              true,
+	"secret":              true,
+	"api_key":             true,
+	"apikey":              true,
+	"credentials":         true,
+	"plaintext":           true,
+	"private_key":         true,
+	"encryption_key":      true,
+	"x-api-key":           true,
+}
+
+var secretSuffixes = []string{"_token", "-token", "_password", "_secret", "-secret"}
+
+// payloadKeys are attribute keys carrying client payloads; redacted unless Config.LogPayloads is set.
+var payloadKeys = map[string]bool{
+	"body":          true,
+	"payload":       true,
+	"request_body":  true,
+	"response_body": true,
+}
+
+// credentialPrefixes mark string values that are credentials whatever their key.
+var credentialPrefixes = []string{"bearer ", "basic ", "digest ", "negotiate "}
+
+// redactor returns the ReplaceAttr function applied to every attribute before it is written.
+func redactor(logPayloads bool) func(groups []string, a slog.Attr) slog.Attr {
+	return func(_ []string, a slog.Attr) slog.Attr {
+		key := strings.ToLower(a.Key)
+		if i := strings.LastIndexByte(key, '.'); i >= 0 {
+			key = key[i+1:]
+		}
+		if isSecretKey(key) {
+			return slog.String(a.Key, Redacted)
+		}
+		if payloadKeys[key] && !logPayloads {
+			return slog.String(a.Key, fmt.Sprintf("[REDACTED %d bytes]", payloadLen(a.Value)))
+		}
+		if a.Value.Kind() == slog.KindString && looksLikeCredential(a.Value.String()) {
+			return slog.String(a.Key, Redacted)
+		}
+		return a
+	}
+}
+
+// IsSecretKey reports whether values under key (a log attribute, header or parameter name; any case) are
+// credentials that must not be written out.
+func IsSecretKey(key string) bool {
+	return isSecretKey(strings.ToLower(key))
+}
+
+func isSecretKey(key string) bool {
+	if secretKeys[key] {
+		return true
+	}
+	for _, s := range secretSuffixes {
+		if strings.HasSuffix(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func looksLikeCredential(v string) bool {
+	if len(v) < 8 {
+		return false
+	}
+	lv := strings.ToLower(v[:min(len(v), 10)])
+	for _, p := range credentialPrefixes {
+		if strings.HasPrefix(lv, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func payloadLen(v slog.Value) int {
+	switch v.Kind() {
+	case slog.KindString:
+		return len(v.String())
+	case slog.KindAny:
+		if b, ok := v.Any().([]byte); ok {
+			return len(b)
+		}
+	}
+	return len(v.String())
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Default is the registry served at /metrics.
+var Default = NewRegistry()
+
+// Proxy request metrics. source, route and target are UUIDs; route and target are empty when no route
+// matched or the request was not forwarded.
+var (
+	Requests = Default.NewCounterVec("featherproxy_requests_total",
+		"Requests handled by the proxy.", "source", "route", "target", "method", "status_class")
+	RequestDuration = Default.NewHistogramVec("featherproxy_request_duration_seconds",
+		"Time from receiving a request to finishing its response.", DefBuckets, "source", "route", "target", "method", "status_class")
+	InFlight = Default.NewGaugeVec("featherproxy_requests_in_flight",
+		"Requests currently being served.", "source")
+	UpstreamErrors = Default.NewCounterVec("featherproxy_upstream_errors_total",
+		"Failed upstream round trips (connection errors and timeouts).", "source", "route", "target", "kind")
+	ACLDenials = Default.NewCounterVec("featherproxy_acl_denials_total",
+		"Requests rejected by a source server's ACL.", "source")
+	AuthDenials = Default.NewCounterVec("featherproxy_auth_denials_total",
+		"Requests rejected by a route's source authentication.", "source", "route")
+	ListenerUp = Default.NewGaugeVec("featherproxy_listener_up",
+		"1 while a source server's listener is accepting connections, 0 when it failed to start or stopped.", "source", "address", "protocol")
+)
+
+// Cache metrics; cache is the backend ("memory", "redis").
+var (
+	CacheHits   = Default.NewCounterVec("featherproxy_cache_hits_total", "Cache lookups that found a value.", "cache")
+	CacheMisses = Default.NewCounterVec("featherproxy_cache_misses_total", "Cache lookups that found nothing.", "cache")
+)
+
+// Stats pipeline metrics; sink is the stats sink name ("db", "file", "statsd", "http").
+var (
+	StatsQueueDepth = Default.NewGaugeVec("featherproxy_stats_queue_depth",
+		"Stats waiting in a sink's queue to be written.", "sink")
+	StatsDropped = Default.NewCounterVec("featherproxy_stats_dropped_total",
+		"Stats a sink lost: its queue was full (and its spill queue, if any), or a failed batch could not be spilled.", "sink")
+	StatsSinkWritten = Default.NewCounterVec("featherproxy_stats_sink_written_total",
+		"Stats a sink wrote successfully.", "sink")
+	StatsSinkErrors = Default.NewCounterVec("featherproxy_stats_sink_errors_total",
+		"Batches a sink failed to write (spilled, or dropped without a spill queue).", "sink")
+	StatsSpilled = Default.NewCounterVec("featherproxy_stats_spilled_total",
+		"Stats written to a sink's disk spill queue (queue overflow or failed write).", "sink")
+	StatsReplayed = Default.NewCounterVec("featherproxy_stats_replayed_total",
+		"Spilled stats replayed to their sink.", "sink")
+	StatsSpillBytes = Default.NewGaugeVec("featherproxy_stats_spill_bytes",
+		"Size of a sink's spill queue segments on disk.", "sink")
+	StatsSampledOut = Default.NewCounterVec("featherproxy_stats_sampled_out_total",
+		"Stats not recorded because sampling skipped them (the kept ones carry their weight).")
+	StatsLiveSubscribers = Default.NewGaugeVec("featherproxy_stats_live_subscribers",
+		"Clients subscribed to the live stats feed.")
+	StatsLiveDropped = Default.NewCounterVec("featherproxy_stats_live_dropped_total",
+		"Stats a live feed subscriber missed because its buffer was full.")
+)
+
+// Alerting metrics.
+var (
+	AlertsFiring = Default.NewGaugeVec("featherproxy_alerts_firing",
+		"Alert rules firing at the last evaluation.")
+	AlertNotifications = Default.NewCounterVec("featherproxy_alert_notifications_total",
+		"Webhook notifications sent; status is firing or resolved, result ok or error.", "status", "result")
+)
+
+// StatusClass returns the label for an HTTP status: "1xx" … "5xx".
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "other"
+	}
+	return string(rune('0'+status/100)) + "xx"
+}
+
+// MethodLabel returns the request method, or "OTHER" for non-standard methods, so clients cannot create
+// unbounded label values.
+func MethodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+// Endpoint placement, from METRICS_ADDR.
+const (
+	// EndpointAdmin serves /metrics on the admin (UI/API) server.
+	EndpointAdmin = ""
+	// EndpointOff disables the endpoint (metrics are still collected).
+	EndpointOff = "off"
+)
+
+// AddrFromEnv returns METRICS_ADDR: EndpointAdmin (unset), EndpointOff, or a listen address such as
+// ":9100" for a dedicated metrics server.
+func AddrFromEnv() string {
+	v := strings.TrimSpace(os.Getenv("METRICS_ADDR"))
+	if strings.EqualFold(v, EndpointOff) || strings.EqualFold(v, "false") {
+		return EndpointOff
+	}
+	return v
+}
+
+// Serve runs a dedicated metrics server on addr (GET /metrics) until ctx is cancelled.
+func Serve(ctx context.Context, addr string, reg *Registry) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", reg.Handler())
+	srv := &http.Server{Addr: addr, Handler: mux, ReadTimeout: 10 * time.Second, WriteTimeout: 30 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
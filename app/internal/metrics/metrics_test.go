@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWriteText(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.NewCounterVec("test_requests_total", "Requests.\nSecond line.", "route", "status_class")
+	c.With("r2", "2xx").Inc()
+	c.With("r1", "5xx").Add(2)
+	c.With("r1", `a"b\`).Inc()
+	g := reg.NewGaugeVec("test_in_flight", "In flight.")
+	g.With().Inc()
+	g.With().Inc()
+	g.With().Dec()
+	h := reg.NewHistogramVec("test_duration_seconds", "Duration.", []float64{0.5, 0.1}, "route")
+	h.With("r1").Observe(0.05)
+	h.With("r1").Observe(0.1)
+	h.With("r1").Observe(3)
+
+	var buf bytes.Buffer
+	if err := reg.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_requests_total Requests.\nSecond line.
+# TYPE test_requests_total counter
+test_requests_total{route="r1",status_class="5xx"} 2
+test_requests_total{route="r1",status_class="a\"b\\"} 1
+test_requests_total{route="r2",status_class="2xx"} 1
+# HELP test_in_flight In flight.
+# TYPE test_in_flight gauge
+test_in_flight 1
+# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="r1",le="0.1"} 2
+test_duration_seconds_bucket{route="r1",le="0.5"} 2
+test_duration_seconds_bucket{route="r1",le="+Inf"} 3
+test_duration_seconds_sum{route="r1"} 3.15
+test_duration_seconds_count{route="r1"} 3
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteText:\n%s\nwant:\n%s", got, want)
+	}
+
+	g.Delete()
+	buf.Reset()
+	_ = reg.WriteText(&buf)
+	if strings.Contains(buf.String(), "test_in_flight 1") {
+		t.Error("deleted gauge series still written")
+	}
+}
+
+func TestRegistryDuplicatePanics(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounterVec("dup_total", "x")
+	defer func() {
+		if recover() == nil {
+			t.Error("duplicate registration did not panic")
+		}
+	}()
+	reg.NewGaugeVec("dup_total", "x")
+}
+
+func TestHandler(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounterVec("x_total", "x").With().Inc()
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("status = %d content-type = %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), "x_total 1\n") {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+}
+
+func TestLabels(t *testing.T) {
+	for status, want := range map[int]string{200: "2xx", 404: "4xx", 503: "5xx", 99: "other", 600: "other"} {
+		if got := StatusClass(status); got != want {
+			t.Errorf("StatusClass(%d) = %q, want %q", status, got, want)
+		}
+	}
+	if MethodLabel("GET") != "GET" || MethodLabel("PROPFIND") != "OTHER" {
+		t.Error("MethodLabel")
+	}
+}
@@ -0,0 +1,317 @@
+// Package metrics is a small Prometheus instrumentation library: counters, gauges and histograms with
+// labels, exposed in the Prometheus text format (version 0.0.4). It has no dependencies beyond the
+// standard library; featherproxy.go defines the metrics the application records.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Registry holds metric families and renders them in the text format.
+type Registry struct {
+	mu       sync.Mutex
+	families []family
+	names    map[string]bool
+}
+
+// family is one registered metric (all its label combinations).
+type family interface {
+	write(b *bytes.Buffer)
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+func (r *Registry) register(name string, f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.names[name] = true
+	r.families = append(r.families, f)
+}
+
+// WriteText writes every metric in the Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	families := append([]family(nil), r.families...)
+	r.mu.Unlock()
+	var b bytes.Buffer
+	for _, f := range families {
+		f.write(&b)
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// Handler serves the registry in the text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.WriteText(w)
+	})
+}
+
+// vec stores the series of one family, keyed by label values.
+type vec[T any] struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+	newT   func() *T
+
+	mu     sync.RWMutex
+	series map[string]*labeledSeries[T]
+}
+
+type labeledSeries[T any] struct {
+	values []string
+	s      *T
+}
+
+func newVec[T any](name, help, typ string, labels []string, newT func() *T) *vec[T] {
+	return &vec[T]{name: name, help: help, typ: typ, labels: labels, newT: newT, series: make(map[string]*labeledSeries[T])}
+}
+
+func (v *vec[T]) with(values []string) *T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s: got %d label values, want %d", v.name, len(values), len(v.labels)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.RLock()
+	ls := v.series[key]
+	v.mu.RUnlock()
+	if ls != nil {
+		return ls.s
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if ls = v.series[key]; ls == nil {
+		ls = &labeledSeries[T]{values: append([]string(nil), values...), s: v.newT()}
+		v.series[key] = ls
+	}
+	return ls.s
+}
+
+func (v *vec[T]) delete(values []string) {
+	v.mu.Lock()
+	delete(v.series, strings.Join(values, "\xff"))
+	v.mu.Unlock()
+}
+
+// sorted returns the series ordered by label values, for stable output.
+func (v *vec[T]) sorted() []*labeledSeries[T] {
+	v.mu.RLock()
+	out := make([]*labeledSeries[T], 0, len(v.series))
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		out = append(out, v.series[k])
+	}
+	v.mu.RUnlock()
+	return out
+}
+
+func (v *vec[T]) writeHeader(b *bytes.Buffer) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", v.name, escapeHelp(v.help), v.name, v.typ)
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct{ bits atomic.Uint64 }
+
+// Inc adds 1.
+func (c *Counter) Inc() { c.Add(1) }
+
+// Add adds delta, which must not be negative.
+func (c *Counter) Add(delta float64) { addFloat(&c.bits, delta) }
+
+// Value returns the current value.
+func (c *Counter) Value() float64 { return math.Float64frombits(c.bits.Load()) }
+
+// CounterVec is a counter family partitioned by labels.
+type CounterVec struct{ v *vec[Counter] }
+
+// NewCounterVec registers a counter family.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{v: newVec(name, help, "counter", labels, func() *Counter { return new(Counter) })}
+	r.register(name, c)
+	return c
+}
+
+// With returns the counter for the label values (in registration order).
+func (c *CounterVec) With(values ...string) *Counter { return c.v.with(values) }
+
+func (c *CounterVec) write(b *bytes.Buffer) {
+	c.v.writeHeader(b)
+	for _, ls := range c.v.sorted() {
+		writeSample(b, c.v.name, c.v.labels, ls.values, "", "", ls.s.Value())
+	}
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct{ bits atomic.Uint64 }
+
+// Set sets the value.
+func (g *Gauge) Set(v float64) { g.bits.Store(math.Float64bits(v)) }
+
+// Add adds delta (which may be negative).
+func (g *Gauge) Add(delta float64) { addFloat(&g.bits, delta) }
+
+// Inc adds 1.
+func (g *Gauge) Inc() { g.Add(1) }
+
+// Dec subtracts 1.
+func (g *Gauge) Dec() { g.Add(-1) }
+
+// Value returns the current value.
+func (g *Gauge) Value() float64 { return math.Float64frombits(g.bits.Load()) }
+
+// GaugeVec is a gauge family partitioned by labels.
+type GaugeVec struct{ v *vec[Gauge] }
+
+// NewGaugeVec registers a gauge family.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{v: newVec(name, help, "gauge", labels, func() *Gauge { return new(Gauge) })}
+	r.register(name, g)
+	return g
+}
+
+// With returns the gauge for the label values (in registration order).
+func (g *GaugeVec) With(values ...string) *Gauge { return g.v.with(values) }
+
+// Delete removes the series for the label values, e.g. when the thing it describes no longer exists.
+func (g *GaugeVec) Delete(values ...string) { g.v.delete(values) }
+
+func (g *GaugeVec) write(b *bytes.Buffer) {
+	g.v.writeHeader(b)
+	for _, ls := range g.v.sorted() {
+		writeSample(b, g.v.name, g.v.labels, ls.values, "", "", ls.s.Value())
+	}
+}
+
+// DefBuckets are latency buckets in seconds, from 5ms to 10s.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	bounds []float64
+	counts []atomic.Uint64 // per bucket, not cumulative; the last one is +Inf
+	count  atomic.Uint64
+	sum    atomic.Uint64
+}
+
+// Observe records one value.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v) // first bound >= v (buckets are inclusive upper bounds)
+	h.counts[i].Add(1)
+	addFloat(&h.sum, v)
+	h.count.Add(1)
+}
+
+// HistogramVec is a histogram family partitioned by labels.
+type HistogramVec struct {
+	v      *vec[Histogram]
+	bounds []float64
+}
+
+// NewHistogramVec registers a histogram family with the given upper bounds (sorted ascending).
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	bounds := append([]float64(nil), buckets...)
+	sort.Float64s(bounds)
+	h := &HistogramVec{bounds: bounds}
+	h.v = newVec(name, help, "histogram", labels, func() *Histogram {
+		return &Histogram{bounds: bounds, counts: make([]atomic.Uint64, len(bounds)+1)}
+	})
+	r.register(name, h)
+	return h
+}
+
+// With returns the histogram for the label values (in registration order).
+func (h *HistogramVec) With(values ...string) *Histogram { return h.v.with(values) }
+
+func (h *HistogramVec) write(b *bytes.Buffer) {
+	h.v.writeHeader(b)
+	for _, ls := range h.v.sorted() {
+		var cum uint64
+		for i, bound := range h.bounds {
+			cum += ls.s.counts[i].Load()
+			writeSample(b, h.v.name+"_bucket", h.v.labels, ls.values, "le", formatFloat(bound), float64(cum))
+		}
+		cum += ls.s.counts[len(h.bounds)].Load()
+		writeSample(b, h.v.name+"_bucket", h.v.labels, ls.values, "le", "+Inf", float64(cum))
+		writeSample(b, h.v.name+"_sum", h.v.labels, ls.values, "", "", math.Float64frombits(ls.s.sum.Load()))
+		writeSample(b, h.v.name+"_count", h.v.labels, ls.values, "", "", float64(ls.s.count.Load()))
+	}
+}
+
+func addFloat(bits *atomic.Uint64, delta float64) {
+	for {
+		old := bits.Load()
+		if bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func writeSample(b *bytes.Buffer, name string, labels, values []string, extraLabel, extraValue string, v float64) {
+	b.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		b.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, `%s="%s"`, l, escapeLabel(values[i]))
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, `%s="%s"`, extraLabel, extraValue)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(v))
+	b.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
@@ -0,0 +1,205 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"FeatherProxy/app/internal/accesslog"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// requestInfo collects what the handler learns about a request while serving it, for the access log,
+// metrics and stats.
+type requestInfo struct {
+	clientIP           string
+	routeUUID          uuid.UUID
+	targetServerUUID   uuid.UUID
+	authenticationUUID uuid.UUID     // source authentication that matched; Nil when none was required
+	requestBody        *countingBody // nil when the request has no body
+	variant            string        // traffic split variant; empty when the route has no split
+	outcome            string        // schema.Outcome*; set by the handler at each exit
+	aclRule            string        // deny_list entry that denied the request (OutcomeACLDenied)
+}
+
+// accessLogs holds the open access log of each source server. A log is reopened when its options
+// change (UpdatedAt moves), so edits through the admin API apply without a proxy reload.
+type accessLogs struct {
+	mu      sync.Mutex
+	loggers map[uuid.UUID]*openAccessLog
+}
+
+type openAccessLog struct {
+	updatedAt time.Time
+	logger    *accesslog.Logger // nil when no open has succeeded yet for these options
+	opened    chan struct{}     // closed once the open for these options finished
+}
+
+func newAccessLogs() *accessLogs {
+	return &accessLogs{loggers: make(map[uuid.UUID]*openAccessLog)}
+}
+
+// logger returns the logger for opts. The first time (or while no logger is open) it waits for the
+// log to open, so no request goes unlogged. When the options change, the new logger is opened in the
+// background, since opening can block (a syslog dial waits up to 5s), and the previous one keeps
+// serving until it is ready.
+func (a *accessLogs) logger(opts *schema.AccessLogOptions) *accesslog.Logger {
+	a.mu.Lock()
+	cur := a.loggers[opts.SourceServerUUID]
+	if cur != nil && cur.updatedAt.Equal(opts.UpdatedAt) {
+		l := cur.logger
+		a.mu.Unlock()
+		if l != nil {
+			return l
+		}
+		<-cur.opened
+		return a.loggerOf(cur)
+	}
+	next := &openAccessLog{updatedAt: opts.UpdatedAt, opened: make(chan struct{})}
+	if cur != nil {
+		next.logger = cur.logger
+	}
+	a.loggers[opts.SourceServerUUID] = next
+	a.mu.Unlock()
+	if next.logger != nil {
+		go a.open(opts.SourceServerUUID, next, accessLogConfig(opts))
+		return next.logger
+	}
+	a.open(opts.SourceServerUUID, next, accessLogConfig(opts))
+	return a.loggerOf(next)
+}
+
+func (a *accessLogs) loggerOf(entry *openAccessLog) *accesslog.Logger {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return entry.logger
+}
+
+// open opens the logger for entry and swaps it in, closing the previous one. When opening fails the
+// previous logger keeps serving. The new logger is discarded when entry was replaced or removed
+// meanwhile.
+func (a *accessLogs) open(sourceServerUUID uuid.UUID, entry *openAccessLog, config accesslog.Config) {
+	defer close(entry.opened)
+	l, err := accesslog.New(config)
+	if err != nil {
+		logger.Error("open access log failed", "source_server", sourceServerUUID, "error", err)
+		return
+	}
+	a.mu.Lock()
+	prev := entry.logger
+	current := a.loggers[sourceServerUUID] == entry
+	if current {
+		entry.logger = l
+	}
+	a.mu.Unlock()
+	if !current {
+		_ = l.Close()
+		return
+	}
+	if prev != nil {
+		_ = prev.Close()
+	}
+}
+
+// CheckAccessLog opens and closes the output described by opts, so options that cannot be opened
+// (an unwritable path, an unreachable syslog address) are rejected when saved.
+func CheckAccessLog(opts *schema.AccessLogOptions) error {
+	l, err := accesslog.New(accessLogConfig(opts))
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+// close closes the source server's logger, if open (access log disabled or removed).
+func (a *accessLogs) close(sourceServerUUID uuid.UUID) {
+	a.mu.Lock()
+	cur := a.loggers[sourceServerUUID]
+	delete(a.loggers, sourceServerUUID)
+	a.mu.Unlock()
+	if cur != nil && cur.logger != nil {
+		_ = cur.logger.Close()
+	}
+}
+
+// closeAll flushes and closes every open logger.
+func (a *accessLogs) closeAll() {
+	a.mu.Lock()
+	open := a.loggers
+	a.loggers = make(map[uuid.UUID]*openAccessLog)
+	a.mu.Unlock()
+	for _, cur := range open {
+		if cur.logger != nil {
+			_ = cur.logger.Close()
+		}
+	}
+}
+
+func accessLogConfig(opts *schema.AccessLogOptions) accesslog.Config {
+	interval, _ := time.ParseDuration(opts.RotateInterval)
+	return accesslog.Config{
+		Format:         opts.Format,
+		Template:       opts.Template,
+		Output:         opts.Output,
+		FilePath:       opts.FilePath,
+		MaxSizeBytes:   int64(opts.MaxSizeMB) << 20,
+		RotateInterval: interval,
+		MaxBackups:     opts.MaxBackups,
+		MaxAge:         time.Duration(opts.MaxAgeDays) * 24 * time.Hour,
+		SyslogNetwork:  opts.SyslogNetwork,
+		SyslogAddress:  opts.SyslogAddress,
+		SyslogFacility: opts.SyslogFacility,
+		SyslogAppName:  opts.SyslogAppName,
+	}
+}
+
+// writeAccessLog logs the finished request to the source server's access log, when one is enabled.
+func (s *Service) writeAccessLog(r *http.Request, rec *responseRecorder, sourceServerUUID uuid.UUID, info *requestInfo) {
+	if s.accessLogs == nil {
+		return
+	}
+	opts, err := repoCall(r.Context(), "GetAccessLogOptions", func() (schema.AccessLogOptions, error) { return s.repo.GetAccessLogOptions(sourceServerUUID) })
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.ErrorContext(r.Context(), "get access log options failed", "error", err)
+		return
+	}
+	if err != nil || !opts.Enabled {
+		s.accessLogs.close(sourceServerUUID)
+		return
+	}
+	l := s.accessLogs.logger(&opts)
+	if l == nil {
+		return
+	}
+	user, _, _ := r.BasicAuth()
+	e := accesslog.Entry{
+		Time:             rec.start,
+		ClientIP:         info.clientIP,
+		User:             user,
+		Method:           r.Method,
+		URI:              r.RequestURI,
+		Proto:            r.Proto,
+		Host:             r.Host,
+		Status:           rec.statusCode,
+		Bytes:            rec.bytes,
+		Duration:         time.Since(rec.start),
+		Referer:          r.Referer(),
+		UserAgent:        r.UserAgent(),
+		RequestID:        requestIDFrom(r.Context()),
+		SourceServerUUID: sourceServerUUID.String(),
+	}
+	if e.URI == "" {
+		e.URI = r.URL.RequestURI()
+	}
+	if info.routeUUID != uuid.Nil {
+		e.RouteUUID = info.routeUUID.String()
+	}
+	if info.targetServerUUID != uuid.Nil {
+		e.TargetServerUUID = info.targetServerUUID.String()
+	}
+	l.Log(&e)
+}
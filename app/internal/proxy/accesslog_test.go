@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"FeatherProxy/app/internal/accesslog"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+// waitLogger polls a.logger(opts) until it returns a logger other than not.
+func waitLogger(t *testing.T, a *accessLogs, opts *schema.AccessLogOptions, not *accesslog.Logger) *accesslog.Logger {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if l := a.logger(opts); l != nil && l != not {
+			return l
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("access log not opened")
+	return nil
+}
+
+func TestAccessLogs_logsFirstRequestAndSwapsInBackground(t *testing.T) {
+	a := newAccessLogs()
+	defer a.closeAll()
+	path := filepath.Join(t.TempDir(), "access.log")
+	opts := &schema.AccessLogOptions{
+		SourceServerUUID: uuid.New(),
+		Enabled:          true,
+		Format:           accesslog.FormatCommon,
+		Output:           accesslog.OutputFile,
+		FilePath:         path,
+		UpdatedAt:        time.Now(),
+	}
+	// The first request waits for the log to open, so it is logged.
+	first := a.logger(opts)
+	if first == nil {
+		t.Fatal("first logger = nil, want the opened log")
+	}
+	first.Log(&accesslog.Entry{Time: time.Now(), ClientIP: "192.0.2.1", Method: "GET", URI: "/opened", Proto: "HTTP/1.1", Status: 200})
+
+	// After an options change the previous logger serves until the new one is open.
+	changed := *opts
+	changed.UpdatedAt = opts.UpdatedAt.Add(time.Second)
+	if l := a.logger(&changed); l != first {
+		t.Errorf("logger after change = %v, want the previous one", l)
+	}
+	second := waitLogger(t, a, &changed, first)
+	second.Log(&accesslog.Entry{Time: time.Now(), ClientIP: "192.0.2.1", Method: "GET", URI: "/first", Proto: "HTTP/1.1", Status: 200})
+
+	// A change that cannot be opened keeps the working logger.
+	notADir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(notADir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	broken := changed
+	broken.FilePath = filepath.Join(notADir, "access.log")
+	broken.UpdatedAt = changed.UpdatedAt.Add(time.Second)
+	a.logger(&broken)
+	a.mu.Lock()
+	entry := a.loggers[opts.SourceServerUUID]
+	a.mu.Unlock()
+	<-entry.opened
+	if l := a.logger(&broken); l != second {
+		t.Fatalf("logger after failed open = %v, want the previous one", l)
+	}
+	second.Log(&accesslog.Entry{Time: time.Now(), ClientIP: "192.0.2.1", Method: "GET", URI: "/second", Proto: "HTTP/1.1", Status: 200})
+
+	a.closeAll()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, uri := range []string{"/opened", "/first", "/second"} {
+		if !strings.Contains(string(data), uri) {
+			t.Errorf("access log missing %s: %q", uri, data)
+		}
+	}
+}
@@ -2,73 +2,63 @@ package proxy
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
 	"net"
-	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 
 	"FeatherProxy/app/internal/database/schema"
 )
 
-// clientIPFromRequest returns the client IP used for ACL checks.
-// Order of precedence:
-// 1. opts.ClientIPHeader header
-// 2. X-Forwarded-For header
-// 3. RemoteAddr
-
-func clientIPFromRequest(r *http.Request, opts *schema.ACLOptions) net.IP {
-	if opts != nil && opts.ClientIPHeader != "" {
-		log.Println("clientIPFromRequest", "ClientIPHeader", opts.ClientIPHeader)
-		return net.ParseIP(r.Header.Get(opts.ClientIPHeader))
-	}
-	if r.Header.Get("X-Forwarded-For") != "" {
-		log.Println("clientIPFromRequest", "X-Forwarded-For", r.Header.Get("X-Forwarded-For"))
-		log.Println("clientIPFromRequest", "X-Forwarded-For value", net.ParseIP(r.Header.Get("X-Forwarded-For")))
-		return net.ParseIP(r.Header.Get("X-Forwarded-For"))
-	}
-	log.Println("clientIPFromRequest", "RemoteAddr", r.RemoteAddr)
-	log.Println("clientIPFromRequest", "RemoteAddr value", net.ParseIP(r.RemoteAddr))
-
-	return net.ParseIP(r.RemoteAddr)
-}
-
-// clientMatchesACL returns true if the client with the given IP matches any
-// entry in the list. Entries may be:
+// clientMatchesACL reports whether the client with the given IP matches any
+// entry in the list, and returns the first entry that matched. Entries may be:
 //   - exact IP (IPv4/IPv6)
 //   - CIDR
+//   - "country:<ISO code>" (e.g. "country:RU"), from the GeoIP database
+//   - "asn:<number>" (e.g. "asn:14061" or "asn:AS14061"), from the GeoIP database
 //   - exact hostname
 //   - wildcard hostname (e.g. "*.internal.com.br")
 //
-// Matching is performed in two phases:
+// Matching is performed in three phases:
 //  1. IP/CIDR entries are evaluated exactly as before (no DNS).
-//  2. If there are hostname entries and resolver is non-nil, reverse DNS
+//  2. If there are country or ASN entries and geo is non-nil, the IP is
+//     looked up in the local GeoIP database once and matched against them.
+//     Without geo, or with a malformed value, these entries never match;
+//     each such entry is logged once.
+//  3. If there are hostname entries and resolver is non-nil, reverse DNS
 //     is performed for the IP and the resulting hostnames are matched
 //     against hostname and wildcard entries.
-func clientMatchesACL(ctx context.Context, ip net.IP, list []string, resolver HostnameResolver) bool {
+func clientMatchesACL(ctx context.Context, ip net.IP, list []string, resolver HostnameResolver, geo GeoLocator) (string, bool) {
 	if ip == nil {
-		return false
+		return "", false
 	}
 
 	// First pass: IP and CIDR entries (backwards compatible behavior).
-	hasHostnameEntries := false
+	hasHostnameEntries, hasGeoEntries := false, false
 	for _, raw := range list {
 		entry := strings.TrimSpace(raw)
 		if entry == "" {
 			continue
 		}
+		if _, _, ok := geoEntry(entry); ok {
+			hasGeoEntries = true
+			continue
+		}
 		if strings.Contains(entry, "/") {
 			_, cidr, err := net.ParseCIDR(entry)
 			if err != nil {
 				continue
 			}
 			if cidr.Contains(ip) {
-				return true
+				return entry, true
 			}
 			continue
 		}
 		if other := net.ParseIP(entry); other != nil {
 			if ip.Equal(other) {
-				return true
+				return entry, true
 			}
 			continue
 		}
@@ -76,9 +66,38 @@ func clientMatchesACL(ctx context.Context, ip net.IP, list []string, resolver Ho
 		hasHostnameEntries = true
 	}
 
+	// Second pass: country and ASN entries, against the local database (no network). Entries that can
+	// never match (no GeoIP, or a malformed value) are logged once each.
+	if hasGeoEntries {
+		if geo == nil {
+			for _, raw := range list {
+				if entry := strings.TrimSpace(raw); isGeoEntry(entry) {
+					warnUnusableACLEntry(ctx, entry, "GeoIP is not configured (GEOIP_DB)")
+				}
+			}
+		} else {
+			loc := geo.Lookup(ip)
+			for _, raw := range list {
+				entry := strings.TrimSpace(raw)
+				kind, value, ok := geoEntry(entry)
+				if !ok {
+					continue
+				}
+				country, asn, err := parseGeoEntry(kind, value)
+				if err != nil {
+					warnUnusableACLEntry(ctx, entry, err.Error())
+					continue
+				}
+				if (country != "" && strings.EqualFold(loc.Country, country)) || (asn != 0 && loc.ASN == asn) {
+					return entry, true
+				}
+			}
+		}
+	}
+
 	// No hostname entries or no resolver configured: no further matches.
 	if !hasHostnameEntries || resolver == nil {
-		return false
+		return "", false
 	}
 
 	if ctx == nil {
@@ -88,7 +107,7 @@ func clientMatchesACL(ctx context.Context, ip net.IP, list []string, resolver Ho
 	hostnames, err := resolver.ReverseLookup(ctx, ip)
 	if err != nil || len(hostnames) == 0 {
 		// On lookup failure or no names, hostname entries do not match.
-		return false
+		return "", false
 	}
 
 	// Normalize ACL hostname entries once for comparison.
@@ -98,6 +117,9 @@ func clientMatchesACL(ctx context.Context, ip net.IP, list []string, resolver Ho
 		if entry == "" {
 			continue
 		}
+		if _, _, ok := geoEntry(entry); ok {
+			continue
+		}
 		if strings.Contains(entry, "/") {
 			continue
 		}
@@ -108,7 +130,7 @@ func clientMatchesACL(ctx context.Context, ip net.IP, list []string, resolver Ho
 		normalizedEntries = append(normalizedEntries, entry)
 	}
 	if len(normalizedEntries) == 0 {
-		return false
+		return "", false
 	}
 
 	for _, h := range hostnames {
@@ -124,38 +146,115 @@ func clientMatchesACL(ctx context.Context, ip net.IP, list []string, resolver Ho
 					continue
 				}
 				if strings.HasSuffix(host, "."+suffix) {
-					return true
+					return entry, true
 				}
 				continue
 			}
 			// Exact hostname match.
 			if host == entry {
-				return true
+				return entry, true
 			}
 		}
 	}
 
-	return false
+	return "", false
+}
+
+// ACL entry prefixes for GeoIP-based rules.
+const (
+	aclCountryPrefix = "country:"
+	aclASNPrefix     = "asn:"
+)
+
+// unusableACLEntries holds the geo ACL entries already logged as unusable, so each is logged once.
+var unusableACLEntries sync.Map
+
+// warnUnusableACLEntry logs, once per entry, that an ACL entry can never match and why.
+func warnUnusableACLEntry(ctx context.Context, entry, reason string) {
+	if _, logged := unusableACLEntries.LoadOrStore(entry, true); logged {
+		return
+	}
+	aclLogger.WarnContext(ctx, "ACL entry never matches", "entry", entry, "reason", reason)
+}
+
+// ValidateACLEntry checks the country and ASN entries of an allow or deny list: "country:" takes a
+// two-letter ISO code and "asn:" a positive AS number (optionally prefixed with AS). Both need GeoIP, so
+// without it (geoIP false) they are rejected rather than stored to silently never match. Other entries
+// (IPs, CIDRs and hostnames) are accepted as they are.
+func ValidateACLEntry(entry string, geoIP bool) error {
+	entry = strings.TrimSpace(entry)
+	kind, value, ok := geoEntry(entry)
+	if !ok {
+		return nil
+	}
+	if _, _, err := parseGeoEntry(kind, value); err != nil {
+		return fmt.Errorf("%q: %v", entry, err)
+	}
+	if !geoIP {
+		return fmt.Errorf("%q needs GeoIP, which is not configured (GEOIP_DB)", entry)
+	}
+	return nil
+}
+
+// parseGeoEntry parses the value of a geo entry: an ISO country code (upper-cased) for country entries,
+// an AS number for ASN entries.
+func parseGeoEntry(kind, value string) (country string, asn int64, err error) {
+	switch kind {
+	case aclCountryPrefix:
+		if len(value) != 2 || !isASCIILetter(value[0]) || !isASCIILetter(value[1]) {
+			return "", 0, errors.New("not a two-letter country code (e.g. country:BR)")
+		}
+		return strings.ToUpper(value), 0, nil
+	default:
+		n, err := strconv.ParseInt(strings.TrimPrefix(strings.ToUpper(value), "AS"), 10, 64)
+		if err != nil || n <= 0 {
+			return "", 0, errors.New("not an AS number (e.g. asn:14061 or asn:AS14061)")
+		}
+		return "", n, nil
+	}
+}
+
+func isASCIILetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isGeoEntry(entry string) bool {
+	_, _, ok := geoEntry(entry)
+	return ok
+}
+
+// geoEntry splits a "country:XX" or "asn:N" entry (prefix case-insensitive) into its prefix and value.
+func geoEntry(entry string) (kind, value string, ok bool) {
+	for _, prefix := range []string{aclCountryPrefix, aclASNPrefix} {
+		if len(entry) > len(prefix) && strings.EqualFold(entry[:len(prefix)], prefix) {
+			return prefix, strings.TrimSpace(entry[len(prefix):]), true
+		}
+	}
+	return "", "", false
 }
 
-// aclDeny returns true if the request should be denied based on ACL options.
+// aclDeny reports whether the client should be denied based on ACL options,
+// and the deny_list entry that matched (empty for allow_only denials, where
+// no entry matched).
 // If opts is nil or opts.Mode is "off", returns false (allow).
 // allow_only: deny if client IP is not in AllowList.
 // deny_only: deny if client IP is in DenyList.
-func aclDeny(ctx context.Context, r *http.Request, opts *schema.ACLOptions, resolver HostnameResolver) bool {
+// clientIP is the resolved client IP (see clientIPFromRequest).
+func aclDeny(ctx context.Context, clientIP net.IP, opts *schema.ACLOptions, resolver HostnameResolver, geo GeoLocator) (bool, string) {
 	if opts == nil || opts.Mode == "off" {
-		return false
+		return false, ""
 	}
-	clientIP := clientIPFromRequest(r, opts)
 	switch opts.Mode {
 	case "allow_only":
 		if len(opts.AllowList) == 0 {
-			return true // allow nobody -> deny all
+			return true, "" // allow nobody -> deny all
 		}
-		return !clientMatchesACL(ctx, clientIP, opts.AllowList, resolver)
+		_, ok := clientMatchesACL(ctx, clientIP, opts.AllowList, resolver, geo)
+		return !ok, ""
 	case "deny_only":
-		return clientMatchesACL(ctx, clientIP, opts.DenyList, resolver)
+		rule, ok := clientMatchesACL(ctx, clientIP, opts.DenyList, resolver, geo)
+		return ok, rule
 	default:
-		return false
+		return false, ""
 	}
 }
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+
+	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/geoip"
+	"FeatherProxy/app/internal/logging"
+)
+
+// fakeGeo locates IPs from a fixed table.
+type fakeGeo map[string]geoip.Location
+
+func (g fakeGeo) Lookup(ip net.IP) geoip.Location { return g[ip.String()] }
+
+func TestClientMatchesACL_countryAndASN(t *testing.T) {
+	geo := fakeGeo{
+		"203.0.113.7":  {Country: "RU", ASN: 64512},
+		"198.51.100.1": {Country: "BR", ASN: 14061},
+	}
+	tests := []struct {
+		ip        string
+		list      []string
+		geo       GeoLocator
+		wantRule  string
+		wantMatch bool
+	}{
+		{"203.0.113.7", []string{"country:ru"}, geo, "country:ru", true},
+		{"203.0.113.7", []string{"Country:RU"}, geo, "Country:RU", true},
+		{"198.51.100.1", []string{"country:RU", "asn:14061"}, geo, "asn:14061", true},
+		{"198.51.100.1", []string{"asn:AS14061"}, geo, "asn:AS14061", true},
+		{"198.51.100.1", []string{"198.51.100.0/24", "country:BR"}, geo, "198.51.100.0/24", true},
+		{"192.0.2.1", []string{"country:RU", "asn:0"}, geo, "", false}, // not in the database
+		{"203.0.113.7", []string{"asn:notanumber", "country:"}, geo, "", false},
+		{"203.0.113.7", []string{"country:RU"}, nil, "", false}, // GeoIP not configured
+	}
+	for _, tt := range tests {
+		rule, ok := clientMatchesACL(context.Background(), net.ParseIP(tt.ip), tt.list, nil, tt.geo)
+		if ok != tt.wantMatch || rule != tt.wantRule {
+			t.Errorf("%s in %v: rule %q match %v, want %q %v", tt.ip, tt.list, rule, ok, tt.wantRule, tt.wantMatch)
+		}
+	}
+}
+
+func TestClientMatchesACL_logsUnusableGeoEntriesOnce(t *testing.T) {
+	var buf bytes.Buffer
+	logging.Configure(logging.Config{Level: slog.LevelInfo, Output: &buf})
+	t.Cleanup(func() { logging.Configure(logging.Config{Level: slog.LevelInfo}) })
+
+	ip := net.ParseIP("203.0.113.7")
+	for i := 0; i < 3; i++ {
+		clientMatchesACL(context.Background(), ip, []string{"country:NZ", "10.0.0.0/8"}, nil, nil)
+		clientMatchesACL(context.Background(), ip, []string{"asn:bogus"}, nil, fakeGeo{})
+	}
+	for _, entry := range []string{"country:NZ", "asn:bogus"} {
+		if n := strings.Count(buf.String(), "entry="+entry); n != 1 {
+			t.Errorf("%s logged %d times, want once: %s", entry, n, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "10.0.0.0/8") {
+		t.Errorf("CIDR entry logged: %s", buf.String())
+	}
+}
+
+func TestValidateACLEntry(t *testing.T) {
+	for _, tt := range []struct {
+		entry   string
+		geoIP   bool
+		wantErr bool
+	}{
+		{"country:br", true, false},
+		{"ASN:AS14061", true, false},
+		{"country:BR", false, true},
+		{"country:BRA", true, true},
+		{"asn:-1", true, true},
+		{"asn:AS", true, true},
+		{"10.0.0.0/8", false, false},
+		{"*.internal.example", false, false},
+	} {
+		if err := ValidateACLEntry(tt.entry, tt.geoIP); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateACLEntry(%q, %v) = %v, want error %v", tt.entry, tt.geoIP, err, tt.wantErr)
+		}
+	}
+}
+
+func TestACLDeny_geoEntriesInBothModes(t *testing.T) {
+	geo := fakeGeo{"203.0.113.7": {Country: "RU", ASN: 64512}, "198.51.100.1": {Country: "BR"}}
+	deny := &schema.ACLOptions{Mode: "deny_only", DenyList: []string{"10.0.0.0/8", "asn:64512"}}
+	allow := &schema.ACLOptions{Mode: "allow_only", AllowList: []string{"country:BR"}}
+	for _, tt := range []struct {
+		opts     *schema.ACLOptions
+		ip       string
+		denied   bool
+		wantRule string
+	}{
+		{deny, "203.0.113.7", true, "asn:64512"},
+		{deny, "198.51.100.1", false, ""},
+		{allow, "198.51.100.1", false, ""},
+		{allow, "203.0.113.7", true, ""},
+	} {
+		denied, rule := aclDeny(context.Background(), net.ParseIP(tt.ip), tt.opts, nil, geo)
+		if denied != tt.denied || rule != tt.wantRule {
+			t.Errorf("%s %s: denied %v rule %q, want %v %q", tt.opts.Mode, tt.ip, denied, rule, tt.denied, tt.wantRule)
+		}
+	}
+}
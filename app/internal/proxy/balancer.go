@@ -0,0 +1,150 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash/fnv"
+	mrand "math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+// affinitySecretFromEnv returns the key used to sign affinity cookies (AFFINITY_COOKIE_SECRET).
+// When unset, a random per-process key is used: cookies issued before a restart no longer verify
+// and those clients are simply pinned again.
+func affinitySecretFromEnv() []byte {
+	if v := os.Getenv("AFFINITY_COOKIE_SECRET"); v != "" {
+		return []byte(v)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		logger.Error("generate affinity key failed", "error", err)
+	}
+	return key
+}
+
+// pickUpstream returns the target server for a request on a route with a target pool, applying the
+// route's affinity mode. Unhealthy targets are skipped; if every target is unhealthy the whole pool
+// is used so requests still go somewhere.
+func (s *Service) pickUpstream(w http.ResponseWriter, r *http.Request, b *schema.RouteBalancing, primary uuid.UUID, clientIP string) uuid.UUID {
+	pool := upstreamPool(primary, b.TargetServerUUIDs)
+	candidates := make([]uuid.UUID, 0, len(pool))
+	for _, id := range pool {
+		if s.health.healthy(id) {
+			candidates = append(candidates, id)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = pool
+	}
+
+	var key string
+	switch b.Affinity {
+	case schema.AffinityCookie:
+		name := b.CookieName
+		if name == "" {
+			name = schema.DefaultAffinityCookie
+		}
+		if c, err := r.Cookie(name); err == nil {
+			if id, ok := verifyAffinityCookie(s.affinityKey, b.RouteUUID, c.Value); ok && containsUUID(candidates, id) {
+				return id
+			}
+		}
+		id := candidates[mrand.IntN(len(candidates))]
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    signAffinityCookie(s.affinityKey, b.RouteUUID, id),
+			Path:     "/",
+			MaxAge:   b.CookieTTLSeconds,
+			Secure:   r.TLS != nil,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		return id
+	case schema.AffinityHashCookie:
+		if c, err := r.Cookie(b.AffinityKey); err == nil {
+			key = c.Value
+		}
+	case schema.AffinityHashHeader:
+		key = r.Header.Get(b.AffinityKey)
+	case schema.AffinityClientIP:
+		key = clientIP
+	}
+	if key != "" {
+		return rendezvousPick(key, candidates)
+	}
+	return candidates[mrand.IntN(len(candidates))]
+}
+
+// upstreamPool returns primary followed by the extra targets, without duplicates.
+func upstreamPool(primary uuid.UUID, extra []uuid.UUID) []uuid.UUID {
+	pool := make([]uuid.UUID, 0, len(extra)+1)
+	pool = append(pool, primary)
+	for _, id := range extra {
+		if !containsUUID(pool, id) {
+			pool = append(pool, id)
+		}
+	}
+	return pool
+}
+
+func containsUUID(list []uuid.UUID, id uuid.UUID) bool {
+	for _, v := range list {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// rendezvousPick picks the candidate with the highest hash score for key (rendezvous hashing).
+// When a target leaves the candidate list only the keys pinned to it move elsewhere.
+func rendezvousPick(key string, candidates []uuid.UUID) uuid.UUID {
+	var best uuid.UUID
+	var bestScore uint64
+	for i, id := range candidates {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key))
+		_, _ = h.Write(id[:])
+		if score := h.Sum64(); i == 0 || score > bestScore {
+			best, bestScore = id, score
+		}
+	}
+	return best
+}
+
+// signAffinityCookie returns "<target uuid>.<signature>", signed for the route so the value cannot be
+// forged or replayed on another route.
+func signAffinityCookie(key []byte, routeUUID, targetUUID uuid.UUID) string {
+	return targetUUID.String() + "." + affinitySignature(key, routeUUID, targetUUID)
+}
+
+// verifyAffinityCookie returns the target UUID from a cookie value produced by signAffinityCookie.
+func verifyAffinityCookie(key []byte, routeUUID uuid.UUID, value string) (uuid.UUID, bool) {
+	idStr, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	if !hmac.Equal([]byte(sig), []byte(affinitySignature(key, routeUUID, id))) {
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func affinitySignature(key []byte, routeUUID, targetUUID uuid.UUID) string {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(routeUUID[:])
+	_, _ = mac.Write(targetUUID[:])
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
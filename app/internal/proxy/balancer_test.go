@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+func TestAffinityCookieSignature(t *testing.T) {
+	key := []byte("secret")
+	routeID := uuid.New()
+	targetID := uuid.New()
+	v := signAffinityCookie(key, routeID, targetID)
+	if got, ok := verifyAffinityCookie(key, routeID, v); !ok || got != targetID {
+		t.Fatalf("verify own cookie = %v, %v", got, ok)
+	}
+	if _, ok := verifyAffinityCookie(key, uuid.New(), v); ok {
+		t.Error("cookie verified for another route")
+	}
+	if _, ok := verifyAffinityCookie([]byte("other"), routeID, v); ok {
+		t.Error("cookie verified with another key")
+	}
+	// A client cannot pick a backend by editing the UUID part.
+	forged := uuid.New().String() + v[36:]
+	if _, ok := verifyAffinityCookie(key, routeID, forged); ok {
+		t.Error("forged cookie verified")
+	}
+}
+
+func TestRendezvousPick_stableAndFailover(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	all := []uuid.UUID{a, b, c}
+	first := rendezvousPick("client-1", all)
+	if got := rendezvousPick("client-1", []uuid.UUID{c, b, a}); got != first {
+		t.Errorf("order-dependent pick: %v vs %v", got, first)
+	}
+	var rest []uuid.UUID
+	for _, id := range all {
+		if id != first {
+			rest = append(rest, id)
+		}
+	}
+	if got := rendezvousPick("client-1", rest); got == first {
+		t.Error("pick did not fail over when the pinned target was removed")
+	}
+}
+
+func TestPickUpstream_cookieFailover(t *testing.T) {
+	primary, extra := uuid.New(), uuid.New()
+	s := &Service{health: newHealthTracker(), affinityKey: []byte("k")}
+	b := &schema.RouteBalancing{RouteUUID: uuid.New(), TargetServerUUIDs: []uuid.UUID{extra}, Affinity: schema.AffinityCookie}
+
+	// Pinned to extra via a valid cookie.
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: schema.DefaultAffinityCookie, Value: signAffinityCookie(s.affinityKey, b.RouteUUID, extra)})
+	w := httptest.NewRecorder()
+	if got := s.pickUpstream(w, r, b, primary, ""); got != extra {
+		t.Fatalf("pinned pick = %v, want %v", got, extra)
+	}
+	if w.Header().Get("Set-Cookie") != "" {
+		t.Error("cookie re-issued for a valid pin")
+	}
+
+	// Pinned target becomes unhealthy: request moves to primary and a new cookie is issued.
+	for i := 0; i < unhealthyAfter; i++ {
+		s.health.failure(extra)
+	}
+	w = httptest.NewRecorder()
+	if got := s.pickUpstream(w, r, b, primary, ""); got != primary {
+		t.Fatalf("failover pick = %v, want %v", got, primary)
+	}
+	if w.Header().Get("Set-Cookie") == "" {
+		t.Error("no cookie issued after failover")
+	}
+
+	// Cookies issued on HTTPS listeners are Secure.
+	r = httptest.NewRequest(http.MethodGet, "https://proxy.example/", nil)
+	w = httptest.NewRecorder()
+	s.pickUpstream(w, r, b, primary, "")
+	if c := w.Result().Cookies(); len(c) != 1 || !c[0].Secure {
+		t.Errorf("cookies on HTTPS = %+v, want one Secure cookie", c)
+	}
+}
+
+func TestHealthTracker(t *testing.T) {
+	h := newHealthTracker()
+	id := uuid.New()
+	for i := 0; i < unhealthyAfter-1; i++ {
+		h.failure(id)
+	}
+	if !h.healthy(id) {
+		t.Fatal("unhealthy before threshold")
+	}
+	h.success(id)
+	h.failure(id)
+	if !h.healthy(id) {
+		t.Fatal("success did not reset failures")
+	}
+	for i := 0; i < unhealthyAfter; i++ {
+		h.failure(id)
+	}
+	if h.healthy(id) {
+		t.Fatal("healthy after threshold")
+	}
+}
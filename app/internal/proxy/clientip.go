@@ -0,0 +1,227 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"FeatherProxy/app/internal/database/schema"
+)
+
+// clientIPFromRequest returns the client IP used for ACL checks, stats and forwarding.
+//
+// Without trusted proxies (opts.TrustedProxies empty):
+//  1. opts.ClientIPHeader header, when set: its rightmost IP (the one the
+//     proxy in front of us saw), so the admin opts into believing it;
+//  2. otherwise the connection address. X-Forwarded-For and Forwarded are
+//     ignored: any client could send them.
+//
+// With trusted proxies, forwarding headers are believed only when the
+// connection comes from a trusted proxy. The hops in opts.ClientIPHeader (if
+// set; "Forwarded" reads the RFC 7239 "for=" nodes), else in X-Forwarded-For,
+// are then walked right to left, skipping trusted proxies: the first untrusted
+// hop is the client. A hop that cannot be parsed (e.g. "unknown" or an
+// obfuscated identifier) stops the walk at the last trusted one. The chain is
+// never picked by which headers the request carries: a client could add the
+// one its proxy does not overwrite.
+//
+// It returns nil only when RemoteAddr is not an address (e.g. in some tests).
+func clientIPFromRequest(r *http.Request, opts *schema.ACLOptions) net.IP {
+	peer := parseHopIP(r.RemoteAddr)
+	var trusted []*net.IPNet
+	if opts != nil {
+		trusted = parseTrustedProxies(opts.TrustedProxies)
+	}
+	if len(trusted) == 0 {
+		if opts != nil && opts.ClientIPHeader != "" {
+			if hops := headerHops(r.Header, opts.ClientIPHeader); len(hops) > 0 {
+				if ip := parseHopIP(hops[len(hops)-1]); ip != nil {
+					return ip
+				}
+			}
+		}
+		return peer
+	}
+	if peer == nil || !ipInNets(peer, trusted) {
+		return peer
+	}
+	header := opts.ClientIPHeader
+	if header == "" {
+		header = "X-Forwarded-For"
+	}
+	hops := headerHops(r.Header, header)
+	ip := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := parseHopIP(hops[i])
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !ipInNets(hop, trusted) {
+			break
+		}
+	}
+	return ip
+}
+
+// peerTrusted reports whether the connection comes from one of opts.TrustedProxies, so that its
+// forwarding headers are believed and extended rather than replaced.
+func peerTrusted(r *http.Request, opts *schema.ACLOptions) bool {
+	if opts == nil {
+		return false
+	}
+	peer := parseHopIP(r.RemoteAddr)
+	return peer != nil && ipInNets(peer, parseTrustedProxies(opts.TrustedProxies))
+}
+
+// clientIPString returns the client IP string for stats, using the same logic as ACL.
+func clientIPString(r *http.Request, acl *schema.ACLOptions) string {
+	ip := clientIPFromRequest(r, acl)
+	if ip != nil {
+		return ip.String()
+	}
+	// Fallback: strip port from RemoteAddr if present
+	addr := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// parseTrustedProxies parses trusted proxy entries (CIDRs or single IPs); invalid entries are skipped.
+func parseTrustedProxies(list []string) []*net.IPNet {
+	var out []*net.IPNet
+	for _, raw := range list {
+		if n := parseIPOrCIDR(raw); n != nil {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// parseIPOrCIDR parses "10.0.0.0/8" or a single IP (as a /32 or /128 network). It returns nil if invalid.
+func parseIPOrCIDR(entry string) *net.IPNet {
+	entry = strings.TrimSpace(entry)
+	if strings.Contains(entry, "/") {
+		_, n, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil
+		}
+		return n
+	}
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+func ipInNets(ip net.IP, nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerHops returns the comma-separated values of every occurrence of the header, in order.
+// For the Forwarded header it returns the "for=" node of each element instead.
+func headerHops(h http.Header, name string) []string {
+	values := h.Values(name)
+	if strings.EqualFold(name, "Forwarded") {
+		return forwardedFor(values)
+	}
+	var hops []string
+	for _, v := range values {
+		for _, hop := range strings.Split(v, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// forwardedFor returns the "for=" node of each element of RFC 7239 Forwarded header values, in order,
+// unquoted. An element without "for=" yields "" (an unknown hop).
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, elem := range splitQuoted(v, ',') {
+			if strings.TrimSpace(elem) == "" {
+				continue
+			}
+			node := ""
+			for _, pair := range splitQuoted(elem, ';') {
+				k, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(strings.TrimSpace(k), "for") {
+					node = unquote(strings.TrimSpace(val))
+					break
+				}
+			}
+			hops = append(hops, node)
+		}
+	}
+	return hops
+}
+
+// splitQuoted splits s on sep outside double-quoted strings (which may contain backslash escapes).
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	start, quoted := 0, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && quoted:
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unquote removes the double quotes (and backslash escapes) of an RFC 7230 quoted-string.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	var b strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] == '\\' && i+1 < len(s)-1 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// parseHopIP parses a hop address: an IP, "ip:port", "[ipv6]" or "[ipv6]:port". It returns nil for anything
+// else, such as "unknown" or an obfuscated Forwarded identifier ("_hidden").
+func parseHopIP(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") {
+		end := strings.IndexByte(s, ']')
+		if end < 0 {
+			return nil
+		}
+		rest := s[end+1:]
+		if rest != "" && !strings.HasPrefix(rest, ":") {
+			return nil
+		}
+		return net.ParseIP(s[1:end])
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		return net.ParseIP(host)
+	}
+	return nil
+}
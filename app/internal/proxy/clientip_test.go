@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"FeatherProxy/app/internal/database/schema"
+)
+
+func TestClientIPFromRequest(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "2001:db8:ffff::1"}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		opts       *schema.ACLOptions
+		want       string
+	}{
+		{"direct IPv4 with port", "192.0.2.1:1234", nil, nil, "192.0.2.1"},
+		{"direct IPv6 with port", "[2001:db8::5]:443", nil, nil, "2001:db8::5"},
+		{"XFF ignored without trusted proxies", "192.0.2.1:1234",
+			map[string][]string{"X-Forwarded-For": {"203.0.113.9"}}, &schema.ACLOptions{}, "192.0.2.1"},
+		{"client IP header without trusted proxies: rightmost", "192.0.2.1:1234",
+			map[string][]string{"X-Forwarded-For": {"198.51.100.1, 203.0.113.9"}}, &schema.ACLOptions{ClientIPHeader: "X-Forwarded-For"}, "203.0.113.9"},
+		{"untrusted peer cannot spoof", "192.0.2.1:1234",
+			map[string][]string{"X-Forwarded-For": {"203.0.113.9"}}, &schema.ACLOptions{TrustedProxies: trusted}, "192.0.2.1"},
+		{"multi-hop XFF walked right to left", "10.0.0.2:5000",
+			map[string][]string{"X-Forwarded-For": {"198.51.100.7, 203.0.113.9", "10.1.2.3"}}, &schema.ACLOptions{TrustedProxies: trusted}, "203.0.113.9"},
+		{"all hops trusted: leftmost", "10.0.0.2:5000",
+			map[string][]string{"X-Forwarded-For": {"10.9.9.9, 10.1.2.3"}}, &schema.ACLOptions{TrustedProxies: trusted}, "10.9.9.9"},
+		{"garbage hop stops at the last trusted", "10.0.0.2:5000",
+			map[string][]string{"X-Forwarded-For": {"not-an-ip, 10.1.2.3"}}, &schema.ACLOptions{TrustedProxies: trusted}, "10.1.2.3"},
+		{"XFF by default even when Forwarded is sent", "[2001:db8:ffff::1]:8443",
+			map[string][]string{
+				"Forwarded":       {`for=198.51.100.7;proto=https, for="[2001:db8:cafe::17]:4711";by=10.0.0.1`, "for=10.0.0.3"},
+				"X-Forwarded-For": {"203.0.113.9"},
+			}, &schema.ACLOptions{TrustedProxies: trusted}, "203.0.113.9"},
+		{"Forwarded alone is not read by default", "10.0.0.2:5000",
+			map[string][]string{"Forwarded": {"for=198.51.100.7"}}, &schema.ACLOptions{TrustedProxies: trusted}, "10.0.0.2"},
+		{"Forwarded when configured", "[2001:db8:ffff::1]:8443",
+			map[string][]string{
+				"Forwarded":       {`for=198.51.100.7;proto=https, for="[2001:db8:cafe::17]:4711";by=10.0.0.1`, "for=10.0.0.3"},
+				"X-Forwarded-For": {"203.0.113.9"},
+			}, &schema.ACLOptions{ClientIPHeader: "Forwarded", TrustedProxies: trusted}, "2001:db8:cafe::17"},
+		{"Forwarded unknown hop", "10.0.0.2:5000",
+			map[string][]string{"Forwarded": {"for=unknown, for=10.0.0.3"}}, &schema.ACLOptions{ClientIPHeader: "Forwarded", TrustedProxies: trusted}, "10.0.0.3"},
+		{"Forwarded IPv4 with port and quoted separators", "10.0.0.2:5000",
+			map[string][]string{"Forwarded": {`by="a,b;c";for="192.0.2.60:8080"`}}, &schema.ACLOptions{ClientIPHeader: "Forwarded", TrustedProxies: trusted}, "192.0.2.60"},
+		{"client IP header with trusted proxies", "10.0.0.2:5000",
+			map[string][]string{"X-Real-Ip": {"203.0.113.50"}, "X-Forwarded-For": {"198.51.100.7"}},
+			&schema.ACLOptions{ClientIPHeader: "X-Real-IP", TrustedProxies: trusted}, "203.0.113.50"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		for k, vs := range tt.headers {
+			for _, v := range vs {
+				r.Header.Add(k, v)
+			}
+		}
+		if got := clientIPString(r, tt.opts); got != tt.want {
+			t.Errorf("%s: client IP = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
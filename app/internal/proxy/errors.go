@@ -0,0 +1,176 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// errorPageData is the data available to error page templates.
+type errorPageData struct {
+	Status     int
+	StatusText string
+	Kind       string
+	Method     string
+	Path       string
+}
+
+// errorPages returns the source server's custom error pages, or false when none are configured.
+func (s *Service) errorPages(r *http.Request, sourceServerUUID uuid.UUID) (schema.ErrorPages, bool) {
+	pages, err := repoCall(r.Context(), "GetErrorPages", func() (schema.ErrorPages, error) { return s.repo.GetErrorPages(sourceServerUUID) })
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.ErrorContext(r.Context(), "get error pages failed", "error", err)
+		}
+		return schema.ErrorPages{}, false
+	}
+	return pages, true
+}
+
+// writeError answers a proxy-generated error of the given kind (schema.ErrorKind*). The body comes from
+// the source server's page for that kind when configured, otherwise a generic body that only names the
+// status, so no internal detail reaches the client.
+func (s *Service) writeError(w http.ResponseWriter, r *http.Request, sourceServerUUID uuid.UUID, kind string) {
+	status := schema.ErrorKindStatus[kind]
+	var page schema.ErrorPage
+	if pages, ok := s.errorPages(r, sourceServerUUID); ok {
+		page = pages.Pages[kind]
+	}
+	contentType, body := renderErrorPage(r, &page, errorPageData{
+		Status:     status,
+		StatusText: http.StatusText(status),
+		Kind:       kind,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+	})
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// replaceUpstreamError swaps the body of an upstream 5xx response for the source server's error page
+// when ReplaceUpstream5xx is enabled. The upstream status code is kept.
+func (s *Service) replaceUpstreamError(resp *http.Response, r *http.Request, sourceServerUUID uuid.UUID) {
+	if resp.StatusCode < 500 {
+		return
+	}
+	pages, ok := s.errorPages(r, sourceServerUUID)
+	if !ok || !pages.ReplaceUpstream5xx {
+		return
+	}
+	kind := upstreamErrorKind(resp.StatusCode)
+	page := pages.Pages[kind]
+	contentType, body := renderErrorPage(r, &page, errorPageData{
+		Status:     resp.StatusCode,
+		StatusText: http.StatusText(resp.StatusCode),
+		Kind:       kind,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+	})
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Set("Content-Type", contentType)
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+}
+
+// upstreamErrorKind maps an upstream 5xx status to the error page kind used to replace it.
+func upstreamErrorKind(status int) string {
+	switch status {
+	case http.StatusServiceUnavailable:
+		return schema.ErrorKindServiceUnavailable
+	case http.StatusGatewayTimeout:
+		return schema.ErrorKindGatewayTimeout
+	default:
+		return schema.ErrorKindBadGateway
+	}
+}
+
+// transportErrorKind classifies an error from the reverse proxy transport.
+func transportErrorKind(err error) string {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return schema.ErrorKindGatewayTimeout
+	}
+	return schema.ErrorKindBadGateway
+}
+
+// renderErrorPage returns the content type and body for an error, choosing HTML or JSON from the
+// request's Accept header. A template that fails to execute, or a JSON template whose output is not
+// valid JSON, falls back to the built-in body. JSON templates should insert values with the json func
+// (e.g. {"path": {{json .Path}}}): the path and method come from the client.
+func renderErrorPage(r *http.Request, page *schema.ErrorPage, data errorPageData) (string, []byte) {
+	if prefersHTML(r.Header.Get("Accept")) {
+		if page.HTML != "" {
+			var buf bytes.Buffer
+			t, err := parsedTemplates.get(page.HTML, true)
+			if err == nil {
+				err = t.Execute(&buf, data)
+			}
+			if err == nil {
+				return "text/html; charset=utf-8", buf.Bytes()
+			}
+			logger.WarnContext(r.Context(), "render error page failed", "format", "html", "kind", data.Kind, "error", err)
+		}
+		title := htmltemplate.HTMLEscapeString(strconv.Itoa(data.Status) + " " + data.StatusText)
+		return "text/html; charset=utf-8", []byte("<!DOCTYPE html>\n<html><head><title>" + title + "</title></head><body><h1>" + title + "</h1></body></html>\n")
+	}
+	if page.JSON != "" {
+		var buf bytes.Buffer
+		t, err := parsedTemplates.get(page.JSON, false)
+		if err == nil {
+			err = t.Execute(&buf, data)
+		}
+		if err == nil && !json.Valid(buf.Bytes()) {
+			err = errors.New("output is not valid JSON")
+		}
+		if err == nil {
+			return "application/json", buf.Bytes()
+		}
+		logger.WarnContext(r.Context(), "render error page failed", "format", "json", "kind", data.Kind, "error", err)
+	}
+	// Same envelope as the admin API errors.
+	b, _ := json.Marshal(map[string]string{"error": data.StatusText})
+	return "application/json", append(b, '\n')
+}
+
+// prefersHTML reports whether the Accept header ranks text/html above application/json.
+// Wildcards count for neither, so clients without a preference (e.g. curl) get JSON.
+func prefersHTML(accept string) bool {
+	var htmlQ, jsonQ float64
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		switch mediaType {
+		case "text/html", "application/xhtml+xml":
+			htmlQ = max(htmlQ, q)
+		case "application/json", "application/problem+json":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return htmlQ > jsonQ
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"FeatherProxy/app/internal/database/schema"
+)
+
+func TestPrefersHTML(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"application/json", false},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", true},
+		{"text/html;q=0.5, application/json", false},
+		{"application/json;q=0.2, text/html", true},
+	}
+	for _, tt := range tests {
+		if got := prefersHTML(tt.accept); got != tt.want {
+			t.Errorf("prefersHTML(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestRenderErrorPage(t *testing.T) {
+	data := errorPageData{Status: 403, StatusText: "Forbidden", Kind: schema.ErrorKindACLDenied, Method: "GET", Path: "/<x>"}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	ct, body := renderErrorPage(r, &schema.ErrorPage{}, data)
+	if ct != "application/json" || strings.TrimSpace(string(body)) != `{"error":"Forbidden"}` {
+		t.Errorf("default json = %s %s", ct, body)
+	}
+
+	page := &schema.ErrorPage{
+		JSON: `{"code":{{.Status}},"kind":"{{.Kind}}"}`,
+		HTML: `<p>{{.Path}} is off limits</p>`,
+	}
+	_, body = renderErrorPage(r, page, data)
+	if string(body) != `{"code":403,"kind":"acl_denied"}` {
+		t.Errorf("custom json = %s", body)
+	}
+
+	r.Header.Set("Accept", "text/html")
+	ct, body = renderErrorPage(r, page, data)
+	if !strings.HasPrefix(ct, "text/html") || string(body) != `<p>/&lt;x&gt; is off limits</p>` {
+		t.Errorf("custom html = %s %s", ct, body)
+	}
+
+	_, body = renderErrorPage(r, &schema.ErrorPage{}, data)
+	if !strings.Contains(string(body), "<h1>403 Forbidden</h1>") {
+		t.Errorf("default html = %s", body)
+	}
+}
+
+func TestRenderErrorPage_jsonEscaping(t *testing.T) {
+	data := errorPageData{Status: 404, StatusText: "Not Found", Kind: schema.ErrorKindNoRoute, Method: "GET", Path: `/a","admin":true,"b":"`}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	// The json func quotes and escapes the client-controlled path.
+	_, body := renderErrorPage(r, &schema.ErrorPage{JSON: `{"path":{{json .Path}}}`}, data)
+	var got map[string]any
+	if err := json.Unmarshal(body, &got); err != nil || len(got) != 1 || got["path"] != data.Path {
+		t.Errorf("json func body = %s (%v)", body, err)
+	}
+
+	// Output that is not valid JSON falls back to the built-in body.
+	data.Path = `/a"b`
+	_, body = renderErrorPage(r, &schema.ErrorPage{JSON: `{"path":"{{.Path}}"}`}, data)
+	if strings.TrimSpace(string(body)) != `{"error":"Not Found"}` {
+		t.Errorf("invalid json body = %s, want the default", body)
+	}
+}
+
+func TestUpstreamErrorKind(t *testing.T) {
+	if got := upstreamErrorKind(503); got != schema.ErrorKindServiceUnavailable {
+		t.Errorf("503 -> %s", got)
+	}
+	if got := upstreamErrorKind(504); got != schema.ErrorKindGatewayTimeout {
+		t.Errorf("504 -> %s", got)
+	}
+	if got := upstreamErrorKind(500); got != schema.ErrorKindBadGateway {
+		t.Errorf("500 -> %s", got)
+	}
+}
@@ -0,0 +1,211 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"FeatherProxy/app/internal/database/schema"
+)
+
+// Forwarding headers sent to targets. ServerOptions can rename each one, or disable it with
+// schema.ForwardHeaderOff.
+const (
+	headerXForwardedFor   = "X-Forwarded-For"
+	headerXForwardedProto = "X-Forwarded-Proto"
+	headerXForwardedHost  = "X-Forwarded-Host"
+	headerXForwardedPort  = "X-Forwarded-Port"
+	headerForwarded       = "Forwarded"
+)
+
+// forwarding is what director needs to set the forwarding headers of one request.
+type forwarding struct {
+	clientIP     string                // resolved client IP (see clientIPFromRequest); empty when unknown
+	peerTrusted  bool                  // the connection comes from a trusted proxy: its forwarding headers are extended
+	preserveHost bool                  // send the incoming Host instead of the target's (TargetServer.PreserveHost)
+	opts         *schema.ServerOptions // header names; nil = defaults
+}
+
+// forwardHeaderName returns the header to send for a forwarding header: the configured name, def when
+// none is configured, or "" when it is disabled.
+func forwardHeaderName(configured, def string) string {
+	switch configured = strings.TrimSpace(configured); configured {
+	case "":
+		return def
+	case schema.ForwardHeaderOff:
+		return ""
+	}
+	return configured
+}
+
+// reservedForwardHeaders cannot be used as forwarding header names: hop-by-hop headers are stripped
+// before targets see them, and the rest carry the request itself.
+var reservedForwardHeaders = map[string]bool{
+	"Host": true, "Authorization": true, "Cookie": true, "Content-Length": true, "Content-Type": true,
+	"Content-Encoding": true, "Expect": true, "Connection": true, "Keep-Alive": true, "Proxy-Authenticate": true,
+	"Proxy-Authorization": true, "Proxy-Connection": true, "Te": true, "Trailer": true, "Transfer-Encoding": true,
+	"Upgrade": true,
+}
+
+// ValidateForwardHeaders checks the forwarding header names of opts: each must be a valid header field
+// name (an RFC 7230 token) that is not reserved, and no two enabled headers may share a name.
+func ValidateForwardHeaders(opts schema.ServerOptions) error {
+	seen := map[string]string{}
+	for _, h := range []struct{ option, configured, def string }{
+		{"forwarded_for_header", opts.ForwardedForHeader, headerXForwardedFor},
+		{"forwarded_proto_header", opts.ForwardedProtoHeader, headerXForwardedProto},
+		{"forwarded_host_header", opts.ForwardedHostHeader, headerXForwardedHost},
+		{"forwarded_port_header", opts.ForwardedPortHeader, headerXForwardedPort},
+		{"forwarded_header", opts.ForwardedHeader, headerForwarded},
+	} {
+		name := forwardHeaderName(h.configured, h.def)
+		if name == "" {
+			continue
+		}
+		if !validHeaderName(name) {
+			return fmt.Errorf("%s: %q is not a valid header name", h.option, name)
+		}
+		key := http.CanonicalHeaderKey(name)
+		if reservedForwardHeaders[key] {
+			return fmt.Errorf("%s: %s is reserved", h.option, key)
+		}
+		if other, ok := seen[key]; ok {
+			return fmt.Errorf("%s: %s is already used by %s", h.option, key, other)
+		}
+		seen[key] = h.option
+	}
+	return nil
+}
+
+// validHeaderName reports whether name is a header field name: a non-empty RFC 7230 token.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !isTokenChar(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// priorHeader returns what the previous hop sent for a forwarding header: the values under the standard
+// name def, which proxies in front of us write, else those under the configured name when it differs.
+func priorHeader(in *http.Request, def, name string) []string {
+	if v := in.Header.Values(def); len(v) > 0 || strings.EqualFold(def, name) {
+		return v
+	}
+	return in.Header.Values(name)
+}
+
+// setForwardingHeaders sets X-Forwarded-For, X-Forwarded-Proto, X-Forwarded-Host, X-Forwarded-Port and
+// Forwarded (RFC 7239) on out, under their configured names. Proto, host and port describe the listener
+// the request came in on. When the peer is a trusted proxy, the chain it sent is extended with the peer
+// (and its proto, host and port are kept, since they describe the original request); otherwise whatever
+// the client sent is replaced, so targets never see spoofed values. The chain a trusted proxy sent is
+// read from the standard names even when a header is renamed (see priorHeader).
+func setForwardingHeaders(out, in *http.Request, fwd forwarding) {
+	opts := fwd.opts
+	if opts == nil {
+		opts = &schema.ServerOptions{}
+	}
+	// Drop the standard names first: a renamed or disabled header must not leak the client's value.
+	for _, h := range []string{headerXForwardedFor, headerXForwardedProto, headerXForwardedHost, headerXForwardedPort, headerForwarded} {
+		out.Header.Del(h)
+	}
+	peer := ""
+	if ip := parseHopIP(in.RemoteAddr); ip != nil {
+		peer = ip.String()
+	}
+	proto := "http"
+	if in.TLS != nil {
+		proto = "https"
+	}
+
+	if name := forwardHeaderName(opts.ForwardedForHeader, headerXForwardedFor); name != "" {
+		prior := strings.Join(priorHeader(in, headerXForwardedFor, name), ", ")
+		switch {
+		case fwd.peerTrusted && prior != "" && peer != "":
+			out.Header.Set(name, prior+", "+peer)
+		case fwd.clientIP != "":
+			out.Header.Set(name, fwd.clientIP)
+		default:
+			out.Header.Del(name)
+		}
+	}
+	keepOrSet := func(configured, def, value string) {
+		name := forwardHeaderName(configured, def)
+		if name == "" {
+			return
+		}
+		if v := priorHeader(in, def, name); fwd.peerTrusted && len(v) > 0 && v[0] != "" {
+			out.Header.Set(name, v[0])
+			return
+		}
+		out.Header.Set(name, value)
+	}
+	keepOrSet(opts.ForwardedProtoHeader, headerXForwardedProto, proto)
+	keepOrSet(opts.ForwardedHostHeader, headerXForwardedHost, in.Host)
+	keepOrSet(opts.ForwardedPortHeader, headerXForwardedPort, listenerPort(in, proto))
+
+	if name := forwardHeaderName(opts.ForwardedHeader, headerForwarded); name != "" {
+		prior := strings.Join(priorHeader(in, headerForwarded, name), ", ")
+		node := fwd.clientIP
+		if fwd.peerTrusted && prior != "" {
+			node = peer
+		}
+		elem := "for=" + forwardedNode(node) + ";host=" + forwardedValue(in.Host) + ";proto=" + proto
+		if fwd.peerTrusted && prior != "" {
+			elem = prior + ", " + elem
+		}
+		out.Header.Set(name, elem)
+	}
+}
+
+// listenerPort returns the port of the listener that accepted in: from the connection's local address,
+// else the Host header, else the scheme's default.
+func listenerPort(in *http.Request, proto string) string {
+	if addr, ok := in.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		if _, port, err := net.SplitHostPort(addr.String()); err == nil {
+			return port
+		}
+	}
+	if _, port, err := net.SplitHostPort(in.Host); err == nil && port != "" {
+		return port
+	}
+	if proto == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// forwardedNode formats an IP as an RFC 7239 node: IPv6 in quoted brackets, "unknown" when empty.
+func forwardedNode(ip string) string {
+	switch {
+	case ip == "":
+		return "unknown"
+	case strings.Contains(ip, ":"):
+		return `"[` + ip + `]"`
+	}
+	return ip
+}
+
+// forwardedValue quotes an RFC 7239 value when it is not a plain token (e.g. a host with a port).
+func forwardedValue(v string) string {
+	for _, c := range v {
+		if !isTokenChar(c) {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+		}
+	}
+	return v
+}
+
+func isTokenChar(c rune) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-.^_`|~", c)
+}
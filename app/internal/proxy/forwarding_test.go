@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"FeatherProxy/app/internal/database/schema"
+)
+
+func TestSetForwardingHeaders(t *testing.T) {
+	spoofed := func() *http.Request {
+		in := httptest.NewRequest(http.MethodGet, "http://api.example.com:8080/x", nil)
+		in.RemoteAddr = "10.0.0.2:5000"
+		in.Header.Set("X-Forwarded-For", "203.0.113.9")
+		in.Header.Set("X-Forwarded-Proto", "https")
+		in.Header.Set("X-Forwarded-Host", "public.example.com")
+		in.Header.Set("X-Forwarded-Port", "443")
+		in.Header.Set("Forwarded", `for=203.0.113.9;proto=https`)
+		return in
+	}
+
+	// Untrusted peer on a plain HTTP listener: everything the client sent is replaced.
+	in := spoofed()
+	in = in.WithContext(context.WithValue(in.Context(), http.LocalAddrContextKey, &net.TCPAddr{IP: net.ParseIP("0.0.0.0"), Port: 8080}))
+	out := in.Clone(in.Context())
+	setForwardingHeaders(out, in, forwarding{clientIP: "10.0.0.2"})
+	for h, want := range map[string]string{
+		"X-Forwarded-For":   "10.0.0.2",
+		"X-Forwarded-Proto": "http",
+		"X-Forwarded-Host":  "api.example.com:8080",
+		"X-Forwarded-Port":  "8080",
+		"Forwarded":         `for=10.0.0.2;host="api.example.com:8080";proto=http`,
+	} {
+		if got := out.Header.Get(h); got != want {
+			t.Errorf("untrusted %s = %q, want %q", h, got, want)
+		}
+	}
+
+	// Trusted peer: the chain is extended and the original proto, host and port are kept.
+	in = spoofed()
+	out = in.Clone(in.Context())
+	setForwardingHeaders(out, in, forwarding{clientIP: "203.0.113.9", peerTrusted: true})
+	for h, want := range map[string]string{
+		"X-Forwarded-For":   "203.0.113.9, 10.0.0.2",
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "public.example.com",
+		"X-Forwarded-Port":  "443",
+		"Forwarded":         `for=203.0.113.9;proto=https, for=10.0.0.2;host="api.example.com:8080";proto=http`,
+	} {
+		if got := out.Header.Get(h); got != want {
+			t.Errorf("trusted %s = %q, want %q", h, got, want)
+		}
+	}
+
+	// Renamed and disabled headers; IPv6 client in Forwarded.
+	in = spoofed()
+	in.Header.Set("X-Client-Chain", "198.51.100.1")
+	out = in.Clone(in.Context())
+	opts := &schema.ServerOptions{
+		ForwardedForHeader:   "X-Client-Chain",
+		ForwardedPortHeader:  schema.ForwardHeaderOff,
+		ForwardedHostHeader:  schema.ForwardHeaderOff,
+		ForwardedProtoHeader: schema.ForwardHeaderOff,
+	}
+	setForwardingHeaders(out, in, forwarding{clientIP: "2001:db8::7", opts: opts})
+	if got := out.Header.Get("X-Client-Chain"); got != "2001:db8::7" {
+		t.Errorf("renamed X-Forwarded-For = %q", got)
+	}
+	for _, h := range []string{"X-Forwarded-For", "X-Forwarded-Port", "X-Forwarded-Host", "X-Forwarded-Proto"} {
+		if got := out.Header.Get(h); got != "" {
+			t.Errorf("%s = %q, want it removed", h, got)
+		}
+	}
+	if got := out.Header.Get("Forwarded"); got != `for="[2001:db8::7]";host="api.example.com:8080";proto=http` {
+		t.Errorf("Forwarded = %q", got)
+	}
+
+	// Renamed header behind a trusted proxy: the chain is read from the standard X-Forwarded-For the
+	// proxy wrote, not from the renamed header.
+	in = spoofed()
+	in.Header.Set("X-Client-Chain", "198.51.100.1")
+	out = in.Clone(in.Context())
+	setForwardingHeaders(out, in, forwarding{clientIP: "203.0.113.9", peerTrusted: true, opts: &schema.ServerOptions{ForwardedForHeader: "X-Client-Chain"}})
+	if got := out.Header.Get("X-Client-Chain"); got != "203.0.113.9, 10.0.0.2" {
+		t.Errorf("trusted renamed X-Forwarded-For = %q", got)
+	}
+}
+
+func TestValidateForwardHeaders(t *testing.T) {
+	for _, tt := range []struct {
+		opts    schema.ServerOptions
+		wantErr bool
+	}{
+		{schema.ServerOptions{}, false},
+		{schema.ServerOptions{ForwardedForHeader: "X-Client-Chain", ForwardedHeader: schema.ForwardHeaderOff}, false},
+		{schema.ServerOptions{ForwardedForHeader: "X Client"}, true},
+		{schema.ServerOptions{ForwardedForHeader: "X-Client:Chain"}, true},
+		{schema.ServerOptions{ForwardedHostHeader: "host"}, true},
+		{schema.ServerOptions{ForwardedProtoHeader: "Transfer-Encoding"}, true},
+		{schema.ServerOptions{ForwardedPortHeader: "x-forwarded-for"}, true},
+		{schema.ServerOptions{ForwardedForHeader: schema.ForwardHeaderOff, ForwardedPortHeader: "X-Forwarded-For"}, false},
+	} {
+		if err := ValidateForwardHeaders(tt.opts); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateForwardHeaders(%+v) = %v, want error %v", tt.opts, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDirectorPreservesHost(t *testing.T) {
+	target, _ := url.Parse("http://backend.internal:9000/api")
+	incoming := httptest.NewRequest(http.MethodGet, "http://shop.example.com/api", nil)
+	out, _ := http.NewRequest(http.MethodGet, "/", nil)
+	director(target, incoming, forwarding{clientIP: "192.0.2.1", preserveHost: true}, nil)(out)
+	if out.Host != "shop.example.com" || out.URL.Host != "backend.internal:9000" {
+		t.Errorf("Host = %q, URL host = %q; want the incoming Host sent to the target's address", out.Host, out.URL.Host)
+	}
+}
+
+func TestReverseProxyForwardsHeadersOnce(t *testing.T) {
+	var got http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer backend.Close()
+	target, _ := url.Parse(backend.URL + "/x")
+
+	in := httptest.NewRequest(http.MethodGet, "http://proxy.local/x", nil)
+	in.RemoteAddr = "192.0.2.1:1234"
+	in.Header.Set("X-Forwarded-For", "203.0.113.9") // spoofed: the peer is not trusted
+	rewrite := director(target, in, forwarding{clientIP: "192.0.2.1"}, nil)
+	proxy := &httputil.ReverseProxy{Rewrite: func(pr *httputil.ProxyRequest) { rewrite(pr.Out) }}
+	proxy.ServeHTTP(httptest.NewRecorder(), in)
+	if xff := got.Values("X-Forwarded-For"); len(xff) != 1 || xff[0] != "192.0.2.1" {
+		t.Errorf("backend X-Forwarded-For = %q, want just the client", xff)
+	}
+}
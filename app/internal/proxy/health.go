@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// unhealthyAfter is the number of consecutive upstream failures after which a target is skipped.
+	unhealthyAfter = 3
+	// unhealthyCooldown is how long a failing target is skipped before it gets traffic again.
+	unhealthyCooldown = 30 * time.Second
+)
+
+// healthTracker keeps passive health state per target server, based on the outcome of proxied requests.
+// Safe for concurrent use.
+type healthTracker struct {
+	mu    sync.Mutex
+	state map[uuid.UUID]*targetHealth
+	now   func() time.Time
+}
+
+type targetHealth struct {
+	failures  int
+	downUntil time.Time
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{state: make(map[uuid.UUID]*targetHealth), now: time.Now}
+}
+
+// healthy returns false while the target is in its cooldown after repeated failures.
+func (h *healthTracker) healthy(id uuid.UUID) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st, ok := h.state[id]
+	if !ok {
+		return true
+	}
+	return !h.now().Before(st.downUntil)
+}
+
+// failure records a failed upstream call; the target is marked down once the threshold is reached.
+func (h *healthTracker) failure(id uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st, ok := h.state[id]
+	if !ok {
+		st = &targetHealth{}
+		h.state[id] = st
+	}
+	st.failures++
+	if st.failures >= unhealthyAfter {
+		st.downUntil = h.now().Add(unhealthyCooldown)
+		st.failures = 0
+	}
+}
+
+// success clears the failure count for the target.
+func (h *healthTracker) success(id uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.state, id)
+}
@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// activeMaintenance returns the maintenance window of the given scope and owner when it applies to this
+// request: enabled, inside its schedule, and the client is not on its allowlist. The window is read per
+// request (through the repository cache), so toggling it takes effect without a reload.
+func (s *Service) activeMaintenance(r *http.Request, scope string, ownerUUID uuid.UUID, clientIP string) (schema.Maintenance, bool) {
+	m, err := repoCall(r.Context(), "GetMaintenance", func() (schema.Maintenance, error) { return s.repo.GetMaintenance(scope, ownerUUID) })
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.ErrorContext(r.Context(), "get maintenance failed", "scope", scope, "owner", ownerUUID, "error", err)
+		}
+		return schema.Maintenance{}, false
+	}
+	if !m.ActiveAt(time.Now()) {
+		return schema.Maintenance{}, false
+	}
+	if _, ok := clientMatchesACL(r.Context(), net.ParseIP(clientIP), m.AllowList, s.resolver, s.geo); ok {
+		logger.DebugContext(r.Context(), "client on maintenance allowlist, bypassing", "scope", scope, "owner", ownerUUID, "client_ip", clientIP)
+		return schema.Maintenance{}, false
+	}
+	return m, true
+}
+
+// serveMaintenance answers 503 for an active maintenance window, with Retry-After and the window's
+// body (or the source server's service_unavailable error page when no body is set).
+func (s *Service) serveMaintenance(w http.ResponseWriter, r *http.Request, sourceServerUUID uuid.UUID, m *schema.Maintenance) {
+	logger.DebugContext(r.Context(), "answered by maintenance", "method", r.Method, "path", r.URL.Path, "scope", m.Scope, "owner", m.OwnerUUID)
+	if v := retryAfter(m, time.Now()); v != "" {
+		w.Header().Set("Retry-After", v)
+	}
+	if m.Body == "" {
+		s.writeError(w, r, sourceServerUUID, schema.ErrorKindServiceUnavailable)
+		return
+	}
+	contentType := m.ContentType
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = w.Write([]byte(m.Body))
+}
+
+// retryAfter returns the Retry-After value for a window: the configured delay in seconds, else the
+// scheduled end as an HTTP date, else empty.
+func retryAfter(m *schema.Maintenance, now time.Time) string {
+	if m.RetryAfterSeconds > 0 {
+		return strconv.Itoa(m.RetryAfterSeconds)
+	}
+	if m.EndsAt != nil && m.EndsAt.After(now) {
+		return m.EndsAt.UTC().Format(http.TimeFormat)
+	}
+	return ""
+}
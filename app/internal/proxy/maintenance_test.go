@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+func TestMaintenanceActiveAt(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	before, after := now.Add(-time.Hour), now.Add(time.Hour)
+	tests := []struct {
+		name string
+		m    schema.Maintenance
+		want bool
+	}{
+		{"disabled", schema.Maintenance{}, false},
+		{"enabled, no schedule", schema.Maintenance{Enabled: true}, true},
+		{"not started", schema.Maintenance{Enabled: true, StartsAt: &after}, false},
+		{"inside window", schema.Maintenance{Enabled: true, StartsAt: &before, EndsAt: &after}, true},
+		{"ended", schema.Maintenance{Enabled: true, EndsAt: &before}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.m.ActiveAt(now); got != tt.want {
+			t.Errorf("%s: ActiveAt = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	end := now.Add(30 * time.Minute)
+	if got := retryAfter(&schema.Maintenance{RetryAfterSeconds: 120, EndsAt: &end}, now); got != "120" {
+		t.Errorf("seconds: got %q", got)
+	}
+	if got := retryAfter(&schema.Maintenance{EndsAt: &end}, now); got != "Sun, 01 Jun 2025 12:30:00 GMT" {
+		t.Errorf("end date: got %q", got)
+	}
+	if got := retryAfter(&schema.Maintenance{}, now); got != "" {
+		t.Errorf("none: got %q", got)
+	}
+}
+
+func TestServeMaintenance(t *testing.T) {
+	s := &Service{}
+	m := &schema.Maintenance{OwnerUUID: uuid.New(), Scope: schema.MaintenanceScopeRoute, Enabled: true, Body: `{"status":"maintenance"}`, ContentType: "application/json", RetryAfterSeconds: 600}
+	w := httptest.NewRecorder()
+	s.serveMaintenance(w, httptest.NewRequest(http.MethodGet, "/", nil), uuid.New(), m)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "600" || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", w.Header())
+	}
+	if w.Body.String() != m.Body {
+		t.Errorf("body = %s", w.Body.String())
+	}
+}
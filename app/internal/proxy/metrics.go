@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"FeatherProxy/app/internal/metrics"
+
+	"github.com/google/uuid"
+)
+
+// observeRequest records a finished request in the Prometheus request counter and latency histogram.
+func observeRequest(rec *responseRecorder, r *http.Request, sourceServerUUID uuid.UUID, info *requestInfo) {
+	labels := []string{
+		sourceServerUUID.String(),
+		uuidLabel(info.routeUUID),
+		uuidLabel(info.targetServerUUID),
+		metrics.MethodLabel(r.Method),
+		metrics.StatusClass(rec.statusCode),
+	}
+	metrics.Requests.With(labels...).Inc()
+	metrics.RequestDuration.With(labels...).Observe(time.Since(rec.start).Seconds())
+}
+
+// observeUpstreamError counts a failed upstream round trip; client cancellations are counted as "canceled".
+func observeUpstreamError(sourceServerUUID uuid.UUID, info *requestInfo, err error) {
+	kind := transportErrorKind(err)
+	if errors.Is(err, context.Canceled) {
+		kind = "canceled"
+	}
+	metrics.UpstreamErrors.With(sourceServerUUID.String(), uuidLabel(info.routeUUID), uuidLabel(info.targetServerUUID), kind).Inc()
+}
+
+// uuidLabel returns id as a label value, or "" for uuid.Nil.
+func uuidLabel(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+	return id.String()
+}
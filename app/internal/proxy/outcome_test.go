@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"FeatherProxy/app/internal/database"
+	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/logging"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// outcomeRepo serves the lookups the handler makes before reaching the upstream; other methods are not
+// implemented (the embedded nil interface panics if they are called).
+type outcomeRepo struct {
+	database.Repository
+	acl    schema.ACLOptions
+	routes map[string]schema.Route // by path
+	auths  []schema.RouteSourceAuth
+}
+
+func (r *outcomeRepo) GetServerOptions(uuid.UUID) (schema.ServerOptions, error) {
+	return schema.ServerOptions{}, gorm.ErrRecordNotFound
+}
+func (r *outcomeRepo) GetACLOptions(uuid.UUID) (schema.ACLOptions, error) {
+	if r.acl.Mode == "" {
+		return schema.ACLOptions{}, gorm.ErrRecordNotFound
+	}
+	return r.acl, nil
+}
+func (r *outcomeRepo) GetErrorPages(uuid.UUID) (schema.ErrorPages, error) {
+	return schema.ErrorPages{}, gorm.ErrRecordNotFound
+}
+func (r *outcomeRepo) GetAccessLogOptions(uuid.UUID) (schema.AccessLogOptions, error) {
+	return schema.AccessLogOptions{}, gorm.ErrRecordNotFound
+}
+func (r *outcomeRepo) GetMaintenance(string, uuid.UUID) (schema.Maintenance, error) {
+	return schema.Maintenance{}, gorm.ErrRecordNotFound
+}
+func (r *outcomeRepo) FindRouteBySourceMethodPath(_ uuid.UUID, _, path string) (schema.Route, error) {
+	if rt, ok := r.routes[path]; ok {
+		return rt, nil
+	}
+	return schema.Route{}, gorm.ErrRecordNotFound
+}
+func (r *outcomeRepo) ListSourceAuthsForRoute(uuid.UUID) ([]schema.RouteSourceAuth, error) {
+	return r.auths, nil
+}
+func (r *outcomeRepo) GetAuthenticationWithPlainToken(id uuid.UUID) (schema.Authentication, error) {
+	return schema.Authentication{AuthenticationUUID: id, TokenType: "bearer", Token: "secret"}, nil
+}
+
+type captureRecorder struct{ stats []schema.ProxyStat }
+
+func (c *captureRecorder) Record(stat schema.ProxyStat) { c.stats = append(c.stats, stat) }
+
+func TestHandlerRecordsOutcome(t *testing.T) {
+	staticRoute := schema.Route{RouteUUID: uuid.New(), Method: "GET", SourcePath: "/static", Kind: schema.RouteKindStatic, Response: &schema.RouteResponse{Status: 200, Body: "hi"}}
+	authRoute := schema.Route{RouteUUID: uuid.New(), Method: "GET", SourcePath: "/private", Kind: schema.RouteKindStatic, Response: &schema.RouteResponse{Status: 200}}
+	authUUID := uuid.New()
+	repo := &outcomeRepo{routes: map[string]schema.Route{"/static": staticRoute, "/private": authRoute}}
+	rec := &captureRecorder{}
+	s := NewService(repo, nil, 0, rec, nil)
+	h := s.handler(uuid.New())
+
+	tests := []struct {
+		path, auth, outcome string
+		acl                 schema.ACLOptions
+		status              int
+	}{
+		{path: "/missing", outcome: schema.OutcomeNoRoute, status: http.StatusNotFound},
+		{path: "/static", outcome: schema.OutcomeServed, status: http.StatusOK},
+		{path: "/static", outcome: schema.OutcomeACLDenied, status: http.StatusForbidden, acl: schema.ACLOptions{Mode: "deny_only", ClientIPHeader: "X-Client-IP", DenyList: []string{"192.0.2.0/24"}}},
+		{path: "/private", outcome: schema.OutcomeAuthDenied, status: http.StatusForbidden},
+		{path: "/private", auth: "Bearer secret", outcome: schema.OutcomeServed, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		repo.acl = tt.acl
+		repo.auths = nil
+		if tt.path == "/private" {
+			repo.auths = []schema.RouteSourceAuth{{RouteUUID: authRoute.RouteUUID, AuthenticationUUID: authUUID}}
+		}
+		rec.stats = nil
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("X-Client-IP", "192.0.2.10")
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s %s: status = %d, want %d", tt.path, tt.outcome, w.Code, tt.status)
+		}
+		if len(rec.stats) != 1 {
+			t.Fatalf("%s %s: recorded %d stats, want 1", tt.path, tt.outcome, len(rec.stats))
+		}
+		st := rec.stats[0]
+		if st.Outcome != tt.outcome || st.StatusCode == nil || *st.StatusCode != tt.status {
+			t.Errorf("%s: stat outcome = %q status = %v, want %q", tt.path, st.Outcome, st.StatusCode, tt.outcome)
+		}
+		if tt.outcome == schema.OutcomeACLDenied && st.ACLRule != "192.0.2.0/24" {
+			t.Errorf("%s: acl_rule = %q, want the matched deny entry", tt.path, st.ACLRule)
+		}
+		if tt.auth != "" && (st.AuthenticationUUID == nil || *st.AuthenticationUUID != authUUID) {
+			t.Errorf("%s: authentication_uuid = %v, want %s", tt.path, st.AuthenticationUUID, authUUID)
+		}
+	}
+}
+
+func TestHandlerMissingACLOptionsIsNotAnError(t *testing.T) {
+	var buf bytes.Buffer
+	logging.Configure(logging.Config{Level: slog.LevelInfo, Output: &buf})
+	t.Cleanup(func() { logging.Configure(logging.Config{Level: slog.LevelInfo}) })
+
+	route := schema.Route{RouteUUID: uuid.New(), Method: "GET", SourcePath: "/static", Kind: schema.RouteKindStatic, Response: &schema.RouteResponse{Status: 200}}
+	repo := &outcomeRepo{routes: map[string]schema.Route{"/static": route}}
+	h := NewService(repo, nil, 0, &captureRecorder{}, nil).handler(uuid.New())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", w.Code)
+	}
+	if strings.Contains(buf.String(), "get ACL options failed") {
+		t.Errorf("missing ACL options logged as an error: %s", buf.String())
+	}
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/logging"
+
+	"github.com/google/uuid"
+)
+
+// defaultRequestIDHeader is used when the source server's options do not name a request ID header.
+const defaultRequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds accepted incoming request IDs; longer values are replaced.
+const maxRequestIDLen = 128
+
+type requestIDKey struct{}
+
+// requestIDHeader returns the request ID header configured in opts, or the default.
+func requestIDHeader(opts *schema.ServerOptions) string {
+	if opts != nil && opts.RequestIDHeader != "" {
+		return opts.RequestIDHeader
+	}
+	return defaultRequestIDHeader
+}
+
+// withRequestID takes the request ID from the incoming header when it is usable, otherwise generates a
+// UUIDv7. The ID is set on the request header (so it is forwarded to the target), echoed on the response
+// and stored in the request context for stats; log records written with the request context carry it.
+func withRequestID(w http.ResponseWriter, r *http.Request, header string) *http.Request {
+	id := r.Header.Get(header)
+	if !validRequestID(id) {
+		id = newRequestID()
+		r.Header.Set(header, id)
+	}
+	w.Header().Set(header, id)
+	ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+	return r.WithContext(logging.ContextWithAttrs(ctx, slog.String("request_id", id)))
+}
+
+// requestIDFrom returns the request ID stored by withRequestID, or "".
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID accepts non-empty IDs of visible ASCII characters up to maxRequestIDLen, so a client
+// cannot inject spaces or control characters into logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+	return id.String()
+}
@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+func TestWithRequestID_keepsIncoming(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Correlation-Id", "abc-123")
+	w := httptest.NewRecorder()
+	r = withRequestID(w, r, requestIDHeader(&schema.ServerOptions{RequestIDHeader: "X-Correlation-Id"}))
+	if got := requestIDFrom(r.Context()); got != "abc-123" {
+		t.Errorf("request ID = %q, want abc-123", got)
+	}
+	if got := w.Header().Get("X-Correlation-Id"); got != "abc-123" {
+		t.Errorf("echoed = %q, want abc-123", got)
+	}
+}
+
+func TestWithRequestID_generates(t *testing.T) {
+	for _, incoming := range []string{"", "has space", strings.Repeat("x", maxRequestIDLen+1)} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if incoming != "" {
+			r.Header.Set(defaultRequestIDHeader, incoming)
+		}
+		w := httptest.NewRecorder()
+		r = withRequestID(w, r, requestIDHeader(nil))
+		id := requestIDFrom(r.Context())
+		parsed, err := uuid.Parse(id)
+		if err != nil || parsed.Version() != 7 {
+			t.Errorf("incoming %q: generated %q is not a UUIDv7", incoming, id)
+		}
+		if r.Header.Get(defaultRequestIDHeader) != id || w.Header().Get(defaultRequestIDHeader) != id {
+			t.Errorf("incoming %q: ID not forwarded and echoed", incoming)
+		}
+	}
+}
@@ -3,12 +3,12 @@ package proxy
 import (
 	"context"
 	"encoding/json"
-	"log"
 	"net"
 	"strings"
 	"time"
 
 	"FeatherProxy/app/internal/cache"
+	"FeatherProxy/app/internal/geoip"
 )
 
 // Caching key prefix
@@ -22,6 +22,12 @@ type HostnameResolver interface {
 	ReverseLookup(ctx context.Context, ip net.IP) ([]string, error)
 }
 
+// GeoLocator looks client IPs up in GeoIP databases, for country and ASN ACL entries.
+// *geoip.Resolver implements it. Implementations should be safe for concurrent use.
+type GeoLocator interface {
+	Lookup(ip net.IP) geoip.Location
+}
+
 type cachingResolver struct {
 	cache cache.Cache
 	ttl   time.Duration
@@ -30,7 +36,6 @@ type cachingResolver struct {
 type noCacheResolver struct{}
 
 func ReverseLookup(ctx context.Context, ip net.IP) ([]string, error) {
-	log.Println("ReverseLookup", "ip", ip.String())
 	if ip == nil {
 		return nil, nil
 	}
@@ -52,8 +57,7 @@ func ReverseLookup(ctx context.Context, ip net.IP) ([]string, error) {
 			hostnames = append(hostnames, h)
 		}
 	}
-	log.Println("ReverseLookup", "hostnames", hostnames)
-	log.Println("ReverseLookup", "err", err)
+	aclLogger.DebugContext(ctx, "reverse lookup", "ip", ip.String(), "hostnames", hostnames, "error", err)
 	return hostnames, err
 }
 
@@ -3,9 +3,10 @@ package proxy
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"math/rand/v2"
 	"net"
 	"net/http"
 	"net/http/httputil"
@@ -18,9 +19,18 @@ import (
 	"FeatherProxy/app/internal/cache"
 	"FeatherProxy/app/internal/database"
 	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/logging"
+	"FeatherProxy/app/internal/metrics"
 	"FeatherProxy/app/internal/stats"
+	"FeatherProxy/app/internal/tracing"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	logger    = logging.For(logging.Proxy)
+	aclLogger = logging.For(logging.ACL)
 )
 
 // maxDebugPayloadBytes is the maximum request body bytes to log when debug payload is enabled.
@@ -44,14 +54,14 @@ func debugPayloadFilePath() string {
 func peekAndRestoreBody(r *http.Request) {
 	prefix, err := io.ReadAll(io.LimitReader(r.Body, maxDebugPayloadBytes))
 	if err != nil {
-		log.Printf("proxy/debug: read body prefix: %v", err)
+		logger.WarnContext(r.Context(), "debug payload: read body prefix failed", "error", err)
 	}
 	truncated := len(prefix) == maxDebugPayloadBytes
 	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(prefix), r.Body))
 	if len(prefix) == 0 {
 		return
 	}
-	msg := fmt.Sprintf("%s %s %d bytes", r.Method, r.URL.Path, len(prefix))
+	msg := fmt.Sprintf("%s %s %d bytes request_id=%s", r.Method, r.URL.Path, len(prefix), requestIDFrom(r.Context()))
 	if truncated {
 		msg += " (truncated)"
 	}
@@ -62,38 +72,49 @@ func peekAndRestoreBody(r *http.Request) {
 			_, _ = f.WriteString(line)
 			_ = f.Close()
 		} else {
-			log.Printf("proxy/debug: write payload file: %v", err)
+			logger.WarnContext(r.Context(), "debug payload: write payload file failed", "error", err)
 		}
 		debugPayloadFileMu.Unlock()
 	} else {
-		if truncated {
-			log.Printf("proxy/debug: %s body (first %d bytes, truncated): %s", msg, len(prefix), string(prefix))
-		} else {
-			log.Printf("proxy/debug: %s body: %s", msg, string(prefix))
-		}
+		logger.InfoContext(r.Context(), "debug payload", "method", r.Method, "path", r.URL.Path, "bytes", len(prefix), "truncated", truncated, "body", string(prefix))
 	}
 }
 
 // Service runs one HTTP listener per source server and proxies matching requests to target servers.
 type Service struct {
-	repo     database.Repository
-	resolver HostnameResolver
-	recorder stats.Recorder // optional; when set, proxied requests are recorded for stats
+	repo        database.Repository
+	resolver    HostnameResolver
+	recorder    stats.Recorder  // optional; when set, proxied requests are recorded for stats
+	health      *healthTracker  // passive upstream health, used to fail over within a route's target pool
+	affinityKey []byte          // signs affinity cookies
+	accessLogs  *accessLogs     // per-source-server access logs, opened on first use
+	tracer      *tracing.Tracer // optional; when set, requests are traced and trace context is propagated
+	geo         GeoLocator      // optional; country and ASN ACL entries match only when set
 }
 
 // NewService returns a proxy service that uses the given repository for route
 // and server lookups. It configures a HostnameResolver for ACL hostname and
 // wildcard matching, using the same cache instance and TTL as the database
 // cache when available. If recorder is non-nil, successfully proxied requests
-// are recorded asynchronously for statistics.
-func NewService(repo database.Repository, c cache.Cache, cacheTTL time.Duration, recorder stats.Recorder) *Service {
+// are recorded asynchronously for statistics. If tracer is non-nil, each request gets a span (continuing
+// an incoming W3C trace) and the trace context is forwarded to the target.
+func NewService(repo database.Repository, c cache.Cache, cacheTTL time.Duration, recorder stats.Recorder, tracer *tracing.Tracer) *Service {
 	return &Service{
-		repo:     repo,
-		resolver: NewResolver(c, cacheTTL),
-		recorder: recorder,
+		repo:        repo,
+		resolver:    NewResolver(c, cacheTTL),
+		recorder:    recorder,
+		health:      newHealthTracker(),
+		affinityKey: affinitySecretFromEnv(),
+		accessLogs:  newAccessLogs(),
+		tracer:      tracer,
 	}
 }
 
+// SetGeoIP sets the GeoIP databases used by "country:" and "asn:" ACL entries. Call before Run.
+func (s *Service) SetGeoIP(geo GeoLocator) {
+	s.geo = geo
+}
+
 // Run starts a listener for each source server and blocks until ctx is cancelled.
 // On shutdown, all proxy servers are stopped. If there are no source servers, Run returns when ctx is done.
 func (s *Service) Run(ctx context.Context) error {
@@ -103,7 +124,7 @@ func (s *Service) Run(ctx context.Context) error {
 	}
 
 	if len(sources) == 0 {
-		log.Println("proxy: no source servers configured, waiting for shutdown")
+		logger.Info("no source servers configured, waiting for shutdown")
 		<-ctx.Done()
 		return nil
 	}
@@ -113,6 +134,7 @@ func (s *Service) Run(ctx context.Context) error {
 	defer func() {
 		close(shutdown)
 		wg.Wait()
+		s.accessLogs.closeAll()
 	}()
 
 	for i := range sources {
@@ -123,23 +145,35 @@ func (s *Service) Run(ctx context.Context) error {
 			Handler: s.handler(source.SourceServerUUID),
 		}
 		opts, _ := s.repo.GetServerOptions(source.SourceServerUUID)
+		up := metrics.ListenerUp.With(source.SourceServerUUID.String(), addr, source.Protocol)
+		up.Set(0)
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if source.Protocol == "https" {
-				if opts.TLSCertPath == "" || opts.TLSKeyPath == "" {
-					log.Printf("proxy: HTTPS source %s (%s) missing TLS cert/key paths, skipping", source.Name, addr)
-					return
-				}
-				log.Printf("proxy: listening on https://%s (%s)", addr, source.Name)
-				if err := server.ListenAndServeTLS(opts.TLSCertPath, opts.TLSKeyPath); err != nil && err != http.ErrServerClosed {
-					log.Printf("proxy: server %s: %v", addr, err)
-				}
+			defer metrics.ListenerUp.Delete(source.SourceServerUUID.String(), addr, source.Protocol)
+			https := source.Protocol == "https"
+			if https && (opts.TLSCertPath == "" || opts.TLSKeyPath == "") {
+				logger.Warn("HTTPS source missing TLS cert/key paths, skipping", "source_server", source.Name, "addr", addr)
+				<-shutdown
+				return
+			}
+			ln, err := net.Listen("tcp", addr)
+			if err != nil {
+				logger.Error("server failed", "addr", addr, "error", err)
+				<-shutdown
+				return
+			}
+			logger.Info("listening", "url", source.Protocol+"://"+addr, "source_server", source.Name)
+			up.Set(1)
+			if https {
+				err = server.ServeTLS(ln, opts.TLSCertPath, opts.TLSKeyPath)
 			} else {
-				log.Printf("proxy: listening on http://%s (%s)", addr, source.Name)
-				if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-					log.Printf("proxy: server %s: %v", addr, err)
-				}
+				err = server.Serve(ln)
+			}
+			up.Set(0)
+			if err != nil && err != http.ErrServerClosed {
+				logger.Error("server failed", "addr", addr, "error", err)
+				<-shutdown
 			}
 		}()
 		go func() {
@@ -152,12 +186,15 @@ func (s *Service) Run(ctx context.Context) error {
 	return nil
 }
 
-// responseRecorder wraps http.ResponseWriter to capture status code and duration.
+// responseRecorder wraps http.ResponseWriter to capture status code, body size and duration, and for
+// proxied requests the upstream address and time to first byte.
 type responseRecorder struct {
 	http.ResponseWriter
 	statusCode int
 	written    bool
+	bytes      int64
 	start      time.Time
+	upstream   upstreamTrace
 }
 
 func (rw *responseRecorder) WriteHeader(code int) {
@@ -173,143 +210,299 @@ func (rw *responseRecorder) Write(p []byte) (int, error) {
 		rw.statusCode = http.StatusOK
 		rw.written = true
 	}
-	return rw.ResponseWriter.Write(p)
-}
-
-// clientIPString returns the client IP string for stats, using the same logic as ACL.
-func clientIPString(r *http.Request, acl *schema.ACLOptions) string {
-	ip := clientIPFromRequest(r, acl)
-	if ip != nil {
-		return ip.String()
-	}
-	// Fallback: strip port from RemoteAddr if present
-	addr := r.RemoteAddr
-	if host, _, err := net.SplitHostPort(addr); err == nil {
-		return host
-	}
-	return addr
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
 }
 
 // handler returns an http.Handler that routes requests for the given source server.
 func (s *Service) handler(sourceServerUUID uuid.UUID) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := s.tracer.StartServer(r.Context(), "proxy "+r.Method, r.Header)
+		r = r.WithContext(ctx)
+		sw := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK, start: time.Now()}
+		w = sw
+		info := &requestInfo{outcome: schema.OutcomeProxied}
+		inFlight := metrics.InFlight.With(sourceServerUUID.String())
+		inFlight.Inc()
+		defer func() {
+			inFlight.Dec()
+			observeRequest(sw, r, sourceServerUUID, info)
+			s.writeAccessLog(r, sw, sourceServerUUID, info)
+			s.recordRequest(sw, r, sourceServerUUID, info)
+			span.SetAttr("http.response.status_code", sw.statusCode)
+			if sw.statusCode >= 500 {
+				span.SetErrorStatus(http.StatusText(sw.statusCode))
+			}
+			span.End()
+		}()
+		span.SetAttr("http.request.method", r.Method)
+		span.SetAttr("url.path", r.URL.Path)
+		span.SetAttr("server.address", r.Host)
+
+		opts, err := repoCall(ctx, "GetServerOptions", func() (schema.ServerOptions, error) { return s.repo.GetServerOptions(sourceServerUUID) })
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.ErrorContext(ctx, "get server options failed", "error", err)
+		}
+		idHeader := requestIDHeader(&opts)
+		r = withRequestID(w, r, idHeader)
+		span.SetAttr("featherproxy.request_id", requestIDFrom(r.Context()))
 		if debugPayload() && r.Body != nil {
 			peekAndRestoreBody(r)
 		}
-		acl, err := s.repo.GetACLOptions(sourceServerUUID)
-		if err != nil {
-			log.Printf("proxy: get ACL options: %v", err)
+		info.requestBody = countRequestBody(r)
+
+		aclCtx, aclSpan := tracing.Start(r.Context(), "acl.evaluate", tracing.KindInternal)
+		acl, err := repoCall(aclCtx, "GetACLOptions", func() (schema.ACLOptions, error) { return s.repo.GetACLOptions(sourceServerUUID) })
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// No ACL configured for this source server.
+			acl, err = schema.ACLOptions{Mode: "off"}, nil
+		} else if err != nil {
+			aclLogger.ErrorContext(r.Context(), "get ACL options failed", "error", err)
+		}
+		// The client IP is resolved once (trusted proxies, forwarding headers) and used for ACL, stats and forwarding.
+		info.clientIP = clientIPString(r, &acl)
+		denied, rule := false, ""
+		if err == nil {
+			denied, rule = aclDeny(aclCtx, net.ParseIP(info.clientIP), &acl, s.resolver, s.geo)
+		}
+		aclSpan.SetAttr("featherproxy.acl.mode", acl.Mode)
+		aclSpan.SetAttr("featherproxy.acl.denied", denied)
+		if rule != "" {
+			aclSpan.SetAttr("featherproxy.acl.rule", rule)
 		}
-		if err == nil && aclDeny(r.Context(), r, &acl, s.resolver) {
-			log.Printf("proxy/acl: %s %s denied by ACL", r.Method, r.URL.Path)
-			http.Error(w, "Forbidden", http.StatusForbidden)
+		aclSpan.End()
+		if denied {
+			info.aclRule = rule
+			aclLogger.DebugContext(r.Context(), "denied by ACL", "method", r.Method, "path", r.URL.Path, "client_ip", info.clientIP, "mode", acl.Mode, "rule", rule)
+			metrics.ACLDenials.With(sourceServerUUID.String()).Inc()
+			info.outcome = schema.OutcomeACLDenied
+			s.writeError(w, r, sourceServerUUID, schema.ErrorKindACLDenied)
 			return
 		}
-		route, err := s.repo.FindRouteBySourceMethodPath(sourceServerUUID, r.Method, r.URL.Path)
+		clientIP := info.clientIP
+		span.SetAttr("client.address", clientIP)
+		if m, ok := s.activeMaintenance(r, schema.MaintenanceScopeSourceServer, sourceServerUUID, clientIP); ok {
+			info.outcome = schema.OutcomeMaintenance
+			s.serveMaintenance(w, r, sourceServerUUID, &m)
+			return
+		}
+		routeCtx, routeSpan := tracing.Start(r.Context(), "route.lookup", tracing.KindInternal)
+		route, err := repoCall(routeCtx, "FindRouteBySourceMethodPath", func() (schema.Route, error) {
+			return s.repo.FindRouteBySourceMethodPath(sourceServerUUID, r.Method, r.URL.Path)
+		})
+		routeSpan.SetAttr("featherproxy.route.matched", err == nil)
+		routeSpan.End()
 		if err != nil {
-			log.Printf("proxy/auth: %s %s no route match", r.Method, r.URL.Path)
-			http.NotFound(w, r)
+			logger.DebugContext(r.Context(), "no route match", "method", r.Method, "path", r.URL.Path)
+			info.outcome = schema.OutcomeNoRoute
+			s.writeError(w, r, sourceServerUUID, schema.ErrorKindNoRoute)
+			return
+		}
+		logger.DebugContext(r.Context(), "route matched", "method", r.Method, "path", r.URL.Path, "route", route.RouteUUID, "target_server", route.TargetServerUUID)
+		info.routeUUID = route.RouteUUID
+		span.SetName(r.Method + " " + route.SourcePath)
+		span.SetAttr("http.route", route.SourcePath)
+		span.SetAttr("featherproxy.route_uuid", route.RouteUUID.String())
+		if m, ok := s.activeMaintenance(r, schema.MaintenanceScopeRoute, route.RouteUUID, clientIP); ok {
+			info.outcome = schema.OutcomeMaintenance
+			s.serveMaintenance(w, r, sourceServerUUID, &m)
 			return
 		}
-		log.Printf("proxy/auth: %s %s route=%s target_server=%s", r.Method, r.URL.Path, route.RouteUUID, route.TargetServerUUID)
 
 		// Enforce source authentication (client auth) if configured for this route.
-		authorized, err := s.isSourceAuthorized(r, route.RouteUUID)
+		authCtx, authSpan := tracing.Start(r.Context(), "auth.source", tracing.KindInternal)
+		authUUID, authorized, err := s.isSourceAuthorized(r.WithContext(authCtx), route.RouteUUID)
+		authSpan.SetAttr("featherproxy.auth.authorized", authorized)
+		authSpan.RecordError(err)
+		authSpan.End()
 		if err != nil {
-			log.Printf("proxy/auth: route=%s source auth error: %v", route.RouteUUID, err)
-			http.Error(w, "source auth error", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "source auth failed", "route", route.RouteUUID, "error", err)
+			info.outcome = schema.OutcomeError
+			s.writeError(w, r, sourceServerUUID, schema.ErrorKindInternal)
 			return
 		}
 		if !authorized {
-			log.Printf("proxy/auth: route=%s source auth denied", route.RouteUUID)
-			http.Error(w, "Forbidden", http.StatusForbidden)
+			logger.DebugContext(r.Context(), "source auth denied", "route", route.RouteUUID)
+			metrics.AuthDenials.With(sourceServerUUID.String(), route.RouteUUID.String()).Inc()
+			info.outcome = schema.OutcomeAuthDenied
+			s.writeError(w, r, sourceServerUUID, schema.ErrorKindAuthDenied)
 			return
 		}
+		info.authenticationUUID = authUUID
+
+		params, _ := schema.MatchPath(route.SourcePath, r.URL.Path)
 
-		target, err := s.repo.GetTargetServer(route.TargetServerUUID)
+		// Static, redirect and mock routes answer here, after ACL and auth, without a target server.
+		if !route.ProxiesToTarget() {
+			info.outcome = schema.OutcomeServed
+			serveRouteResponse(w, r, &route, params)
+			return
+		}
+		if len(params) > 0 {
+			route.TargetPath = schema.ExpandPath(route.TargetPath, params)
+		}
+
+		// Traffic split: pick the variant (and its target) before target lookup.
+		targetServerUUID := route.TargetServerUUID
+		variant := ""
+		if split, err := repoCall(r.Context(), "GetTrafficSplit", func() (schema.TrafficSplit, error) { return s.repo.GetTrafficSplit(route.RouteUUID) }); err == nil && len(split.Rules) > 0 {
+			choice := selectVariant(r, &split, route.TargetServerUUID, rand.IntN(100))
+			targetServerUUID = choice.TargetServerUUID
+			variant = choice.Variant
+			info.variant = variant
+			if split.Sticky && choice.byWeight {
+				http.SetCookie(w, &http.Cookie{Name: stickyCookieName(&split), Value: variant, Path: "/", Secure: r.TLS != nil, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+			}
+			logger.DebugContext(r.Context(), "traffic split variant selected", "route", route.RouteUUID, "variant", variant, "target_server", targetServerUUID)
+		} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.ErrorContext(r.Context(), "get traffic split failed", "route", route.RouteUUID, "error", err)
+		}
+		// Target pool with session affinity applies to the route's own target (not to split variants).
+		if variant == "" || variant == schema.PrimaryVariant {
+			if b, err := repoCall(r.Context(), "GetRouteBalancing", func() (schema.RouteBalancing, error) { return s.repo.GetRouteBalancing(route.RouteUUID) }); err == nil && len(b.TargetServerUUIDs) > 0 {
+				targetServerUUID = s.pickUpstream(w, r, &b, route.TargetServerUUID, clientIP)
+				logger.DebugContext(r.Context(), "balancer selected target", "route", route.RouteUUID, "affinity", b.Affinity, "target_server", targetServerUUID)
+			} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.ErrorContext(r.Context(), "get balancing failed", "route", route.RouteUUID, "error", err)
+			}
+		}
+
+		target, err := repoCall(r.Context(), "GetTargetServer", func() (schema.TargetServer, error) { return s.repo.GetTargetServer(targetServerUUID) })
 		if err != nil {
-			log.Printf("proxy/auth: target server not found: %v", err)
-			http.Error(w, "target server not found", http.StatusBadGateway)
+			logger.ErrorContext(r.Context(), "target server not found", "error", err)
+			info.outcome = schema.OutcomeUpstreamError
+			s.writeError(w, r, sourceServerUUID, schema.ErrorKindBadGateway)
 			return
 		}
-		var targetAuth *schema.Authentication
-		if auth, ok, err := s.repo.GetTargetAuthenticationWithPlainToken(route.RouteUUID); err == nil && ok {
-			targetAuth = &auth
-			log.Printf("proxy/auth: route=%s target auth enabled name=%s type=%s", route.RouteUUID, auth.Name, auth.TokenType)
-		} else {
-			if err != nil {
-				log.Printf("proxy/auth: route=%s get target auth error: %v", route.RouteUUID, err)
-			} else {
-				log.Printf("proxy/auth: route=%s no target auth, forwarding Authorization as-is", route.RouteUUID)
+		targetAuth, err := repoCall(r.Context(), "GetTargetAuthenticationWithPlainToken", func() (*schema.Authentication, error) {
+			auth, ok, err := s.repo.GetTargetAuthenticationWithPlainToken(route.RouteUUID)
+			if err != nil || !ok {
+				return nil, err
 			}
+			return &auth, nil
+		})
+		switch {
+		case targetAuth != nil:
+			logger.DebugContext(r.Context(), "target auth enabled", "route", route.RouteUUID, "auth_name", targetAuth.Name, "token_type", targetAuth.TokenType)
+		case err != nil:
+			logger.ErrorContext(r.Context(), "get target auth failed", "route", route.RouteUUID, "error", err)
+		default:
+			logger.DebugContext(r.Context(), "no target auth, forwarding Authorization as-is", "route", route.RouteUUID)
 		}
+		info.targetServerUUID = targetServerUUID
 		targetURL := buildTargetURL(&target, &route, r.URL.RawQuery)
-		proxy := httputil.NewSingleHostReverseProxy(targetURL)
-		proxy.Director = director(targetURL, r, targetAuth)
-
-		start := time.Now()
-		var rec *responseRecorder
-		if s.recorder != nil {
-			rec = &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK, start: start}
-			w = rec
+		fwd := forwarding{clientIP: info.clientIP, peerTrusted: peerTrusted(r, &acl), preserveHost: target.PreserveHost, opts: &opts}
+		// Rewrite (not Director): ReverseProxy then leaves the forwarding headers to director instead of
+		// appending RemoteAddr to X-Forwarded-For itself.
+		rewrite := director(targetURL, r, fwd, targetAuth)
+		proxy := &httputil.ReverseProxy{Rewrite: func(pr *httputil.ProxyRequest) { rewrite(pr.Out) }}
+		proxy.ModifyResponse = func(resp *http.Response) error {
+			s.health.success(targetServerUUID)
+			resp.Header.Del(idHeader) // already set on the response by withRequestID
+			s.replaceUpstreamError(resp, r, sourceServerUUID)
+			return nil
 		}
-		proxy.ServeHTTP(w, r)
-
-		if s.recorder != nil && rec != nil {
-			dur := time.Since(rec.start).Milliseconds()
-			stat := schema.ProxyStat{
-				Timestamp:        start,
-				SourceServerUUID: sourceServerUUID,
-				RouteUUID:        route.RouteUUID,
-				TargetServerUUID: route.TargetServerUUID,
-				Method:           r.Method,
-				Path:             r.URL.Path,
-				StatusCode:       intPtr(rec.statusCode),
-				DurationMs:       int64Ptr(dur),
-				ClientIP:         clientIPString(r, &acl),
+		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			if !errors.Is(err, context.Canceled) {
+				s.health.failure(targetServerUUID)
+			}
+			tracing.SpanFromContext(r.Context()).RecordError(err)
+			observeUpstreamError(sourceServerUUID, info, err)
+			logger.WarnContext(r.Context(), "upstream error", "upstream", targetURL.Host, "error", err)
+			kind := transportErrorKind(err)
+			info.outcome = schema.OutcomeUpstreamError
+			if kind == schema.ErrorKindGatewayTimeout {
+				info.outcome = schema.OutcomeTimeout
 			}
-			s.recorder.Record(stat)
+			s.writeError(w, r, sourceServerUUID, kind)
+		}
+
+		upCtx, upSpan := tracing.Start(r.Context(), "upstream "+r.Method, tracing.KindClient)
+		upSpan.SetAttr("server.address", targetURL.Host)
+		upSpan.SetAttr("url.full", targetURL.Scheme+"://"+targetURL.Host+targetURL.Path)
+		upSpan.SetAttr("featherproxy.target_server_uuid", targetServerUUID.String())
+		if variant != "" {
+			upSpan.SetAttr("featherproxy.variant", variant)
 		}
+		proxy.ServeHTTP(w, r.WithContext(sw.upstream.withUpstreamTrace(upCtx)))
+		upSpan.SetAttr("http.response.status_code", sw.statusCode)
+		upSpan.End()
 	})
 }
 
+// recordRequest sends the stat for a finished request, whatever its outcome, to the recorder, if one is configured.
+func (s *Service) recordRequest(rec *responseRecorder, r *http.Request, sourceServerUUID uuid.UUID, info *requestInfo) {
+	if s.recorder == nil {
+		return
+	}
+	dur := time.Since(rec.start).Milliseconds()
+	stat := schema.ProxyStat{
+		Timestamp:        rec.start,
+		SourceServerUUID: sourceServerUUID,
+		RouteUUID:        info.routeUUID,
+		TargetServerUUID: info.targetServerUUID,
+		Method:           r.Method,
+		Path:             r.URL.Path,
+		StatusCode:       intPtr(rec.statusCode),
+		DurationMs:       int64Ptr(dur),
+		ClientIP:         info.clientIP,
+		Variant:          info.variant,
+		Outcome:          info.outcome,
+		RequestID:        requestIDFrom(r.Context()),
+		RequestBytes:     int64Ptr(requestBytes(r, info.requestBody)),
+		ResponseBytes:    int64Ptr(rec.bytes),
+		Proto:            r.Proto,
+		TLSVersion:       tlsVersionName(r),
+		UserAgent:        userAgent(r),
+		Query:            r.URL.RawQuery,
+		ACLRule:          info.aclRule,
+	}
+	addr, ttfb, gotResponse := rec.upstream.result()
+	stat.UpstreamAddr = addr
+	if gotResponse {
+		stat.TTFBMs = int64Ptr(ttfb.Milliseconds())
+	}
+	if info.authenticationUUID != uuid.Nil {
+		id := info.authenticationUUID
+		stat.AuthenticationUUID = &id
+	}
+	s.recorder.Record(stat)
+}
+
 func intPtr(n int) *int       { return &n }
 func int64Ptr(n int64) *int64 { return &n }
 
-// director returns a Director that rewrites the outgoing request to the backend URL and sets forwarding headers.
-// If targetAuth is set, the outgoing Authorization header is set to that credential (e.g. Bearer token) and the
+// director returns a function that rewrites the outgoing request to the backend URL and sets forwarding headers
+// (see setForwardingHeaders). The Host header is the target's unless fwd.preserveHost. If targetAuth is set, the outgoing Authorization header is set to that credential (e.g. Bearer token) and the
 // incoming Authorization is not forwarded, so the backend sees only the configured credential.
-func director(target *url.URL, incoming *http.Request, targetAuth *schema.Authentication) func(*http.Request) {
+func director(target *url.URL, incoming *http.Request, fwd forwarding, targetAuth *schema.Authentication) func(*http.Request) {
 	return func(out *http.Request) {
 		out.URL.Scheme = target.Scheme
 		out.URL.Host = target.Host
 		out.URL.Path = target.Path
 		out.URL.RawQuery = target.RawQuery
 		out.Host = target.Host
-		// Check if incoming contains X-Forwarded-For or X-Forwarded-Proto, if not, set them.
-		if incoming.Header.Get("X-Forwarded-For") == "" {
-			out.Header.Set("X-Forwarded-For", incoming.RemoteAddr)
-		}
-		if incoming.Header.Get("X-Forwarded-Proto") == "" {
-			out.Header.Set("X-Forwarded-Proto", "https")
-		}
-		if incoming.Header.Get("X-Forwarded-Proto") == "http" {
-			out.Header.Set("X-Forwarded-Proto", "http")
+		if fwd.preserveHost {
+			out.Host = incoming.Host
 		}
+		// Continue the trace: the upstream span (in the outgoing request's context) is the parent.
+		tracing.Inject(out.Header, tracing.SpanFromContext(out.Context()).SpanContext())
+		setForwardingHeaders(out, incoming, fwd)
 		if targetAuth != nil && targetAuth.Token != "" {
 			switch targetAuth.TokenType {
 			case "bearer", "Bearer":
 				out.Header.Set("Authorization", "Bearer "+targetAuth.Token)
-				log.Printf("proxy/auth: director set Authorization Bearer (len=%d)", len(targetAuth.Token))
+				logger.DebugContext(incoming.Context(), "director set Authorization", "scheme", "Bearer", "length", len(targetAuth.Token))
 			default:
 				out.Header.Set("Authorization", targetAuth.Token)
-				log.Printf("proxy/auth: director set Authorization raw type=%s", targetAuth.TokenType)
+				logger.DebugContext(incoming.Context(), "director set Authorization", "token_type", targetAuth.TokenType)
 			}
 		} else {
 			// No target auth: forward incoming Authorization as-is
 			if v := incoming.Header.Get("Authorization"); v != "" {
 				out.Header.Set("Authorization", v)
-				log.Printf("proxy/auth: director forwarded incoming Authorization")
+				logger.DebugContext(incoming.Context(), "director forwarded incoming Authorization")
 			}
 		}
 	}
@@ -344,37 +537,40 @@ func joinPath(base, p string) string {
 }
 
 // isSourceAuthorized returns true if the incoming request is allowed by the
-// route's configured source authentications. If no source authentications are
-// configured for the route, it returns true (no auth required).
+// route's configured source authentications, along with the UUID of the
+// authentication that matched. If no source authentications are configured
+// for the route, it returns true and uuid.Nil (no auth required).
 //
 // If one or more source authentications are configured, the incoming
 // Authorization header must match at least one of the configured credentials,
 // formatted according to its TokenType (e.g. "Bearer <token>" for bearer).
-func (s *Service) isSourceAuthorized(r *http.Request, routeUUID uuid.UUID) (bool, error) {
-	list, err := s.repo.ListSourceAuthsForRoute(routeUUID)
+func (s *Service) isSourceAuthorized(r *http.Request, routeUUID uuid.UUID) (uuid.UUID, bool, error) {
+	list, err := repoCall(r.Context(), "ListSourceAuthsForRoute", func() ([]schema.RouteSourceAuth, error) { return s.repo.ListSourceAuthsForRoute(routeUUID) })
 	if err != nil {
-		return false, err
+		return uuid.Nil, false, err
 	}
 	if len(list) == 0 {
 		// No source auth configured for this route.
-		return true, nil
+		return uuid.Nil, true, nil
 	}
 	incoming := strings.TrimSpace(r.Header.Get("Authorization"))
 	if incoming == "" {
 		// Auth required but no credentials provided.
-		return false, nil
+		return uuid.Nil, false, nil
 	}
 	for _, mapping := range list {
-		auth, err := s.repo.GetAuthenticationWithPlainToken(mapping.AuthenticationUUID)
+		auth, err := repoCall(r.Context(), "GetAuthenticationWithPlainToken", func() (schema.Authentication, error) {
+			return s.repo.GetAuthenticationWithPlainToken(mapping.AuthenticationUUID)
+		})
 		if err != nil {
-			return false, err
+			return uuid.Nil, false, err
 		}
 		if expected := buildAuthHeaderValue(&auth); expected != "" && incoming == expected {
-			return true, nil
+			return mapping.AuthenticationUUID, true, nil
 		}
 	}
 	// No match found among allowed source authentications.
-	return false, nil
+	return uuid.Nil, false, nil
 }
 
 // buildAuthHeaderValue formats an Authentication as an Authorization header
@@ -2,12 +2,17 @@ package proxy
 
 import (
 	"crypto/tls"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/tracing"
+
+	"github.com/google/uuid"
 )
 
 func TestJoinHostPort(t *testing.T) {
@@ -93,24 +98,242 @@ func TestDirectorSetsForwardHeadersAndAuth(t *testing.T) {
 	out, _ := http.NewRequest(http.MethodGet, "/", nil)
 
 	// Case 1: no target auth -> incoming Authorization is forwarded.
-	d1 := director(target, incoming, nil)
+	d1 := director(target, incoming, forwarding{clientIP: "192.0.2.1"}, nil)
 	d1(out)
 	if got := out.Header.Get("Authorization"); got != "Bearer incoming" {
 		t.Fatalf("Authorization forwarded = %q, want %q", got, "Bearer incoming")
 	}
-	if got := out.Header.Get("X-Forwarded-For"); got != "192.0.2.1:1234" {
-		t.Errorf("X-Forwarded-For = %q, want %q", got, "192.0.2.1:1234")
+	if got := out.Header.Get("X-Forwarded-For"); got != "192.0.2.1" {
+		t.Errorf("X-Forwarded-For = %q, want the resolved client IP %q", got, "192.0.2.1")
 	}
 	if got := out.Header.Get("X-Forwarded-Proto"); got != "https" {
 		t.Errorf("X-Forwarded-Proto = %q, want %q", got, "https")
 	}
+	if got := out.Header.Get("X-Forwarded-Host"); got != "proxy.internal" {
+		t.Errorf("X-Forwarded-Host = %q, want %q", got, "proxy.internal")
+	}
+	if got := out.Header.Get("X-Forwarded-Port"); got != "443" {
+		t.Errorf("X-Forwarded-Port = %q, want %q", got, "443")
+	}
+	if got := out.Header.Get("Forwarded"); got != "for=192.0.2.1;host=proxy.internal;proto=https" {
+		t.Errorf("Forwarded = %q", got)
+	}
+	if out.Host != "backend.internal" {
+		t.Errorf("Host = %q, want the target's", out.Host)
+	}
 
 	// Case 2: target auth present -> override Authorization.
 	out2, _ := http.NewRequest(http.MethodGet, "/", nil)
 	targetAuth := &schema.Authentication{TokenType: "bearer", Token: "secret"}
-	d2 := director(target, incoming, targetAuth)
+	d2 := director(target, incoming, forwarding{clientIP: "192.0.2.1"}, targetAuth)
 	d2(out2)
 	if got := out2.Header.Get("Authorization"); got != "Bearer secret" {
 		t.Fatalf("Authorization with target auth = %q, want %q", got, "Bearer secret")
 	}
 }
+
+func TestSelectVariant(t *testing.T) {
+	primary := uuid.New()
+	canary := uuid.New()
+	split := &schema.TrafficSplit{
+		Sticky: true,
+		Rules: []schema.TrafficRule{
+			{Variant: "canary", TargetServerUUID: canary, Weight: 5, Header: "X-Canary", HeaderValue: "1", Cookie: "beta"},
+		},
+	}
+
+	// Header condition wins regardless of roll.
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Canary", "1")
+	if got := selectVariant(r, split, primary, 99); got.Variant != "canary" || got.TargetServerUUID != canary || got.byWeight {
+		t.Errorf("header match = %+v", got)
+	}
+
+	// Cookie condition with empty value matches any value.
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "beta", Value: "yes"})
+	if got := selectVariant(r, split, primary, 99); got.Variant != "canary" {
+		t.Errorf("cookie match = %+v", got)
+	}
+
+	// Weighted: roll below weight goes to canary, above to primary.
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	if got := selectVariant(r, split, primary, 4); got.Variant != "canary" || !got.byWeight {
+		t.Errorf("roll 4 = %+v", got)
+	}
+	if got := selectVariant(r, split, primary, 5); got.Variant != schema.PrimaryVariant || got.TargetServerUUID != primary {
+		t.Errorf("roll 5 = %+v", got)
+	}
+
+	// Sticky cookie keeps the client on its variant.
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: schema.DefaultVariantCookie, Value: "canary"})
+	if got := selectVariant(r, split, primary, 99); got.Variant != "canary" || got.byWeight {
+		t.Errorf("sticky = %+v", got)
+	}
+
+	// No split: always primary.
+	if got := selectVariant(r, nil, primary, 0); got.Variant != schema.PrimaryVariant {
+		t.Errorf("nil split = %+v", got)
+	}
+}
+
+func TestServeRouteResponse_staticTemplate(t *testing.T) {
+	route := &schema.Route{
+		Kind:       schema.RouteKindStatic,
+		SourcePath: "/users/{id}",
+		Response: &schema.RouteResponse{
+			Status:  http.StatusTeapot,
+			Headers: map[string]string{"Content-Type": "application/json", "X-User": "{{.Params.id}}"},
+			Body:    `{"id":"{{.Params.id}}","q":"{{.Query.Get "q"}}"}`,
+		},
+	}
+	r := httptest.NewRequest(http.MethodGet, "/users/42?q=hi", nil)
+	params, ok := schema.MatchPath(route.SourcePath, r.URL.Path)
+	if !ok {
+		t.Fatal("pattern did not match")
+	}
+	w := httptest.NewRecorder()
+	serveRouteResponse(w, r, route, params)
+	if w.Code != http.StatusTeapot {
+		t.Errorf("status = %d, want 418", w.Code)
+	}
+	if got := w.Header().Get("X-User"); got != "42" {
+		t.Errorf("X-User = %q, want 42", got)
+	}
+	if got := w.Body.String(); got != `{"id":"42","q":"hi"}` {
+		t.Errorf("body = %s", got)
+	}
+}
+
+func TestServeRouteResponse_escapesClientValues(t *testing.T) {
+	attack := url.QueryEscape(`<script>alert(1)</script>`)
+
+	// An HTML template is rendered with html/template.
+	route := &schema.Route{
+		Kind:     schema.RouteKindStatic,
+		Response: &schema.RouteResponse{Body: `<html><body>Hello {{.Query.Get "name"}}</body></html>`},
+	}
+	w := httptest.NewRecorder()
+	serveRouteResponse(w, httptest.NewRequest(http.MethodGet, "/?name="+attack, nil), route, nil)
+	if strings.Contains(w.Body.String(), "<script>") {
+		t.Errorf("html body not escaped: %s", w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q, want text/html", ct)
+	}
+
+	// A plain text template stays text/plain even when the rendered body looks like HTML.
+	route.Response.Body = `{{.Query.Get "name"}}`
+	w = httptest.NewRecorder()
+	serveRouteResponse(w, httptest.NewRequest(http.MethodGet, "/?name="+attack, nil), route, nil)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+	if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+	}
+
+	// The json func quotes values in JSON bodies.
+	route.Response.Headers = map[string]string{"Content-Type": "application/json"}
+	route.Response.Body = `{"name":{{json (.Query.Get "name")}}}`
+	w = httptest.NewRecorder()
+	serveRouteResponse(w, httptest.NewRequest(http.MethodGet, "/?name="+url.QueryEscape(`a","admin":true,"b":"`), nil), route, nil)
+	var got map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || len(got) != 1 {
+		t.Errorf("json body = %s (%v)", w.Body.String(), err)
+	}
+}
+
+func TestServeRouteResponse_templateError(t *testing.T) {
+	route := &schema.Route{
+		Kind:     schema.RouteKindStatic,
+		Response: &schema.RouteResponse{Body: `secret {{.Nope.Deeper}`},
+	}
+	w := httptest.NewRecorder()
+	serveRouteResponse(w, httptest.NewRequest(http.MethodGet, "/", nil), route, nil)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "secret") || strings.Contains(w.Body.String(), "{{") {
+		t.Errorf("body leaks the template: %s", w.Body.String())
+	}
+}
+
+func TestServeRouteResponse_redirect(t *testing.T) {
+	route := &schema.Route{
+		Kind:     schema.RouteKindRedirect,
+		Response: &schema.RouteResponse{Status: http.StatusMovedPermanently, RedirectURL: "https://new.example.com/items/{{.Params.id}}"},
+	}
+	r := httptest.NewRequest(http.MethodGet, "/old/7", nil)
+	w := httptest.NewRecorder()
+	serveRouteResponse(w, r, route, map[string]string{"id": "7"})
+	if w.Code != http.StatusMovedPermanently {
+		t.Errorf("status = %d, want 301", w.Code)
+	}
+	if got := w.Header().Get("Location"); got != "https://new.example.com/items/7" {
+		t.Errorf("Location = %q", got)
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		ok            bool
+		id            string
+	}{
+		{"/users/{id}", "/users/42", true, "42"},
+		{"/users/{id}", "/users/42/posts", false, ""},
+		{"/users/{id}", "/users/", false, ""},
+		{"/users/me", "/users/me", true, ""},
+		{"/users/{id}", "/users/..", false, ""},
+		{"/users/{id}", "/users/.", false, ""},
+		{"/users/{id}", "/users/..42", true, "..42"},
+	}
+	for _, tt := range tests {
+		params, ok := schema.MatchPath(tt.pattern, tt.path)
+		if ok != tt.ok || params["id"] != tt.id {
+			t.Errorf("MatchPath(%q, %q) = %v, %v", tt.pattern, tt.path, params, ok)
+		}
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	tests := []struct {
+		template string
+		params   map[string]string
+		want     string
+	}{
+		{"/internal/users/{id}/posts/{post}", map[string]string{"id": "7", "post": "9"}, "/internal/users/7/posts/9"},
+		{"/a/{x}/{y}", map[string]string{"x": "{y}", "y": "2"}, "/a/{y}/2"},
+		{"/a/{y}/{x}", map[string]string{"x": "{y}", "y": "2"}, "/a/2/{y}"},
+		{"/a/{missing}/{x", map[string]string{"x": "1"}, "/a/{missing}/{x"},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 10; i++ { // map order must not matter
+			if got := schema.ExpandPath(tt.template, tt.params); got != tt.want {
+				t.Fatalf("ExpandPath(%q, %v) = %q, want %q", tt.template, tt.params, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestDirectorPropagatesTraceContext(t *testing.T) {
+	tracer := tracing.New(tracing.Config{Sampler: tracing.SamplerAlwaysOn})
+	incoming := httptest.NewRequest(http.MethodGet, "http://proxy.local/x", nil)
+	incoming.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, _ := tracer.StartServer(incoming.Context(), "GET", incoming.Header)
+	ctx, upstream := tracing.Start(ctx, "upstream GET", tracing.KindClient)
+
+	target, _ := url.Parse("http://backend.internal/x")
+	out := incoming.Clone(ctx)
+	director(target, incoming, forwarding{clientIP: "192.0.2.1"}, nil)(out)
+
+	want := upstream.SpanContext().Traceparent()
+	if got := out.Header.Get("traceparent"); got != want {
+		t.Errorf("traceparent = %q, want %q", got, want)
+	}
+	if !strings.Contains(want, "4bf92f3577b34da6a3ce929d0e0e4736") {
+		t.Errorf("trace ID not continued: %s", want)
+	}
+}
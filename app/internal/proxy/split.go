@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"net/http"
+
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+// variantChoice is the result of evaluating a route's traffic split for one request.
+type variantChoice struct {
+	Variant          string
+	TargetServerUUID uuid.UUID
+	byWeight         bool // picked by weight (not by a header/cookie condition or sticky cookie)
+}
+
+// selectVariant evaluates split for r and returns the variant and target to use.
+// Order of precedence:
+//  1. the first rule whose header or cookie condition matches
+//  2. the sticky cookie, if Sticky is set and it names a known variant
+//  3. weighted pick using roll (0-99); the share not covered by rule weights goes to primary
+func selectVariant(r *http.Request, split *schema.TrafficSplit, primary uuid.UUID, roll int) variantChoice {
+	primaryChoice := variantChoice{Variant: schema.PrimaryVariant, TargetServerUUID: primary}
+	if split == nil || len(split.Rules) == 0 {
+		return primaryChoice
+	}
+	for _, rule := range split.Rules {
+		if ruleConditionMatches(r, &rule) {
+			return variantChoice{Variant: rule.Variant, TargetServerUUID: rule.TargetServerUUID}
+		}
+	}
+	if split.Sticky {
+		if c, err := r.Cookie(stickyCookieName(split)); err == nil {
+			if c.Value == schema.PrimaryVariant {
+				return primaryChoice
+			}
+			for _, rule := range split.Rules {
+				if rule.Variant == c.Value {
+					return variantChoice{Variant: rule.Variant, TargetServerUUID: rule.TargetServerUUID}
+				}
+			}
+		}
+	}
+	cumulative := 0
+	for _, rule := range split.Rules {
+		cumulative += rule.Weight
+		if roll < cumulative {
+			return variantChoice{Variant: rule.Variant, TargetServerUUID: rule.TargetServerUUID, byWeight: true}
+		}
+	}
+	primaryChoice.byWeight = true
+	return primaryChoice
+}
+
+// ruleConditionMatches reports whether the rule has a header or cookie condition and r satisfies it.
+// Rules without conditions never match here; they only take part in the weighted pick.
+func ruleConditionMatches(r *http.Request, rule *schema.TrafficRule) bool {
+	if rule.Header != "" {
+		if v := r.Header.Get(rule.Header); v != "" && (rule.HeaderValue == "" || v == rule.HeaderValue) {
+			return true
+		}
+	}
+	if rule.Cookie != "" {
+		if c, err := r.Cookie(rule.Cookie); err == nil && (rule.CookieValue == "" || c.Value == rule.CookieValue) {
+			return true
+		}
+	}
+	return false
+}
+
+func stickyCookieName(split *schema.TrafficSplit) string {
+	if split.StickyCookie != "" {
+		return split.StickyCookie
+	}
+	return schema.DefaultVariantCookie
+}
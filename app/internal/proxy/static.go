@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sync"
+	"text/template"
+	"time"
+
+	"FeatherProxy/app/internal/database/schema"
+)
+
+// responseTemplateData is the data available to static, redirect and mock response templates.
+type responseTemplateData struct {
+	Params map[string]string
+	Query  url.Values
+	Method string
+	Path   string
+	Host   string
+}
+
+// templateFuncs are available in response and error page templates in addition to the template builtins.
+var templateFuncs = template.FuncMap{
+	// json renders v as a JSON value, e.g. {"id": {{json .Params.id}}} quotes and escapes the param.
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// ValidateTemplate parses a route response or error page template, as the proxy will when serving it.
+func ValidateTemplate(text string, html bool) error {
+	_, err := parseTemplate(text, html)
+	return err
+}
+
+// serveRouteResponse answers r from the route's configured response (static, redirect and mock routes).
+// Params, query and Host come from the client, so an HTML body is rendered with html/template and every
+// body is sent with an explicit Content-Type and nosniff; a template error answers a bare 500.
+func serveRouteResponse(w http.ResponseWriter, r *http.Request, route *schema.Route, params map[string]string) {
+	resp := route.Response
+	if resp == nil {
+		logger.ErrorContext(r.Context(), "route has no response configured", "route", route.RouteUUID, "kind", route.Kind)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if params == nil {
+		params = map[string]string{}
+	}
+	data := responseTemplateData{
+		Params: params,
+		Query:  r.URL.Query(),
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Host:   r.Host,
+	}
+	if route.Kind == schema.RouteKindMock && resp.DelayMs > 0 {
+		select {
+		case <-time.After(time.Duration(resp.DelayMs) * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	for name, v := range resp.Headers {
+		value, err := renderResponseTemplate(v, false, &data)
+		if err != nil {
+			logger.WarnContext(r.Context(), "render response header failed", "route", route.RouteUUID, "header", name, "error", err)
+			continue
+		}
+		w.Header().Set(name, value)
+	}
+	status := resp.Status
+	if route.Kind == schema.RouteKindRedirect {
+		if status == 0 {
+			status = http.StatusFound
+		}
+		location, err := renderResponseTemplate(resp.RedirectURL, false, &data)
+		if err != nil {
+			logger.WarnContext(r.Context(), "render redirect URL failed", "route", route.RouteUUID, "error", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, location, status)
+		return
+	}
+	if status == 0 {
+		status = http.StatusOK
+	}
+	// The content type comes from the configured header or the template itself, never from the
+	// rendered body, which a client could make look like HTML.
+	contentType := w.Header().Get("Content-Type")
+	if contentType == "" && resp.Body != "" {
+		contentType = http.DetectContentType([]byte(resp.Body))
+		w.Header().Set("Content-Type", contentType)
+	}
+	body, err := renderResponseTemplate(resp.Body, isHTMLContentType(contentType), &data)
+	if err != nil {
+		logger.WarnContext(r.Context(), "render response body failed", "route", route.RouteUUID, "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(body))
+}
+
+// isHTMLContentType reports whether browsers render a body of this content type as markup.
+func isHTMLContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "text/html", "application/xhtml+xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// renderResponseTemplate executes text with data, escaping values for HTML when html is set.
+func renderResponseTemplate(text string, html bool, data *responseTemplateData) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	t, err := parsedTemplates.get(text, html)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// maxParsedTemplates bounds templateCache; edited routes and error pages leave their old sources behind.
+const maxParsedTemplates = 1024
+
+// executor is a parsed text/template or html/template.
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+type templateKey struct {
+	text string
+	html bool
+}
+
+type parsedTemplate struct {
+	t   executor
+	err error
+}
+
+// templateCache keeps parsed templates by source, so each is parsed once rather than on every request.
+// Parse errors are cached too.
+type templateCache struct {
+	mu sync.Mutex
+	m  map[templateKey]parsedTemplate
+}
+
+var parsedTemplates = &templateCache{m: make(map[templateKey]parsedTemplate)}
+
+// get returns the template parsed from text, with html/template when html is set.
+func (c *templateCache) get(text string, html bool) (executor, error) {
+	key := templateKey{text: text, html: html}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.m[key]; ok {
+		return p.t, p.err
+	}
+	var p parsedTemplate
+	p.t, p.err = parseTemplate(text, html)
+	if len(c.m) >= maxParsedTemplates {
+		clear(c.m)
+	}
+	c.m[key] = p
+	return p.t, p.err
+}
+
+// parseTemplate parses text with html/template when html is set, else with text/template.
+func parseTemplate(text string, html bool) (executor, error) {
+	if html {
+		return htmltemplate.New("template").Funcs(htmltemplate.FuncMap(templateFuncs)).Option("missingkey=zero").Parse(text)
+	}
+	return template.New("template").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+}
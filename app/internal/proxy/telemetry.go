@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxUserAgentLen bounds the user agent stored with a request's stat.
+const maxUserAgentLen = 512
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
+// countRequestBody wraps r.Body so the bytes read from it can be recorded. Returns nil when there is no body.
+func countRequestBody(r *http.Request) *countingBody {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	b := &countingBody{ReadCloser: r.Body}
+	r.Body = b
+	return b
+}
+
+// requestBytes returns the request body size: the bytes the proxy read, or the declared Content-Length
+// when the body was not read (static and mock routes, rejected requests).
+func requestBytes(r *http.Request, body *countingBody) int64 {
+	var n int64
+	if body != nil {
+		n = body.n.Load()
+	}
+	if n == 0 && r.ContentLength > 0 {
+		n = r.ContentLength
+	}
+	return n
+}
+
+// upstreamTrace records the upstream connection address and time to first byte of one proxied request.
+// Its hooks run on transport goroutines, hence the lock.
+type upstreamTrace struct {
+	mu    sync.Mutex
+	start time.Time
+	addr  string
+	ttfb  time.Duration
+	done  bool // first byte received
+}
+
+// withUpstreamTrace returns ctx with an httptrace hook filling t; use it for the outgoing request.
+func (t *upstreamTrace) withUpstreamTrace(ctx context.Context) context.Context {
+	t.mu.Lock()
+	t.start = time.Now()
+	t.mu.Unlock()
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Conn == nil {
+				return
+			}
+			t.mu.Lock()
+			t.addr = info.Conn.RemoteAddr().String()
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			if !t.done {
+				t.ttfb = time.Since(t.start)
+				t.done = true
+			}
+			t.mu.Unlock()
+		},
+	})
+}
+
+// result returns the upstream address and TTFB; ok is false when no response byte arrived.
+func (t *upstreamTrace) result() (addr string, ttfb time.Duration, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.addr, t.ttfb, t.done
+}
+
+// tlsVersionName returns the client's TLS version ("TLS 1.3"), or "" for plain HTTP.
+func tlsVersionName(r *http.Request) string {
+	if r.TLS == nil {
+		return ""
+	}
+	return tls.VersionName(r.TLS.Version)
+}
+
+// userAgent returns the request's User-Agent, truncated to maxUserAgentLen.
+func userAgent(r *http.Request) string {
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLen {
+		ua = ua[:maxUserAgentLen]
+	}
+	return ua
+}
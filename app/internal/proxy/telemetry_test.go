@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpstreamTrace(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer backend.Close()
+
+	var tr upstreamTrace
+	if _, _, ok := tr.result(); ok {
+		t.Fatal("result before any request should not be ok")
+	}
+	req, _ := http.NewRequestWithContext(tr.withUpstreamTrace(t.Context()), http.MethodGet, backend.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	addr, ttfb, ok := tr.result()
+	if !ok || addr != strings.TrimPrefix(backend.URL, "http://") {
+		t.Errorf("addr = %q ok = %v, want %s", addr, ok, backend.URL)
+	}
+	if ttfb < 20*time.Millisecond {
+		t.Errorf("ttfb = %v, want >= 20ms", ttfb)
+	}
+}
+
+func TestRequestBytes(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello world"))
+	r.ContentLength = -1 // chunked: only the bytes read count
+	body := countRequestBody(r)
+	if got := requestBytes(r, body); got != 0 {
+		t.Errorf("before read = %d, want 0", got)
+	}
+	_, _ = io.ReadAll(r.Body)
+	if got := requestBytes(r, body); got != 11 {
+		t.Errorf("after read = %d, want 11", got)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("abc"))
+	if got := requestBytes(r, countRequestBody(r)); got != 3 {
+		t.Errorf("unread body with Content-Length = %d, want 3", got)
+	}
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	if body := countRequestBody(r); body != nil || requestBytes(r, body) != 0 {
+		t.Errorf("no body: %v", body)
+	}
+}
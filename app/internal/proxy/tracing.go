@@ -0,0 +1,28 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+
+	"FeatherProxy/app/internal/tracing"
+
+	"gorm.io/gorm"
+)
+
+// repoCall runs a repository call as a child span of the span in ctx, so slow cache or DB lookups
+// show up in the request's trace. A not-found result is an attribute, not an error.
+func repoCall[T any](ctx context.Context, method string, fn func() (T, error)) (T, error) {
+	_, span := tracing.Start(ctx, "repository."+method, tracing.KindInternal)
+	v, err := fn()
+	endRepoSpan(span, err)
+	return v, err
+}
+
+func endRepoSpan(span *tracing.Span, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		span.SetAttr("db.not_found", true)
+	} else {
+		span.RecordError(err)
+	}
+	span.End()
+}
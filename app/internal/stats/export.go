@@ -0,0 +1,121 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+// Export formats.
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+)
+
+// ExportContentTypes maps an export format to its MIME type.
+var ExportContentTypes = map[string]string{
+	ExportCSV:    "text/csv; charset=utf-8",
+	ExportNDJSON: "application/x-ndjson",
+}
+
+// exportColumns is the CSV header; columns follow the ProxyStat JSON names.
+var exportColumns = []string{
+	"id", "timestamp", "source_server_uuid", "route_uuid", "target_server_uuid", "method", "path",
+	"status_code", "duration_ms", "client_ip", "variant", "request_id", "outcome", "request_bytes",
+	"response_bytes", "ttfb_ms", "upstream_addr", "proto", "tls_version", "user_agent", "query",
+	"authentication_uuid", "sample_weight", "country", "region", "asn", "as_org", "acl_rule",
+}
+
+// ExportWriter writes stats one at a time in an export format. Call Close to flush.
+type ExportWriter interface {
+	Write(stat schema.ProxyStat) error
+	Close() error
+}
+
+// NewExportWriter returns a writer for format (ExportCSV or ExportNDJSON). CSV output starts with a header row.
+func NewExportWriter(w io.Writer, format string) (ExportWriter, error) {
+	switch format {
+	case ExportCSV:
+		return &csvExport{w: csv.NewWriter(w)}, nil
+	case ExportNDJSON:
+		bw := bufio.NewWriter(w)
+		return &ndjsonExport{bw: bw, enc: json.NewEncoder(bw)}, nil
+	}
+	return nil, errors.New("stats: export format must be csv or ndjson")
+}
+
+type csvExport struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (e *csvExport) Write(s schema.ProxyStat) error {
+	if !e.wroteHeader {
+		e.wroteHeader = true
+		if err := e.w.Write(exportColumns); err != nil {
+			return err
+		}
+	}
+	return e.w.Write([]string{
+		s.ID.String(), s.Timestamp.UTC().Format(time.RFC3339Nano), s.SourceServerUUID.String(), s.RouteUUID.String(),
+		s.TargetServerUUID.String(), s.Method, s.Path, optInt(s.StatusCode), optInt64(s.DurationMs), s.ClientIP,
+		s.Variant, s.RequestID, s.Outcome, optInt64(s.RequestBytes), optInt64(s.ResponseBytes), optInt64(s.TTFBMs),
+		s.UpstreamAddr, s.Proto, s.TLSVersion, s.UserAgent, s.Query, optUUID(s.AuthenticationUUID),
+		strconv.FormatInt(s.Weight(), 10), s.Country, s.Region, optASN(s.ASN), s.ASOrg, s.ACLRule,
+	})
+}
+
+func (e *csvExport) Close() error {
+	if !e.wroteHeader {
+		e.wroteHeader = true
+		_ = e.w.Write(exportColumns)
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonExport struct {
+	bw  *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *ndjsonExport) Write(s schema.ProxyStat) error { return e.enc.Encode(s) }
+
+func (e *ndjsonExport) Close() error { return e.bw.Flush() }
+
+func optInt(p *int) string {
+	if p == nil {
+		return ""
+	}
+	return strconv.Itoa(*p)
+}
+
+func optInt64(p *int64) string {
+	if p == nil {
+		return ""
+	}
+	return strconv.FormatInt(*p, 10)
+}
+
+// optASN formats an ASN, with 0 (not resolved) as an empty field.
+func optASN(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func optUUID(p *uuid.UUID) string {
+	if p == nil {
+		return ""
+	}
+	return p.String()
+}
@@ -0,0 +1,58 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+func TestExportWriter(t *testing.T) {
+	code, bytesOut := 200, int64(42)
+	stat := schema.ProxyStat{
+		ID: uuid.New(), Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Method: "GET", Path: "/a,b",
+		StatusCode: &code, ResponseBytes: &bytesOut, Outcome: schema.OutcomeProxied,
+	}
+
+	var buf bytes.Buffer
+	w, err := NewExportWriter(&buf, ExportCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(stat); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[0] != strings.Join(exportColumns, ",") {
+		t.Fatalf("csv = %q", buf.String())
+	}
+	if !strings.Contains(lines[1], `2026-01-02T03:04:05Z`) || !strings.Contains(lines[1], `"/a,b",200,,`) ||
+		!strings.Contains(lines[1], ",proxied,,42,") {
+		t.Errorf("csv row = %q", lines[1])
+	}
+
+	buf.Reset()
+	w, _ = NewExportWriter(&buf, ExportNDJSON)
+	_ = w.Write(stat)
+	_ = w.Write(stat)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var got schema.ProxyStat
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &got) != nil || got.ID != stat.ID {
+		t.Errorf("ndjson = %q", buf.String())
+	}
+
+	if _, err := NewExportWriter(&buf, "xml"); err == nil {
+		t.Error("NewExportWriter(xml): want error")
+	}
+}
@@ -0,0 +1,160 @@
+package stats
+
+import (
+	"errors"
+	"sync"
+
+	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/metrics"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultLiveSubscribers = 16
+	defaultLiveBuffer      = 256
+)
+
+// ErrTooManySubscribers is returned by Hub.Subscribe when the subscriber limit is reached.
+var ErrTooManySubscribers = errors.New("stats: too many live subscribers")
+
+// ErrHubClosed is returned by Hub.Subscribe after the hub is closed.
+var ErrHubClosed = errors.New("stats: live feed closed")
+
+// LiveFilter selects the stats a live subscriber receives. Zero fields match everything.
+type LiveFilter struct {
+	SourceServerUUID *uuid.UUID
+	RouteUUID        *uuid.UUID
+	Status           int // exact status code
+	StatusClass      int // 1..5 for 1xx..5xx
+	ClientIP         string
+	Outcome          string
+}
+
+// Match reports whether stat passes the filter.
+func (f LiveFilter) Match(stat schema.ProxyStat) bool {
+	if f.SourceServerUUID != nil && stat.SourceServerUUID != *f.SourceServerUUID {
+		return false
+	}
+	if f.RouteUUID != nil && stat.RouteUUID != *f.RouteUUID {
+		return false
+	}
+	if f.Status != 0 && (stat.StatusCode == nil || *stat.StatusCode != f.Status) {
+		return false
+	}
+	if f.StatusClass != 0 && (stat.StatusCode == nil || *stat.StatusCode/100 != f.StatusClass) {
+		return false
+	}
+	if f.ClientIP != "" && stat.ClientIP != f.ClientIP {
+		return false
+	}
+	if f.Outcome != "" && stat.Outcome != f.Outcome {
+		return false
+	}
+	return true
+}
+
+// Hub fans recorded stats out to live subscribers (e.g. the admin live tail). Publishing never blocks:
+// a subscriber whose buffer is full misses the stat, and the miss is counted on its Subscription.
+type Hub struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	max    int
+	closed bool
+}
+
+// NewHub returns a hub accepting up to maxSubscribers subscribers (defaultLiveSubscribers when <= 0).
+func NewHub(maxSubscribers int) *Hub {
+	if maxSubscribers <= 0 {
+		maxSubscribers = defaultLiveSubscribers
+	}
+	return &Hub{subs: make(map[*Subscription]struct{}), max: maxSubscribers}
+}
+
+// Subscription receives the stats matching its filter on C until Close is called or the hub is closed,
+// which closes C.
+type Subscription struct {
+	C <-chan schema.ProxyStat
+
+	ch      chan schema.ProxyStat
+	filter  LiveFilter
+	hub     *Hub
+	mu      sync.Mutex
+	dropped int64
+}
+
+// Subscribe registers a subscriber with a buffer of the given size (defaultLiveBuffer when <= 0).
+func (h *Hub) Subscribe(filter LiveFilter, buffer int) (*Subscription, error) {
+	if buffer <= 0 {
+		buffer = defaultLiveBuffer
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrHubClosed
+	}
+	if len(h.subs) >= h.max {
+		return nil, ErrTooManySubscribers
+	}
+	ch := make(chan schema.ProxyStat, buffer)
+	sub := &Subscription{C: ch, ch: ch, filter: filter, hub: h}
+	h.subs[sub] = struct{}{}
+	metrics.StatsLiveSubscribers.With().Set(float64(len(h.subs)))
+	return sub, nil
+}
+
+// Publish sends stat to every subscriber whose filter matches it, without blocking.
+func (h *Hub) Publish(stat schema.ProxyStat) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subs {
+		if !sub.filter.Match(stat) {
+			continue
+		}
+		select {
+		case sub.ch <- stat:
+		default:
+			sub.mu.Lock()
+			sub.dropped++
+			sub.mu.Unlock()
+			metrics.StatsLiveDropped.With().Inc()
+		}
+	}
+}
+
+// Close closes every subscription and rejects new ones.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for sub := range h.subs {
+		close(sub.ch)
+		delete(h.subs, sub)
+	}
+	metrics.StatsLiveSubscribers.With().Set(0)
+}
+
+// Close unsubscribes and closes C. Safe to call more than once and after the hub is closed.
+func (s *Subscription) Close() {
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+	delete(h.subs, s)
+	close(s.ch)
+	metrics.StatsLiveSubscribers.With().Set(float64(len(h.subs)))
+}
+
+// TakeDropped returns how many stats were missed because the buffer was full since the last call.
+func (s *Subscription) TakeDropped() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.dropped
+	s.dropped = 0
+	return n
+}
@@ -0,0 +1,54 @@
+package stats
+
+import (
+	"testing"
+
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+func TestHub_filterDropAndClose(t *testing.T) {
+	h := NewHub(2)
+	route := uuid.New()
+	ok, fail := 200, 502
+	errs, err := h.Subscribe(LiveFilter{RouteUUID: &route, StatusClass: 5}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := h.Subscribe(LiveFilter{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Subscribe(LiveFilter{}, 1); err != ErrTooManySubscribers {
+		t.Fatalf("third subscribe: err = %v, want ErrTooManySubscribers", err)
+	}
+
+	h.Publish(schema.ProxyStat{RouteUUID: route, StatusCode: &ok})
+	h.Publish(schema.ProxyStat{RouteUUID: route, StatusCode: &fail, Path: "/first"})
+	h.Publish(schema.ProxyStat{RouteUUID: route, StatusCode: &fail, Path: "/second"}) // buffer of 1 is full
+	h.Publish(schema.ProxyStat{RouteUUID: uuid.New(), StatusCode: &fail})
+
+	if got := <-errs.C; got.Path != "/first" {
+		t.Errorf("filtered subscriber got %q, want /first", got.Path)
+	}
+	if n := errs.TakeDropped(); n != 1 {
+		t.Errorf("dropped = %d, want 1", n)
+	}
+	if len(all.C) != 4 {
+		t.Errorf("unfiltered subscriber has %d stats, want 4", len(all.C))
+	}
+
+	errs.Close()
+	errs.Close()
+	if _, err := h.Subscribe(LiveFilter{}, 1); err != nil {
+		t.Errorf("subscribe after unsubscribe: %v", err)
+	}
+	h.Close()
+	for range all.C {
+	}
+	if _, err := h.Subscribe(LiveFilter{}, 1); err != ErrHubClosed {
+		t.Errorf("subscribe after close: err = %v, want ErrHubClosed", err)
+	}
+	h.Publish(schema.ProxyStat{}) // no subscribers left; must not panic
+}
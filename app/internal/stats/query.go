@@ -0,0 +1,51 @@
+package stats
+
+import (
+	"net/url"
+	"strings"
+
+	"FeatherProxy/app/internal/logging"
+)
+
+// Query recording modes (STATS_QUERY).
+const (
+	// QueryRedact stores the query string with the values of secret parameters replaced (default).
+	QueryRedact = "redact"
+	// QueryFull stores the query string as received.
+	QueryFull = "full"
+	// QueryOff does not store query strings.
+	QueryOff = "off"
+)
+
+// redactedValue replaces the value of a redacted query parameter.
+const redactedValue = "REDACTED"
+
+// maxQueryLen bounds the stored query string; longer ones are truncated.
+const maxQueryLen = 2048
+
+// redactQuery applies mode to a raw query string. In QueryRedact mode, parameters whose name is a secret
+// (token, password, api_key, *_secret, …; see logging.IsSecretKey) or listed in extra (lower-case) keep
+// their name but lose their value. Order and encoding of the other parameters are preserved.
+func redactQuery(raw, mode string, extra map[string]bool) string {
+	switch mode {
+	case QueryOff:
+		return ""
+	case QueryFull:
+	default:
+		parts := strings.Split(raw, "&")
+		for i, part := range parts {
+			name, _, hasValue := strings.Cut(part, "=")
+			if !hasValue {
+				continue
+			}
+			if key, err := url.QueryUnescape(name); err == nil && (logging.IsSecretKey(key) || extra[strings.ToLower(key)]) {
+				parts[i] = name + "=" + redactedValue
+			}
+		}
+		raw = strings.Join(parts, "&")
+	}
+	if len(raw) > maxQueryLen {
+		raw = raw[:maxQueryLen]
+	}
+	return raw
+}
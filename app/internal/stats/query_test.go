@@ -0,0 +1,21 @@
+package stats
+
+import "testing"
+
+func TestRedactQuery(t *testing.T) {
+	extra := map[string]bool{"session": true}
+	tests := []struct {
+		raw, mode, want string
+	}{
+		{"q=shoes&page=2", QueryRedact, "q=shoes&page=2"},
+		{"q=x&access_token=abc&API_KEY=k&session=s", QueryRedact, "q=x&access_token=REDACTED&API_KEY=REDACTED&session=REDACTED"},
+		{"pass%77ord=hunter2&flag", QueryRedact, "pass%77ord=REDACTED&flag"},
+		{"token=abc", QueryFull, "token=abc"},
+		{"token=abc", QueryOff, ""},
+	}
+	for _, tt := range tests {
+		if got := redactQuery(tt.raw, tt.mode, extra); got != tt.want {
+			t.Errorf("redactQuery(%q, %s) = %q, want %q", tt.raw, tt.mode, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package stats
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"FeatherProxy/app/internal/database/schema"
+)
+
+// sampler thins stats out once the record rate passes threshold stats per second; below it every stat is
+// kept. Above it, a successful request (proxied or served, status below 500) is kept with probability 1/k
+// and carries SampleWeight k, where k is the rate divided by threshold, rounded up. The rate is the larger
+// of the previous and the current second's count, so k follows a spike within the second it starts.
+// Weighted aggregates therefore estimate the true counts, while errors, denials and 5xx are always kept
+// with weight 1 and stay exact.
+type sampler struct {
+	threshold int64
+	intN      func(int64) int64 // random source: a value in [0, n)
+
+	mu        sync.Mutex
+	second    int64 // Unix second being counted
+	cur, prev int64 // stats recorded in second and in the second before
+}
+
+func newSampler(threshold int64) *sampler {
+	return &sampler{threshold: threshold, intN: rand.Int64N}
+}
+
+// keep counts the stat and reports whether to record it, setting its SampleWeight when sampled.
+func (s *sampler) keep(stat *schema.ProxyStat, now time.Time) bool {
+	sec := now.Unix()
+	s.mu.Lock()
+	if sec != s.second {
+		if sec == s.second+1 {
+			s.prev = s.cur
+		} else {
+			s.prev = 0
+		}
+		s.second, s.cur = sec, 0
+	}
+	s.cur++
+	rate := max(s.prev, s.cur)
+	s.mu.Unlock()
+
+	if rate <= s.threshold || !sampleable(stat) {
+		return true
+	}
+	k := (rate + s.threshold - 1) / s.threshold
+	if s.intN(k) != 0 {
+		return false
+	}
+	stat.SampleWeight = k
+	return true
+}
+
+// sampleable reports whether the stat is a success that sampling may skip.
+func sampleable(stat *schema.ProxyStat) bool {
+	if stat.Outcome != schema.OutcomeProxied && stat.Outcome != schema.OutcomeServed {
+		return false
+	}
+	return stat.StatusCode == nil || *stat.StatusCode < 500
+}
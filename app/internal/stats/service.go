@@ -2,22 +2,37 @@ package stats
 
 import (
 	"context"
-	"log"
+	"net"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"FeatherProxy/app/internal/database"
 	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/geoip"
+	"FeatherProxy/app/internal/logging"
+	"FeatherProxy/app/internal/metrics"
+
+	"github.com/google/uuid"
 )
 
+var logger = logging.For(logging.Stats)
+
 const (
-	defaultBatchSize      = 50
-	defaultFlushInterval  = 5 * time.Second
-	defaultChannelCap     = 1000
-	defaultRetentionDays  = 30
-	defaultVacuumInterval = 24 * time.Hour
+	defaultBatchSize       = 50
+	defaultFlushInterval   = 5 * time.Second
+	defaultChannelCap      = 1000
+	defaultRetentionDays   = 30
+	defaultVacuumInterval  = 24 * time.Hour
+	defaultRollupInterval  = time.Minute
+	defaultMinuteRetention = 90  // days
+	defaultHourRetention   = 730 // days
+	// rollupGrace is added to the flush interval before a minute is rolled up, so stats still batched in
+	// the worker land in proxy_stats first.
+	rollupGrace = 30 * time.Second
 )
 
 // Config holds stats service configuration (from env or defaults).
@@ -27,6 +42,31 @@ type Config struct {
 	ChannelCap     int
 	RetentionDays  int
 	VacuumInterval time.Duration // how often to run vacuum (delete stats older than retention)
+	QueryMode      string        // QueryRedact (default), QueryFull or QueryOff
+	RedactParams   []string      // extra query parameter names to redact in QueryRedact mode
+
+	DisableRollups      bool          // skip the minute/hour rollup job
+	RollupInterval      time.Duration // how often to roll up new stats
+	MinuteRetentionDays int           // retention of per-minute rollups
+	HourRetentionDays   int           // retention of per-hour rollups
+
+	LiveMaxSubscribers int // concurrent clients of the live feed
+
+	// Sinks lists where recorded stats are written (Sink* names); SinkDB when empty. Each sink has its own
+	// queue of ChannelCap stats, batched by BatchSize and FlushInterval.
+	Sinks      []string
+	FileSink   FileSinkConfig
+	StatsDSink StatsDSinkConfig
+	HTTPSink   HTTPSinkConfig
+
+	// SpillDir enables a disk spill queue per sink (in SpillDir/<sink>) for stats that overflow the sink's
+	// queue or fail to write; they are replayed once the sink accepts writes again. Empty: drop them.
+	SpillDir      string
+	SpillMaxBytes int64 // per sink; stats beyond it are dropped
+
+	// SampleThreshold is the record rate (stats per second) above which successful requests are sampled
+	// and stored with a weight (see sampler). 0 disables sampling.
+	SampleThreshold int64
 }
 
 // ConfigFromEnv returns config from environment (STATS_BATCH_SIZE, STATS_FLUSH_INTERVAL, etc.).
@@ -36,6 +76,7 @@ func ConfigFromEnv() Config {
 		FlushInterval: defaultFlushInterval,
 		ChannelCap:    defaultChannelCap,
 		RetentionDays: defaultRetentionDays,
+		QueryMode:     QueryRedact,
 	}
 	if v := os.Getenv("STATS_BATCH_SIZE"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 {
@@ -62,16 +103,106 @@ func ConfigFromEnv() Config {
 			c.VacuumInterval = d
 		}
 	}
+	switch v := strings.ToLower(strings.TrimSpace(os.Getenv("STATS_QUERY"))); v {
+	case QueryFull, QueryOff, QueryRedact:
+		c.QueryMode = v
+	}
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("STATS_ROLLUPS"))) {
+	case "off", "false", "0":
+		c.DisableRollups = true
+	}
+	if v := os.Getenv("STATS_ROLLUP_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			c.RollupInterval = d
+		}
+	}
+	if v := os.Getenv("STATS_ROLLUP_MINUTE_RETENTION_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			c.MinuteRetentionDays = n
+		}
+	}
+	if v := os.Getenv("STATS_ROLLUP_HOUR_RETENTION_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			c.HourRetentionDays = n
+		}
+	}
+	if v := os.Getenv("STATS_LIVE_MAX_SUBSCRIBERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			c.LiveMaxSubscribers = n
+		}
+	}
+	if v := os.Getenv("STATS_SINKS"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				c.Sinks = append(c.Sinks, name)
+			}
+		}
+	}
+	c.FileSink.Path = os.Getenv("STATS_SINK_FILE_PATH")
+	if v := os.Getenv("STATS_SINK_FILE_MAX_SIZE_MB"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			c.FileSink.MaxBytes = int64(n) << 20
+		}
+	}
+	if v := os.Getenv("STATS_SINK_FILE_MAX_BACKUPS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			c.FileSink.MaxBackups = n
+		}
+	}
+	if v := os.Getenv("STATS_SINK_FILE_MAX_AGE_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			c.FileSink.MaxAge = time.Duration(n) * 24 * time.Hour
+		}
+	}
+	c.StatsDSink.Addr = os.Getenv("STATS_SINK_STATSD_ADDR")
+	c.StatsDSink.Prefix = os.Getenv("STATS_SINK_STATSD_PREFIX")
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("STATS_SINK_STATSD_TAGS"))) {
+	case "dogstatsd", "on", "true", "1":
+		c.StatsDSink.DogStatsD = true
+	}
+	c.HTTPSink.URL = os.Getenv("STATS_SINK_HTTP_URL")
+	c.HTTPSink.Authorization = os.Getenv("STATS_SINK_HTTP_AUTHORIZATION")
+	if v := os.Getenv("STATS_SINK_HTTP_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			c.HTTPSink.Timeout = d
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("STATS_SINK_HTTP_GZIP"))) {
+	case "on", "true", "1":
+		c.HTTPSink.Gzip = true
+	}
+	c.SpillDir = os.Getenv("STATS_SPILL_DIR")
+	if v := os.Getenv("STATS_SPILL_MAX_MB"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			c.SpillMaxBytes = int64(n) << 20
+		}
+	}
+	if v := os.Getenv("STATS_SAMPLE_THRESHOLD"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			c.SampleThreshold = n
+		}
+	}
+	if v := os.Getenv("STATS_REDACT_QUERY_PARAMS"); v != "" {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				c.RedactParams = append(c.RedactParams, p)
+			}
+		}
+	}
 	return c
 }
 
-// Service runs the async stats worker (batch insert) and periodic vacuum.
-// It implements Recorder: Record() sends to a channel and does not block.
+// Service runs one async worker per sink (batched writes), the rollup job and periodic vacuum.
+// It implements Recorder: Record() sends to the sinks' queues and does not block.
 type Service struct {
-	repo   database.Repository
-	config Config
-	ch     chan schema.ProxyStat
-	wg     sync.WaitGroup
+	repo    database.Repository
+	config  Config
+	workers []*sinkWorker
+	wg      sync.WaitGroup
+	redact  map[string]bool // lower-cased Config.RedactParams
+	live    *Hub
+	sampler *sampler        // nil when sampling is off
+	geo     *geoip.Resolver // nil when GeoIP is off
 }
 
 // NewService creates a stats service that will use the given repository for persistence.
@@ -91,43 +222,126 @@ func NewService(repo database.Repository, config Config) *Service {
 	if config.VacuumInterval <= 0 {
 		config.VacuumInterval = defaultVacuumInterval
 	}
-	return &Service{
+	if config.RollupInterval <= 0 {
+		config.RollupInterval = defaultRollupInterval
+	}
+	if config.MinuteRetentionDays <= 0 {
+		config.MinuteRetentionDays = defaultMinuteRetention
+	}
+	if config.HourRetentionDays <= 0 {
+		config.HourRetentionDays = defaultHourRetention
+	}
+	redact := make(map[string]bool, len(config.RedactParams))
+	for _, p := range config.RedactParams {
+		redact[strings.ToLower(p)] = true
+	}
+	s := &Service{
 		repo:   repo,
 		config: config,
-		ch:     make(chan schema.ProxyStat, config.ChannelCap),
+		redact: redact,
+		live:   NewHub(config.LiveMaxSubscribers),
+	}
+	if config.SampleThreshold > 0 {
+		s.sampler = newSampler(config.SampleThreshold)
 	}
+	for _, sink := range newSinks(repo, config) {
+		var spill *spillQueue
+		if config.SpillDir != "" {
+			var err error
+			if spill, err = openSpillQueue(filepath.Join(config.SpillDir, sink.Name()), config.SpillMaxBytes); err != nil {
+				logger.Error("spill queue disabled", "sink", sink.Name(), "error", err)
+			}
+		}
+		s.workers = append(s.workers, newSinkWorker(sink, config.ChannelCap, config.BatchSize, config.FlushInterval, spill))
+	}
+	return s
+}
+
+// SetGeoIP enables enrichment of recorded stats with the client IP's country, region and ASN.
+// Call before the service records stats.
+func (s *Service) SetGeoIP(geo *geoip.Resolver) {
+	s.geo = geo
+}
+
+// Live returns the hub that receives every recorded stat as it is recorded, before the batch flush.
+// It is closed when Run returns.
+func (s *Service) Live() *Hub {
+	return s.live
 }
 
-// Record sends the stat to the worker channel. Non-blocking; drops and logs if channel full.
+// Record sends the stat to every sink's queue. Non-blocking: a sink whose queue is full spills the stat
+// to disk (Config.SpillDir) or drops it. The stat gets its ID here, so every sink (and a replay) sees the
+// same one, and the query string is redacted (or dropped) according to Config.QueryMode. With SetGeoIP,
+// the client IP's location is added. The stat is published to Live subscribers before sampling, whether
+// or not the queues have room.
 func (s *Service) Record(stat schema.ProxyStat) {
-	select {
-	case s.ch <- stat:
-	default:
-		log.Printf("stats: channel full, dropping stat for %s %s", stat.Method, stat.Path)
+	if stat.ID == uuid.Nil {
+		stat.ID = uuid.New()
+	}
+	if stat.Query != "" {
+		stat.Query = redactQuery(stat.Query, s.config.QueryMode, s.redact)
+	}
+	if s.geo != nil {
+		if ip := net.ParseIP(stat.ClientIP); ip != nil {
+			loc := s.geo.Lookup(ip)
+			stat.Country, stat.Region, stat.ASN, stat.ASOrg = loc.Country, loc.Region, loc.ASN, loc.ASOrg
+		}
+	}
+	s.live.Publish(stat)
+	if s.sampler != nil && !s.sampler.keep(&stat, time.Now()) {
+		metrics.StatsSampledOut.With().Inc()
+		return
+	}
+	for _, w := range s.workers {
+		w.enqueue(stat)
 	}
 }
 
-// Run starts the worker and vacuum loop. Blocks until ctx is cancelled.
-// On shutdown, flushes any remaining batch before returning.
+// Run starts the sink workers, rollup and vacuum loops. Blocks until ctx is cancelled.
+// On shutdown, each sink flushes its remaining batch and is closed before Run returns.
 func (s *Service) Run(ctx context.Context) {
-	// Worker: read from channel, batch, flush on N or T
-	s.wg.Add(1)
-	go func() {
-		defer s.wg.Done()
-		s.runWorker(ctx)
-	}()
+	// Workers: read from the sink's queue, batch, flush on N or T
+	for _, w := range s.workers {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			w.run(ctx)
+		}()
+	}
 
 	// Vacuum once at start, then at configured interval (e.g. STATS_VACUUM_INTERVAL=24h)
 	runVacuum := func() {
 		until := time.Now().Add(-time.Duration(s.config.RetentionDays) * 24 * time.Hour)
-		log.Printf("stats: vacuum running (delete stats older than %s, retention=%dd)", until.Format("2006-01-02"), s.config.RetentionDays)
+		logger.Info("vacuum running", "older_than", until.Format("2006-01-02"), "retention_days", s.config.RetentionDays)
 		n, err := s.repo.DeleteProxyStatsOlderThan(until)
 		if err != nil {
-			log.Printf("stats: vacuum failed: %v", err)
+			logger.Error("vacuum failed", "error", err)
 		} else {
-			log.Printf("stats: vacuum completed (removed %d records)", n)
+			logger.Info("vacuum completed", "removed", n)
+		}
+		if s.config.DisableRollups {
+			return
+		}
+		for _, tier := range []struct {
+			resolution string
+			days       int
+		}{{schema.RollupMinute, s.config.MinuteRetentionDays}, {schema.RollupHour, s.config.HourRetentionDays}} {
+			n, err := s.repo.DeleteStatsRollupsOlderThan(tier.resolution, time.Now().Add(-time.Duration(tier.days)*24*time.Hour))
+			if err != nil {
+				logger.Error("rollup vacuum failed", "resolution", tier.resolution, "error", err)
+			} else {
+				logger.Info("rollup vacuum completed", "resolution", tier.resolution, "removed", n, "retention_days", tier.days)
+			}
 		}
 	}
+	// Roll up before vacuuming, so raw stats about to expire are aggregated first.
+	var rollupC <-chan time.Time
+	if !s.config.DisableRollups {
+		s.runRollup()
+		rollupTicker := time.NewTicker(s.config.RollupInterval)
+		defer rollupTicker.Stop()
+		rollupC = rollupTicker.C
+	}
 	runVacuum()
 
 	ticker := time.NewTicker(s.config.VacuumInterval)
@@ -136,69 +350,21 @@ func (s *Service) Run(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
+			s.live.Close()
 			s.wg.Wait()
 			return
+		case <-rollupC:
+			s.runRollup()
 		case <-ticker.C:
 			runVacuum()
 		}
 	}
 }
 
-func (s *Service) runWorker(ctx context.Context) {
-	batch := make([]schema.ProxyStat, 0, s.config.BatchSize*2)
-	flushTimer := time.NewTimer(s.config.FlushInterval)
-	defer flushTimer.Stop()
-	flush := func() {
-		if len(batch) == 0 {
-			return
-		}
-		toInsert := make([]schema.ProxyStat, len(batch))
-		copy(toInsert, batch)
-		batch = batch[:0]
-		if err := s.repo.CreateProxyStats(toInsert); err != nil {
-			log.Printf("stats: batch insert failed: %v", err)
-		}
-		if !flushTimer.Stop() {
-			select {
-			case <-flushTimer.C:
-			default:
-			}
-		}
-		flushTimer.Reset(s.config.FlushInterval)
-	}
-
-	for {
-		select {
-		case stat, ok := <-s.ch:
-			if !ok {
-				flush()
-				return
-			}
-			batch = append(batch, stat)
-			if len(batch) >= s.config.BatchSize {
-				flush()
-			}
-		case <-flushTimer.C:
-			flush()
-			flushTimer.Reset(s.config.FlushInterval)
-		case <-ctx.Done():
-			// Drain channel best-effort, then flush
-			for {
-				select {
-				case stat, ok := <-s.ch:
-					if !ok {
-						flush()
-						return
-					}
-					batch = append(batch, stat)
-					if len(batch) >= s.config.BatchSize {
-						flush()
-					}
-				default:
-					flush()
-					return
-				}
-			}
-		}
+// runRollup aggregates stats older than the flush interval (plus rollupGrace) into the rollup tables.
+func (s *Service) runRollup() {
+	until := time.Now().Add(-s.config.FlushInterval - rollupGrace)
+	if err := s.repo.RollupProxyStats(until); err != nil {
+		logger.Error("rollup failed", "error", err)
 	}
 }
@@ -0,0 +1,253 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"FeatherProxy/app/internal/database"
+	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/metrics"
+)
+
+// Sink names accepted in STATS_SINKS.
+const (
+	SinkDB     = "db"     // proxy_stats table (default); the stats API, rollups and alerting read from it
+	SinkFile   = "file"   // NDJSON file with size-based rotation
+	SinkStatsD = "statsd" // StatsD / DogStatsD over UDP
+	SinkHTTP   = "http"   // batches POSTed to an HTTP endpoint
+)
+
+// Sink is a destination for recorded stats. Each configured sink gets its own queue and worker, so a slow
+// or failing sink does not delay the others (or the proxy).
+type Sink interface {
+	// Name identifies the sink in logs and metrics.
+	Name() string
+	// Write stores one batch. Calls come from a single goroutine; on error the batch goes to the spill
+	// queue (and is written again later) or is dropped. A batch may be written more than once.
+	Write(batch []schema.ProxyStat) error
+	// Close flushes and releases the sink. Called once, after the last Write.
+	Close() error
+}
+
+// dbSink writes batches to the proxy_stats table.
+type dbSink struct {
+	repo database.Repository
+}
+
+func (dbSink) Name() string { return SinkDB }
+
+func (s dbSink) Write(batch []schema.ProxyStat) error { return s.repo.CreateProxyStats(batch) }
+
+func (dbSink) Close() error { return nil }
+
+// newSinks builds the sinks named in config.Sinks (SinkDB when empty). Unknown names and sinks that fail
+// to start are logged and skipped, so one bad sink does not stop stats from reaching the others.
+func newSinks(repo database.Repository, config Config) []Sink {
+	names := config.Sinks
+	if len(names) == 0 {
+		names = []string{SinkDB}
+	}
+	var sinks []Sink
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		var sink Sink
+		var err error
+		switch name {
+		case SinkDB:
+			sink = dbSink{repo: repo}
+		case SinkFile:
+			sink, err = NewFileSink(config.FileSink)
+		case SinkStatsD:
+			sink, err = NewStatsDSink(config.StatsDSink)
+		case SinkHTTP:
+			sink, err = NewHTTPSink(config.HTTPSink)
+		default:
+			err = fmt.Errorf("unknown sink (want %s, %s, %s or %s)", SinkDB, SinkFile, SinkStatsD, SinkHTTP)
+		}
+		if err != nil {
+			logger.Error("stats sink disabled", "sink", name, "error", err)
+			continue
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) == 0 {
+		logger.Warn("no stats sink enabled; recorded stats only reach the live feed")
+	}
+	return sinks
+}
+
+// replayBatchesPerTick bounds how many spilled batches a worker replays per flush interval, so a long
+// backlog does not hold up new stats.
+const replayBatchesPerTick = 100
+
+// sinkWorker batches stats for one sink: it flushes every batchSize stats or flushInterval, whichever
+// comes first. With a spill queue, stats that do not fit in the queue and batches the sink fails to write
+// are spilled to disk and replayed on later flush ticks instead of being dropped.
+type sinkWorker struct {
+	sink          Sink
+	ch            chan schema.ProxyStat
+	batchSize     int
+	flushInterval time.Duration
+	spill         *spillQueue // nil: overflow and failed batches are dropped
+}
+
+func newSinkWorker(sink Sink, queueCap, batchSize int, flushInterval time.Duration, spill *spillQueue) *sinkWorker {
+	return &sinkWorker{
+		sink:          sink,
+		ch:            make(chan schema.ProxyStat, queueCap),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		spill:         spill,
+	}
+}
+
+// enqueue hands the stat to the worker. Non-blocking; spills it if the queue is full, and drops and logs
+// it if there is no spill queue or it is full too.
+func (w *sinkWorker) enqueue(stat schema.ProxyStat) {
+	select {
+	case w.ch <- stat:
+		metrics.StatsQueueDepth.With(w.sink.Name()).Set(float64(len(w.ch)))
+	default:
+		if w.spillStats([]schema.ProxyStat{stat}) {
+			return
+		}
+		metrics.StatsDropped.With(w.sink.Name()).Inc()
+		logger.Warn("sink queue full, dropping stat", "sink", w.sink.Name(), "method", stat.Method, "path", stat.Path)
+	}
+}
+
+// spillStats appends stats to the spill queue. It returns false when there is none or it has no room.
+func (w *sinkWorker) spillStats(stats []schema.ProxyStat) bool {
+	if w.spill == nil {
+		return false
+	}
+	if err := w.spill.append(stats); err != nil {
+		if !errors.Is(err, errSpillFull) {
+			logger.Error("spill failed", "sink", w.sink.Name(), "count", len(stats), "error", err)
+		}
+		return false
+	}
+	metrics.StatsSpilled.With(w.sink.Name()).Add(float64(len(stats)))
+	metrics.StatsSpillBytes.With(w.sink.Name()).Set(float64(w.spill.bytes()))
+	return true
+}
+
+// run reads the queue until ctx is cancelled, then drains what is queued, flushes and closes the sink.
+func (w *sinkWorker) run(ctx context.Context) {
+	defer func() {
+		if w.spill != nil {
+			if err := w.spill.close(); err != nil {
+				logger.Error("spill queue close failed", "sink", w.sink.Name(), "error", err)
+			}
+		}
+		if err := w.sink.Close(); err != nil {
+			logger.Error("sink close failed", "sink", w.sink.Name(), "error", err)
+		}
+	}()
+	batch := make([]schema.ProxyStat, 0, w.batchSize*2)
+	flushTimer := time.NewTimer(w.flushInterval)
+	defer flushTimer.Stop()
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		toWrite := make([]schema.ProxyStat, len(batch))
+		copy(toWrite, batch)
+		batch = batch[:0]
+		w.write(toWrite)
+		if !flushTimer.Stop() {
+			select {
+			case <-flushTimer.C:
+			default:
+			}
+		}
+		flushTimer.Reset(w.flushInterval)
+	}
+
+	for {
+		select {
+		case stat := <-w.ch:
+			metrics.StatsQueueDepth.With(w.sink.Name()).Set(float64(len(w.ch)))
+			batch = append(batch, stat)
+			if len(batch) >= w.batchSize {
+				flush()
+			}
+		case <-flushTimer.C:
+			flush()
+			w.replay()
+			flushTimer.Reset(w.flushInterval)
+		case <-ctx.Done():
+			// Drain the queue best-effort, then flush
+			for {
+				select {
+				case stat := <-w.ch:
+					batch = append(batch, stat)
+					if len(batch) >= w.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					metrics.StatsQueueDepth.With(w.sink.Name()).Set(0)
+					return
+				}
+			}
+		}
+	}
+}
+
+// write passes one batch to the sink. Errors are logged and counted; the batch is spilled, or dropped
+// when it cannot be.
+func (w *sinkWorker) write(batch []schema.ProxyStat) {
+	if err := w.call(batch); err != nil {
+		metrics.StatsSinkErrors.With(w.sink.Name()).Inc()
+		if w.spillStats(batch) {
+			logger.Warn("sink write failed, batch spilled", "sink", w.sink.Name(), "count", len(batch), "error", err)
+			return
+		}
+		metrics.StatsDropped.With(w.sink.Name()).Add(float64(len(batch)))
+		logger.Error("sink write failed", "sink", w.sink.Name(), "count", len(batch), "error", err)
+		return
+	}
+	metrics.StatsSinkWritten.With(w.sink.Name()).Add(float64(len(batch)))
+}
+
+// call runs Sink.Write, turning a panic into an error.
+func (w *sinkWorker) call(batch []schema.ProxyStat) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("sink panicked: %v", v)
+		}
+	}()
+	return w.sink.Write(batch)
+}
+
+// replay syncs the spill queue and writes up to replayBatchesPerTick spilled batches to the sink. A
+// failure leaves the rest for the next tick.
+func (w *sinkWorker) replay() {
+	if w.spill == nil {
+		return
+	}
+	if err := w.spill.sync(); err != nil {
+		logger.Error("spill queue sync failed", "sink", w.sink.Name(), "error", err)
+	}
+	if w.spill.empty() {
+		return
+	}
+	n, err := w.spill.replay(w.batchSize, replayBatchesPerTick, w.call)
+	if n > 0 {
+		metrics.StatsReplayed.With(w.sink.Name()).Add(float64(n))
+		metrics.StatsSinkWritten.With(w.sink.Name()).Add(float64(n))
+		logger.Info("replayed spilled stats", "sink", w.sink.Name(), "count", n)
+	}
+	if err != nil {
+		metrics.StatsSinkErrors.With(w.sink.Name()).Inc()
+		logger.Warn("replay failed, retrying later", "sink", w.sink.Name(), "error", err)
+	}
+	metrics.StatsSpillBytes.With(w.sink.Name()).Set(float64(w.spill.bytes()))
+}
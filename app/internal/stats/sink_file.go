@@ -0,0 +1,85 @@
+package stats
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"FeatherProxy/app/internal/accesslog"
+	"FeatherProxy/app/internal/database/schema"
+)
+
+const (
+	defaultFileSinkMaxBytes   = 100 << 20
+	defaultFileSinkMaxBackups = 5
+)
+
+// FileSinkConfig configures the NDJSON file sink.
+type FileSinkConfig struct {
+	Path       string        // required
+	MaxBytes   int64         // rotate once the file would grow past this size
+	MaxBackups int           // rotated files kept, newest first
+	MaxAge     time.Duration // rotated files older than this are removed; 0 = kept
+}
+
+// FileSink appends stats as NDJSON (the export format) to a file. It rotates like the access logs
+// (accesslog.RotatingFile): when the file would grow past MaxBytes it is renamed to Path.<timestamp>, and
+// backups beyond MaxBackups or older than MaxAge are removed. A batch is never split across files.
+type FileSink struct {
+	f   *accesslog.RotatingFile
+	buf bytes.Buffer
+}
+
+// NewFileSink opens (or creates) config.Path for appending.
+func NewFileSink(config FileSinkConfig) (*FileSink, error) {
+	if config.Path == "" {
+		return nil, errors.New("file sink path is required (STATS_SINK_FILE_PATH)")
+	}
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = defaultFileSinkMaxBytes
+	}
+	if config.MaxBackups <= 0 {
+		config.MaxBackups = defaultFileSinkMaxBackups
+	}
+	f, err := accesslog.OpenRotatingFile(&accesslog.RotatingFile{
+		Path:       config.Path,
+		MaxSize:    config.MaxBytes,
+		MaxBackups: config.MaxBackups,
+		MaxAge:     config.MaxAge,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{f: f}, nil
+}
+
+func (s *FileSink) Name() string { return SinkFile }
+
+func (s *FileSink) Write(batch []schema.ProxyStat) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	s.buf.Reset()
+	w, _ := NewExportWriter(&s.buf, ExportNDJSON)
+	for _, stat := range batch {
+		if err := w.Write(stat); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	// RotatingFile.Write appends the last newline itself.
+	err := s.f.Write(bytes.TrimSuffix(s.buf.Bytes(), []byte("\n")))
+	var rotateErr *accesslog.RotateError
+	if errors.As(err, &rotateErr) {
+		// The batch was written; retrying it would duplicate it.
+		logger.Warn("file sink rotation failed, appending to the current file", "path", rotateErr.Path, "error", rotateErr.Err)
+		return nil
+	}
+	return err
+}
+
+func (s *FileSink) Close() error {
+	return s.f.Close()
+}
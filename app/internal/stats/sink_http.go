@@ -0,0 +1,97 @@
+package stats
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"FeatherProxy/app/internal/database/schema"
+)
+
+const defaultHTTPSinkTimeout = 10 * time.Second
+
+// HTTPSinkConfig configures the HTTP sink.
+type HTTPSinkConfig struct {
+	URL           string        // http(s) endpoint; required
+	Timeout       time.Duration // per request; default 10s
+	Authorization string        // sent as the Authorization header when set
+	Gzip          bool          // gzip the request body (Content-Encoding: gzip)
+}
+
+// HTTPSink POSTs each batch as an NDJSON body (the export format, one stat per line). A non-2xx answer
+// is an error; the batch is not retried.
+type HTTPSink struct {
+	config HTTPSinkConfig
+	client *http.Client
+	buf    bytes.Buffer
+}
+
+// NewHTTPSink validates config.URL.
+func NewHTTPSink(config HTTPSinkConfig) (*HTTPSink, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("http sink URL must be an absolute http(s) URL (STATS_SINK_HTTP_URL)")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultHTTPSinkTimeout
+	}
+	return &HTTPSink{config: config, client: &http.Client{Timeout: config.Timeout}}, nil
+}
+
+func (s *HTTPSink) Name() string { return SinkHTTP }
+
+func (s *HTTPSink) Write(batch []schema.ProxyStat) error {
+	s.buf.Reset()
+	var body io.Writer = &s.buf
+	var gz *gzip.Writer
+	if s.config.Gzip {
+		gz = gzip.NewWriter(&s.buf)
+		body = gz
+	}
+	w, _ := NewExportWriter(body, ExportNDJSON)
+	for _, stat := range batch {
+		if err := w.Write(stat); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(http.MethodPost, s.config.URL, bytes.NewReader(s.buf.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ExportContentTypes[ExportNDJSON])
+	req.Header.Set("User-Agent", "FeatherProxy-Stats")
+	if gz != nil {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if s.config.Authorization != "" {
+		req.Header.Set("Authorization", s.config.Authorization)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *HTTPSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,119 @@
+package stats
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/metrics"
+)
+
+const (
+	defaultStatsDPrefix = "featherproxy."
+	// statsDMaxPacket keeps each datagram within a typical 1500-byte MTU.
+	statsDMaxPacket = 1432
+)
+
+// StatsDSinkConfig configures the StatsD sink.
+type StatsDSinkConfig struct {
+	Addr      string // host:port of the StatsD agent; required
+	Prefix    string // metric name prefix; default "featherproxy."
+	DogStatsD bool   // send DogStatsD tags instead of encoding them in the metric name
+}
+
+// StatsDSink sends per-batch aggregates over UDP: a request counter and one timing per request with a
+// duration. With DogStatsD, outcome, status class, method and the source, route and target UUIDs are
+// tags; with plain StatsD, outcome and status class are part of the metric name. A sampled stat adds its
+// weight to the counter and sends its timing with the sample rate (@1/weight).
+type StatsDSink struct {
+	config StatsDSinkConfig
+	conn   net.Conn
+}
+
+// NewStatsDSink resolves config.Addr and opens the UDP socket.
+func NewStatsDSink(config StatsDSinkConfig) (*StatsDSink, error) {
+	if config.Addr == "" {
+		return nil, errors.New("statsd sink address is required (STATS_SINK_STATSD_ADDR)")
+	}
+	if config.Prefix == "" {
+		config.Prefix = defaultStatsDPrefix
+	}
+	conn, err := net.Dial("udp", config.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsDSink{config: config, conn: conn}, nil
+}
+
+func (s *StatsDSink) Name() string { return SinkStatsD }
+
+func (s *StatsDSink) Write(batch []schema.ProxyStat) error {
+	var packet []byte
+	for _, line := range s.lines(batch) {
+		if len(packet) > 0 && len(packet)+1+len(line) > statsDMaxPacket {
+			if _, err := s.conn.Write(packet); err != nil {
+				return err
+			}
+			packet = packet[:0]
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		if _, err := s.conn.Write(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *StatsDSink) Close() error { return s.conn.Close() }
+
+// lines formats the batch: request counters summed per metric name and tags (sorted, so output is
+// stable), followed by one timing per request.
+func (s *StatsDSink) lines(batch []schema.ProxyStat) []string {
+	type series struct{ name, tags string }
+	counts := make(map[series]int64)
+	var timings []string
+	for _, stat := range batch {
+		status := "none"
+		if stat.StatusCode != nil {
+			status = metrics.StatusClass(*stat.StatusCode)
+		}
+		var counter, timer, tags string
+		if s.config.DogStatsD {
+			counter = s.config.Prefix + "requests"
+			timer = s.config.Prefix + "request.duration"
+			tags = "|#" + strings.Join([]string{
+				"outcome:" + stat.Outcome,
+				"status_class:" + status,
+				"method:" + metrics.MethodLabel(stat.Method),
+				"source:" + stat.SourceServerUUID.String(),
+				"route:" + stat.RouteUUID.String(),
+				"target:" + stat.TargetServerUUID.String(),
+			}, ",")
+		} else {
+			counter = s.config.Prefix + "requests." + stat.Outcome + "." + status
+			timer = s.config.Prefix + "request.duration." + stat.Outcome
+		}
+		counts[series{counter, tags}] += stat.Weight()
+		if stat.DurationMs != nil {
+			rate := ""
+			if w := stat.Weight(); w > 1 {
+				rate = "|@" + strconv.FormatFloat(1/float64(w), 'g', 4, 64)
+			}
+			timings = append(timings, timer+":"+strconv.FormatInt(*stat.DurationMs, 10)+"|ms"+rate+tags)
+		}
+	}
+	lines := make([]string, 0, len(counts)+len(timings))
+	for key, n := range counts {
+		lines = append(lines, key.name+":"+strconv.FormatInt(n, 10)+"|c"+key.tags)
+	}
+	sort.Strings(lines)
+	return append(lines, timings...)
+}
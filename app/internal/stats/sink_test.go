@@ -0,0 +1,217 @@
+package stats
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+func TestFileSink_rotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.ndjson")
+	sink, err := NewFileSink(FileSinkConfig{Path: path, MaxBytes: 300, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := []schema.ProxyStat{{ID: uuid.New(), Method: "GET", Path: "/a", Outcome: schema.OutcomeProxied}}
+	for i := 0; i < 5; i++ {
+		if err := sink.Write(batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	backups, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Errorf("backups = %v, want MaxBackups (2) files named %s.<timestamp>", backups, path)
+	}
+	for _, name := range append(backups, path) {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(b), `{"id":"`) || !strings.HasSuffix(string(b), "}\n") || len(b) > 300 {
+			t.Errorf("%s: %d bytes %q", name, len(b), b)
+		}
+	}
+}
+
+func TestStatsDSink_lines(t *testing.T) {
+	ok, fail := 200, 502
+	d1, d2 := int64(12), int64(30)
+	batch := []schema.ProxyStat{
+		{Method: "GET", StatusCode: &ok, DurationMs: &d1, Outcome: schema.OutcomeProxied},
+		{Method: "GET", StatusCode: &ok, DurationMs: &d2, Outcome: schema.OutcomeProxied},
+		{Method: "POST", StatusCode: &fail, Outcome: schema.OutcomeUpstreamError},
+	}
+	plain := &StatsDSink{config: StatsDSinkConfig{Prefix: "fp."}}
+	got := strings.Join(plain.lines(batch), "\n")
+	want := "fp.requests.proxied.2xx:2|c\nfp.requests.upstream_error.5xx:1|c\nfp.request.duration.proxied:12|ms\nfp.request.duration.proxied:30|ms"
+	if got != want {
+		t.Errorf("plain lines:\n%s\nwant:\n%s", got, want)
+	}
+	dog := &StatsDSink{config: StatsDSinkConfig{Prefix: "fp.", DogStatsD: true}}
+	lines := dog.lines(batch[:1])
+	zero := uuid.Nil.String()
+	tags := "|#outcome:proxied,status_class:2xx,method:GET,source:" + zero + ",route:" + zero + ",target:" + zero
+	if len(lines) != 2 || lines[0] != "fp.requests:1|c"+tags || lines[1] != "fp.request.duration:12|ms"+tags {
+		t.Errorf("dogstatsd lines = %q", lines)
+	}
+}
+
+func TestStatsDSink_splitsPackets(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	sink, err := NewStatsDSink(StatsDSinkConfig{Addr: pc.LocalAddr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	batch := make([]schema.ProxyStat, 200)
+	for i := range batch {
+		d := int64(i)
+		batch[i] = schema.ProxyStat{DurationMs: &d, Outcome: schema.OutcomeProxied}
+	}
+	if err := sink.Write(batch); err != nil {
+		t.Fatal(err)
+	}
+	var lines int
+	buf := make([]byte, 64<<10)
+	pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for lines < 201 {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("after %d lines: %v", lines, err)
+		}
+		if n > statsDMaxPacket {
+			t.Fatalf("packet of %d bytes", n)
+		}
+		lines += strings.Count(string(buf[:n]), "\n") + 1
+	}
+	if lines != 201 {
+		t.Errorf("lines = %d, want 201 (1 counter + 200 timings)", lines)
+	}
+}
+
+func TestHTTPSink_postsNDJSON(t *testing.T) {
+	var got []string
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sc := bufio.NewScanner(zr)
+		for sc.Scan() {
+			got = append(got, sc.Text())
+		}
+		if r.URL.Path == "/fail" {
+			http.Error(w, "nope", http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	if _, err := NewHTTPSink(HTTPSinkConfig{URL: "localhost:9000/ingest"}); err == nil {
+		t.Error("URL without scheme accepted")
+	}
+	sink, err := NewHTTPSink(HTTPSinkConfig{URL: srv.URL + "/ingest", Authorization: "Bearer t", Gzip: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write([]schema.ProxyStat{{Path: "/a"}, {Path: "/b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !strings.Contains(got[1], `"path":"/b"`) {
+		t.Errorf("body lines = %q", got)
+	}
+	if header.Get("Authorization") != "Bearer t" || header.Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("headers = %v", header)
+	}
+	failing, _ := NewHTTPSink(HTTPSinkConfig{URL: srv.URL + "/fail", Gzip: true})
+	if err := failing.Write([]schema.ProxyStat{{Path: "/c"}}); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("err = %v, want 503", err)
+	}
+}
+
+// recordingSink collects written stats; fail makes every Write return an error.
+type recordingSink struct {
+	name   string
+	fail   bool
+	mu     sync.Mutex
+	stats  []schema.ProxyStat
+	closed bool
+}
+
+func (s *recordingSink) Name() string { return s.name }
+
+func (s *recordingSink) Write(batch []schema.ProxyStat) error {
+	if s.fail {
+		return errors.New("down")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats = append(s.stats, batch...)
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func TestService_failingSinkIsolated(t *testing.T) {
+	good, bad := &recordingSink{name: "good"}, &recordingSink{name: "bad", fail: true}
+	s := &Service{live: NewHub(0)}
+	s.workers = []*sinkWorker{
+		newSinkWorker(bad, 1, 10, time.Hour, nil),
+		newSinkWorker(good, 10, 10, time.Hour, nil),
+	}
+	for _, p := range []string{"/a", "/b", "/c"} {
+		s.Record(schema.ProxyStat{Path: p}) // bad's queue holds one; the rest are dropped for bad only
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, w := range s.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.run(ctx)
+		}()
+	}
+	cancel()
+	wg.Wait()
+	if len(good.stats) != 3 || !good.closed || !bad.closed {
+		t.Errorf("good got %d stats (closed %v), bad closed %v", len(good.stats), good.closed, bad.closed)
+	}
+}
+
+func TestNewSinks_skipsBadConfig(t *testing.T) {
+	sinks := newSinks(nil, Config{Sinks: []string{"db", "file", "kafka", "db"}})
+	if len(sinks) != 1 || sinks[0].Name() != SinkDB {
+		t.Errorf("sinks = %v, want only db", sinks)
+	}
+}
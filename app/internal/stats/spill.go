@@ -0,0 +1,277 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"FeatherProxy/app/internal/database/schema"
+)
+
+const (
+	defaultSpillMaxBytes = 1 << 30
+	// spillSegmentBytes is the size at which the segment being written is sealed and a new one started.
+	spillSegmentBytes = 8 << 20
+	spillSegmentExt   = ".wal"
+	// spillMaxLine bounds one stat's JSON when reading segments back.
+	spillMaxLine = 1 << 20
+)
+
+// errSpillFull is returned by spillQueue.append when the queue is at its size limit.
+var errSpillFull = errors.New("spill queue full")
+
+// spillQueue is a disk-backed FIFO of stats a sink could not take: overflow of its in-memory queue and
+// batches whose write failed. Stats are appended as NDJSON to numbered segment files (000…1.wal, …) in
+// dir; replay reads the oldest segment back in batches and deletes it once every batch was written.
+//
+// Delivery is at least once: a replay interrupted by a failure resumes at the failed batch, but after a
+// restart a partly replayed segment starts over. The db sink skips IDs it already stored.
+type spillQueue struct {
+	dir      string
+	maxBytes int64
+
+	mu     sync.Mutex
+	w      *os.File      // segment being written; nil until the next append
+	bw     *bufio.Writer // buffers w
+	wseq   uint64        // sequence of w, or of the next segment to create
+	wsize  int64         // bytes in w
+	size   int64         // bytes in all segments
+	sealed []uint64      // segments no longer written, oldest first
+
+	// Replay state, used by the sink worker only.
+	rseq uint64 // segment being replayed (0: none)
+	roff int64  // offset of the first stat not yet written
+}
+
+// openSpillQueue opens (creating if needed) dir and picks up the segments a previous run left behind.
+func openSpillQueue(dir string, maxBytes int64) (*spillQueue, error) {
+	if maxBytes <= 0 {
+		maxBytes = defaultSpillMaxBytes
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	q := &spillQueue{dir: dir, maxBytes: maxBytes, wseq: 1}
+	for _, e := range entries {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(e.Name(), spillSegmentExt), 10, 64)
+		if err != nil || !strings.HasSuffix(e.Name(), spillSegmentExt) || e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		q.sealed = append(q.sealed, seq)
+		q.size += info.Size()
+		q.wseq = max(q.wseq, seq+1)
+	}
+	sort.Slice(q.sealed, func(i, j int) bool { return q.sealed[i] < q.sealed[j] })
+	return q, nil
+}
+
+func (q *spillQueue) path(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seq, spillSegmentExt))
+}
+
+// append writes the stats to the current segment (buffered; see sync). It fails with errSpillFull when
+// they do not fit under maxBytes, in which case nothing is written.
+func (q *spillQueue) append(stats []schema.ProxyStat) error {
+	var buf []byte
+	for _, stat := range stats {
+		b, err := json.Marshal(stat)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, b...), '\n')
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.size+int64(len(buf)) > q.maxBytes {
+		return errSpillFull
+	}
+	if q.w != nil && q.wsize+int64(len(buf)) > spillSegmentBytes {
+		if err := q.sealLocked(); err != nil {
+			return err
+		}
+	}
+	if q.w == nil {
+		f, err := os.OpenFile(q.path(q.wseq), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+		if err != nil {
+			return err
+		}
+		q.w, q.bw, q.wsize = f, bufio.NewWriter(f), 0
+	}
+	n, err := q.bw.Write(buf)
+	q.wsize += int64(n)
+	q.size += int64(n)
+	return err
+}
+
+// sync flushes buffered appends and fsyncs the current segment.
+func (q *spillQueue) sync() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.w == nil {
+		return nil
+	}
+	if err := q.bw.Flush(); err != nil {
+		return err
+	}
+	return q.w.Sync()
+}
+
+// sealLocked closes the current segment and queues it for replay.
+func (q *spillQueue) sealLocked() error {
+	if q.w == nil {
+		return nil
+	}
+	err := q.bw.Flush()
+	if cerr := q.w.Close(); err == nil {
+		err = cerr
+	}
+	q.sealed = append(q.sealed, q.wseq)
+	q.w, q.bw = nil, nil
+	q.wseq++
+	return err
+}
+
+// bytes returns the size of all segments.
+func (q *spillQueue) bytes() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// empty reports whether there is nothing to replay.
+func (q *spillQueue) empty() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.rseq == 0 && len(q.sealed) == 0 && q.w == nil
+}
+
+// replay reads spilled stats back, oldest first, and passes them to write in batches of batchSize until
+// the queue is empty, maxBatches batches were written or write fails. It returns the number of stats
+// written; on failure the failed batch is retried by the next replay.
+func (q *spillQueue) replay(batchSize, maxBatches int, write func([]schema.ProxyStat) error) (int, error) {
+	written := 0
+	for batches := 0; batches < maxBatches; {
+		if q.rseq == 0 {
+			q.mu.Lock()
+			if len(q.sealed) == 0 {
+				// Replay what is being written too, so a short outage does not wait for a full segment.
+				if err := q.sealLocked(); err != nil || len(q.sealed) == 0 {
+					q.mu.Unlock()
+					return written, err
+				}
+			}
+			q.rseq, q.roff = q.sealed[0], 0
+			q.sealed = q.sealed[1:]
+			q.mu.Unlock()
+		}
+		n, done, err := q.replaySegment(batchSize, maxBatches-batches, write)
+		written += n
+		batches += (n + batchSize - 1) / batchSize
+		if err != nil {
+			return written, err
+		}
+		if !done {
+			return written, nil
+		}
+		info, statErr := os.Stat(q.path(q.rseq))
+		if err := os.Remove(q.path(q.rseq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return written, err
+		}
+		if statErr == nil {
+			q.mu.Lock()
+			q.size -= info.Size()
+			q.mu.Unlock()
+		}
+		q.rseq = 0
+	}
+	return written, nil
+}
+
+// replaySegment writes stats of segment rseq from roff on, at most maxBatches batches. done reports that
+// the segment was read to the end. Lines that do not decode (a write cut short by a crash) are skipped.
+func (q *spillQueue) replaySegment(batchSize, maxBatches int, write func([]schema.ProxyStat) error) (written int, done bool, err error) {
+	f, err := os.Open(q.path(q.rseq))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, true, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(q.roff, 0); err != nil {
+		return 0, false, err
+	}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), spillMaxLine)
+	batch := make([]schema.ProxyStat, 0, batchSize)
+	off := q.roff
+	flush := func() error {
+		if err := write(batch); err != nil {
+			return err
+		}
+		written += len(batch)
+		batch = batch[:0]
+		q.roff = off
+		maxBatches--
+		return nil
+	}
+	for sc.Scan() {
+		off += int64(len(sc.Bytes())) + 1
+		var stat schema.ProxyStat
+		if json.Unmarshal(sc.Bytes(), &stat) != nil {
+			logger.Warn("spill queue: skipping unreadable stat", "segment", q.path(q.rseq))
+			continue
+		}
+		batch = append(batch, stat)
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return written, false, err
+			}
+			if maxBatches == 0 {
+				return written, false, nil
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return written, false, err
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return written, false, err
+		}
+	}
+	return written, true, nil
+}
+
+// close flushes and closes the segment being written; it is replayed on the next start.
+func (q *spillQueue) close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.w == nil {
+		return nil
+	}
+	err := q.bw.Flush()
+	if serr := q.w.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := q.w.Close(); err == nil {
+		err = cerr
+	}
+	q.w, q.bw = nil, nil
+	return err
+}
@@ -0,0 +1,161 @@
+package stats
+
+import (
+	"errors"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+func spillStats(n int) []schema.ProxyStat {
+	out := make([]schema.ProxyStat, n)
+	for i := range out {
+		out[i] = schema.ProxyStat{ID: uuid.New(), Path: "/p", Outcome: schema.OutcomeProxied}
+	}
+	return out
+}
+
+func TestSpillQueue_replayResumesAfterFailure(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openSpillQueue(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := spillStats(25)
+	if err := q.append(want[:5]); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.append(want[5:]); err != nil {
+		t.Fatal(err)
+	}
+	var got []schema.ProxyStat
+	calls := 0
+	write := func(batch []schema.ProxyStat) error {
+		calls++
+		if calls == 2 {
+			return errors.New("db down")
+		}
+		got = append(got, batch...)
+		return nil
+	}
+	n, err := q.replay(10, 100, write)
+	if n != 10 || err == nil {
+		t.Fatalf("first replay: n = %d, err = %v; want 10 and an error", n, err)
+	}
+	if q.empty() {
+		t.Fatal("queue empty after a failed replay")
+	}
+	n, err = q.replay(10, 100, write)
+	if n != 15 || err != nil {
+		t.Fatalf("second replay: n = %d, err = %v; want 15", n, err)
+	}
+	if len(got) != 25 || got[0].ID != want[0].ID || got[24].ID != want[24].ID {
+		t.Fatalf("replayed %d stats, out of order or missing", len(got))
+	}
+	if !q.empty() || q.bytes() != 0 {
+		t.Errorf("after replay: empty %v, %d bytes", q.empty(), q.bytes())
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*"+spillSegmentExt)); len(files) != 0 {
+		t.Errorf("segments left: %v", files)
+	}
+}
+
+func TestSpillQueue_survivesRestartAndLimit(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openSpillQueue(dir, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.append(spillStats(3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.append(spillStats(50)); !errors.Is(err, errSpillFull) {
+		t.Fatalf("append over the limit: err = %v, want errSpillFull", err)
+	}
+	if err := q.close(); err != nil {
+		t.Fatal(err)
+	}
+	// A crash can leave a partial line at the end of a segment.
+	f, err := os.OpenFile(q.path(1), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"`)
+	f.Close()
+
+	q, err = openSpillQueue(dir, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.empty() || q.bytes() == 0 {
+		t.Fatal("reopened queue lost its segment")
+	}
+	var got int
+	n, err := q.replay(10, 100, func(batch []schema.ProxyStat) error { got += len(batch); return nil })
+	if n != 3 || got != 3 || err != nil {
+		t.Errorf("replay after restart: n = %d, got %d, err = %v; want 3", n, got, err)
+	}
+}
+
+func TestSinkWorker_spillsFailedBatchAndReplays(t *testing.T) {
+	q, err := openSpillQueue(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &recordingSink{name: "db", fail: true}
+	w := newSinkWorker(sink, 1, 10, time.Hour, q)
+	w.write(spillStats(4))
+	w.enqueue(schema.ProxyStat{ID: uuid.New()})
+	w.enqueue(schema.ProxyStat{ID: uuid.New()}) // queue of 1 is full: spilled
+	if len(sink.stats) != 0 || q.empty() {
+		t.Fatalf("sink got %d stats, spill empty %v", len(sink.stats), q.empty())
+	}
+	w.replay() // still failing: kept
+	sink.fail = false
+	w.replay()
+	if len(sink.stats) != 5 || !q.empty() {
+		t.Errorf("after recovery: sink got %d stats (want 5), spill empty %v", len(sink.stats), q.empty())
+	}
+}
+
+func TestSampler_weightsEstimateTotal(t *testing.T) {
+	s := newSampler(100)
+	s.intN = rand.New(rand.NewPCG(1, 2)).Int64N
+	now := time.Unix(1_700_000_000, 0)
+	ok, fail := 200, 503
+	var weight, kept, errorsKept int64
+	for i := 0; i < 10000; i++ {
+		stat := schema.ProxyStat{StatusCode: &ok, Outcome: schema.OutcomeProxied}
+		if i%100 == 0 {
+			stat.StatusCode = &fail
+		}
+		if !s.keep(&stat, now) {
+			continue
+		}
+		kept++
+		weight += stat.Weight()
+		if *stat.StatusCode == fail {
+			errorsKept++
+			if stat.Weight() != 1 {
+				t.Fatalf("5xx sampled with weight %d", stat.Weight())
+			}
+		}
+	}
+	if errorsKept != 100 {
+		t.Errorf("5xx kept = %d, want all 100", errorsKept)
+	}
+	if kept > 2000 || weight < 8500 || weight > 11500 {
+		t.Errorf("kept %d stats with total weight %d; want few stats weighing about 10000", kept, weight)
+	}
+	// Two seconds later the spike no longer counts: nothing is sampled.
+	stat := schema.ProxyStat{StatusCode: &ok, Outcome: schema.OutcomeProxied}
+	if !s.keep(&stat, now.Add(2*time.Second)) || stat.SampleWeight != 0 {
+		t.Errorf("after the spike: weight %d, want unsampled", stat.SampleWeight)
+	}
+}
@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// W3C Trace Context headers.
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// maxTracestateLen bounds the tracestate value that is passed through.
+const maxTracestateLen = 512
+
+// SpanContext identifies a span within a trace, as carried by the traceparent and tracestate headers.
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Sampled    bool
+	TraceState string // opaque vendor data, passed through unchanged
+}
+
+// IsValid reports whether both IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent formats sc as a version 00 traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+}
+
+// ParseTraceparent parses a traceparent header value. Unknown future versions are accepted as long as
+// the version 00 fields are well-formed; version ff and all-zero IDs are rejected.
+func ParseTraceparent(v string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 {
+		return SpanContext{}, false
+	}
+	if !decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&0x01 == 0x01
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	return sc, true
+}
+
+// decodeHex decodes lowercase hex s into dst, requiring an exact length match.
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// Extract reads the remote span context from the traceparent and tracestate headers.
+func Extract(h http.Header) (SpanContext, bool) {
+	sc, ok := ParseTraceparent(h.Get(TraceparentHeader))
+	if !ok {
+		return SpanContext{}, false
+	}
+	if ts := strings.TrimSpace(strings.Join(h.Values(TracestateHeader), ",")); len(ts) <= maxTracestateLen {
+		sc.TraceState = ts
+	}
+	return sc, true
+}
+
+// Inject writes sc to the traceparent and tracestate headers, replacing any incoming values.
+func Inject(h http.Header, sc SpanContext) {
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		h.Set(TracestateHeader, sc.TraceState)
+	} else {
+		h.Del(TracestateHeader)
+	}
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span carried by ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
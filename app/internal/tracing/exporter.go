@@ -0,0 +1,221 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"FeatherProxy/app/internal/logging"
+)
+
+var logger = logging.For(logging.Proxy)
+
+// exportTimeout bounds one export request, including the final flush on shutdown.
+const exportTimeout = 10 * time.Second
+
+// exporter batches finished spans and posts them to an OTLP/HTTP collector as JSON.
+type exporter struct {
+	config  Config
+	client  *http.Client
+	ch      chan *Span
+	dropped atomic.Int64
+}
+
+func newExporter(config Config) *exporter {
+	return &exporter{
+		config: config,
+		client: &http.Client{Timeout: exportTimeout},
+		ch:     make(chan *Span, config.QueueSize),
+	}
+}
+
+// enqueue queues a finished span. Non-blocking; drops the span when the queue is full.
+func (e *exporter) enqueue(s *Span) {
+	select {
+	case e.ch <- s:
+	default:
+		if n := e.dropped.Add(1); n == 1 || n%1000 == 0 {
+			logger.Warn("tracing: export queue full, dropping spans", "dropped", n)
+		}
+	}
+}
+
+func (e *exporter) run(ctx context.Context) {
+	batch := make([]*Span, 0, e.config.BatchSize)
+	ticker := time.NewTicker(e.config.FlushInterval)
+	defer ticker.Stop()
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		exportCtx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		if err := e.export(exportCtx, batch); err != nil {
+			logger.Warn("tracing: export failed", "spans", len(batch), "error", err)
+		}
+		cancel()
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case s := <-e.ch:
+			batch = append(batch, s)
+			if len(batch) >= e.config.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			for {
+				select {
+				case s := <-e.ch:
+					batch = append(batch, s)
+					if len(batch) >= e.config.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// export posts one batch of spans.
+func (e *exporter) export(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(e.payload(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.config.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP/JSON request shapes (opentelemetry-proto ExportTraceServiceRequest).
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	TraceState        string         `json:"traceState,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 2 = STATUS_CODE_ERROR
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func (e *exporter) payload(spans []*Span) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.sc.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.sc.SpanID[:]),
+			TraceState:        s.sc.TraceState,
+			Name:              s.name,
+			Kind:              int(s.kind),
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parentID != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		for _, a := range s.attrs {
+			span.Attributes = append(span.Attributes, keyValue(a.key, a.value))
+		}
+		if s.errorText != "" {
+			span.Status = &otlpStatus{Code: 2, Message: s.errorText}
+		}
+		s.mu.Unlock()
+		out = append(out, span)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{keyValue("service.name", e.config.ServiceName)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "FeatherProxy/app/internal/tracing"}, Spans: out}},
+	}}}
+}
+
+func keyValue(key string, value any) otlpKeyValue {
+	var v otlpAnyValue
+	switch x := value.(type) {
+	case string:
+		v.StringValue = &x
+	case bool:
+		v.BoolValue = &x
+	case int:
+		s := strconv.Itoa(x)
+		v.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(x, 10)
+		v.IntValue = &s
+	case float64:
+		v.DoubleValue = &x
+	default:
+		s := fmt.Sprint(x)
+		v.StringValue = &s
+	}
+	return otlpKeyValue{Key: key, Value: v}
+}
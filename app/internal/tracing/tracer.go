@@ -0,0 +1,342 @@
+// Package tracing implements W3C Trace Context propagation and span export over OTLP/HTTP (JSON),
+// configured with the standard OTEL_* environment variables.
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Samplers (OTEL_TRACES_SAMPLER values).
+const (
+	SamplerAlwaysOn                = "always_on"
+	SamplerAlwaysOff               = "always_off"
+	SamplerTraceIDRatio            = "traceidratio"
+	SamplerParentBasedAlwaysOn     = "parentbased_always_on"
+	SamplerParentBasedAlwaysOff    = "parentbased_always_off"
+	SamplerParentBasedTraceIDRatio = "parentbased_traceidratio"
+)
+
+const (
+	defaultServiceName   = "featherproxy"
+	defaultFlushInterval = 5 * time.Second
+	defaultBatchSize     = 512
+	defaultQueueSize     = 2048
+)
+
+// Kind is the OTLP span kind.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// Config holds tracing configuration (from env or defaults).
+type Config struct {
+	ServiceName   string
+	Endpoint      string            // OTLP/HTTP traces URL; empty = spans are not exported (context is still propagated)
+	Headers       map[string]string // extra headers on export requests (e.g. auth)
+	Sampler       string            // one of the Sampler* values
+	SamplerArg    float64           // ratio for the traceidratio samplers
+	BatchSize     int
+	FlushInterval time.Duration
+	QueueSize     int
+}
+
+// ConfigFromEnv returns config from the standard OpenTelemetry environment variables:
+// OTEL_SERVICE_NAME, OTEL_EXPORTER_OTLP_TRACES_ENDPOINT / OTEL_EXPORTER_OTLP_ENDPOINT,
+// OTEL_EXPORTER_OTLP_TRACES_HEADERS / OTEL_EXPORTER_OTLP_HEADERS, OTEL_TRACES_EXPORTER,
+// OTEL_TRACES_SAMPLER, OTEL_TRACES_SAMPLER_ARG, OTEL_BSP_SCHEDULE_DELAY (ms),
+// OTEL_BSP_MAX_EXPORT_BATCH_SIZE, OTEL_BSP_MAX_QUEUE_SIZE and OTEL_SDK_DISABLED.
+func ConfigFromEnv() Config {
+	c := Config{
+		ServiceName:   defaultServiceName,
+		Sampler:       SamplerParentBasedAlwaysOn,
+		SamplerArg:    1,
+		BatchSize:     defaultBatchSize,
+		FlushInterval: defaultFlushInterval,
+		QueueSize:     defaultQueueSize,
+	}
+	if v := os.Getenv("OTEL_SERVICE_NAME"); v != "" {
+		c.ServiceName = v
+	}
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); v != "" {
+		c.Endpoint = v
+	} else if v := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); v != "" {
+		c.Endpoint = strings.TrimSuffix(v, "/") + "/v1/traces"
+	}
+	if strings.EqualFold(os.Getenv("OTEL_TRACES_EXPORTER"), "none") || strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		c.Endpoint = ""
+	}
+	headers := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS")
+	if headers == "" {
+		headers = os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")
+	}
+	c.Headers = parseHeaders(headers)
+	if v := strings.ToLower(os.Getenv("OTEL_TRACES_SAMPLER")); v != "" {
+		c.Sampler = v
+	}
+	if v := os.Getenv("OTEL_TRACES_SAMPLER_ARG"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
+			c.SamplerArg = f
+		}
+	}
+	if v := os.Getenv("OTEL_BSP_SCHEDULE_DELAY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			c.FlushInterval = time.Duration(n) * time.Millisecond
+		}
+	}
+	if v := os.Getenv("OTEL_BSP_MAX_EXPORT_BATCH_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			c.BatchSize = n
+		}
+	}
+	if v := os.Getenv("OTEL_BSP_MAX_QUEUE_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			c.QueueSize = n
+		}
+	}
+	return c
+}
+
+// parseHeaders parses the OTEL "key1=value1,key2=value2" header list (values are URL-encoded).
+func parseHeaders(s string) map[string]string {
+	out := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			continue
+		}
+		if dec, err := url.QueryUnescape(strings.TrimSpace(v)); err == nil {
+			v = dec
+		}
+		out[strings.TrimSpace(k)] = v
+	}
+	return out
+}
+
+// Tracer creates spans and hands sampled, finished spans to the exporter. A nil *Tracer is valid and
+// creates no spans.
+type Tracer struct {
+	config   Config
+	exporter *exporter // nil when Endpoint is empty
+}
+
+// New returns a tracer for config. Call Run to export spans.
+func New(config Config) *Tracer {
+	if config.ServiceName == "" {
+		config.ServiceName = defaultServiceName
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultFlushInterval
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultQueueSize
+	}
+	t := &Tracer{config: config}
+	if config.Endpoint != "" {
+		t.exporter = newExporter(config)
+	}
+	return t
+}
+
+// Run exports finished spans until ctx is cancelled, then flushes what is queued.
+func (t *Tracer) Run(ctx context.Context) {
+	if t == nil || t.exporter == nil {
+		<-ctx.Done()
+		return
+	}
+	t.exporter.run(ctx)
+}
+
+// StartServer starts the span for an incoming request, continuing the trace from the request's
+// traceparent/tracestate headers when present, otherwise starting a new trace.
+func (t *Tracer) StartServer(ctx context.Context, name string, h http.Header) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	remote, hasRemote := Extract(h)
+	sc := SpanContext{SpanID: newSpanID()}
+	var parentID [8]byte
+	if hasRemote {
+		sc.TraceID = remote.TraceID
+		sc.TraceState = remote.TraceState
+		parentID = remote.SpanID
+	} else {
+		sc.TraceID = newTraceID()
+	}
+	sc.Sampled = t.sample(sc.TraceID, remote, hasRemote)
+	span := &Span{tracer: t, sc: sc, parentID: parentID, name: name, kind: KindServer, start: time.Now()}
+	return ContextWithSpan(ctx, span), span
+}
+
+// Start starts a child of the span in ctx. Without a span in ctx it returns ctx and a nil span, whose
+// methods are no-ops.
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	sc := parent.sc
+	sc.SpanID = newSpanID()
+	span := &Span{tracer: parent.tracer, sc: sc, parentID: parent.sc.SpanID, name: name, kind: kind, start: time.Now()}
+	return ContextWithSpan(ctx, span), span
+}
+
+// sample decides whether a new local root (or remote child) span is sampled.
+func (t *Tracer) sample(traceID [16]byte, parent SpanContext, hasParent bool) bool {
+	switch t.config.Sampler {
+	case SamplerAlwaysOn:
+		return true
+	case SamplerAlwaysOff:
+		return false
+	case SamplerTraceIDRatio:
+		return ratioSampled(traceID, t.config.SamplerArg)
+	case SamplerParentBasedAlwaysOff:
+		return hasParent && parent.Sampled
+	case SamplerParentBasedTraceIDRatio:
+		if hasParent {
+			return parent.Sampled
+		}
+		return ratioSampled(traceID, t.config.SamplerArg)
+	default: // parentbased_always_on
+		if hasParent {
+			return parent.Sampled
+		}
+		return true
+	}
+}
+
+// ratioSampled samples deterministically from the low 8 bytes of the trace ID, so every service using
+// the same ratio makes the same decision for a trace.
+func ratioSampled(traceID [16]byte, ratio float64) bool {
+	if ratio >= 1 {
+		return true
+	}
+	if ratio <= 0 {
+		return false
+	}
+	bound := uint64(ratio * math.MaxInt64)
+	return binary.BigEndian.Uint64(traceID[8:])>>1 < bound
+}
+
+func newTraceID() [16]byte {
+	var id [16]byte
+	for id == [16]byte{} {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() [8]byte {
+	var id [8]byte
+	for id == [8]byte{} {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
+
+// Span is one timed operation. A nil *Span is valid; all methods are no-ops.
+type Span struct {
+	tracer   *Tracer
+	sc       SpanContext
+	parentID [8]byte
+	name     string
+	kind     Kind
+	start    time.Time
+
+	mu        sync.Mutex
+	end       time.Time
+	attrs     []attribute
+	errorText string
+	ended     bool
+}
+
+type attribute struct {
+	key   string
+	value any // string, bool, int, int64 or float64
+}
+
+// SpanContext returns the span's context, used to propagate the trace to the next hop.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetName replaces the span name, e.g. once the matched route is known.
+func (s *Span) SetName(name string) {
+	if s == nil || !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+// SetAttr records an attribute on the span. Values should be string, bool, int, int64 or float64.
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil || !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attribute{key: key, value: value})
+	s.mu.Unlock()
+}
+
+// RecordError marks the span as failed with err's message. A nil err is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil || !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	s.errorText = err.Error()
+	s.mu.Unlock()
+}
+
+// SetErrorStatus marks the span as failed with the given description.
+func (s *Span) SetErrorStatus(description string) {
+	if s == nil || !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	s.errorText = description
+	s.mu.Unlock()
+}
+
+// End finishes the span and queues it for export. Only the first call has an effect.
+func (s *Span) End() {
+	if s == nil || !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.exporter.enqueue(s)
+}
+
+// recording reports whether the span will be exported.
+func (s *Span) recording() bool {
+	return s.sc.Sampled && s.tracer != nil && s.tracer.exporter != nil
+}
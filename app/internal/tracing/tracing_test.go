@@ -0,0 +1,140 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	valid := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceparent(valid)
+	if !ok || !sc.Sampled {
+		t.Fatalf("ParseTraceparent(%q) = %+v, %v", valid, sc, ok)
+	}
+	if got := sc.Traceparent(); got != valid {
+		t.Errorf("round trip = %q, want %q", got, valid)
+	}
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	}
+	for _, v := range invalid {
+		if _, ok := ParseTraceparent(v); ok {
+			t.Errorf("ParseTraceparent(%q) accepted", v)
+		}
+	}
+	// Future versions may append fields.
+	if _, ok := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"); !ok {
+		t.Error("future version rejected")
+	}
+}
+
+func TestStartServerContinuesRemoteTrace(t *testing.T) {
+	tr := New(Config{Sampler: SamplerParentBasedAlwaysOn})
+	h := http.Header{}
+	h.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	h.Set(TracestateHeader, "vendor=abc")
+	ctx, server := tr.StartServer(context.Background(), "GET", h)
+	_, child := Start(ctx, "upstream", KindClient)
+
+	sc := child.SpanContext()
+	if got := sc.Traceparent()[3:35]; got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID = %s, want the remote one", got)
+	}
+	if sc.Sampled {
+		t.Error("parent-based sampler ignored unsampled parent")
+	}
+	if sc.SpanID == server.SpanContext().SpanID {
+		t.Error("child reused the server span ID")
+	}
+	out := http.Header{}
+	Inject(out, sc)
+	if out.Get(TracestateHeader) != "vendor=abc" || out.Get(TraceparentHeader) != sc.Traceparent() {
+		t.Errorf("injected headers = %v", out)
+	}
+}
+
+func TestRatioSampled(t *testing.T) {
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		if ratioSampled(newTraceID(), 0.25) {
+			sampled++
+		}
+	}
+	if sampled < 2000 || sampled > 3000 {
+		t.Errorf("sampled %d of 10000 at ratio 0.25", sampled)
+	}
+	if ratioSampled(newTraceID(), 0) || !ratioSampled(newTraceID(), 1) {
+		t.Error("ratio bounds not honored")
+	}
+}
+
+func TestExporterPostsOTLPJSON(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "Bearer t" {
+			t.Errorf("request %s auth=%q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		b, _ := io.ReadAll(r.Body)
+		bodies <- b
+	}))
+	defer collector.Close()
+
+	tr := New(Config{
+		ServiceName:   "edge",
+		Endpoint:      collector.URL + "/v1/traces",
+		Headers:       map[string]string{"Authorization": "Bearer t"},
+		Sampler:       SamplerAlwaysOn,
+		FlushInterval: time.Hour,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		tr.Run(ctx)
+		close(done)
+	}()
+
+	rctx, server := tr.StartServer(context.Background(), "GET /users/{id}", http.Header{})
+	_, child := Start(rctx, "repository.GetRoute", KindInternal)
+	child.RecordError(errors.New("db down"))
+	child.End()
+	server.SetAttr("http.response.status_code", 200)
+	server.End()
+	cancel() // shutdown flushes the queue
+	<-done
+
+	var req otlpRequest
+	if err := json.Unmarshal(<-bodies, &req); err != nil {
+		t.Fatal(err)
+	}
+	rs := req.ResourceSpans[0]
+	if v := rs.Resource.Attributes[0].Value.StringValue; v == nil || *v != "edge" {
+		t.Errorf("service.name = %v", v)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	repo, root := spans[0], spans[1]
+	if repo.ParentSpanID != root.SpanID || repo.TraceID != root.TraceID || root.ParentSpanID != "" {
+		t.Errorf("parent/child IDs wrong: %+v %+v", repo, root)
+	}
+	if repo.Status == nil || repo.Status.Code != 2 || !strings.Contains(repo.Status.Message, "db down") {
+		t.Errorf("repo span status = %+v", repo.Status)
+	}
+	if root.Kind != int(KindServer) || root.Attributes[0].Value.IntValue == nil || *root.Attributes[0].Value.IntValue != "200" {
+		t.Errorf("root span = %+v", root)
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"FeatherProxy/app/internal/accesslog"
+	"FeatherProxy/app/internal/database"
+	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/proxy"
+
+	"gorm.io/gorm"
+)
+
+func GetAccessLogOptions(repo database.Repository, w http.ResponseWriter, _ *http.Request, sourceIDStr string) {
+	id, ok := parseUUIDParam(w, sourceIDStr, "invalid source server UUID")
+	if !ok {
+		return
+	}
+	if _, err := repo.GetSourceServer(id); !handleRepoGetError(w, err) {
+		return
+	}
+	opts, err := repo.GetAccessLogOptions(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondJSON(w, http.StatusOK, schema.AccessLogOptions{
+			SourceServerUUID: id,
+			Format:           accesslog.FormatCommon,
+			Output:           accesslog.OutputStdout,
+			SyslogFacility:   accesslog.DefaultSyslogFacility,
+		})
+		return
+	}
+	if !handleRepoGetError(w, err) {
+		return
+	}
+	respondJSON(w, http.StatusOK, opts)
+}
+
+func SetAccessLogOptions(repo database.Repository, w http.ResponseWriter, r *http.Request, sourceIDStr string) {
+	id, ok := parseUUIDParam(w, sourceIDStr, "invalid source server UUID")
+	if !ok {
+		return
+	}
+	if _, err := repo.GetSourceServer(id); !handleRepoGetError(w, err) {
+		return
+	}
+	var body struct {
+		Enabled        bool   `json:"enabled"`
+		Format         string `json:"format"`
+		Template       string `json:"template"`
+		Output         string `json:"output"`
+		FilePath       string `json:"file_path"`
+		MaxSizeMB      int    `json:"max_size_mb"`
+		RotateInterval string `json:"rotate_interval"`
+		MaxBackups     int    `json:"max_backups"`
+		MaxAgeDays     int    `json:"max_age_days"`
+		SyslogNetwork  string `json:"syslog_network"`
+		SyslogAddress  string `json:"syslog_address"`
+		SyslogFacility *int   `json:"syslog_facility"`
+		SyslogAppName  string `json:"syslog_app_name"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	opts := schema.AccessLogOptions{
+		SourceServerUUID: id,
+		Enabled:          body.Enabled,
+		Format:           body.Format,
+		Template:         body.Template,
+		Output:           body.Output,
+		FilePath:         strings.TrimSpace(body.FilePath),
+		MaxSizeMB:        body.MaxSizeMB,
+		RotateInterval:   strings.TrimSpace(body.RotateInterval),
+		MaxBackups:       body.MaxBackups,
+		MaxAgeDays:       body.MaxAgeDays,
+		SyslogNetwork:    body.SyslogNetwork,
+		SyslogAddress:    strings.TrimSpace(body.SyslogAddress),
+		SyslogFacility:   accesslog.DefaultSyslogFacility,
+		SyslogAppName:    strings.TrimSpace(body.SyslogAppName),
+	}
+	if body.SyslogFacility != nil {
+		opts.SyslogFacility = *body.SyslogFacility
+	}
+	if opts.Format == "" {
+		opts.Format = accesslog.FormatCommon
+	}
+	if opts.Output == "" {
+		opts.Output = accesslog.OutputStdout
+	}
+	if msg := validateAccessLogOptions(&opts); msg != "" {
+		respondJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if opts.Enabled && opts.Output != accesslog.OutputStdout {
+		if err := proxy.CheckAccessLog(&opts); err != nil {
+			respondJSONError(w, http.StatusBadRequest, "cannot open access log: "+err.Error())
+			return
+		}
+	}
+	if err := repo.SetAccessLogOptions(opts); err != nil {
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	current, _ := repo.GetAccessLogOptions(id)
+	respondJSON(w, http.StatusOK, current)
+}
+
+// validateAccessLogOptions returns an error message, or "" when opts is valid.
+func validateAccessLogOptions(opts *schema.AccessLogOptions) string {
+	switch opts.Format {
+	case accesslog.FormatCommon, accesslog.FormatCombined, accesslog.FormatJSON:
+	case accesslog.FormatTemplate:
+		if _, err := accesslog.ParseTemplate(opts.Template); err != nil {
+			return "invalid template: " + err.Error()
+		}
+	default:
+		return "format must be common, combined, json, or template"
+	}
+	switch opts.Output {
+	case accesslog.OutputStdout:
+	case accesslog.OutputFile:
+		if opts.FilePath == "" {
+			return "file_path required for file output"
+		}
+		if opts.MaxSizeMB < 0 || opts.MaxBackups < 0 || opts.MaxAgeDays < 0 {
+			return "max_size_mb, max_backups and max_age_days must not be negative"
+		}
+		if opts.RotateInterval != "" {
+			if d, err := time.ParseDuration(opts.RotateInterval); err != nil || d < time.Minute {
+				return "rotate_interval must be a duration of at least 1m (e.g. 24h)"
+			}
+		}
+	case accesslog.OutputSyslog:
+		switch opts.SyslogNetwork {
+		case "udp", "tcp", "unix", "unixgram":
+		default:
+			return "syslog_network must be udp, tcp, unix, or unixgram"
+		}
+		if opts.SyslogAddress == "" {
+			return "syslog_address required for syslog output"
+		}
+		if opts.SyslogFacility < 0 || opts.SyslogFacility > 23 {
+			return "syslog_facility must be between 0 and 23"
+		}
+	default:
+		return "output must be stdout, file, or syslog"
+	}
+	return ""
+}
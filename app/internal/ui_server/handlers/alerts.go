@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"FeatherProxy/app/internal/alerting"
+	"FeatherProxy/app/internal/database"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAlertWindowSeconds = 300
+	maxAlertWindowSeconds     = 24 * 60 * 60 // rules read raw stats; keep windows short
+	minAlertWindowSeconds     = 10
+)
+
+// ListAlertStatuses handles GET /api/alerts: the last evaluation of every enabled rule, firing first.
+func ListAlertStatuses(svc *alerting.Service, w http.ResponseWriter, _ *http.Request) {
+	if svc == nil {
+		respondJSONError(w, http.StatusServiceUnavailable, "alerting not available")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"alerts": svc.Statuses()})
+}
+
+// alertRuleBody is the create/update body of an alert rule. Omitted enabled means true.
+type alertRuleBody struct {
+	Name             string      `json:"name"`
+	Enabled          *bool       `json:"enabled"`
+	Metric           string      `json:"metric"`
+	Operator         string      `json:"operator"`
+	Threshold        float64     `json:"threshold"`
+	WindowSeconds    int         `json:"window_seconds"`
+	MinRequests      int64       `json:"min_requests"`
+	SourceServerUUID *uuid.UUID  `json:"source_server_uuid"`
+	RouteUUID        *uuid.UUID  `json:"route_uuid"`
+	TargetServerUUID *uuid.UUID  `json:"target_server_uuid"`
+	WebhookUUIDs     []uuid.UUID `json:"webhook_uuids"`
+}
+
+// apply validates the body and copies it onto rule. Writes 400 and returns false on bad input.
+func (b alertRuleBody) apply(repo database.Repository, w http.ResponseWriter, rule *schema.AlertRule) bool {
+	if b.Name == "" {
+		respondJSONError(w, http.StatusBadRequest, "name is required")
+		return false
+	}
+	if !slices.Contains(schema.AlertMetrics, b.Metric) {
+		respondJSONError(w, http.StatusBadRequest, "metric must be one of error_rate, latency_p95_ms, latency_p99_ms, request_count")
+		return false
+	}
+	if !slices.Contains(schema.AlertOperators, b.Operator) {
+		respondJSONError(w, http.StatusBadRequest, "operator must be one of >, >=, <, <=")
+		return false
+	}
+	if b.WindowSeconds == 0 {
+		b.WindowSeconds = defaultAlertWindowSeconds
+	}
+	if b.WindowSeconds < minAlertWindowSeconds || b.WindowSeconds > maxAlertWindowSeconds {
+		respondJSONError(w, http.StatusBadRequest, "window_seconds must be between 10 and 86400")
+		return false
+	}
+	if b.MinRequests < 0 {
+		respondJSONError(w, http.StatusBadRequest, "min_requests must not be negative")
+		return false
+	}
+	for _, id := range b.WebhookUUIDs {
+		if _, err := repo.GetAlertWebhook(id); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				respondJSONError(w, http.StatusBadRequest, "unknown webhook "+id.String())
+			} else {
+				respondJSONError(w, http.StatusInternalServerError, err.Error())
+			}
+			return false
+		}
+	}
+	rule.Name = b.Name
+	rule.Enabled = b.Enabled == nil || *b.Enabled
+	rule.Metric = b.Metric
+	rule.Operator = b.Operator
+	rule.Threshold = b.Threshold
+	rule.WindowSeconds = b.WindowSeconds
+	rule.MinRequests = b.MinRequests
+	rule.SourceServerUUID = b.SourceServerUUID
+	rule.RouteUUID = b.RouteUUID
+	rule.TargetServerUUID = b.TargetServerUUID
+	rule.WebhookUUIDs = b.WebhookUUIDs
+	if rule.WebhookUUIDs == nil {
+		rule.WebhookUUIDs = []uuid.UUID{}
+	}
+	return true
+}
+
+func ListAlertRules(repo database.Repository, w http.ResponseWriter, _ *http.Request) {
+	list, err := repo.ListAlertRules()
+	if err != nil {
+		uiLogger.Error("list alert rules failed", "error", err)
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if list == nil {
+		list = []schema.AlertRule{}
+	}
+	respondJSON(w, http.StatusOK, list)
+}
+
+func CreateAlertRule(repo database.Repository, w http.ResponseWriter, r *http.Request) {
+	var body alertRuleBody
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	rule := schema.AlertRule{AlertRuleUUID: uuid.New()}
+	if !body.apply(repo, w, &rule) {
+		return
+	}
+	if err := repo.CreateAlertRule(rule); err != nil {
+		uiLogger.Error("create alert rule failed", "error", err)
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	out, _ := repo.GetAlertRule(rule.AlertRuleUUID)
+	uiLogger.Info("alert rule created", "rule", rule.AlertRuleUUID, "name", rule.Name)
+	respondJSON(w, http.StatusCreated, out)
+}
+
+func GetAlertRule(repo database.Repository, w http.ResponseWriter, _ *http.Request, idStr string) {
+	id, ok := parseUUIDParam(w, idStr, "invalid alert rule UUID")
+	if !ok {
+		return
+	}
+	rule, err := repo.GetAlertRule(id)
+	if !handleRepoGetError(w, err) {
+		return
+	}
+	respondJSON(w, http.StatusOK, rule)
+}
+
+func UpdateAlertRule(repo database.Repository, w http.ResponseWriter, r *http.Request, idStr string) {
+	id, ok := parseUUIDParam(w, idStr, "invalid alert rule UUID")
+	if !ok {
+		return
+	}
+	rule, err := repo.GetAlertRule(id)
+	if !handleRepoGetError(w, err) {
+		return
+	}
+	var body alertRuleBody
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	if !body.apply(repo, w, &rule) {
+		return
+	}
+	if err := repo.UpdateAlertRule(rule); err != nil {
+		uiLogger.Error("update alert rule failed", "rule", id, "error", err)
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	out, _ := repo.GetAlertRule(id)
+	uiLogger.Info("alert rule updated", "rule", id)
+	respondJSON(w, http.StatusOK, out)
+}
+
+func DeleteAlertRule(repo database.Repository, w http.ResponseWriter, _ *http.Request, idStr string) {
+	id, ok := parseUUIDParam(w, idStr, "invalid alert rule UUID")
+	if !ok {
+		return
+	}
+	if err := repo.DeleteAlertRule(id); err != nil {
+		uiLogger.Error("delete alert rule failed", "rule", id, "error", err)
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	uiLogger.Info("alert rule deleted", "rule", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// alertWebhookBody is the create/update body of an alert webhook.
+type alertWebhookBody struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Template    string `json:"template"`
+	ContentType string `json:"content_type"`
+}
+
+// apply validates the body and copies it onto hook. Writes 400 and returns false on bad input.
+func (b alertWebhookBody) apply(w http.ResponseWriter, hook *schema.AlertWebhook) bool {
+	if b.Name == "" {
+		respondJSONError(w, http.StatusBadRequest, "name is required")
+		return false
+	}
+	if u, err := url.Parse(b.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		respondJSONError(w, http.StatusBadRequest, "url must be an absolute http or https URL")
+		return false
+	}
+	if _, err := alerting.ParseTemplate(b.Template); err != nil {
+		respondJSONError(w, http.StatusBadRequest, "invalid template: "+err.Error())
+		return false
+	}
+	hook.Name = b.Name
+	hook.URL = b.URL
+	hook.Template = b.Template
+	hook.ContentType = b.ContentType
+	return true
+}
+
+func ListAlertWebhooks(repo database.Repository, w http.ResponseWriter, _ *http.Request) {
+	list, err := repo.ListAlertWebhooks()
+	if err != nil {
+		uiLogger.Error("list alert webhooks failed", "error", err)
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if list == nil {
+		list = []schema.AlertWebhook{}
+	}
+	respondJSON(w, http.StatusOK, list)
+}
+
+func CreateAlertWebhook(repo database.Repository, w http.ResponseWriter, r *http.Request) {
+	var body alertWebhookBody
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	hook := schema.AlertWebhook{AlertWebhookUUID: uuid.New()}
+	if !body.apply(w, &hook) {
+		return
+	}
+	if err := repo.CreateAlertWebhook(hook); err != nil {
+		uiLogger.Error("create alert webhook failed", "error", err)
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	out, _ := repo.GetAlertWebhook(hook.AlertWebhookUUID)
+	uiLogger.Info("alert webhook created", "webhook", hook.AlertWebhookUUID, "name", hook.Name)
+	respondJSON(w, http.StatusCreated, out)
+}
+
+func GetAlertWebhook(repo database.Repository, w http.ResponseWriter, _ *http.Request, idStr string) {
+	id, ok := parseUUIDParam(w, idStr, "invalid alert webhook UUID")
+	if !ok {
+		return
+	}
+	hook, err := repo.GetAlertWebhook(id)
+	if !handleRepoGetError(w, err) {
+		return
+	}
+	respondJSON(w, http.StatusOK, hook)
+}
+
+func UpdateAlertWebhook(repo database.Repository, w http.ResponseWriter, r *http.Request, idStr string) {
+	id, ok := parseUUIDParam(w, idStr, "invalid alert webhook UUID")
+	if !ok {
+		return
+	}
+	hook, err := repo.GetAlertWebhook(id)
+	if !handleRepoGetError(w, err) {
+		return
+	}
+	var body alertWebhookBody
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	if !body.apply(w, &hook) {
+		return
+	}
+	if err := repo.UpdateAlertWebhook(hook); err != nil {
+		uiLogger.Error("update alert webhook failed", "webhook", id, "error", err)
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	out, _ := repo.GetAlertWebhook(id)
+	uiLogger.Info("alert webhook updated", "webhook", id)
+	respondJSON(w, http.StatusOK, out)
+}
+
+func DeleteAlertWebhook(repo database.Repository, w http.ResponseWriter, _ *http.Request, idStr string) {
+	id, ok := parseUUIDParam(w, idStr, "invalid alert webhook UUID")
+	if !ok {
+		return
+	}
+	if err := repo.DeleteAlertWebhook(id); err != nil {
+		uiLogger.Error("delete alert webhook failed", "webhook", id, "error", err)
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	uiLogger.Info("alert webhook deleted", "webhook", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListAlertSilences handles GET /api/alerts/silences: silences that have not ended yet.
+func ListAlertSilences(repo database.Repository, w http.ResponseWriter, _ *http.Request) {
+	list, err := repo.ListAlertSilences(time.Now())
+	if err != nil {
+		uiLogger.Error("list alert silences failed", "error", err)
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if list == nil {
+		list = []schema.AlertSilence{}
+	}
+	respondJSON(w, http.StatusOK, list)
+}
+
+// CreateAlertSilence handles POST /api/alerts/silences. The body names the rule (omit alert_rule_uuid to
+// silence every rule), starts_at (default now) and either ends_at or a duration such as "2h".
+func CreateAlertSilence(repo database.Repository, w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		AlertRuleUUID *uuid.UUID `json:"alert_rule_uuid"`
+		StartsAt      *time.Time `json:"starts_at"`
+		EndsAt        *time.Time `json:"ends_at"`
+		Duration      string     `json:"duration"`
+		Comment       string     `json:"comment"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	s := schema.AlertSilence{AlertSilenceUUID: uuid.New(), AlertRuleUUID: body.AlertRuleUUID, StartsAt: time.Now(), Comment: body.Comment}
+	if body.StartsAt != nil {
+		s.StartsAt = *body.StartsAt
+	}
+	switch {
+	case body.EndsAt != nil:
+		s.EndsAt = *body.EndsAt
+	case body.Duration != "":
+		d, err := time.ParseDuration(body.Duration)
+		if err != nil || d <= 0 {
+			respondJSONError(w, http.StatusBadRequest, "invalid duration (use e.g. 2h)")
+			return
+		}
+		s.EndsAt = s.StartsAt.Add(d)
+	default:
+		respondJSONError(w, http.StatusBadRequest, "ends_at or duration is required")
+		return
+	}
+	if !s.EndsAt.After(s.StartsAt) || !s.EndsAt.After(time.Now()) {
+		respondJSONError(w, http.StatusBadRequest, "ends_at must be after starts_at and in the future")
+		return
+	}
+	if s.AlertRuleUUID != nil {
+		if _, err := repo.GetAlertRule(*s.AlertRuleUUID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				respondJSONError(w, http.StatusBadRequest, "unknown alert rule")
+			} else {
+				respondJSONError(w, http.StatusInternalServerError, err.Error())
+			}
+			return
+		}
+	}
+	if err := repo.CreateAlertSilence(s); err != nil {
+		uiLogger.Error("create alert silence failed", "error", err)
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	uiLogger.Info("alert silence created", "silence", s.AlertSilenceUUID, "rule", s.AlertRuleUUID, "ends_at", s.EndsAt)
+	respondJSON(w, http.StatusCreated, s)
+}
+
+func DeleteAlertSilence(repo database.Repository, w http.ResponseWriter, _ *http.Request, idStr string) {
+	id, ok := parseUUIDParam(w, idStr, "invalid alert silence UUID")
+	if !ok {
+		return
+	}
+	if err := repo.DeleteAlertSilence(id); err != nil {
+		uiLogger.Error("delete alert silence failed", "silence", id, "error", err)
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	uiLogger.Info("alert silence deleted", "silence", id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"errors"
-	"log"
 	"net/http"
 
 	"FeatherProxy/app/internal/database"
@@ -12,22 +11,22 @@ import (
 )
 
 func ListAuthentications(repo database.Repository, w http.ResponseWriter, _ *http.Request) {
-	log.Printf("api/auth: GET /api/authentications")
+	uiLogger.Debug("list authentications")
 	list, err := repo.ListAuthentications()
 	if err != nil {
-		log.Printf("api/auth: list authentications error: %v", err)
+		uiLogger.Error("list authentications failed", "error", err)
 		respondJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	if list == nil {
 		list = []schema.Authentication{}
 	}
-	log.Printf("api/auth: list ok count=%d", len(list))
+	uiLogger.Debug("list authentications ok", "count", len(list))
 	respondJSON(w, http.StatusOK, list)
 }
 
 func CreateAuthentication(repo database.Repository, w http.ResponseWriter, r *http.Request) {
-	log.Printf("api/auth: POST /api/authentications")
+	uiLogger.Debug("create authentication")
 	var body struct {
 		Name      string `json:"name"`
 		TokenType string `json:"token_type"`
@@ -51,21 +50,21 @@ func CreateAuthentication(repo database.Repository, w http.ResponseWriter, r *ht
 	}
 	if err := repo.CreateAuthentication(a); err != nil {
 		if errors.Is(err, database.ErrEncryptionKeyMissing) {
-			log.Printf("api/auth: create AUTH_ENCRYPTION_KEY missing")
+			uiLogger.Warn("create authentication: AUTH_ENCRYPTION_KEY missing")
 			respondJSONError(w, http.StatusServiceUnavailable, "authentication encryption not configured: set AUTH_ENCRYPTION_KEY")
 			return
 		}
-		log.Printf("api/auth: create error: %v", err)
+		uiLogger.Error("create authentication failed", "error", err)
 		respondJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	out, _ := repo.GetAuthentication(a.AuthenticationUUID)
-	log.Printf("api/auth: create ok id=%s", a.AuthenticationUUID)
+	uiLogger.Info("authentication created", "auth", a.AuthenticationUUID)
 	respondJSON(w, http.StatusCreated, out)
 }
 
 func GetAuthentication(repo database.Repository, w http.ResponseWriter, _ *http.Request, idStr string) {
-	log.Printf("api/auth: GET /api/authentications/%s", idStr)
+	uiLogger.Debug("get authentication", "auth", idStr)
 	id, ok := parseUUIDParam(w, idStr, "invalid authentication UUID")
 	if !ok {
 		return
@@ -78,7 +77,7 @@ func GetAuthentication(repo database.Repository, w http.ResponseWriter, _ *http.
 }
 
 func UpdateAuthentication(repo database.Repository, w http.ResponseWriter, r *http.Request, idStr string) {
-	log.Printf("api/auth: PUT /api/authentications/%s", idStr)
+	uiLogger.Debug("update authentication", "auth", idStr)
 	id, ok := parseUUIDParam(w, idStr, "invalid authentication UUID")
 	if !ok {
 		return
@@ -104,30 +103,30 @@ func UpdateAuthentication(repo database.Repository, w http.ResponseWriter, r *ht
 	}
 	if err := repo.UpdateAuthentication(existing); err != nil {
 		if errors.Is(err, database.ErrEncryptionKeyMissing) {
-			log.Printf("api/auth: update AUTH_ENCRYPTION_KEY missing")
+			uiLogger.Warn("update authentication: AUTH_ENCRYPTION_KEY missing")
 			respondJSONError(w, http.StatusServiceUnavailable, "authentication encryption not configured: set AUTH_ENCRYPTION_KEY")
 			return
 		}
-		log.Printf("api/auth: update error: %v", err)
+		uiLogger.Error("update authentication failed", "auth", id, "error", err)
 		respondJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	updated, _ := repo.GetAuthentication(id)
-	log.Printf("api/auth: update ok id=%s", id)
+	uiLogger.Info("authentication updated", "auth", id)
 	respondJSON(w, http.StatusOK, updated)
 }
 
 func DeleteAuthentication(repo database.Repository, w http.ResponseWriter, _ *http.Request, idStr string) {
-	log.Printf("api/auth: DELETE /api/authentications/%s", idStr)
+	uiLogger.Debug("delete authentication", "auth", idStr)
 	id, ok := parseUUIDParam(w, idStr, "invalid authentication UUID")
 	if !ok {
 		return
 	}
 	if err := repo.DeleteAuthentication(id); err != nil {
-		log.Printf("api/auth: delete error: %v", err)
+		uiLogger.Error("delete authentication failed", "auth", id, "error", err)
 		respondJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	log.Printf("api/auth: delete ok id=%s", id)
+	uiLogger.Info("authentication deleted", "auth", id)
 	w.WriteHeader(http.StatusNoContent)
 }
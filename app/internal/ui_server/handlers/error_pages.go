@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"FeatherProxy/app/internal/database"
+	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/proxy"
+
+	"gorm.io/gorm"
+)
+
+func GetErrorPages(repo database.Repository, w http.ResponseWriter, _ *http.Request, sourceIDStr string) {
+	id, ok := parseUUIDParam(w, sourceIDStr, "invalid source server UUID")
+	if !ok {
+		return
+	}
+	if _, err := repo.GetSourceServer(id); !handleRepoGetError(w, err) {
+		return
+	}
+	pages, err := repo.GetErrorPages(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondJSON(w, http.StatusOK, schema.ErrorPages{SourceServerUUID: id, Pages: map[string]schema.ErrorPage{}})
+		return
+	}
+	if !handleRepoGetError(w, err) {
+		return
+	}
+	respondJSON(w, http.StatusOK, pages)
+}
+
+func SetErrorPages(repo database.Repository, w http.ResponseWriter, r *http.Request, sourceIDStr string) {
+	id, ok := parseUUIDParam(w, sourceIDStr, "invalid source server UUID")
+	if !ok {
+		return
+	}
+	if _, err := repo.GetSourceServer(id); !handleRepoGetError(w, err) {
+		return
+	}
+	var body struct {
+		Pages              map[string]schema.ErrorPage `json:"pages"`
+		ReplaceUpstream5xx bool                        `json:"replace_upstream_5xx"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	for kind, page := range body.Pages {
+		if _, known := schema.ErrorKindStatus[kind]; !known {
+			respondJSONError(w, http.StatusBadRequest, "unknown error page kind: "+kind)
+			return
+		}
+		if err := proxy.ValidateTemplate(page.JSON, false); err != nil {
+			respondJSONError(w, http.StatusBadRequest, "invalid json template for "+kind+": "+err.Error())
+			return
+		}
+		if err := proxy.ValidateTemplate(page.HTML, true); err != nil {
+			respondJSONError(w, http.StatusBadRequest, "invalid html template for "+kind+": "+err.Error())
+			return
+		}
+	}
+	pages := schema.ErrorPages{
+		SourceServerUUID:   id,
+		Pages:              body.Pages,
+		ReplaceUpstream5xx: body.ReplaceUpstream5xx,
+	}
+	if err := repo.SetErrorPages(pages); err != nil {
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	current, _ := repo.GetErrorPages(id)
+	respondJSON(w, http.StatusOK, current)
+}
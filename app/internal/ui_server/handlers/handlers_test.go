@@ -1,16 +1,24 @@
 package handlers
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"FeatherProxy/app/internal/database"
 	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/logging"
+	"FeatherProxy/app/internal/stats"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -46,6 +54,31 @@ type mockRepo struct {
 	FnSetSourceAuthsForRoute   func(uuid.UUID, []uuid.UUID) error
 	FnGetTargetAuthForRoute    func(uuid.UUID) (uuid.UUID, bool, error)
 	FnSetTargetAuthForRoute    func(uuid.UUID, *uuid.UUID) error
+	FnGetTrafficSplit          func(uuid.UUID) (schema.TrafficSplit, error)
+	FnSetTrafficSplit          func(schema.TrafficSplit) error
+	FnGetRouteBalancing        func(uuid.UUID) (schema.RouteBalancing, error)
+	FnSetRouteBalancing        func(schema.RouteBalancing) error
+	FnGetErrorPages            func(uuid.UUID) (schema.ErrorPages, error)
+	FnSetErrorPages            func(schema.ErrorPages) error
+	FnGetMaintenance           func(string, uuid.UUID) (schema.Maintenance, error)
+	FnSetMaintenance           func(schema.Maintenance) error
+	FnGetProxyStatsByRequestID func(string) ([]schema.ProxyStat, error)
+	FnGetAccessLogOptions      func(uuid.UUID) (schema.AccessLogOptions, error)
+	FnSetAccessLogOptions      func(schema.AccessLogOptions) error
+	FnStatsLatency             func(string, schema.StatsFilter, int) ([]schema.LatencyStats, error)
+	FnStatsLatencyHistogram    func(schema.StatsFilter, []int64) ([]schema.LatencyBucket, error)
+	FnStatsByOutcome           func(string, schema.StatsFilter) ([]schema.OutcomeCount, error)
+	FnStatsTPS                 func(time.Time, time.Duration) ([]schema.BucketCount, error)
+	FnStreamProxyStats         func(schema.StatsFilter, func(schema.ProxyStat) error) error
+	FnListProxyStats           func(schema.StatsListQuery) (schema.StatsPage, error)
+	FnStatsByCountry           func(*time.Time, int) ([]schema.CountryCount, error)
+	FnStatsByASN               func(*time.Time, int) ([]schema.ASNCount, error)
+	FnListAlertRules           func() ([]schema.AlertRule, error)
+	FnGetAlertRule             func(uuid.UUID) (schema.AlertRule, error)
+	FnCreateAlertRule          func(schema.AlertRule) error
+	FnGetAlertWebhook          func(uuid.UUID) (schema.AlertWebhook, error)
+	FnCreateAlertWebhook       func(schema.AlertWebhook) error
+	FnCreateAlertSilence       func(schema.AlertSilence) error
 }
 
 func (m *mockRepo) ListSourceServers() ([]schema.SourceServer, error) {
@@ -216,6 +249,66 @@ func (m *mockRepo) SetTargetAuthForRoute(routeID uuid.UUID, authID *uuid.UUID) e
 	}
 	return nil
 }
+func (m *mockRepo) GetTrafficSplit(routeID uuid.UUID) (schema.TrafficSplit, error) {
+	if m.FnGetTrafficSplit != nil {
+		return m.FnGetTrafficSplit(routeID)
+	}
+	return schema.TrafficSplit{}, gorm.ErrRecordNotFound
+}
+func (m *mockRepo) SetTrafficSplit(split schema.TrafficSplit) error {
+	if m.FnSetTrafficSplit != nil {
+		return m.FnSetTrafficSplit(split)
+	}
+	return nil
+}
+func (m *mockRepo) GetRouteBalancing(routeID uuid.UUID) (schema.RouteBalancing, error) {
+	if m.FnGetRouteBalancing != nil {
+		return m.FnGetRouteBalancing(routeID)
+	}
+	return schema.RouteBalancing{}, gorm.ErrRecordNotFound
+}
+func (m *mockRepo) SetRouteBalancing(b schema.RouteBalancing) error {
+	if m.FnSetRouteBalancing != nil {
+		return m.FnSetRouteBalancing(b)
+	}
+	return nil
+}
+func (m *mockRepo) GetErrorPages(id uuid.UUID) (schema.ErrorPages, error) {
+	if m.FnGetErrorPages != nil {
+		return m.FnGetErrorPages(id)
+	}
+	return schema.ErrorPages{}, gorm.ErrRecordNotFound
+}
+func (m *mockRepo) SetErrorPages(p schema.ErrorPages) error {
+	if m.FnSetErrorPages != nil {
+		return m.FnSetErrorPages(p)
+	}
+	return nil
+}
+func (m *mockRepo) GetAccessLogOptions(id uuid.UUID) (schema.AccessLogOptions, error) {
+	if m.FnGetAccessLogOptions != nil {
+		return m.FnGetAccessLogOptions(id)
+	}
+	return schema.AccessLogOptions{}, gorm.ErrRecordNotFound
+}
+func (m *mockRepo) SetAccessLogOptions(opts schema.AccessLogOptions) error {
+	if m.FnSetAccessLogOptions != nil {
+		return m.FnSetAccessLogOptions(opts)
+	}
+	return nil
+}
+func (m *mockRepo) GetMaintenance(scope string, id uuid.UUID) (schema.Maintenance, error) {
+	if m.FnGetMaintenance != nil {
+		return m.FnGetMaintenance(scope, id)
+	}
+	return schema.Maintenance{}, gorm.ErrRecordNotFound
+}
+func (m *mockRepo) SetMaintenance(mt schema.Maintenance) error {
+	if m.FnSetMaintenance != nil {
+		return m.FnSetMaintenance(mt)
+	}
+	return nil
+}
 
 // Unused by handlers but required by interface
 func (m *mockRepo) GetRouteFromSourcePath(string) (schema.Route, error) {
@@ -235,8 +328,17 @@ func (m *mockRepo) GetTargetAuthenticationWithPlainToken(uuid.UUID) (schema.Auth
 }
 
 func (m *mockRepo) CreateProxyStats([]schema.ProxyStat) error { return nil }
-func (m *mockRepo) ListProxyStats(limit, offset int, since *time.Time) ([]schema.ProxyStat, int64, error) {
-	return nil, 0, nil
+func (m *mockRepo) ListProxyStats(q schema.StatsListQuery) (schema.StatsPage, error) {
+	if m.FnListProxyStats != nil {
+		return m.FnListProxyStats(q)
+	}
+	return schema.StatsPage{}, nil
+}
+func (m *mockRepo) GetProxyStatsByRequestID(id string) ([]schema.ProxyStat, error) {
+	if m.FnGetProxyStatsByRequestID != nil {
+		return m.FnGetProxyStatsByRequestID(id)
+	}
+	return nil, nil
 }
 func (m *mockRepo) DeleteProxyStatsOlderThan(time.Time) (int64, error) { return 0, nil }
 func (m *mockRepo) ClearAllProxyStats() error                 { return nil }
@@ -245,9 +347,113 @@ func (m *mockRepo) StatsSummary() (schema.StatsSummary, error) {
 }
 func (m *mockRepo) StatsByRoute(*time.Time, int) ([]schema.RouteCount, error) { return nil, nil }
 func (m *mockRepo) StatsByCaller(*time.Time, int) ([]schema.CallerCount, error) { return nil, nil }
+func (m *mockRepo) StatsByCountry(since *time.Time, limit int) ([]schema.CountryCount, error) {
+	if m.FnStatsByCountry != nil {
+		return m.FnStatsByCountry(since, limit)
+	}
+	return nil, nil
+}
+func (m *mockRepo) StatsByASN(since *time.Time, limit int) ([]schema.ASNCount, error) {
+	if m.FnStatsByASN != nil {
+		return m.FnStatsByASN(since, limit)
+	}
+	return nil, nil
+}
 func (m *mockRepo) StatsBySourceServer(*time.Time) ([]schema.ServerCount, error) { return nil, nil }
 func (m *mockRepo) StatsByTargetServer(*time.Time) ([]schema.ServerCount, error) { return nil, nil }
-func (m *mockRepo) StatsTPS(time.Time, time.Duration) ([]schema.BucketCount, error) { return nil, nil }
+func (m *mockRepo) StatsByVariant(*uuid.UUID, *time.Time) ([]schema.VariantCount, error) {
+	return nil, nil
+}
+func (m *mockRepo) StatsTPS(since time.Time, bucket time.Duration) ([]schema.BucketCount, error) {
+	if m.FnStatsTPS != nil {
+		return m.FnStatsTPS(since, bucket)
+	}
+	return nil, nil
+}
+func (m *mockRepo) StatsLatency(groupBy string, f schema.StatsFilter, limit int) ([]schema.LatencyStats, error) {
+	if m.FnStatsLatency != nil {
+		return m.FnStatsLatency(groupBy, f, limit)
+	}
+	return nil, nil
+}
+func (m *mockRepo) StatsLatencyHistogram(f schema.StatsFilter, bounds []int64) ([]schema.LatencyBucket, error) {
+	if m.FnStatsLatencyHistogram != nil {
+		return m.FnStatsLatencyHistogram(f, bounds)
+	}
+	return nil, nil
+}
+func (m *mockRepo) StreamProxyStats(f schema.StatsFilter, fn func(schema.ProxyStat) error) error {
+	if m.FnStreamProxyStats != nil {
+		return m.FnStreamProxyStats(f, fn)
+	}
+	return nil
+}
+func (m *mockRepo) RollupProxyStats(time.Time) error { return nil }
+func (m *mockRepo) StatsStatusCounts(schema.StatsFilter) (schema.StatusCounts, error) {
+	return schema.StatusCounts{}, nil
+}
+func (m *mockRepo) OldestProxyStat() (*time.Time, error) {
+	return nil, nil
+}
+func (m *mockRepo) ListAlertRules() ([]schema.AlertRule, error) {
+	if m.FnListAlertRules != nil {
+		return m.FnListAlertRules()
+	}
+	return nil, nil
+}
+func (m *mockRepo) GetAlertRule(id uuid.UUID) (schema.AlertRule, error) {
+	if m.FnGetAlertRule != nil {
+		return m.FnGetAlertRule(id)
+	}
+	return schema.AlertRule{}, gorm.ErrRecordNotFound
+}
+func (m *mockRepo) CreateAlertRule(rule schema.AlertRule) error {
+	if m.FnCreateAlertRule != nil {
+		return m.FnCreateAlertRule(rule)
+	}
+	return nil
+}
+func (m *mockRepo) UpdateAlertRule(schema.AlertRule) error { return nil }
+func (m *mockRepo) DeleteAlertRule(uuid.UUID) error       { return nil }
+func (m *mockRepo) ListAlertWebhooks() ([]schema.AlertWebhook, error) {
+	return nil, nil
+}
+func (m *mockRepo) GetAlertWebhook(id uuid.UUID) (schema.AlertWebhook, error) {
+	if m.FnGetAlertWebhook != nil {
+		return m.FnGetAlertWebhook(id)
+	}
+	return schema.AlertWebhook{}, gorm.ErrRecordNotFound
+}
+func (m *mockRepo) CreateAlertWebhook(hook schema.AlertWebhook) error {
+	if m.FnCreateAlertWebhook != nil {
+		return m.FnCreateAlertWebhook(hook)
+	}
+	return nil
+}
+func (m *mockRepo) UpdateAlertWebhook(schema.AlertWebhook) error { return nil }
+func (m *mockRepo) DeleteAlertWebhook(uuid.UUID) error         { return nil }
+func (m *mockRepo) ListAlertSilences(time.Time) ([]schema.AlertSilence, error) {
+	return nil, nil
+}
+func (m *mockRepo) CreateAlertSilence(s schema.AlertSilence) error {
+	if m.FnCreateAlertSilence != nil {
+		return m.FnCreateAlertSilence(s)
+	}
+	return nil
+}
+func (m *mockRepo) DeleteAlertSilence(uuid.UUID) error { return nil }
+func (m *mockRepo) DeleteAlertSilencesEndedBefore(time.Time) (int64, error) {
+	return 0, nil
+}
+func (m *mockRepo) DeleteStatsRollupsOlderThan(string, time.Time) (int64, error) {
+	return 0, nil
+}
+func (m *mockRepo) StatsByOutcome(groupBy string, f schema.StatsFilter) ([]schema.OutcomeCount, error) {
+	if m.FnStatsByOutcome != nil {
+		return m.FnStatsByOutcome(groupBy, f)
+	}
+	return nil, nil
+}
 
 var _ database.Repository = (*mockRepo)(nil)
 
@@ -401,6 +607,55 @@ func TestGetACLOptions_ok(t *testing.T) {
 	}
 }
 
+func TestSetServerOptions_forwardHeaders(t *testing.T) {
+	id := uuid.New()
+	var saved schema.ServerOptions
+	repo := &mockRepo{
+		FnGetSourceServer: func(uuid.UUID) (schema.SourceServer, error) {
+			return schema.SourceServer{SourceServerUUID: id}, nil
+		},
+		FnSetServerOptions: func(opts schema.ServerOptions) error {
+			saved = opts
+			return nil
+		},
+	}
+	body := `{"forwarded_for_header":" x-client-chain ","forwarded_port_header":"-","forwarded_header":""}`
+	w := httptest.NewRecorder()
+	SetServerOptions(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), id.String())
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if saved.ForwardedForHeader != "X-Client-Chain" || saved.ForwardedPortHeader != schema.ForwardHeaderOff || saved.ForwardedHeader != "" {
+		t.Errorf("saved = %+v", saved)
+	}
+}
+
+func TestSetServerOptions_rejectsBadForwardHeaders(t *testing.T) {
+	id := uuid.New()
+	repo := &mockRepo{
+		FnGetSourceServer: func(uuid.UUID) (schema.SourceServer, error) {
+			return schema.SourceServer{SourceServerUUID: id}, nil
+		},
+		FnSetServerOptions: func(schema.ServerOptions) error {
+			t.Error("SetServerOptions called for invalid options")
+			return nil
+		},
+	}
+	for _, body := range []string{
+		`{"forwarded_for_header":"X Client"}`,
+		`{"forwarded_host_header":"Host"}`,
+		`{"forwarded_proto_header":"Connection"}`,
+		`{"forwarded_for_header":"X-Client","forwarded_port_header":"x-client"}`,
+		`{"forwarded_header":"X-Forwarded-For"}`,
+	} {
+		w := httptest.NewRecorder()
+		SetServerOptions(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), id.String())
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, w.Code)
+		}
+	}
+}
+
 func TestSetACLOptions_ok(t *testing.T) {
 	id := uuid.New()
 	var saved schema.ACLOptions
@@ -416,15 +671,54 @@ func TestSetACLOptions_ok(t *testing.T) {
 			return saved, nil
 		},
 	}
-	body := `{"mode":"deny_only","client_ip_header":"X-Real-IP","allow_list":[],"deny_list":["10.0.0.1"]}`
+	body := `{"mode":"deny_only","client_ip_header":"X-Real-IP","allow_list":[],"deny_list":["10.0.0.1"],"trusted_proxies":[" 10.0.0.0/8","","2001:db8::1"]}`
 	w := httptest.NewRecorder()
-	SetACLOptions(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), id.String())
+	SetACLOptions(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), id.String(), false)
 	if w.Code != http.StatusOK {
 		t.Errorf("status = %d, want 200", w.Code)
 	}
 	if saved.Mode != "deny_only" || saved.ClientIPHeader != "X-Real-IP" || len(saved.DenyList) != 1 || saved.DenyList[0] != "10.0.0.1" {
 		t.Errorf("saved = %+v", saved)
 	}
+	if len(saved.TrustedProxies) != 2 || saved.TrustedProxies[0] != "10.0.0.0/8" {
+		t.Errorf("trusted proxies = %v", saved.TrustedProxies)
+	}
+
+	body = `{"mode":"off","trusted_proxies":["proxy.internal"]}`
+	w = httptest.NewRecorder()
+	SetACLOptions(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), id.String(), false)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("hostname trusted proxy: status = %d, want 400", w.Code)
+	}
+}
+
+func TestSetACLOptions_geoEntries(t *testing.T) {
+	id := uuid.New()
+	repo := &mockRepo{
+		FnGetSourceServer: func(uuid.UUID) (schema.SourceServer, error) {
+			return schema.SourceServer{SourceServerUUID: id}, nil
+		},
+		FnSetACLOptions: func(schema.ACLOptions) error { return nil },
+		FnGetACLOptions: func(uuid.UUID) (schema.ACLOptions, error) { return schema.ACLOptions{}, nil },
+	}
+	for _, tt := range []struct {
+		body  string
+		geoIP bool
+		want  int
+	}{
+		{`{"mode":"deny_only","deny_list":["country:RU","asn:AS14061","asn:64512"]}`, true, http.StatusOK},
+		{`{"mode":"deny_only","deny_list":["country:RU"]}`, false, http.StatusBadRequest},
+		{`{"mode":"allow_only","allow_list":["asn:64512"]}`, false, http.StatusBadRequest},
+		{`{"mode":"deny_only","deny_list":["country:Russia"]}`, true, http.StatusBadRequest},
+		{`{"mode":"deny_only","deny_list":["asn:notanumber"]}`, true, http.StatusBadRequest},
+		{`{"mode":"deny_only","deny_list":["10.0.0.0/8","*.internal"]}`, false, http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		SetACLOptions(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(tt.body))), id.String(), tt.geoIP)
+		if w.Code != tt.want {
+			t.Errorf("%s (geoip %v): status = %d, want %d: %s", tt.body, tt.geoIP, w.Code, tt.want, w.Body)
+		}
+	}
 }
 
 func TestSetACLOptions_invalidMode(t *testing.T) {
@@ -436,7 +730,7 @@ func TestSetACLOptions_invalidMode(t *testing.T) {
 	}
 	body := `{"mode":"invalid","allow_list":[],"deny_list":[]}`
 	w := httptest.NewRecorder()
-	SetACLOptions(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), id.String())
+	SetACLOptions(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), id.String(), false)
 	if w.Code != http.StatusBadRequest {
 		t.Errorf("status = %d, want 400", w.Code)
 	}
@@ -465,13 +759,13 @@ func TestCreateTargetServer(t *testing.T) {
 			return nil
 		},
 	}
-	body := `{"name":"backend","protocol":"https","host":"api.example.com","port":443,"base_path":"/v1"}`
+	body := `{"name":"backend","protocol":"https","host":"api.example.com","port":443,"base_path":"/v1","preserve_host":true}`
 	w := httptest.NewRecorder()
 	CreateTargetServer(repo, w, httptest.NewRequest(http.MethodPost, "/api/target-servers", bytes.NewReader([]byte(body))))
 	if w.Code != http.StatusCreated {
 		t.Errorf("status = %d, want 201", w.Code)
 	}
-	if created.Name != "backend" || created.Port != 443 {
+	if created.Name != "backend" || created.Port != 443 || !created.PreserveHost {
 		t.Errorf("created = %+v", created)
 	}
 }
@@ -540,6 +834,43 @@ func TestCreateRoute_protocolMismatch(t *testing.T) {
 	}
 }
 
+func TestCreateRoute_static(t *testing.T) {
+	var created schema.Route
+	repo := &mockRepo{
+		FnCreateRoute: func(r schema.Route) error {
+			created = r
+			return nil
+		},
+	}
+	body := `{"source_server_uuid":"` + uuid.New().String() + `","method":"GET","source_path":"/maintenance","kind":"static","response":{"status":503,"body":"{\"status\":\"down\"}"}}`
+	w := httptest.NewRecorder()
+	CreateRoute(repo, w, httptest.NewRequest(http.MethodPost, "/api/routes", bytes.NewReader([]byte(body))))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201 (%s)", w.Code, w.Body.String())
+	}
+	if created.Kind != schema.RouteKindStatic || created.TargetServerUUID != uuid.Nil || created.Response == nil || created.Response.Status != 503 {
+		t.Errorf("created = %+v", created)
+	}
+}
+
+func TestCreateRoute_redirectValidation(t *testing.T) {
+	repo := &mockRepo{}
+	source := uuid.New().String()
+	bodies := []string{
+		`{"source_server_uuid":"` + source + `","method":"GET","source_path":"/old","kind":"redirect","response":{"status":200,"redirect_url":"/new"}}`,
+		`{"source_server_uuid":"` + source + `","method":"GET","source_path":"/old","kind":"redirect","response":{"status":301}}`,
+		`{"source_server_uuid":"` + source + `","method":"GET","source_path":"/old","kind":"redirect","response":{"redirect_url":"/new/{{.Params.id"}}`,
+		`{"source_server_uuid":"` + source + `","method":"GET","source_path":"/old","kind":"unknown"}`,
+	}
+	for _, body := range bodies {
+		w := httptest.NewRecorder()
+		CreateRoute(repo, w, httptest.NewRequest(http.MethodPost, "/api/routes", bytes.NewReader([]byte(body))))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("body %s: status = %d, want 400", body, w.Code)
+		}
+	}
+}
+
 func TestGetRoute_notFound(t *testing.T) {
 	repo := &mockRepo{}
 	w := httptest.NewRecorder()
@@ -627,3 +958,726 @@ func TestGetRouteTargetAuth(t *testing.T) {
 		t.Errorf("status = %d, want 200", w.Code)
 	}
 }
+
+// --- Traffic split ---
+
+func TestGetRouteTrafficSplit_notConfigured(t *testing.T) {
+	routeID := uuid.New()
+	repo := &mockRepo{
+		FnGetRoute: func(uuid.UUID) (schema.Route, error) { return schema.Route{RouteUUID: routeID}, nil },
+	}
+	w := httptest.NewRecorder()
+	GetRouteTrafficSplit(repo, w, httptest.NewRequest(http.MethodGet, "/", nil), routeID.String())
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", w.Code)
+	}
+	var got schema.TrafficSplit
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.RouteUUID != routeID || len(got.Rules) != 0 {
+		t.Errorf("got = %+v", got)
+	}
+}
+
+func TestPutRouteTrafficSplit_ok(t *testing.T) {
+	routeID := uuid.New()
+	targetID := uuid.New()
+	var saved schema.TrafficSplit
+	repo := &mockRepo{
+		FnGetRoute:        func(uuid.UUID) (schema.Route, error) { return schema.Route{RouteUUID: routeID}, nil },
+		FnGetTargetServer: func(uuid.UUID) (schema.TargetServer, error) { return schema.TargetServer{TargetServerUUID: targetID}, nil },
+		FnSetTrafficSplit: func(s schema.TrafficSplit) error {
+			saved = s
+			return nil
+		},
+		FnGetTrafficSplit: func(uuid.UUID) (schema.TrafficSplit, error) { return saved, nil },
+	}
+	body := `{"sticky":true,"rules":[{"variant":"canary","target_server_uuid":"` + targetID.String() + `","weight":5,"header":"X-Canary","header_value":"1"}]}`
+	w := httptest.NewRecorder()
+	PutRouteTrafficSplit(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), routeID.String())
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body.String())
+	}
+	if !saved.Sticky || len(saved.Rules) != 1 || saved.Rules[0].Variant != "canary" || saved.Rules[0].Weight != 5 {
+		t.Errorf("saved = %+v", saved)
+	}
+}
+
+func TestPutRouteTrafficSplit_invalid(t *testing.T) {
+	routeID := uuid.New()
+	targetID := uuid.New()
+	repo := &mockRepo{
+		FnGetRoute:        func(uuid.UUID) (schema.Route, error) { return schema.Route{RouteUUID: routeID}, nil },
+		FnGetTargetServer: func(uuid.UUID) (schema.TargetServer, error) { return schema.TargetServer{TargetServerUUID: targetID}, nil },
+	}
+	bodies := []string{
+		`{"rules":[{"variant":"primary","target_server_uuid":"` + targetID.String() + `","weight":5}]}`,
+		`{"rules":[{"variant":"a","target_server_uuid":"` + targetID.String() + `","weight":60},{"variant":"b","target_server_uuid":"` + targetID.String() + `","weight":60}]}`,
+		`{"rules":[{"variant":"a","weight":5}]}`,
+	}
+	for _, body := range bodies {
+		w := httptest.NewRecorder()
+		PutRouteTrafficSplit(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), routeID.String())
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("body %s: status = %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestPutRouteTrafficSplit_protocolMismatch(t *testing.T) {
+	routeID := uuid.New()
+	targetID := uuid.New()
+	repo := &mockRepo{
+		FnGetRoute:        func(uuid.UUID) (schema.Route, error) { return schema.Route{RouteUUID: routeID}, nil },
+		FnGetTargetServer: func(uuid.UUID) (schema.TargetServer, error) { return schema.TargetServer{TargetServerUUID: targetID}, nil },
+		FnSetTrafficSplit: func(schema.TrafficSplit) error { return database.ErrProtocolMismatch },
+	}
+	body := `{"rules":[{"variant":"canary","target_server_uuid":"` + targetID.String() + `","weight":5}]}`
+	w := httptest.NewRecorder()
+	PutRouteTrafficSplit(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), routeID.String())
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
+
+// --- Route balancing ---
+
+func TestPutRouteBalancing_ok(t *testing.T) {
+	routeID := uuid.New()
+	primaryID := uuid.New()
+	extraID := uuid.New()
+	var saved schema.RouteBalancing
+	repo := &mockRepo{
+		FnGetRoute: func(uuid.UUID) (schema.Route, error) {
+			return schema.Route{RouteUUID: routeID, TargetServerUUID: primaryID}, nil
+		},
+		FnGetTargetServer: func(id uuid.UUID) (schema.TargetServer, error) { return schema.TargetServer{TargetServerUUID: id}, nil },
+		FnSetRouteBalancing: func(b schema.RouteBalancing) error {
+			saved = b
+			return nil
+		},
+		FnGetRouteBalancing: func(uuid.UUID) (schema.RouteBalancing, error) { return saved, nil },
+	}
+	body := `{"target_server_uuids":["` + primaryID.String() + `","` + extraID.String() + `"],"affinity":"cookie","cookie_ttl_seconds":3600}`
+	w := httptest.NewRecorder()
+	PutRouteBalancing(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), routeID.String())
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body.String())
+	}
+	if saved.Affinity != schema.AffinityCookie || len(saved.TargetServerUUIDs) != 1 || saved.TargetServerUUIDs[0] != extraID {
+		t.Errorf("saved = %+v", saved)
+	}
+}
+
+func TestPutRouteBalancing_protocolMismatch(t *testing.T) {
+	routeID := uuid.New()
+	extraID := uuid.New()
+	repo := &mockRepo{
+		FnGetRoute:          func(uuid.UUID) (schema.Route, error) { return schema.Route{RouteUUID: routeID}, nil },
+		FnGetTargetServer:   func(id uuid.UUID) (schema.TargetServer, error) { return schema.TargetServer{TargetServerUUID: id}, nil },
+		FnSetRouteBalancing: func(schema.RouteBalancing) error { return database.ErrProtocolMismatch },
+	}
+	body := `{"target_server_uuids":["` + extraID.String() + `"]}`
+	w := httptest.NewRecorder()
+	PutRouteBalancing(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), routeID.String())
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
+
+func TestPutRouteBalancing_hashWithoutKey(t *testing.T) {
+	routeID := uuid.New()
+	repo := &mockRepo{
+		FnGetRoute: func(uuid.UUID) (schema.Route, error) { return schema.Route{RouteUUID: routeID}, nil },
+	}
+	w := httptest.NewRecorder()
+	PutRouteBalancing(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(`{"affinity":"hash_header"}`))), routeID.String())
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
+
+func TestSetErrorPages_ok(t *testing.T) {
+	sourceID := uuid.New()
+	var saved schema.ErrorPages
+	repo := &mockRepo{
+		FnGetSourceServer: func(uuid.UUID) (schema.SourceServer, error) {
+			return schema.SourceServer{SourceServerUUID: sourceID}, nil
+		},
+		FnSetErrorPages: func(p schema.ErrorPages) error {
+			saved = p
+			return nil
+		},
+		FnGetErrorPages: func(uuid.UUID) (schema.ErrorPages, error) { return saved, nil },
+	}
+	body := `{"pages":{"no_route":{"json":"{\"error\":\"nothing at {{.Path}}\"}"}},"replace_upstream_5xx":true}`
+	w := httptest.NewRecorder()
+	SetErrorPages(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), sourceID.String())
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body.String())
+	}
+	if !saved.ReplaceUpstream5xx || saved.Pages[schema.ErrorKindNoRoute].JSON == "" {
+		t.Errorf("saved = %+v", saved)
+	}
+}
+
+func TestSetErrorPages_invalid(t *testing.T) {
+	repo := &mockRepo{
+		FnGetSourceServer: func(id uuid.UUID) (schema.SourceServer, error) {
+			return schema.SourceServer{SourceServerUUID: id}, nil
+		},
+	}
+	bodies := []string{
+		`{"pages":{"teapot":{"json":"{}"}}}`,
+		`{"pages":{"bad_gateway":{"html":"{{.Status"}}}`,
+	}
+	for _, body := range bodies {
+		w := httptest.NewRecorder()
+		SetErrorPages(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), uuid.New().String())
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("body %s: status = %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestSetAccessLogOptions_ok(t *testing.T) {
+	sourceID := uuid.New()
+	var saved schema.AccessLogOptions
+	repo := &mockRepo{
+		FnGetSourceServer: func(uuid.UUID) (schema.SourceServer, error) {
+			return schema.SourceServer{SourceServerUUID: sourceID}, nil
+		},
+		FnSetAccessLogOptions: func(o schema.AccessLogOptions) error {
+			saved = o
+			return nil
+		},
+		FnGetAccessLogOptions: func(uuid.UUID) (schema.AccessLogOptions, error) { return saved, nil },
+	}
+	path := filepath.Join(t.TempDir(), "fp", "access.log")
+	body := `{"enabled":true,"output":"file","file_path":" ` + path + ` ","max_size_mb":100,"rotate_interval":"24h","max_backups":7}`
+	w := httptest.NewRecorder()
+	SetAccessLogOptions(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), sourceID.String())
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body.String())
+	}
+	if saved.Format != "common" || saved.FilePath != path || saved.SyslogFacility != 16 {
+		t.Errorf("saved = %+v", saved)
+	}
+}
+
+func TestSetAccessLogOptions_invalid(t *testing.T) {
+	notADir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(notADir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	repo := &mockRepo{
+		FnGetSourceServer: func(id uuid.UUID) (schema.SourceServer, error) {
+			return schema.SourceServer{SourceServerUUID: id}, nil
+		},
+	}
+	bodies := []string{
+		`{"format":"xml"}`,
+		`{"format":"template","template":"{{.Status"}`,
+		`{"output":"file"}`,
+		`{"output":"file","file_path":"/tmp/a.log","rotate_interval":"10s"}`,
+		`{"output":"syslog","syslog_network":"udp"}`,
+		`{"output":"syslog","syslog_network":"udp","syslog_address":"127.0.0.1:514","syslog_facility":24}`,
+		`{"enabled":true,"output":"file","file_path":"` + filepath.Join(notADir, "access.log") + `"}`,
+		`{"enabled":true,"output":"syslog","syslog_network":"unix","syslog_address":"` + filepath.Join(t.TempDir(), "no.sock") + `"}`,
+	}
+	for _, body := range bodies {
+		w := httptest.NewRecorder()
+		SetAccessLogOptions(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), uuid.New().String())
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("body %s: status = %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestSetLogging(t *testing.T) {
+	before := logging.Levels()
+	t.Cleanup(func() {
+		for name, level := range before {
+			l, _ := logging.ParseLevel(level)
+			_ = logging.SetLevel(name, l)
+		}
+	})
+	w := httptest.NewRecorder()
+	SetLogging(w, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"levels":{"proxy":"debug","cache":"off"}}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Levels map[string]string `json:"levels"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Levels["proxy"] != "debug" || resp.Levels["cache"] != "off" || resp.Levels["repo"] != before["repo"] {
+		t.Errorf("levels = %v", resp.Levels)
+	}
+	for _, body := range []string{`{"levels":{"nope":"info"}}`, `{"levels":{"proxy":"loud"}}`} {
+		w := httptest.NewRecorder()
+		SetLogging(w, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("body %s: status = %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestPutRouteMaintenance_ok(t *testing.T) {
+	routeID := uuid.New()
+	var saved schema.Maintenance
+	repo := &mockRepo{
+		FnGetRoute: func(uuid.UUID) (schema.Route, error) { return schema.Route{RouteUUID: routeID}, nil },
+		FnSetMaintenance: func(m schema.Maintenance) error {
+			saved = m
+			return nil
+		},
+		FnGetMaintenance: func(string, uuid.UUID) (schema.Maintenance, error) { return saved, nil },
+	}
+	body := `{"enabled":true,"body":"back soon","retry_after_seconds":300,"starts_at":"2025-06-01T10:00:00Z","ends_at":"2025-06-01T12:00:00Z","allow_list":["10.0.0.0/8"," "]}`
+	w := httptest.NewRecorder()
+	PutRouteMaintenance(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), routeID.String())
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body.String())
+	}
+	if saved.Scope != schema.MaintenanceScopeRoute || saved.OwnerUUID != routeID || !saved.Enabled || len(saved.AllowList) != 1 {
+		t.Errorf("saved = %+v", saved)
+	}
+}
+
+func TestPutSourceServerMaintenance_invalidWindow(t *testing.T) {
+	repo := &mockRepo{
+		FnGetSourceServer: func(id uuid.UUID) (schema.SourceServer, error) {
+			return schema.SourceServer{SourceServerUUID: id}, nil
+		},
+	}
+	body := `{"enabled":true,"starts_at":"2025-06-01T12:00:00Z","ends_at":"2025-06-01T10:00:00Z"}`
+	w := httptest.NewRecorder()
+	PutSourceServerMaintenance(repo, w, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte(body))), uuid.New().String())
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
+
+func TestGetStatsByRequestID(t *testing.T) {
+	repo := &mockRepo{
+		FnGetProxyStatsByRequestID: func(id string) ([]schema.ProxyStat, error) {
+			if id == "known" {
+				return []schema.ProxyStat{{RequestID: id, Path: "/x"}}, nil
+			}
+			return nil, nil
+		},
+	}
+	w := httptest.NewRecorder()
+	GetStatsByRequestID(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/requests/known", nil), "known")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"request_id":"known"`) {
+		t.Errorf("known: status = %d body = %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	GetStatsByRequestID(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/requests/missing", nil), "missing")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing: status = %d, want 404", w.Code)
+	}
+}
+
+func TestGetStatsLatency(t *testing.T) {
+	var gotBy string
+	var gotFilter schema.StatsFilter
+	repo := &mockRepo{
+		FnStatsLatency: func(groupBy string, filter schema.StatsFilter, limit int) ([]schema.LatencyStats, error) {
+			gotBy, gotFilter = groupBy, filter
+			return []schema.LatencyStats{{Count: 3, P95Ms: 12.5}}, nil
+		},
+	}
+	routeID := uuid.New()
+	w := httptest.NewRecorder()
+	GetStatsLatency(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/latency?by=target-server&window=15m&route="+routeID.String(), nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"p95_ms":12.5`) {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if gotBy != schema.StatsGroupTargetServer || gotFilter.RouteUUID == nil || *gotFilter.RouteUUID != routeID {
+		t.Errorf("by = %q filter = %+v", gotBy, gotFilter)
+	}
+	if gotFilter.Since == nil || time.Since(*gotFilter.Since) < 14*time.Minute || time.Since(*gotFilter.Since) > 16*time.Minute {
+		t.Errorf("since = %v, want ~15m ago", gotFilter.Since)
+	}
+	for _, q := range []string{"by=host", "since=yesterday", "window=-1h", "route=nope"} {
+		w = httptest.NewRecorder()
+		GetStatsLatency(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/latency?"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", q, w.Code)
+		}
+	}
+}
+
+func TestGetStatsLatencyHistogram_buckets(t *testing.T) {
+	var gotBounds []int64
+	repo := &mockRepo{
+		FnStatsLatencyHistogram: func(filter schema.StatsFilter, boundsMs []int64) ([]schema.LatencyBucket, error) {
+			gotBounds = boundsMs
+			return nil, nil
+		},
+	}
+	w := httptest.NewRecorder()
+	GetStatsLatencyHistogram(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/latency/histogram?buckets=100,10,50,10", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"buckets":[]`) {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if len(gotBounds) != 3 || gotBounds[0] != 10 || gotBounds[1] != 50 || gotBounds[2] != 100 {
+		t.Errorf("bounds = %v, want [10 50 100]", gotBounds)
+	}
+	if strings.Contains(w.Body.String(), "raw_since") {
+		t.Errorf("rollup-compatible buckets report raw_since: %s", w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	GetStatsLatencyHistogram(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/latency/histogram?buckets=30", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"raw_since":null`) {
+		t.Errorf("raw-only buckets: status = %d body = %s, want raw_since", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	GetStatsLatencyHistogram(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/latency/histogram?buckets=0,10", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("zero bucket: status = %d, want 400", w.Code)
+	}
+}
+
+func TestGetStatsByOutcome(t *testing.T) {
+	var gotBy string
+	var gotFilter schema.StatsFilter
+	repo := &mockRepo{
+		FnStatsByOutcome: func(groupBy string, filter schema.StatsFilter) ([]schema.OutcomeCount, error) {
+			gotBy, gotFilter = groupBy, filter
+			return []schema.OutcomeCount{{Outcome: schema.OutcomeACLDenied, Count: 7}}, nil
+		},
+	}
+	w := httptest.NewRecorder()
+	GetStatsByOutcome(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/by-outcome?by=source_server&outcome=acl_denied", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"outcome":"acl_denied","count":7`) {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if gotBy != schema.StatsGroupSourceServer || gotFilter.Outcome != schema.OutcomeACLDenied {
+		t.Errorf("by = %q filter = %+v", gotBy, gotFilter)
+	}
+	w = httptest.NewRecorder()
+	GetStatsByOutcome(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/by-outcome?outcome=blocked", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown outcome: status = %d, want 400", w.Code)
+	}
+}
+
+func TestGetStatsExport(t *testing.T) {
+	var gotFilter schema.StatsFilter
+	repo := &mockRepo{
+		FnStreamProxyStats: func(filter schema.StatsFilter, fn func(schema.ProxyStat) error) error {
+			gotFilter = filter
+			for i := 0; i < 3; i++ {
+				if err := fn(schema.ProxyStat{ID: uuid.New(), Method: "GET", Path: "/x", Outcome: schema.OutcomeProxied}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	w := httptest.NewRecorder()
+	GetStatsExport(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/export?format=ndjson&window=24h&outcome=proxied", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("status = %d content-type = %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if n := strings.Count(w.Body.String(), "\n"); n != 3 {
+		t.Errorf("ndjson lines = %d, want 3", n)
+	}
+	if gotFilter.Since == nil || time.Since(*gotFilter.Since) < 23*time.Hour || gotFilter.Outcome != schema.OutcomeProxied {
+		t.Errorf("filter = %+v", gotFilter)
+	}
+
+	w = httptest.NewRecorder()
+	GetStatsExport(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/export?gzip=true", nil))
+	if w.Header().Get("Content-Type") != "application/gzip" || !strings.Contains(w.Header().Get("Content-Disposition"), ".csv.gz") {
+		t.Fatalf("headers = %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(zr)
+	if lines := strings.Split(strings.TrimSpace(string(body)), "\n"); len(lines) != 4 || !strings.HasPrefix(lines[0], "id,timestamp,") {
+		t.Errorf("csv = %q", body)
+	}
+
+	for _, q := range []string{"format=xml", "gzip=maybe", "since=yesterday"} {
+		w = httptest.NewRecorder()
+		GetStatsExport(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/export?"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", q, w.Code)
+		}
+	}
+
+	repo.FnStreamProxyStats = func(schema.StatsFilter, func(schema.ProxyStat) error) error { return errors.New("db down") }
+	w = httptest.NewRecorder()
+	GetStatsExport(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/export", nil))
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Disposition") != "" {
+		t.Errorf("failed export: status = %d headers = %v", w.Code, w.Header())
+	}
+}
+
+func TestGetStatsLive(t *testing.T) {
+	hub := stats.NewHub(1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		GetStatsLive(hub, w, r)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/stats/live?status=5xx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status = %d content-type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	lines := bufio.NewScanner(resp.Body)
+	if !lines.Scan() || lines.Text() != ": live" {
+		t.Fatalf("first line = %q", lines.Text())
+	}
+
+	// The hub allows one subscriber, which is the open stream.
+	w := httptest.NewRecorder()
+	GetStatsLive(hub, w, httptest.NewRequest(http.MethodGet, "/api/stats/live", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("second subscriber: status = %d, want 503", w.Code)
+	}
+
+	ok, fail := 200, 503
+	hub.Publish(schema.ProxyStat{Path: "/ok", StatusCode: &ok})
+	hub.Publish(schema.ProxyStat{Path: "/fail", StatusCode: &fail})
+	var event, data string
+	for lines.Scan() && data == "" {
+		if v, found := strings.CutPrefix(lines.Text(), "event: "); found {
+			event = v
+		}
+		if v, found := strings.CutPrefix(lines.Text(), "data: "); found {
+			data = v
+		}
+	}
+	var got schema.ProxyStat
+	if event != "stat" || json.Unmarshal([]byte(data), &got) != nil || got.Path != "/fail" {
+		t.Errorf("event = %q data = %q", event, data)
+	}
+
+	hub.Close()
+	for lines.Scan() {
+	}
+
+	for _, q := range []string{"status=6xx", "status=abc", "client_ip=nope", "route=x", "outcome=blocked"} {
+		w := httptest.NewRecorder()
+		GetStatsLive(stats.NewHub(1), w, httptest.NewRequest(http.MethodGet, "/api/stats/live?"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", q, w.Code)
+		}
+	}
+	w = httptest.NewRecorder()
+	GetStatsLive(nil, w, httptest.NewRequest(http.MethodGet, "/api/stats/live", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("nil hub: status = %d, want 503", w.Code)
+	}
+}
+
+func TestCreateAlertRuleAndSilence(t *testing.T) {
+	hook := uuid.New()
+	var created schema.AlertRule
+	repo := &mockRepo{
+		FnGetAlertWebhook: func(id uuid.UUID) (schema.AlertWebhook, error) {
+			if id == hook {
+				return schema.AlertWebhook{AlertWebhookUUID: id}, nil
+			}
+			return schema.AlertWebhook{}, gorm.ErrRecordNotFound
+		},
+		FnCreateAlertRule: func(rule schema.AlertRule) error { created = rule; return nil },
+		FnGetAlertRule: func(id uuid.UUID) (schema.AlertRule, error) {
+			if id == created.AlertRuleUUID {
+				return created, nil
+			}
+			return schema.AlertRule{}, gorm.ErrRecordNotFound
+		},
+	}
+	body := `{"name":"5xx","metric":"error_rate","operator":">","threshold":0.05,"webhook_uuids":["` + hook.String() + `"]}`
+	w := httptest.NewRecorder()
+	CreateAlertRule(repo, w, httptest.NewRequest(http.MethodPost, "/api/alerts/rules", strings.NewReader(body)))
+	if w.Code != http.StatusCreated || !created.Enabled || created.WindowSeconds != defaultAlertWindowSeconds {
+		t.Fatalf("status = %d created = %+v body = %s", w.Code, created, w.Body.String())
+	}
+	for _, bad := range []string{
+		`{"metric":"error_rate","operator":">"}`,
+		`{"name":"x","metric":"cpu","operator":">"}`,
+		`{"name":"x","metric":"error_rate","operator":"=="}`,
+		`{"name":"x","metric":"error_rate","operator":">","window_seconds":5}`,
+		`{"name":"x","metric":"error_rate","operator":">","webhook_uuids":["` + uuid.New().String() + `"]}`,
+	} {
+		w := httptest.NewRecorder()
+		CreateAlertRule(repo, w, httptest.NewRequest(http.MethodPost, "/api/alerts/rules", strings.NewReader(bad)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", bad, w.Code)
+		}
+	}
+
+	var silence schema.AlertSilence
+	repo.FnCreateAlertSilence = func(s schema.AlertSilence) error { silence = s; return nil }
+	w = httptest.NewRecorder()
+	CreateAlertSilence(repo, w, httptest.NewRequest(http.MethodPost, "/api/alerts/silences",
+		strings.NewReader(`{"alert_rule_uuid":"`+created.AlertRuleUUID.String()+`","duration":"2h","comment":"deploy"}`)))
+	if w.Code != http.StatusCreated || silence.EndsAt.Sub(silence.StartsAt) != 2*time.Hour || silence.Comment != "deploy" {
+		t.Fatalf("silence: status = %d silence = %+v body = %s", w.Code, silence, w.Body.String())
+	}
+	for _, bad := range []string{`{}`, `{"duration":"-1h"}`, `{"ends_at":"2001-01-01T00:00:00Z"}`, `{"alert_rule_uuid":"` + uuid.New().String() + `","duration":"1h"}`} {
+		w := httptest.NewRecorder()
+		CreateAlertSilence(repo, w, httptest.NewRequest(http.MethodPost, "/api/alerts/silences", strings.NewReader(bad)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("silence %s: status = %d, want 400", bad, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	CreateAlertWebhook(repo, w, httptest.NewRequest(http.MethodPost, "/api/alerts/webhooks",
+		strings.NewReader(`{"name":"chat","url":"https://chat.example.com/hook","template":"{{.Summary"}`)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid template") {
+		t.Errorf("bad template: status = %d body = %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	CreateAlertWebhook(repo, w, httptest.NewRequest(http.MethodPost, "/api/alerts/webhooks",
+		strings.NewReader(`{"name":"chat","url":"ftp://chat.example.com/hook"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("bad url: status = %d", w.Code)
+	}
+}
+
+func TestListStats_query(t *testing.T) {
+	var got schema.StatsListQuery
+	route := uuid.New()
+	repo := &mockRepo{
+		FnListProxyStats: func(q schema.StatsListQuery) (schema.StatsPage, error) {
+			got = q
+			if q.Cursor == "stale" {
+				return schema.StatsPage{}, database.ErrInvalidCursor
+			}
+			return schema.StatsPage{Total: 1, NextCursor: "abc"}, nil
+		},
+	}
+	w := httptest.NewRecorder()
+	ListStats(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats?route="+route.String()+
+		"&status=5xx&method=get&client_ip=10.0.0.1&path_prefix=/api&min_duration_ms=250&sort=slowest&limit=20&cursor=xyz&until=2026-01-02T00:00:00Z", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"stats":[]`) || !strings.Contains(w.Body.String(), `"next_cursor":"abc"`) {
+		t.Fatalf("status = %d body = %s", w.Code, w.Body.String())
+	}
+	if got.Filter.RouteUUID == nil || *got.Filter.RouteUUID != route || got.StatusClass != 5 || got.Method != "GET" ||
+		got.ClientIP != "10.0.0.1" || got.PathPrefix != "/api" || got.MinDurationMs == nil || *got.MinDurationMs != 250 ||
+		got.Sort != schema.StatsSortSlowest || got.Limit != 20 || got.Cursor != "xyz" || got.Filter.Until == nil || got.Filter.Since != nil {
+		t.Errorf("query = %+v", got)
+	}
+	for _, q := range []string{"offset=100", "limit=5000", "sort=fastest", "status=9xx", "min_duration_ms=-1", "client_ip=x", "until=today", "cursor=stale"} {
+		w := httptest.NewRecorder()
+		ListStats(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats?"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", q, w.Code)
+		}
+	}
+}
+
+func TestGetStatsTPS_bucket(t *testing.T) {
+	var gotBucket time.Duration
+	repo := &mockRepo{
+		FnStatsTPS: func(since time.Time, bucket time.Duration) ([]schema.BucketCount, error) {
+			gotBucket = bucket
+			return nil, nil
+		},
+	}
+	for _, tt := range []struct {
+		query string
+		want  time.Duration
+	}{
+		{"", time.Minute},
+		{"window=720h", 6 * time.Hour},
+		{"window=6h&bucket=10s", 10 * time.Second},
+	} {
+		w := httptest.NewRecorder()
+		GetStatsTPS(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/tps?"+tt.query, nil))
+		if w.Code != http.StatusOK || gotBucket != tt.want {
+			t.Errorf("%q: status = %d bucket = %v, want %v", tt.query, w.Code, gotBucket, tt.want)
+		}
+	}
+	for _, q := range []string{"bucket=500ms", "window=720h&bucket=10s"} {
+		w := httptest.NewRecorder()
+		GetStatsTPS(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/tps?"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: status = %d, want 400", q, w.Code)
+		}
+	}
+}
+
+func TestGetStatsByCountryAndASN(t *testing.T) {
+	var gotLimit int
+	repo := &mockRepo{
+		FnStatsByCountry: func(since *time.Time, limit int) ([]schema.CountryCount, error) {
+			gotLimit = limit
+			return []schema.CountryCount{{Country: "BR", Count: 7}, {Country: "", Count: 2}}, nil
+		},
+		FnStatsByASN: func(*time.Time, int) ([]schema.ASNCount, error) { return nil, errors.New("db down") },
+	}
+	w := httptest.NewRecorder()
+	GetStatsByCountry(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/by-country?limit=5", nil))
+	if w.Code != http.StatusOK || gotLimit != 5 {
+		t.Fatalf("by-country: status = %d, limit = %d", w.Code, gotLimit)
+	}
+	var body struct {
+		Items []schema.CountryCount `json:"items"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil || len(body.Items) != 2 || body.Items[0].Country != "BR" || body.Items[0].Count != 7 {
+		t.Errorf("by-country body = %+v, err %v", body, err)
+	}
+
+	w = httptest.NewRecorder()
+	GetStatsByASN(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/by-asn", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("by-asn with a failing repo: status = %d, want 500", w.Code)
+	}
+	repo.FnStatsByASN = nil
+	w = httptest.NewRecorder()
+	GetStatsByASN(repo, w, httptest.NewRequest(http.MethodGet, "/api/stats/by-asn", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"items":[]`) {
+		t.Errorf("by-asn empty: status = %d body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPutRouteTrafficSplit_trimsVariantNames(t *testing.T) {
+	routeID, targetID := uuid.New(), uuid.New()
+	var saved schema.TrafficSplit
+	repo := &mockRepo{
+		FnGetRoute: func(uuid.UUID) (schema.Route, error) { return schema.Route{RouteUUID: routeID}, nil },
+		FnGetTargetServer: func(uuid.UUID) (schema.TargetServer, error) {
+			return schema.TargetServer{TargetServerUUID: targetID}, nil
+		},
+		FnSetTrafficSplit: func(split schema.TrafficSplit) error {
+			saved = split
+			return nil
+		},
+	}
+	put := func(variants ...string) int {
+		rules := make([]string, len(variants))
+		for i, v := range variants {
+			rules[i] = `{"variant":"` + v + `","weight":10,"target_server_uuid":"` + targetID.String() + `"}`
+		}
+		body := `{"rules":[` + strings.Join(rules, ",") + `]}`
+		w := httptest.NewRecorder()
+		PutRouteTrafficSplit(repo, w, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body)), routeID.String())
+		return w.Code
+	}
+	if code := put(" canary "); code != http.StatusOK || saved.Rules[0].Variant != "canary" {
+		t.Errorf("status %d, saved variant %q, want 200 and \"canary\"", code, saved.Rules[0].Variant)
+	}
+	if code := put(" "+schema.PrimaryVariant); code != http.StatusBadRequest {
+		t.Errorf("reserved name with spaces: status %d, want 400", code)
+	}
+	if code := put("a", "a "); code != http.StatusBadRequest {
+		t.Errorf("duplicate after trimming: status %d, want 400", code)
+	}
+}
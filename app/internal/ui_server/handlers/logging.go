@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"FeatherProxy/app/internal/logging"
+)
+
+// GetLogging returns the current log level of every subsystem.
+func GetLogging(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]any{"levels": logging.Levels()})
+}
+
+// SetLogging changes log levels at runtime. Body: {"levels": {"proxy": "debug", "cache": "warn"}}; subsystems
+// not listed keep their level. Changes are not persisted: a restart goes back to LOG_LEVEL / LOG_LEVELS.
+func SetLogging(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Levels map[string]string `json:"levels"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	parsed := make(map[string]slog.Level, len(body.Levels))
+	current := logging.Levels()
+	for name, level := range body.Levels {
+		if _, ok := current[name]; !ok {
+			respondJSONError(w, http.StatusBadRequest, "unknown subsystem "+name)
+			return
+		}
+		l, err := logging.ParseLevel(level)
+		if err != nil {
+			respondJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		parsed[name] = l
+	}
+	for name, l := range parsed {
+		_ = logging.SetLevel(name, l)
+		uiLogger.Info("log level changed", "target_subsystem", name, "level", logging.LevelName(l))
+	}
+	GetLogging(w, r)
+}
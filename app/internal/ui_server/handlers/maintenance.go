@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"FeatherProxy/app/internal/database"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func GetRouteMaintenance(repo database.Repository, w http.ResponseWriter, _ *http.Request, routeIDStr string) {
+	routeID, ok := parseUUIDParam(w, routeIDStr, "invalid route UUID")
+	if !ok {
+		return
+	}
+	if _, err := repo.GetRoute(routeID); !handleRepoGetError(w, err) {
+		return
+	}
+	getMaintenance(repo, w, schema.MaintenanceScopeRoute, routeID)
+}
+
+func PutRouteMaintenance(repo database.Repository, w http.ResponseWriter, r *http.Request, routeIDStr string) {
+	routeID, ok := parseUUIDParam(w, routeIDStr, "invalid route UUID")
+	if !ok {
+		return
+	}
+	if _, err := repo.GetRoute(routeID); !handleRepoGetError(w, err) {
+		return
+	}
+	putMaintenance(repo, w, r, schema.MaintenanceScopeRoute, routeID)
+}
+
+func GetSourceServerMaintenance(repo database.Repository, w http.ResponseWriter, _ *http.Request, sourceIDStr string) {
+	id, ok := parseUUIDParam(w, sourceIDStr, "invalid source server UUID")
+	if !ok {
+		return
+	}
+	if _, err := repo.GetSourceServer(id); !handleRepoGetError(w, err) {
+		return
+	}
+	getMaintenance(repo, w, schema.MaintenanceScopeSourceServer, id)
+}
+
+func PutSourceServerMaintenance(repo database.Repository, w http.ResponseWriter, r *http.Request, sourceIDStr string) {
+	id, ok := parseUUIDParam(w, sourceIDStr, "invalid source server UUID")
+	if !ok {
+		return
+	}
+	if _, err := repo.GetSourceServer(id); !handleRepoGetError(w, err) {
+		return
+	}
+	putMaintenance(repo, w, r, schema.MaintenanceScopeSourceServer, id)
+}
+
+func getMaintenance(repo database.Repository, w http.ResponseWriter, scope string, ownerID uuid.UUID) {
+	m, err := repo.GetMaintenance(scope, ownerID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondJSON(w, http.StatusOK, schema.Maintenance{OwnerUUID: ownerID, Scope: scope, AllowList: []string{}})
+		return
+	}
+	if !handleRepoGetError(w, err) {
+		return
+	}
+	respondJSON(w, http.StatusOK, m)
+}
+
+func putMaintenance(repo database.Repository, w http.ResponseWriter, r *http.Request, scope string, ownerID uuid.UUID) {
+	var body struct {
+		Enabled           bool       `json:"enabled"`
+		Body              string     `json:"body"`
+		ContentType       string     `json:"content_type"`
+		RetryAfterSeconds int        `json:"retry_after_seconds"`
+		StartsAt          *time.Time `json:"starts_at"`
+		EndsAt            *time.Time `json:"ends_at"`
+		AllowList         []string   `json:"allow_list"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	if body.RetryAfterSeconds < 0 {
+		respondJSONError(w, http.StatusBadRequest, "retry_after_seconds must not be negative")
+		return
+	}
+	if body.StartsAt != nil && body.EndsAt != nil && !body.EndsAt.After(*body.StartsAt) {
+		respondJSONError(w, http.StatusBadRequest, "ends_at must be after starts_at")
+		return
+	}
+	allow := make([]string, 0, len(body.AllowList))
+	for _, entry := range body.AllowList {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			allow = append(allow, entry)
+		}
+	}
+	m := schema.Maintenance{
+		OwnerUUID:         ownerID,
+		Scope:             scope,
+		Enabled:           body.Enabled,
+		Body:              body.Body,
+		ContentType:       strings.TrimSpace(body.ContentType),
+		RetryAfterSeconds: body.RetryAfterSeconds,
+		StartsAt:          body.StartsAt,
+		EndsAt:            body.EndsAt,
+		AllowList:         allow,
+	}
+	if err := repo.SetMaintenance(m); err != nil {
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	current, _ := repo.GetMaintenance(scope, ownerID)
+	respondJSON(w, http.StatusOK, current)
+}
@@ -3,8 +3,12 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+
+	"FeatherProxy/app/internal/logging"
 )
 
+var uiLogger = logging.For(logging.UI)
+
 func respondJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"log"
 	"net/http"
 
 	"FeatherProxy/app/internal/database"
@@ -11,26 +10,26 @@ import (
 )
 
 func GetRouteSourceAuth(repo database.Repository, w http.ResponseWriter, _ *http.Request, routeIDStr string) {
-	log.Printf("api/route_auth: GET /api/routes/%s/source-auth", routeIDStr)
+	uiLogger.Debug("get source auth", "route", routeIDStr)
 	routeID, ok := parseUUIDParam(w, routeIDStr, "invalid route UUID")
 	if !ok {
 		return
 	}
 	list, err := repo.ListSourceAuthsForRoute(routeID)
 	if err != nil {
-		log.Printf("api/route_auth: get source-auth error: %v", err)
+		uiLogger.Error("get source auth failed", "route", routeID, "error", err)
 		respondJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	if list == nil {
 		list = []schema.RouteSourceAuth{}
 	}
-	log.Printf("api/route_auth: get source-auth ok route=%s count=%d", routeID, len(list))
+	uiLogger.Debug("get source auth ok", "route", routeID, "count", len(list))
 	respondJSON(w, http.StatusOK, list)
 }
 
 func PutRouteSourceAuth(repo database.Repository, w http.ResponseWriter, r *http.Request, routeIDStr string) {
-	log.Printf("api/route_auth: PUT /api/routes/%s/source-auth", routeIDStr)
+	uiLogger.Debug("put source auth", "route", routeIDStr)
 	routeID, ok := parseUUIDParam(w, routeIDStr, "invalid route UUID")
 	if !ok {
 		return
@@ -57,24 +56,24 @@ func PutRouteSourceAuth(repo database.Repository, w http.ResponseWriter, r *http
 		authUUIDs = append(authUUIDs, u)
 	}
 	if err := repo.SetSourceAuthsForRoute(routeID, authUUIDs); err != nil {
-		log.Printf("api/route_auth: put source-auth error: %v", err)
+		uiLogger.Error("put source auth failed", "route", routeID, "error", err)
 		respondJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	list, _ := repo.ListSourceAuthsForRoute(routeID)
-	log.Printf("api/route_auth: put source-auth ok route=%s count=%d", routeID, len(list))
+	uiLogger.Debug("put source auth ok", "route", routeID, "count", len(list))
 	respondJSON(w, http.StatusOK, list)
 }
 
 func GetRouteTargetAuth(repo database.Repository, w http.ResponseWriter, _ *http.Request, routeIDStr string) {
-	log.Printf("api/route_auth: GET /api/routes/%s/target-auth", routeIDStr)
+	uiLogger.Debug("get target auth", "route", routeIDStr)
 	routeID, ok := parseUUIDParam(w, routeIDStr, "invalid route UUID")
 	if !ok {
 		return
 	}
 	authUUID, ok, err := repo.GetTargetAuthForRoute(routeID)
 	if err != nil {
-		log.Printf("api/route_auth: get target-auth error: %v", err)
+		uiLogger.Error("get target auth failed", "route", routeID, "error", err)
 		respondJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -85,12 +84,12 @@ func GetRouteTargetAuth(repo database.Repository, w http.ResponseWriter, _ *http
 	if ok && authUUID != uuid.Nil {
 		out.AuthenticationUUID = authUUID.String()
 	}
-	log.Printf("api/route_auth: get target-auth ok route=%s auth_set=%v", routeID, ok)
+	uiLogger.Debug("get target auth ok", "route", routeID, "auth_set", ok)
 	respondJSON(w, http.StatusOK, out)
 }
 
 func PutRouteTargetAuth(repo database.Repository, w http.ResponseWriter, r *http.Request, routeIDStr string) {
-	log.Printf("api/route_auth: PUT /api/routes/%s/target-auth", routeIDStr)
+	uiLogger.Debug("put target auth", "route", routeIDStr)
 	routeID, ok := parseUUIDParam(w, routeIDStr, "invalid route UUID")
 	if !ok {
 		return
@@ -114,7 +113,7 @@ func PutRouteTargetAuth(repo database.Repository, w http.ResponseWriter, r *http
 		authUUID = &u
 	}
 	if err := repo.SetTargetAuthForRoute(routeID, authUUID); err != nil {
-		log.Printf("api/route_auth: put target-auth error: %v", err)
+		uiLogger.Error("put target auth failed", "route", routeID, "error", err)
 		respondJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -125,6 +124,6 @@ func PutRouteTargetAuth(repo database.Repository, w http.ResponseWriter, r *http
 	if ok && authUUIDOut != uuid.Nil {
 		out.AuthenticationUUID = authUUIDOut.String()
 	}
-	log.Printf("api/route_auth: put target-auth ok route=%s auth_set=%v", routeID, ok)
+	uiLogger.Debug("put target auth ok", "route", routeID, "auth_set", ok)
 	respondJSON(w, http.StatusOK, out)
 }
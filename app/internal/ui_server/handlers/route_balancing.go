@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"FeatherProxy/app/internal/database"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func GetRouteBalancing(repo database.Repository, w http.ResponseWriter, _ *http.Request, routeIDStr string) {
+	routeID, ok := parseUUIDParam(w, routeIDStr, "invalid route UUID")
+	if !ok {
+		return
+	}
+	if _, err := repo.GetRoute(routeID); !handleRepoGetError(w, err) {
+		return
+	}
+	b, err := repo.GetRouteBalancing(routeID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondJSON(w, http.StatusOK, schema.RouteBalancing{RouteUUID: routeID, TargetServerUUIDs: []uuid.UUID{}, Affinity: schema.AffinityNone})
+		return
+	}
+	if !handleRepoGetError(w, err) {
+		return
+	}
+	respondJSON(w, http.StatusOK, b)
+}
+
+func PutRouteBalancing(repo database.Repository, w http.ResponseWriter, r *http.Request, routeIDStr string) {
+	routeID, ok := parseUUIDParam(w, routeIDStr, "invalid route UUID")
+	if !ok {
+		return
+	}
+	route, err := repo.GetRoute(routeID)
+	if !handleRepoGetError(w, err) {
+		return
+	}
+	var body struct {
+		TargetServerUUIDs []string `json:"target_server_uuids"`
+		Affinity          string   `json:"affinity"`
+		AffinityKey       string   `json:"affinity_key"`
+		CookieName        string   `json:"cookie_name"`
+		CookieTTLSeconds  int      `json:"cookie_ttl_seconds"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	affinity := body.Affinity
+	if affinity == "" {
+		affinity = schema.AffinityNone
+	}
+	switch affinity {
+	case schema.AffinityNone, schema.AffinityCookie, schema.AffinityClientIP:
+	case schema.AffinityHashCookie, schema.AffinityHashHeader:
+		if strings.TrimSpace(body.AffinityKey) == "" {
+			respondJSONError(w, http.StatusBadRequest, "affinity_key required for "+affinity)
+			return
+		}
+	default:
+		respondJSONError(w, http.StatusBadRequest, "affinity must be none, cookie, hash_cookie, hash_header, or client_ip")
+		return
+	}
+	if body.CookieTTLSeconds < 0 {
+		respondJSONError(w, http.StatusBadRequest, "cookie_ttl_seconds must not be negative")
+		return
+	}
+	targets := make([]uuid.UUID, 0, len(body.TargetServerUUIDs))
+	for _, idStr := range body.TargetServerUUIDs {
+		if idStr == "" {
+			continue
+		}
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			respondJSONError(w, http.StatusBadRequest, "invalid target_server_uuid in list")
+			return
+		}
+		if id == route.TargetServerUUID {
+			continue // the route's own target is always in the pool
+		}
+		if _, err := repo.GetTargetServer(id); err != nil {
+			respondJSONError(w, http.StatusBadRequest, "target server not found: "+idStr)
+			return
+		}
+		targets = append(targets, id)
+	}
+	b := schema.RouteBalancing{
+		RouteUUID:         routeID,
+		TargetServerUUIDs: targets,
+		Affinity:          affinity,
+		AffinityKey:       strings.TrimSpace(body.AffinityKey),
+		CookieName:        strings.TrimSpace(body.CookieName),
+		CookieTTLSeconds:  body.CookieTTLSeconds,
+	}
+	if err := repo.SetRouteBalancing(b); err != nil {
+		if errors.Is(err, database.ErrProtocolMismatch) {
+			respondJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	current, _ := repo.GetRouteBalancing(routeID)
+	respondJSON(w, http.StatusOK, current)
+}
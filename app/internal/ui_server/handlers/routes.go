@@ -6,6 +6,7 @@ import (
 
 	"FeatherProxy/app/internal/database"
 	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/proxy"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -24,36 +25,15 @@ func ListRoutes(repo database.Repository, w http.ResponseWriter, _ *http.Request
 }
 
 func CreateRoute(repo database.Repository, w http.ResponseWriter, r *http.Request) {
-	var body struct {
-		SourceServerUUID string `json:"source_server_uuid"`
-		TargetServerUUID string `json:"target_server_uuid"`
-		Method           string `json:"method"`
-		SourcePath       string `json:"source_path"`
-		TargetPath       string `json:"target_path"`
-	}
+	var body routeBody
 	if !decodeJSON(w, r, &body) {
 		return
 	}
-	sourceID, ok := parseUUIDParam(w, body.SourceServerUUID, "invalid source_server_uuid")
+	route, ok := body.toRoute(w)
 	if !ok {
 		return
 	}
-	targetID, ok := parseUUIDParam(w, body.TargetServerUUID, "invalid target_server_uuid")
-	if !ok {
-		return
-	}
-	if body.Method == "" || body.SourcePath == "" || body.TargetPath == "" {
-		respondJSONError(w, http.StatusBadRequest, "method, source_path, target_path required")
-		return
-	}
-	route := schema.Route{
-		RouteUUID:        uuid.New(),
-		SourceServerUUID: sourceID,
-		TargetServerUUID: targetID,
-		Method:           body.Method,
-		SourcePath:       body.SourcePath,
-		TargetPath:       body.TargetPath,
-	}
+	route.RouteUUID = uuid.New()
 	if err := repo.CreateRoute(route); err != nil {
 		if errors.Is(err, database.ErrProtocolMismatch) {
 			respondJSONError(w, http.StatusBadRequest, err.Error())
@@ -90,38 +70,17 @@ func UpdateRoute(repo database.Repository, w http.ResponseWriter, r *http.Reques
 	if !handleRepoGetError(w, err) {
 		return
 	}
-	var body struct {
-		SourceServerUUID string `json:"source_server_uuid"`
-		TargetServerUUID string `json:"target_server_uuid"`
-		Method           string `json:"method"`
-		SourcePath       string `json:"source_path"`
-		TargetPath       string `json:"target_path"`
-	}
+	var body routeBody
 	if !decodeJSON(w, r, &body) {
 		return
 	}
-	sourceID, ok := parseUUIDParam(w, body.SourceServerUUID, "invalid source_server_uuid")
+	route, ok := body.toRoute(w)
 	if !ok {
 		return
 	}
-	targetID, ok := parseUUIDParam(w, body.TargetServerUUID, "invalid target_server_uuid")
-	if !ok {
-		return
-	}
-	if body.Method == "" || body.SourcePath == "" || body.TargetPath == "" {
-		respondJSONError(w, http.StatusBadRequest, "method, source_path, target_path required")
-		return
-	}
-	route := schema.Route{
-		RouteUUID:        id,
-		SourceServerUUID: sourceID,
-		TargetServerUUID: targetID,
-		Method:           body.Method,
-		SourcePath:       body.SourcePath,
-		TargetPath:       body.TargetPath,
-		CreatedAt:        existing.CreatedAt,
-		UpdatedAt:        existing.UpdatedAt,
-	}
+	route.RouteUUID = id
+	route.CreatedAt = existing.CreatedAt
+	route.UpdatedAt = existing.UpdatedAt
 	if err := repo.UpdateRoute(route); err != nil {
 		if errors.Is(err, database.ErrProtocolMismatch) {
 			respondJSONError(w, http.StatusBadRequest, err.Error())
@@ -149,3 +108,96 @@ func DeleteRoute(repo database.Repository, w http.ResponseWriter, _ *http.Reques
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// routeBody is the JSON body for route create and update.
+type routeBody struct {
+	SourceServerUUID string                `json:"source_server_uuid"`
+	TargetServerUUID string                `json:"target_server_uuid"`
+	Method           string                `json:"method"`
+	SourcePath       string                `json:"source_path"`
+	TargetPath       string                `json:"target_path"`
+	Kind             string                `json:"kind"`
+	Response         *schema.RouteResponse `json:"response"`
+}
+
+// toRoute validates the body and builds a route (without UUID). On failure it writes a 400 response and returns false.
+func (b *routeBody) toRoute(w http.ResponseWriter) (schema.Route, bool) {
+	sourceID, ok := parseUUIDParam(w, b.SourceServerUUID, "invalid source_server_uuid")
+	if !ok {
+		return schema.Route{}, false
+	}
+	kind := b.Kind
+	if kind == "" {
+		kind = schema.RouteKindProxy
+	}
+	route := schema.Route{
+		SourceServerUUID: sourceID,
+		Method:           b.Method,
+		SourcePath:       b.SourcePath,
+		TargetPath:       b.TargetPath,
+		Kind:             kind,
+	}
+	switch kind {
+	case schema.RouteKindProxy:
+		targetID, ok := parseUUIDParam(w, b.TargetServerUUID, "invalid target_server_uuid")
+		if !ok {
+			return schema.Route{}, false
+		}
+		if b.Method == "" || b.SourcePath == "" || b.TargetPath == "" {
+			respondJSONError(w, http.StatusBadRequest, "method, source_path, target_path required")
+			return schema.Route{}, false
+		}
+		route.TargetServerUUID = targetID
+		return route, true
+	case schema.RouteKindStatic, schema.RouteKindMock, schema.RouteKindRedirect:
+	default:
+		respondJSONError(w, http.StatusBadRequest, "kind must be proxy, static, redirect, or mock")
+		return schema.Route{}, false
+	}
+	if b.Method == "" || b.SourcePath == "" {
+		respondJSONError(w, http.StatusBadRequest, "method, source_path required")
+		return schema.Route{}, false
+	}
+	if b.Response == nil {
+		respondJSONError(w, http.StatusBadRequest, "response required for "+kind+" routes")
+		return schema.Route{}, false
+	}
+	resp := *b.Response
+	if kind == schema.RouteKindRedirect {
+		if resp.Status == 0 {
+			resp.Status = http.StatusFound
+		}
+		if resp.Status < 300 || resp.Status > 399 {
+			respondJSONError(w, http.StatusBadRequest, "redirect status must be 3xx")
+			return schema.Route{}, false
+		}
+		if resp.RedirectURL == "" {
+			respondJSONError(w, http.StatusBadRequest, "response.redirect_url required for redirect routes")
+			return schema.Route{}, false
+		}
+	} else {
+		if resp.Status == 0 {
+			resp.Status = http.StatusOK
+		}
+		if resp.Status < 100 || resp.Status > 599 {
+			respondJSONError(w, http.StatusBadRequest, "response.status must be a valid HTTP status")
+			return schema.Route{}, false
+		}
+	}
+	if resp.DelayMs < 0 || (resp.DelayMs > 0 && kind != schema.RouteKindMock) {
+		respondJSONError(w, http.StatusBadRequest, "response.delay_ms is only allowed (non-negative) on mock routes")
+		return schema.Route{}, false
+	}
+	templates := []string{resp.Body, resp.RedirectURL}
+	for _, v := range resp.Headers {
+		templates = append(templates, v)
+	}
+	for _, t := range templates {
+		if err := proxy.ValidateTemplate(t, false); err != nil {
+			respondJSONError(w, http.StatusBadRequest, "invalid response template: "+err.Error())
+			return schema.Route{}, false
+		}
+	}
+	route.Response = &resp
+	return route, true
+}
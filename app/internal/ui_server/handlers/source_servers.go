@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"fmt"
+	"net"
 	"net/http"
+	"strings"
 
 	"FeatherProxy/app/internal/database"
 	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/proxy"
 
 	"github.com/google/uuid"
 )
@@ -130,16 +134,32 @@ func SetServerOptions(repo database.Repository, w http.ResponseWriter, r *http.R
 		return
 	}
 	var body struct {
-		TLSCertPath string `json:"tls_cert_path"`
-		TLSKeyPath  string `json:"tls_key_path"`
+		TLSCertPath          string `json:"tls_cert_path"`
+		TLSKeyPath           string `json:"tls_key_path"`
+		RequestIDHeader      string `json:"request_id_header"`
+		ForwardedForHeader   string `json:"forwarded_for_header"`
+		ForwardedProtoHeader string `json:"forwarded_proto_header"`
+		ForwardedHostHeader  string `json:"forwarded_host_header"`
+		ForwardedPortHeader  string `json:"forwarded_port_header"`
+		ForwardedHeader      string `json:"forwarded_header"`
 	}
 	if !decodeJSON(w, r, &body) {
 		return
 	}
 	opts := schema.ServerOptions{
-		SourceServerUUID: id,
-		TLSCertPath:      body.TLSCertPath,
-		TLSKeyPath:       body.TLSKeyPath,
+		SourceServerUUID:     id,
+		TLSCertPath:          body.TLSCertPath,
+		TLSKeyPath:           body.TLSKeyPath,
+		RequestIDHeader:      http.CanonicalHeaderKey(strings.TrimSpace(body.RequestIDHeader)),
+		ForwardedForHeader:   forwardHeaderOption(body.ForwardedForHeader),
+		ForwardedProtoHeader: forwardHeaderOption(body.ForwardedProtoHeader),
+		ForwardedHostHeader:  forwardHeaderOption(body.ForwardedHostHeader),
+		ForwardedPortHeader:  forwardHeaderOption(body.ForwardedPortHeader),
+		ForwardedHeader:      forwardHeaderOption(body.ForwardedHeader),
+	}
+	if err := proxy.ValidateForwardHeaders(opts); err != nil {
+		respondJSONError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 	if err := repo.SetServerOptions(opts); err != nil {
 		respondJSONError(w, http.StatusInternalServerError, err.Error())
@@ -149,6 +169,16 @@ func SetServerOptions(repo database.Repository, w http.ResponseWriter, r *http.R
 	respondJSON(w, http.StatusOK, current)
 }
 
+// forwardHeaderOption normalizes a forwarding header name option: canonical header name, or
+// schema.ForwardHeaderOff as is.
+func forwardHeaderOption(name string) string {
+	name = strings.TrimSpace(name)
+	if name == schema.ForwardHeaderOff {
+		return name
+	}
+	return http.CanonicalHeaderKey(name)
+}
+
 func GetACLOptions(repo database.Repository, w http.ResponseWriter, _ *http.Request, sourceIDStr string) {
 	id, ok := parseUUIDParam(w, sourceIDStr, "invalid source server UUID")
 	if !ok {
@@ -164,7 +194,9 @@ func GetACLOptions(repo database.Repository, w http.ResponseWriter, _ *http.Requ
 	respondJSON(w, http.StatusOK, opts)
 }
 
-func SetACLOptions(repo database.Repository, w http.ResponseWriter, r *http.Request, sourceIDStr string) {
+// SetACLOptions replaces a source server's ACL options. Country and ASN list entries are validated, and
+// rejected when geoIP is false: without GeoIP databases they would never match.
+func SetACLOptions(repo database.Repository, w http.ResponseWriter, r *http.Request, sourceIDStr string, geoIP bool) {
 	id, ok := parseUUIDParam(w, sourceIDStr, "invalid source server UUID")
 	if !ok {
 		return
@@ -177,6 +209,7 @@ func SetACLOptions(repo database.Repository, w http.ResponseWriter, r *http.Requ
 		ClientIPHeader string   `json:"client_ip_header"`
 		AllowList      []string `json:"allow_list"`
 		DenyList       []string `json:"deny_list"`
+		TrustedProxies []string `json:"trusted_proxies"`
 	}
 	if !decodeJSON(w, r, &body) {
 		return
@@ -189,12 +222,36 @@ func SetACLOptions(repo database.Repository, w http.ResponseWriter, r *http.Requ
 		respondJSONError(w, http.StatusBadRequest, "mode must be off, allow_only, or deny_only")
 		return
 	}
+	for _, list := range []struct {
+		name    string
+		entries []string
+	}{{"allow_list", body.AllowList}, {"deny_list", body.DenyList}} {
+		for _, entry := range list.entries {
+			if err := proxy.ValidateACLEntry(entry, geoIP); err != nil {
+				respondJSONError(w, http.StatusBadRequest, list.name+": "+err.Error())
+				return
+			}
+		}
+	}
+	trusted := make([]string, 0, len(body.TrustedProxies))
+	for _, entry := range body.TrustedProxies {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+			respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("trusted_proxies: %q is not an IP or CIDR", entry))
+			return
+		}
+		trusted = append(trusted, entry)
+	}
 	opts := schema.ACLOptions{
 		SourceServerUUID: id,
 		Mode:             mode,
 		ClientIPHeader:   body.ClientIPHeader,
-		AllowList:         body.AllowList,
-		DenyList:          body.DenyList,
+		AllowList:        body.AllowList,
+		DenyList:         body.DenyList,
+		TrustedProxies:   trusted,
 	}
 	if err := repo.SetACLOptions(opts); err != nil {
 		respondJSONError(w, http.StatusInternalServerError, err.Error())
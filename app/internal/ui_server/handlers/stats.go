@@ -1,59 +1,118 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"FeatherProxy/app/internal/database"
 	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
 )
 
 const (
-	defaultStatsLimit  = 100
-	defaultStatsOffset = 0
-	defaultTPSWindow   = time.Hour
-	defaultTPSBucket   = time.Minute
+	defaultStatsLimit = 100
+	maxStatsLimit     = 1000
+	defaultTPSWindow  = time.Hour
+	defaultTPSBucket  = time.Minute
+	defaultTPSPoints  = 360  // target series length when no bucket is given
+	maxTPSBuckets     = 5000 // largest window/bucket ratio accepted
 )
 
+// tpsBucketSizes are the bucket sizes picked automatically, smallest first.
+var tpsBucketSizes = []time.Duration{
+	defaultTPSBucket, 5 * time.Minute, 15 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour,
+}
+
+// ListStats handles GET /api/stats: one page of raw stats, newest first by default. Filters: since, until
+// (RFC3339), route, source_server, target_server (UUIDs), outcome, method, status (404 or 5xx), client_ip,
+// path_prefix and min_duration_ms. sort is newest, oldest or slowest; limit is 1..1000 (default 100).
+// Pass the response's next_cursor as cursor to get the next page; there is none on the last page.
 func ListStats(repo database.Repository, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	limit := defaultStatsLimit
-	if v := r.URL.Query().Get("limit"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 1000 {
-			limit = n
+	q := r.URL.Query()
+	lq := schema.StatsListQuery{Limit: defaultStatsLimit, Cursor: q.Get("cursor"), Method: strings.ToUpper(q.Get("method"))}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxStatsLimit {
+			respondJSONError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		lq.Limit = n
+	}
+	if q.Has("offset") {
+		respondJSONError(w, http.StatusBadRequest, "offset is not supported; page with cursor (next_cursor of the previous page)")
+		return
+	}
+	if v := q.Get("sort"); v != "" {
+		if !slices.Contains(schema.StatsSorts, v) {
+			respondJSONError(w, http.StatusBadRequest, "sort must be newest, oldest or slowest")
+			return
 		}
+		lq.Sort = v
 	}
-	offset := defaultStatsOffset
-	if v := r.URL.Query().Get("offset"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
-			offset = n
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"since", &lq.Filter.Since}, {"until", &lq.Filter.Until}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				respondJSONError(w, http.StatusBadRequest, "invalid "+p.name+" (use RFC3339)")
+				return
+			}
+			*p.dst = &t
 		}
 	}
-	var since *time.Time
-	if v := r.URL.Query().Get("since"); v != "" {
-		t, err := time.Parse(time.RFC3339, v)
-		if err != nil {
-			respondJSONError(w, http.StatusBadRequest, "invalid since (use RFC3339)")
+	if !parseStatsScope(w, q, &lq.Filter) {
+		return
+	}
+	if v := q.Get("status"); v != "" {
+		var ok bool
+		if lq.StatusCode, lq.StatusClass, ok = parseStatusParam(v); !ok {
+			respondJSONError(w, http.StatusBadRequest, "invalid status (use a code such as 404 or a class such as 5xx)")
 			return
 		}
-		since = &t
 	}
-	stats, total, err := repo.ListProxyStats(limit, offset, since)
+	if v := q.Get("client_ip"); v != "" {
+		ip := net.ParseIP(v)
+		if ip == nil {
+			respondJSONError(w, http.StatusBadRequest, "invalid client_ip")
+			return
+		}
+		lq.ClientIP = ip.String()
+	}
+	lq.PathPrefix = q.Get("path_prefix")
+	if v := q.Get("min_duration_ms"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			respondJSONError(w, http.StatusBadRequest, "invalid min_duration_ms")
+			return
+		}
+		lq.MinDurationMs = &n
+	}
+	page, err := repo.ListProxyStats(lq)
 	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			respondJSONError(w, http.StatusBadRequest, "invalid cursor (it must come from a page with the same sort)")
+			return
+		}
 		respondJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	if stats == nil {
-		stats = []schema.ProxyStat{}
+	if page.Stats == nil {
+		page.Stats = []schema.ProxyStat{}
 	}
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"stats": stats,
-		"total": total,
-	})
+	respondJSON(w, http.StatusOK, page)
 }
 
 func GetStatsSummary(repo database.Repository, w http.ResponseWriter, r *http.Request) {
@@ -86,6 +145,8 @@ func GetStatsByRoute(repo database.Repository, w http.ResponseWriter, r *http.Re
 	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items})
 }
 
+// GetStatsByCaller returns request counts per client IP. Like by-country, by-asn and by-variant it is
+// counted from raw stats only, so it cannot reach past raw retention; raw_since is where the counts start.
 func GetStatsByCaller(repo database.Repository, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -97,10 +158,61 @@ func GetStatsByCaller(repo database.Repository, w http.ResponseWriter, r *http.R
 		respondJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	rawSince, err := rawStatsSince(repo, since)
+	if err != nil {
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	if items == nil {
 		items = []schema.CallerCount{}
 	}
-	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "raw_since": rawSince})
+}
+
+// GetStatsByCountry returns request counts per client country (GeoIP enrichment; "" is unresolved).
+func GetStatsByCountry(repo database.Repository, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	since, limit := parseStatsSinceLimit(r)
+	items, err := repo.StatsByCountry(since, limit)
+	if err != nil {
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rawSince, err := rawStatsSince(repo, since)
+	if err != nil {
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if items == nil {
+		items = []schema.CountryCount{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "raw_since": rawSince})
+}
+
+// GetStatsByASN returns request counts per client autonomous system (GeoIP enrichment; 0 is unresolved).
+func GetStatsByASN(repo database.Repository, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	since, limit := parseStatsSinceLimit(r)
+	items, err := repo.StatsByASN(since, limit)
+	if err != nil {
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rawSince, err := rawStatsSince(repo, since)
+	if err != nil {
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if items == nil {
+		items = []schema.ASNCount{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "raw_since": rawSince})
 }
 
 func GetStatsBySourceServer(repo database.Repository, w http.ResponseWriter, r *http.Request) {
@@ -146,6 +258,85 @@ func GetStatsByTargetServer(repo database.Repository, w http.ResponseWriter, r *
 	respondJSON(w, http.StatusOK, map[string]interface{}{"items": out})
 }
 
+func GetStatsByVariant(repo database.Repository, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	since, _ := parseStatsSinceLimit(r)
+	var routeID *uuid.UUID
+	if v := r.URL.Query().Get("route"); v != "" {
+		id, ok := parseUUIDParam(w, v, "invalid route UUID")
+		if !ok {
+			return
+		}
+		routeID = &id
+	}
+	items, err := repo.StatsByVariant(routeID, since)
+	if err != nil {
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rawSince, err := rawStatsSince(repo, since)
+	if err != nil {
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if items == nil {
+		items = []schema.VariantCount{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "raw_since": rawSince})
+}
+
+// GetStatsByOutcome counts requests per outcome (proxied, acl_denied, no_route, …), overall or per route,
+// source or target server (by). Takes the filters of GetStatsLatency.
+func GetStatsByOutcome(repo database.Repository, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	by, ok := parseStatsGroup(w, r)
+	if !ok {
+		return
+	}
+	filter, ok := parseStatsFilter(w, r)
+	if !ok {
+		return
+	}
+	items, err := repo.StatsByOutcome(by, filter)
+	if err != nil {
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if items == nil {
+		items = []schema.OutcomeCount{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"by": by, "since": filter.Since, "items": items})
+}
+
+// GetStatsByRequestID returns the stats recorded for one request ID (normally one row; more if a client
+// reused the ID). Stats are written in batches, so a very recent request may not be visible yet.
+func GetStatsByRequestID(repo database.Repository, w http.ResponseWriter, r *http.Request, requestID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if requestID == "" {
+		respondJSONError(w, http.StatusBadRequest, "request ID required")
+		return
+	}
+	stats, err := repo.GetProxyStatsByRequestID(requestID)
+	if err != nil {
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(stats) == 0 {
+		respondJSONError(w, http.StatusNotFound, "no stats for request ID")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"stats": stats})
+}
+
 func GetStatsTPS(repo database.Repository, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -157,12 +348,20 @@ func GetStatsTPS(repo database.Repository, w http.ResponseWriter, r *http.Reques
 			window = d
 		}
 	}
-	bucket := defaultTPSBucket
+	bucket := autoTPSBucket(window)
 	if v := r.URL.Query().Get("bucket"); v != "" {
 		if d, err := time.ParseDuration(v); err == nil && d > 0 {
 			bucket = d
 		}
 	}
+	if bucket < time.Second {
+		respondJSONError(w, http.StatusBadRequest, "bucket must be at least 1s")
+		return
+	}
+	if window/bucket > maxTPSBuckets {
+		respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("window/bucket gives more than %d buckets; use a larger bucket", maxTPSBuckets))
+		return
+	}
 	since := time.Now().Add(-window)
 	buckets, err := repo.StatsTPS(since, bucket)
 	if err != nil {
@@ -172,7 +371,18 @@ func GetStatsTPS(repo database.Repository, w http.ResponseWriter, r *http.Reques
 	if buckets == nil {
 		buckets = []schema.BucketCount{}
 	}
-	respondJSON(w, http.StatusOK, map[string]interface{}{"buckets": buckets})
+	respondJSON(w, http.StatusOK, map[string]interface{}{"bucket_seconds": int64(bucket / time.Second), "buckets": buckets})
+}
+
+// autoTPSBucket returns the default bucket for a window: the smallest of tpsBucketSizes that keeps the
+// series at or below defaultTPSPoints (one day for longer windows).
+func autoTPSBucket(window time.Duration) time.Duration {
+	for _, b := range tpsBucketSizes {
+		if window/b <= defaultTPSPoints {
+			return b
+		}
+	}
+	return tpsBucketSizes[len(tpsBucketSizes)-1]
 }
 
 func ClearStats(repo database.Repository, w http.ResponseWriter, r *http.Request) {
@@ -187,6 +397,19 @@ func ClearStats(repo database.Repository, w http.ResponseWriter, r *http.Request
 	respondJSON(w, http.StatusOK, map[string]string{"ok": "true"})
 }
 
+// rawStatsSince returns the start of the window a raw-only aggregate actually covers: since, or the oldest
+// raw stat when since is earlier or unset (nil when there are no raw stats).
+func rawStatsSince(repo database.Repository, since *time.Time) (*time.Time, error) {
+	oldest, err := repo.OldestProxyStat()
+	if err != nil || oldest == nil {
+		return nil, err
+	}
+	if since != nil && since.After(*oldest) {
+		return since, nil
+	}
+	return oldest, nil
+}
+
 func parseStatsSinceLimit(r *http.Request) (since *time.Time, limit int) {
 	limit = 0
 	if v := r.URL.Query().Get("limit"); v != "" {
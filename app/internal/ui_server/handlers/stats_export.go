@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"FeatherProxy/app/internal/database"
+	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/stats"
+)
+
+// exportFlushEvery is how many rows are written between flushes of the export response.
+const exportFlushEvery = 500
+
+// GetStatsExport handles GET /api/stats/export: streams every stat matching the filters (see parseStatsFilter)
+// oldest first, as csv (default) or ndjson. With gzip=true the body is gzip-compressed and served as a .gz
+// download. Rows are read from the database one at a time, so the export is not capped like GET /api/stats.
+func GetStatsExport(repo database.Repository, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = stats.ExportCSV
+	}
+	contentType, ok := stats.ExportContentTypes[format]
+	if !ok {
+		respondJSONError(w, http.StatusBadRequest, "format must be csv or ndjson")
+		return
+	}
+	compress := false
+	switch r.URL.Query().Get("gzip") {
+	case "", "false", "0":
+	case "true", "1":
+		compress = true
+	default:
+		respondJSONError(w, http.StatusBadRequest, "gzip must be true or false")
+		return
+	}
+	filter, ok := parseStatsFilter(w, r)
+	if !ok {
+		return
+	}
+
+	filename := fmt.Sprintf("proxy-stats-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+	if compress {
+		contentType = "application/gzip"
+		filename += ".gz"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	// Large exports outlive the admin server's write timeout.
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	var out io.Writer = w
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(w)
+		out = gz
+	}
+	ew, _ := stats.NewExportWriter(out, format)
+	rows := 0
+	err := repo.StreamProxyStats(filter, func(s schema.ProxyStat) error {
+		if err := ew.Write(s); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushEvery == 0 {
+			_ = rc.Flush()
+		}
+		return nil
+	})
+	if err != nil && rows == 0 {
+		// Nothing is written yet, so the client can still get a proper error.
+		uiLogger.ErrorContext(r.Context(), "stats export failed", "error", err)
+		w.Header().Del("Content-Disposition")
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if closeErr := ew.Close(); err == nil {
+		err = closeErr
+	}
+	if gz != nil {
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		// Headers are sent already; the truncated body is all the client gets.
+		uiLogger.ErrorContext(r.Context(), "stats export failed", "rows", rows, "error", err)
+	}
+}
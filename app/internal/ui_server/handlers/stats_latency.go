@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"FeatherProxy/app/internal/database"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+)
+
+// defaultLatencyWindow is the window of latency aggregations when neither since nor window is given.
+const defaultLatencyWindow = time.Hour
+
+// defaultLatencyBucketsMs are the histogram upper bounds used when the request does not set buckets.
+var defaultLatencyBucketsMs = []int64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// maxLatencyBuckets bounds custom histogram buckets.
+const maxLatencyBuckets = 50
+
+// GetStatsLatency returns p50/p90/p95/p99, mean, min and max latency. Query: by (route, target_server,
+// source_server; empty for one overall row), since/until (RFC3339) or window (duration, default 1h),
+// route / source_server / target_server UUID filters, limit.
+func GetStatsLatency(repo database.Repository, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	by, ok := parseStatsGroup(w, r)
+	if !ok {
+		return
+	}
+	filter, ok := parseStatsFilter(w, r)
+	if !ok {
+		return
+	}
+	_, limit := parseStatsSinceLimit(r)
+	items, err := repo.StatsLatency(by, filter, limit)
+	if err != nil {
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if items == nil {
+		items = []schema.LatencyStats{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"by": by, "since": filter.Since, "items": items})
+}
+
+// GetStatsLatencyHistogram returns request counts per latency bucket. Query: buckets (comma-separated upper
+// bounds in ms; default 5,10,25,…,10000) plus the filters of GetStatsLatency. Buckets made of rollup
+// histogram bounds are also counted from rollups; others only from raw stats, and then raw_since is where
+// the counts start.
+func GetStatsLatencyHistogram(repo database.Repository, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	bounds := defaultLatencyBucketsMs
+	if v := r.URL.Query().Get("buckets"); v != "" {
+		bounds = nil
+		for _, part := range strings.Split(v, ",") {
+			n, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if err != nil || n <= 0 {
+				respondJSONError(w, http.StatusBadRequest, "buckets must be positive integers (ms)")
+				return
+			}
+			bounds = append(bounds, n)
+		}
+		if len(bounds) > maxLatencyBuckets {
+			respondJSONError(w, http.StatusBadRequest, "too many buckets (max 50)")
+			return
+		}
+		sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+		bounds = dedupeSorted(bounds)
+	}
+	filter, ok := parseStatsFilter(w, r)
+	if !ok {
+		return
+	}
+	buckets, err := repo.StatsLatencyHistogram(filter, bounds)
+	if err != nil {
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if buckets == nil {
+		buckets = []schema.LatencyBucket{}
+	}
+	resp := map[string]interface{}{"since": filter.Since, "buckets": buckets}
+	if !schema.RollupHistogramCompatible(bounds) {
+		rawSince, err := rawStatsSince(repo, filter.Since)
+		if err != nil {
+			respondJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		resp["raw_since"] = rawSince
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// parseStatsGroup reads the by parameter: route, target_server or source_server (hyphens accepted), or
+// empty for no grouping. Writes 400 on bad input.
+func parseStatsGroup(w http.ResponseWriter, r *http.Request) (string, bool) {
+	by := strings.ReplaceAll(r.URL.Query().Get("by"), "-", "_")
+	switch by {
+	case schema.StatsGroupNone, schema.StatsGroupRoute, schema.StatsGroupTargetServer, schema.StatsGroupSourceServer:
+		return by, true
+	}
+	respondJSONError(w, http.StatusBadRequest, "by must be route, target_server or source_server")
+	return "", false
+}
+
+// parseStatsFilter reads since/until/window, the route, source_server and target_server UUID filters and
+// outcome. Without since, the window (default defaultLatencyWindow) ending now is used. Writes 400 on bad input.
+func parseStatsFilter(w http.ResponseWriter, r *http.Request) (schema.StatsFilter, bool) {
+	var f schema.StatsFilter
+	q := r.URL.Query()
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				respondJSONError(w, http.StatusBadRequest, "invalid "+p.name+" (use RFC3339)")
+				return f, false
+			}
+			*p.dst = &t
+		}
+	}
+	if f.Since == nil {
+		window := defaultLatencyWindow
+		if v := q.Get("window"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				respondJSONError(w, http.StatusBadRequest, "invalid window (use a duration, e.g. 1h)")
+				return f, false
+			}
+			window = d
+		}
+		since := time.Now().Add(-window)
+		f.Since = &since
+	}
+	return f, parseStatsScope(w, q, &f)
+}
+
+// parseStatsScope reads the route, source_server and target_server UUID filters and outcome into f.
+// Writes 400 and returns false on bad input.
+func parseStatsScope(w http.ResponseWriter, q url.Values, f *schema.StatsFilter) bool {
+	for _, p := range []struct {
+		name string
+		dst  **uuid.UUID
+	}{{"route", &f.RouteUUID}, {"source_server", &f.SourceServerUUID}, {"target_server", &f.TargetServerUUID}} {
+		if v := q.Get(p.name); v != "" {
+			id, ok := parseUUIDParam(w, v, "invalid "+p.name+" UUID")
+			if !ok {
+				return false
+			}
+			*p.dst = &id
+		}
+	}
+	if v := q.Get("outcome"); v != "" {
+		if !slices.Contains(schema.Outcomes, v) {
+			respondJSONError(w, http.StatusBadRequest, "invalid outcome")
+			return false
+		}
+		f.Outcome = v
+	}
+	return true
+}
+
+// parseStatusParam parses a status filter: an exact code such as 404, or a class such as 5xx.
+func parseStatusParam(v string) (code, class int, ok bool) {
+	if len(v) == 3 && v[1:] == "xx" && v[0] >= '1' && v[0] <= '5' {
+		return 0, int(v[0] - '0'), true
+	}
+	if n, err := strconv.Atoi(v); err == nil && n >= 100 && n <= 599 {
+		return n, 0, true
+	}
+	return 0, 0, false
+}
+
+func dedupeSorted(s []int64) []int64 {
+	out := s[:0]
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"FeatherProxy/app/internal/database/schema"
+	"FeatherProxy/app/internal/stats"
+
+	"github.com/google/uuid"
+)
+
+// liveKeepAlive is how often an idle live feed sends an SSE comment, so proxies keep the connection open.
+const liveKeepAlive = 15 * time.Second
+
+// GetStatsLive handles GET /api/stats/live: a Server-Sent Events stream of every request as the stats
+// service records it, before the batch flush. Each stat is sent as a "stat" event with the ProxyStat JSON;
+// when the client falls behind and its buffer overflows, a "dropped" event reports how many it missed.
+// Query filters: source_server, route (UUIDs), status (a code such as 404 or a class such as 5xx),
+// client_ip and outcome.
+func GetStatsLive(hub *stats.Hub, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if hub == nil {
+		respondJSONError(w, http.StatusServiceUnavailable, "live feed not available")
+		return
+	}
+	filter, ok := parseLiveFilter(w, r)
+	if !ok {
+		return
+	}
+	sub, err := hub.Subscribe(filter, 0)
+	if err != nil {
+		status := http.StatusServiceUnavailable
+		if !errors.Is(err, stats.ErrTooManySubscribers) && !errors.Is(err, stats.ErrHubClosed) {
+			status = http.StatusInternalServerError
+		}
+		respondJSONError(w, status, err.Error())
+		return
+	}
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprint(w, ": live\n\n"); err != nil {
+		return
+	}
+	if err := rc.Flush(); err != nil {
+		uiLogger.WarnContext(r.Context(), "live feed: response cannot be flushed", "error", err)
+		return
+	}
+	uiLogger.DebugContext(r.Context(), "live feed subscribed", "remote", r.RemoteAddr)
+
+	keepAlive := time.NewTicker(liveKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case stat, ok := <-sub.C:
+			if !ok {
+				return // stats service stopped
+			}
+			if n := sub.TakeDropped(); n > 0 {
+				if _, err := fmt.Fprintf(w, "event: dropped\ndata: {\"count\":%d}\n\n", n); err != nil {
+					return
+				}
+			}
+			data, err := json.Marshal(stat)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: stat\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// parseLiveFilter reads the live feed filters from the query. Writes 400 on bad input.
+func parseLiveFilter(w http.ResponseWriter, r *http.Request) (stats.LiveFilter, bool) {
+	var f stats.LiveFilter
+	q := r.URL.Query()
+	for _, p := range []struct {
+		name string
+		dst  **uuid.UUID
+	}{{"route", &f.RouteUUID}, {"source_server", &f.SourceServerUUID}} {
+		if v := q.Get(p.name); v != "" {
+			id, ok := parseUUIDParam(w, v, "invalid "+p.name+" UUID")
+			if !ok {
+				return f, false
+			}
+			*p.dst = &id
+		}
+	}
+	if v := q.Get("status"); v != "" {
+		var ok bool
+		if f.Status, f.StatusClass, ok = parseStatusParam(v); !ok {
+			respondJSONError(w, http.StatusBadRequest, "invalid status (use a code such as 404 or a class such as 5xx)")
+			return f, false
+		}
+	}
+	if v := q.Get("client_ip"); v != "" {
+		ip := net.ParseIP(v)
+		if ip == nil {
+			respondJSONError(w, http.StatusBadRequest, "invalid client_ip")
+			return f, false
+		}
+		f.ClientIP = ip.String()
+	}
+	if v := q.Get("outcome"); v != "" {
+		if !slices.Contains(schema.Outcomes, v) {
+			respondJSONError(w, http.StatusBadRequest, "invalid outcome")
+			return f, false
+		}
+		f.Outcome = v
+	}
+	return f, true
+}
@@ -23,11 +23,12 @@ func ListTargetServers(repo database.Repository, w http.ResponseWriter, _ *http.
 
 func CreateTargetServer(repo database.Repository, w http.ResponseWriter, r *http.Request) {
 	var body struct {
-		Name     string `json:"name"`
-		Protocol string `json:"protocol"`
-		Host     string `json:"host"`
-		Port     int    `json:"port"`
-		BasePath string `json:"base_path"`
+		Name         string `json:"name"`
+		Protocol     string `json:"protocol"`
+		Host         string `json:"host"`
+		Port         int    `json:"port"`
+		BasePath     string `json:"base_path"`
+		PreserveHost bool   `json:"preserve_host"`
 	}
 	if !decodeJSON(w, r, &body) {
 		return
@@ -43,6 +44,7 @@ func CreateTargetServer(repo database.Repository, w http.ResponseWriter, r *http
 		Host:             body.Host,
 		Port:             body.Port,
 		BasePath:         body.BasePath,
+		PreserveHost:     body.PreserveHost,
 	}
 	if err := repo.CreateTargetServer(svc); err != nil {
 		respondJSONError(w, http.StatusInternalServerError, err.Error())
@@ -73,11 +75,12 @@ func UpdateTargetServer(repo database.Repository, w http.ResponseWriter, r *http
 		return
 	}
 	var body struct {
-		Name     string `json:"name"`
-		Protocol string `json:"protocol"`
-		Host     string `json:"host"`
-		Port     int    `json:"port"`
-		BasePath string `json:"base_path"`
+		Name         string `json:"name"`
+		Protocol     string `json:"protocol"`
+		Host         string `json:"host"`
+		Port         int    `json:"port"`
+		BasePath     string `json:"base_path"`
+		PreserveHost bool   `json:"preserve_host"`
 	}
 	if !decodeJSON(w, r, &body) {
 		return
@@ -91,6 +94,7 @@ func UpdateTargetServer(repo database.Repository, w http.ResponseWriter, r *http
 	existing.Host = body.Host
 	existing.Port = body.Port
 	existing.BasePath = body.BasePath
+	existing.PreserveHost = body.PreserveHost
 	if err := repo.UpdateTargetServer(existing); err != nil {
 		respondJSONError(w, http.StatusInternalServerError, err.Error())
 		return
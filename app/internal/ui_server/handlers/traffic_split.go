@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"FeatherProxy/app/internal/database"
+	"FeatherProxy/app/internal/database/schema"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func GetRouteTrafficSplit(repo database.Repository, w http.ResponseWriter, _ *http.Request, routeIDStr string) {
+	routeID, ok := parseUUIDParam(w, routeIDStr, "invalid route UUID")
+	if !ok {
+		return
+	}
+	if _, err := repo.GetRoute(routeID); !handleRepoGetError(w, err) {
+		return
+	}
+	split, err := repo.GetTrafficSplit(routeID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// No split configured: report an empty one so the UI can render the form.
+		respondJSON(w, http.StatusOK, schema.TrafficSplit{RouteUUID: routeID, Rules: []schema.TrafficRule{}})
+		return
+	}
+	if !handleRepoGetError(w, err) {
+		return
+	}
+	respondJSON(w, http.StatusOK, split)
+}
+
+func PutRouteTrafficSplit(repo database.Repository, w http.ResponseWriter, r *http.Request, routeIDStr string) {
+	routeID, ok := parseUUIDParam(w, routeIDStr, "invalid route UUID")
+	if !ok {
+		return
+	}
+	if _, err := repo.GetRoute(routeID); !handleRepoGetError(w, err) {
+		return
+	}
+	var body struct {
+		Rules        []schema.TrafficRule `json:"rules"`
+		Sticky       bool                 `json:"sticky"`
+		StickyCookie string               `json:"sticky_cookie"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	if msg := validateTrafficRules(repo, body.Rules); msg != "" {
+		respondJSONError(w, http.StatusBadRequest, msg)
+		return
+	}
+	split := schema.TrafficSplit{
+		RouteUUID:    routeID,
+		Rules:        body.Rules,
+		Sticky:       body.Sticky,
+		StickyCookie: strings.TrimSpace(body.StickyCookie),
+	}
+	if err := repo.SetTrafficSplit(split); err != nil {
+		if errors.Is(err, database.ErrProtocolMismatch) {
+			respondJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	current, _ := repo.GetTrafficSplit(routeID)
+	respondJSON(w, http.StatusOK, current)
+}
+
+// validateTrafficRules trims the variant names in place and returns an error message for the first
+// invalid rule, or "" if all rules are valid.
+func validateTrafficRules(repo database.Repository, rules []schema.TrafficRule) string {
+	seen := make(map[string]bool, len(rules))
+	total := 0
+	for i := range rules {
+		rules[i].Variant = strings.TrimSpace(rules[i].Variant)
+		rule := rules[i]
+		name := rule.Variant
+		if name == "" {
+			return "variant name required for every rule"
+		}
+		if name == schema.PrimaryVariant {
+			return "variant name \"" + schema.PrimaryVariant + "\" is reserved for the route's own target"
+		}
+		if seen[name] {
+			return "duplicate variant " + name
+		}
+		seen[name] = true
+		if rule.Weight < 0 || rule.Weight > 100 {
+			return "weight must be between 0 and 100"
+		}
+		total += rule.Weight
+		if rule.TargetServerUUID == uuid.Nil {
+			return "target_server_uuid required for variant " + name
+		}
+		if _, err := repo.GetTargetServer(rule.TargetServerUUID); err != nil {
+			return "target server not found for variant " + name
+		}
+	}
+	if total > 100 {
+		return "sum of weights must not exceed 100"
+	}
+	return ""
+}
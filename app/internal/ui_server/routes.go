@@ -14,6 +14,7 @@ func (s *Server) Routes() http.Handler {
 
 	// API
 	mux.HandleFunc("/api/reload", s.handleReload)
+	mux.HandleFunc("/api/logging", s.handleLogging)
 	mux.HandleFunc("/api/source-servers", s.handleSourceServersCollection)
 	mux.HandleFunc("/api/source-servers/", s.handleSourceServerByID)
 	mux.HandleFunc("/api/target-servers", s.handleTargetServersCollection)
@@ -27,12 +28,33 @@ func (s *Server) Routes() http.Handler {
 	mux.HandleFunc("/api/stats/summary", s.handleStatsSummary)
 	mux.HandleFunc("/api/stats/by-route", s.handleStatsByRoute)
 	mux.HandleFunc("/api/stats/by-caller", s.handleStatsByCaller)
+	mux.HandleFunc("/api/stats/by-country", s.handleStatsByCountry)
+	mux.HandleFunc("/api/stats/by-asn", s.handleStatsByASN)
 	mux.HandleFunc("/api/stats/by-source-server", s.handleStatsBySourceServer)
 	mux.HandleFunc("/api/stats/by-target-server", s.handleStatsByTargetServer)
+	mux.HandleFunc("/api/stats/by-variant", s.handleStatsByVariant)
+	mux.HandleFunc("/api/stats/by-outcome", s.handleStatsByOutcome)
 	mux.HandleFunc("/api/stats/tps", s.handleStatsTPS)
+	mux.HandleFunc("/api/stats/latency/histogram", s.handleStatsLatencyHistogram)
+	mux.HandleFunc("/api/stats/latency", s.handleStatsLatency)
+	mux.HandleFunc("/api/stats/export", s.handleStatsExport)
+	mux.HandleFunc("/api/stats/live", s.handleStatsLive)
+	mux.HandleFunc("/api/stats/requests/", s.handleStatsByRequestID)
 	mux.HandleFunc("/api/stats/clear", s.handleStatsClear)
 	mux.HandleFunc("/api/stats", s.handleStatsCollection)
 
+	// Alerting API
+	mux.HandleFunc("/api/alerts", s.handleAlerts)
+	mux.HandleFunc("/api/alerts/rules", s.handleAlertRulesCollection)
+	mux.HandleFunc("/api/alerts/rules/", s.handleAlertRuleByID)
+	mux.HandleFunc("/api/alerts/webhooks", s.handleAlertWebhooksCollection)
+	mux.HandleFunc("/api/alerts/webhooks/", s.handleAlertWebhookByID)
+	mux.HandleFunc("/api/alerts/silences", s.handleAlertSilencesCollection)
+	mux.HandleFunc("/api/alerts/silences/", s.handleAlertSilenceByID)
+
+	// Prometheus metrics, when served on the admin server (METRICS_ADDR unset)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+
 	// UI: serve anything under static from disk (no embed)
 	mux.HandleFunc("/", s.handleStatic)
 
@@ -61,6 +83,128 @@ func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(map[string]string{"ok": "reload triggered"})
 }
 
+// handleLogging: GET /api/logging (levels per subsystem), PUT /api/logging (change levels at runtime).
+func (s *Server) handleLogging(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/logging" {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetLogging(w, r)
+	case http.MethodPut:
+		handlers.SetLogging(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAlerts: GET /api/alerts (state of every enabled rule).
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	handlers.ListAlertStatuses(s.alerts, w, r)
+}
+
+// handleAlertRulesCollection: GET /api/alerts/rules (list), POST /api/alerts/rules (create).
+func (s *Server) handleAlertRulesCollection(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.ListAlertRules(s.repo, w, r)
+	case http.MethodPost:
+		handlers.CreateAlertRule(s.repo, w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAlertRuleByID: GET/PUT/DELETE /api/alerts/rules/{uuid}.
+func (s *Server) handleAlertRuleByID(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/alerts/rules/")
+	if path == "" || strings.Contains(path, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetAlertRule(s.repo, w, r, path)
+	case http.MethodPut:
+		handlers.UpdateAlertRule(s.repo, w, r, path)
+	case http.MethodDelete:
+		handlers.DeleteAlertRule(s.repo, w, r, path)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAlertWebhooksCollection: GET /api/alerts/webhooks (list), POST /api/alerts/webhooks (create).
+func (s *Server) handleAlertWebhooksCollection(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.ListAlertWebhooks(s.repo, w, r)
+	case http.MethodPost:
+		handlers.CreateAlertWebhook(s.repo, w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAlertWebhookByID: GET/PUT/DELETE /api/alerts/webhooks/{uuid}.
+func (s *Server) handleAlertWebhookByID(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/alerts/webhooks/")
+	if path == "" || strings.Contains(path, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		handlers.GetAlertWebhook(s.repo, w, r, path)
+	case http.MethodPut:
+		handlers.UpdateAlertWebhook(s.repo, w, r, path)
+	case http.MethodDelete:
+		handlers.DeleteAlertWebhook(s.repo, w, r, path)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAlertSilencesCollection: GET /api/alerts/silences (active and upcoming), POST (create).
+func (s *Server) handleAlertSilencesCollection(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handlers.ListAlertSilences(s.repo, w, r)
+	case http.MethodPost:
+		handlers.CreateAlertSilence(s.repo, w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAlertSilenceByID: DELETE /api/alerts/silences/{uuid} (expire a silence early).
+func (s *Server) handleAlertSilenceByID(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/alerts/silences/")
+	if path == "" || strings.Contains(path, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	handlers.DeleteAlertSilence(s.repo, w, r, path)
+}
+
+// handleMetrics: GET /metrics (Prometheus text format) when a metrics handler is set.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if s.metrics == nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.metrics.ServeHTTP(w, r)
+}
+
 // handleRoutesCollection: GET /api/routes (list), POST /api/routes (create).
 func (s *Server) handleRoutesCollection(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/api/routes" {
@@ -93,7 +237,7 @@ func (s *Server) handleSourceServersCollection(w http.ResponseWriter, r *http.Re
 	}
 }
 
-// handleSourceServerByID: GET/PUT/DELETE /api/source-servers/{uuid} or GET/PUT /api/source-servers/{uuid}/options.
+// handleSourceServerByID: GET/PUT/DELETE /api/source-servers/{uuid} or GET/PUT /api/source-servers/{uuid}/options, .../acl, .../error-pages, .../maintenance, .../access-log.
 func (s *Server) handleSourceServerByID(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/api/source-servers/")
 	if path == "" {
@@ -122,7 +266,40 @@ func (s *Server) handleSourceServerByID(w http.ResponseWriter, r *http.Request)
 		case http.MethodGet:
 			handlers.GetACLOptions(s.repo, w, r, uuidPart)
 		case http.MethodPut:
-			handlers.SetACLOptions(s.repo, w, r, uuidPart)
+			handlers.SetACLOptions(s.repo, w, r, uuidPart, s.geo != nil)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if len(parts) == 2 && parts[1] == "maintenance" {
+		switch r.Method {
+		case http.MethodGet:
+			handlers.GetSourceServerMaintenance(s.repo, w, r, uuidPart)
+		case http.MethodPut:
+			handlers.PutSourceServerMaintenance(s.repo, w, r, uuidPart)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if len(parts) == 2 && parts[1] == "access-log" {
+		switch r.Method {
+		case http.MethodGet:
+			handlers.GetAccessLogOptions(s.repo, w, r, uuidPart)
+		case http.MethodPut:
+			handlers.SetAccessLogOptions(s.repo, w, r, uuidPart)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if len(parts) == 2 && parts[1] == "error-pages" {
+		switch r.Method {
+		case http.MethodGet:
+			handlers.GetErrorPages(s.repo, w, r, uuidPart)
+		case http.MethodPut:
+			handlers.SetErrorPages(s.repo, w, r, uuidPart)
 		default:
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		}
@@ -179,7 +356,7 @@ func (s *Server) handleTargetServerByID(w http.ResponseWriter, r *http.Request)
 	}
 }
 
-// handleRouteOrRouteAuth: GET/PUT/DELETE /api/routes/{uuid} or .../source-auth or .../target-auth.
+// handleRouteOrRouteAuth: GET/PUT/DELETE /api/routes/{uuid} or .../source-auth, .../target-auth, .../traffic-split, .../balancing, .../maintenance.
 func (s *Server) handleRouteOrRouteAuth(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/api/routes/")
 	if path == "" {
@@ -214,6 +391,39 @@ func (s *Server) handleRouteOrRouteAuth(w http.ResponseWriter, r *http.Request)
 		}
 		return
 	}
+	if subPath == "traffic-split" {
+		switch r.Method {
+		case http.MethodGet:
+			handlers.GetRouteTrafficSplit(s.repo, w, r, routeIDStr)
+		case http.MethodPut:
+			handlers.PutRouteTrafficSplit(s.repo, w, r, routeIDStr)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if subPath == "maintenance" {
+		switch r.Method {
+		case http.MethodGet:
+			handlers.GetRouteMaintenance(s.repo, w, r, routeIDStr)
+		case http.MethodPut:
+			handlers.PutRouteMaintenance(s.repo, w, r, routeIDStr)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if subPath == "balancing" {
+		switch r.Method {
+		case http.MethodGet:
+			handlers.GetRouteBalancing(s.repo, w, r, routeIDStr)
+		case http.MethodPut:
+			handlers.PutRouteBalancing(s.repo, w, r, routeIDStr)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
 	if subPath != "" {
 		http.NotFound(w, r)
 		return
@@ -293,6 +503,14 @@ func (s *Server) handleStatsByCaller(w http.ResponseWriter, r *http.Request) {
 	handlers.GetStatsByCaller(s.repo, w, r)
 }
 
+func (s *Server) handleStatsByCountry(w http.ResponseWriter, r *http.Request) {
+	handlers.GetStatsByCountry(s.repo, w, r)
+}
+
+func (s *Server) handleStatsByASN(w http.ResponseWriter, r *http.Request) {
+	handlers.GetStatsByASN(s.repo, w, r)
+}
+
 func (s *Server) handleStatsBySourceServer(w http.ResponseWriter, r *http.Request) {
 	handlers.GetStatsBySourceServer(s.repo, w, r)
 }
@@ -301,6 +519,35 @@ func (s *Server) handleStatsByTargetServer(w http.ResponseWriter, r *http.Reques
 	handlers.GetStatsByTargetServer(s.repo, w, r)
 }
 
+func (s *Server) handleStatsByVariant(w http.ResponseWriter, r *http.Request) {
+	handlers.GetStatsByVariant(s.repo, w, r)
+}
+
+// handleStatsByRequestID: GET /api/stats/requests/{request_id}.
+func (s *Server) handleStatsByRequestID(w http.ResponseWriter, r *http.Request) {
+	handlers.GetStatsByRequestID(s.repo, w, r, strings.TrimPrefix(r.URL.Path, "/api/stats/requests/"))
+}
+
+func (s *Server) handleStatsByOutcome(w http.ResponseWriter, r *http.Request) {
+	handlers.GetStatsByOutcome(s.repo, w, r)
+}
+
+func (s *Server) handleStatsLatency(w http.ResponseWriter, r *http.Request) {
+	handlers.GetStatsLatency(s.repo, w, r)
+}
+
+func (s *Server) handleStatsExport(w http.ResponseWriter, r *http.Request) {
+	handlers.GetStatsExport(s.repo, w, r)
+}
+
+func (s *Server) handleStatsLive(w http.ResponseWriter, r *http.Request) {
+	handlers.GetStatsLive(s.live, w, r)
+}
+
+func (s *Server) handleStatsLatencyHistogram(w http.ResponseWriter, r *http.Request) {
+	handlers.GetStatsLatencyHistogram(s.repo, w, r)
+}
+
 func (s *Server) handleStatsTPS(w http.ResponseWriter, r *http.Request) {
 	handlers.GetStatsTPS(s.repo, w, r)
 }
@@ -6,7 +6,10 @@ import (
 	"net/http"
 	"time"
 
+	"FeatherProxy/app/internal/alerting"
 	"FeatherProxy/app/internal/database"
+	"FeatherProxy/app/internal/geoip"
+	"FeatherProxy/app/internal/stats"
 )
 
 // Server runs the HTTP service for the route management UI and API.
@@ -15,7 +18,11 @@ type Server struct {
 	staticDir  string
 	httpServer *http.Server
 	repo       database.Repository
-	onReload   func() // optional: when set, POST /api/reload triggers proxy restart
+	onReload   func()            // optional: when set, POST /api/reload triggers proxy restart
+	metrics    http.Handler      // optional: when set, served at GET /metrics
+	live       *stats.Hub        // optional: when set, streamed at GET /api/stats/live
+	alerts     *alerting.Service // optional: when set, rule states are served at GET /api/alerts
+	geo        *geoip.Resolver   // optional: country and ASN ACL entries are rejected without it
 }
 
 // NewServer builds a server that serves the UI and route API on the given address.
@@ -33,6 +40,28 @@ func NewServer(addr string, repo database.Repository, staticDir string, onReload
 	return s
 }
 
+// SetMetricsHandler serves h at /metrics on this server. Call before Run; nil leaves /metrics unserved.
+func (s *Server) SetMetricsHandler(h http.Handler) {
+	s.metrics = h
+}
+
+// SetLiveFeed streams the stats recorded through h at /api/stats/live. Call before Run; nil answers 503.
+func (s *Server) SetLiveFeed(h *stats.Hub) {
+	s.live = h
+}
+
+// SetAlerting reports the rule states of svc at /api/alerts. Call before Run; nil answers 503.
+// Rules, webhooks and silences are managed through the repository either way.
+func (s *Server) SetAlerting(svc *alerting.Service) {
+	s.alerts = svc
+}
+
+// SetGeoIP tells the server GeoIP databases are configured, so ACL options may use country and ASN
+// entries. Call before Run.
+func (s *Server) SetGeoIP(geo *geoip.Resolver) {
+	s.geo = geo
+}
+
 // Run starts the HTTP server and blocks until the context is cancelled or the server errors.
 func (s *Server) Run(ctx context.Context) error {
 	go func() {
@@ -60,18 +60,86 @@ func (stubRepo) SetTargetAuthForRoute(uuid.UUID, *uuid.UUID) error           { r
 func (stubRepo) GetTargetAuthenticationWithPlainToken(uuid.UUID) (schema.Authentication, bool, error) {
 	return schema.Authentication{}, false, nil
 }
+func (stubRepo) GetTrafficSplit(uuid.UUID) (schema.TrafficSplit, error) {
+	return schema.TrafficSplit{}, gorm.ErrRecordNotFound
+}
+func (stubRepo) SetTrafficSplit(schema.TrafficSplit) error { return nil }
+func (stubRepo) GetRouteBalancing(uuid.UUID) (schema.RouteBalancing, error) {
+	return schema.RouteBalancing{}, gorm.ErrRecordNotFound
+}
+func (stubRepo) SetRouteBalancing(schema.RouteBalancing) error { return nil }
+func (stubRepo) GetErrorPages(uuid.UUID) (schema.ErrorPages, error) {
+	return schema.ErrorPages{}, gorm.ErrRecordNotFound
+}
+func (stubRepo) SetErrorPages(schema.ErrorPages) error { return nil }
+func (stubRepo) GetAccessLogOptions(uuid.UUID) (schema.AccessLogOptions, error) {
+	return schema.AccessLogOptions{}, gorm.ErrRecordNotFound
+}
+func (stubRepo) SetAccessLogOptions(schema.AccessLogOptions) error { return nil }
+func (stubRepo) GetMaintenance(string, uuid.UUID) (schema.Maintenance, error) {
+	return schema.Maintenance{}, gorm.ErrRecordNotFound
+}
+func (stubRepo) SetMaintenance(schema.Maintenance) error { return nil }
 func (stubRepo) CreateProxyStats([]schema.ProxyStat) error { return nil }
-func (stubRepo) ListProxyStats(int, int, *time.Time) ([]schema.ProxyStat, int64, error) {
-	return nil, 0, nil
+func (stubRepo) ListProxyStats(schema.StatsListQuery) (schema.StatsPage, error) {
+	return schema.StatsPage{}, nil
 }
+func (stubRepo) GetProxyStatsByRequestID(string) ([]schema.ProxyStat, error) { return nil, nil }
 func (stubRepo) DeleteProxyStatsOlderThan(time.Time) (int64, error) { return 0, nil }
 func (stubRepo) ClearAllProxyStats() error                 { return nil }
 func (stubRepo) StatsSummary() (schema.StatsSummary, error) { return schema.StatsSummary{}, nil }
 func (stubRepo) StatsByRoute(*time.Time, int) ([]schema.RouteCount, error) { return nil, nil }
 func (stubRepo) StatsByCaller(*time.Time, int) ([]schema.CallerCount, error) { return nil, nil }
+func (stubRepo) StatsByCountry(*time.Time, int) ([]schema.CountryCount, error) { return nil, nil }
+func (stubRepo) StatsByASN(*time.Time, int) ([]schema.ASNCount, error) { return nil, nil }
 func (stubRepo) StatsBySourceServer(*time.Time) ([]schema.ServerCount, error) { return nil, nil }
 func (stubRepo) StatsByTargetServer(*time.Time) ([]schema.ServerCount, error) { return nil, nil }
+func (stubRepo) StatsByVariant(*uuid.UUID, *time.Time) ([]schema.VariantCount, error) {
+	return nil, nil
+}
 func (stubRepo) StatsTPS(time.Time, time.Duration) ([]schema.BucketCount, error) { return nil, nil }
+func (stubRepo) StatsLatency(string, schema.StatsFilter, int) ([]schema.LatencyStats, error) {
+	return nil, nil
+}
+func (stubRepo) StatsLatencyHistogram(schema.StatsFilter, []int64) ([]schema.LatencyBucket, error) {
+	return nil, nil
+}
+func (stubRepo) StreamProxyStats(schema.StatsFilter, func(schema.ProxyStat) error) error {
+	return nil
+}
+func (stubRepo) RollupProxyStats(time.Time) error { return nil }
+func (stubRepo) StatsStatusCounts(schema.StatsFilter) (schema.StatusCounts, error) {
+	return schema.StatusCounts{}, nil
+}
+func (stubRepo) OldestProxyStat() (*time.Time, error) { return nil, nil }
+func (stubRepo) ListAlertRules() ([]schema.AlertRule, error) { return nil, nil }
+func (stubRepo) GetAlertRule(uuid.UUID) (schema.AlertRule, error) {
+	return schema.AlertRule{}, gorm.ErrRecordNotFound
+}
+func (stubRepo) CreateAlertRule(schema.AlertRule) error        { return nil }
+func (stubRepo) UpdateAlertRule(schema.AlertRule) error        { return nil }
+func (stubRepo) DeleteAlertRule(uuid.UUID) error               { return nil }
+func (stubRepo) ListAlertWebhooks() ([]schema.AlertWebhook, error) { return nil, nil }
+func (stubRepo) GetAlertWebhook(uuid.UUID) (schema.AlertWebhook, error) {
+	return schema.AlertWebhook{}, gorm.ErrRecordNotFound
+}
+func (stubRepo) CreateAlertWebhook(schema.AlertWebhook) error { return nil }
+func (stubRepo) UpdateAlertWebhook(schema.AlertWebhook) error { return nil }
+func (stubRepo) DeleteAlertWebhook(uuid.UUID) error           { return nil }
+func (stubRepo) ListAlertSilences(time.Time) ([]schema.AlertSilence, error) {
+	return nil, nil
+}
+func (stubRepo) CreateAlertSilence(schema.AlertSilence) error { return nil }
+func (stubRepo) DeleteAlertSilence(uuid.UUID) error           { return nil }
+func (stubRepo) DeleteAlertSilencesEndedBefore(time.Time) (int64, error) {
+	return 0, nil
+}
+func (stubRepo) DeleteStatsRollupsOlderThan(string, time.Time) (int64, error) {
+	return 0, nil
+}
+func (stubRepo) StatsByOutcome(string, schema.StatsFilter) ([]schema.OutcomeCount, error) {
+	return nil, nil
+}
 
 var _ database.Repository = (*stubRepo)(nil)
 
@@ -191,6 +259,23 @@ func TestServer_Routes_routes(t *testing.T) {
 	}
 }
 
+func TestServer_Routes_metrics(t *testing.T) {
+	s := NewServer(":0", stubRepo{}, "internal/ui_server/static", nil)
+	rec := httptest.NewRecorder()
+	s.Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("without handler: status = %d, want 404", rec.Code)
+	}
+	s.SetMetricsHandler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("# metrics\n"))
+	}))
+	rec = httptest.NewRecorder()
+	s.Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "# metrics\n" {
+		t.Errorf("with handler: status = %d body = %q", rec.Code, rec.Body.String())
+	}
+}
+
 func TestServer_Run_shutdown(t *testing.T) {
 	s := NewServer(":0", stubRepo{}, "internal/ui_server/static", nil)
 	ctx, cancel := context.WithCancel(context.Background())
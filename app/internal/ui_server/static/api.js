@@ -223,11 +223,13 @@ export async function reloadProxies() {
 // --- Stats ---
 const API_STATS = '/api/stats';
 
+// getStats lists raw stats. params: limit, cursor (next_cursor of the previous page), sort, since, until,
+// route, source_server, target_server, outcome, method, status, client_ip, path_prefix, min_duration_ms.
 export async function getStats(params = {}) {
   const q = new URLSearchParams();
-  if (params.limit != null) q.set('limit', params.limit);
-  if (params.offset != null) q.set('offset', params.offset);
-  if (params.since) q.set('since', params.since);
+  Object.keys(params).forEach(function (k) {
+    if (params[k] != null && params[k] !== '') q.set(k, params[k]);
+  });
   const url = API_STATS + (q.toString() ? '?' + q.toString() : '');
   const res = await fetch(url);
   if (!res.ok) return { ok: false, error: (await res.json().catch(() => ({}))).error || res.statusText };
@@ -260,6 +262,26 @@ export async function getStatsByCaller(params = {}) {
   return { ok: true, data: await res.json() };
 }
 
+export async function getStatsByCountry(params = {}) {
+  const q = new URLSearchParams();
+  if (params.since) q.set('since', params.since);
+  if (params.limit != null) q.set('limit', params.limit);
+  const url = API_STATS + '/by-country' + (q.toString() ? '?' + q.toString() : '');
+  const res = await fetch(url);
+  if (!res.ok) return { ok: false, error: (await res.json().catch(() => ({}))).error || res.statusText };
+  return { ok: true, data: await res.json() };
+}
+
+export async function getStatsByASN(params = {}) {
+  const q = new URLSearchParams();
+  if (params.since) q.set('since', params.since);
+  if (params.limit != null) q.set('limit', params.limit);
+  const url = API_STATS + '/by-asn' + (q.toString() ? '?' + q.toString() : '');
+  const res = await fetch(url);
+  if (!res.ok) return { ok: false, error: (await res.json().catch(() => ({}))).error || res.statusText };
+  return { ok: true, data: await res.json() };
+}
+
 export async function getStatsBySourceServer(params = {}) {
   const q = new URLSearchParams();
   if (params.since) q.set('since', params.since);
@@ -117,10 +117,12 @@ async function submitCreateSource(e) {
   const protocol = fd.get('protocol');
   const uuid = result.data && result.data.source_server_uuid;
   if (protocol === 'https' && uuid) {
-    const optsResult = await api.setSourceServerOptions(uuid, {
+    // PUT replaces all options: keep the ones this form does not edit (request ID and forwarding headers).
+    const current = await api.getSourceServerOptions(uuid);
+    const optsResult = await api.setSourceServerOptions(uuid, Object.assign({}, current.ok && current.data ? current.data : {}, {
       tls_cert_path: fd.get('tls_cert_path') || '',
       tls_key_path: fd.get('tls_key_path') || ''
-    });
+    }));
     if (!optsResult.ok) {
       showError(errEl, optsResult.error || 'Failed to save TLS options');
       return;
@@ -128,11 +130,13 @@ async function submitCreateSource(e) {
   }
   const aclAllow = (fd.get('acl_allow_list') || '').split(/\r?\n/).map(function (x) { return x.trim(); }).filter(Boolean);
   const aclDeny = (fd.get('acl_deny_list') || '').split(/\r?\n/).map(function (x) { return x.trim(); }).filter(Boolean);
+  const aclTrusted = (fd.get('acl_trusted_proxies') || '').split(/\r?\n/).map(function (x) { return x.trim(); }).filter(Boolean);
   const aclResult = await api.setSourceServerACL(uuid, {
     mode: fd.get('acl_mode') || 'off',
     client_ip_header: (fd.get('acl_client_ip_header') || '').trim(),
     allow_list: aclAllow,
-    deny_list: aclDeny
+    deny_list: aclDeny,
+    trusted_proxies: aclTrusted
   });
   if (!aclResult.ok) {
     showError(errEl, aclResult.error || 'Failed to save ACL options');
@@ -171,11 +175,13 @@ async function editSource(uuid) {
     form.querySelector('[name="acl_client_ip_header"]').value = acl.client_ip_header || '';
     form.querySelector('[name="acl_allow_list"]').value = (acl.allow_list || []).join('\n');
     form.querySelector('[name="acl_deny_list"]').value = (acl.deny_list || []).join('\n');
+    form.querySelector('[name="acl_trusted_proxies"]').value = (acl.trusted_proxies || []).join('\n');
   } else {
     form.querySelector('[name="acl_mode"]').value = 'off';
     form.querySelector('[name="acl_client_ip_header"]').value = '';
     form.querySelector('[name="acl_allow_list"]').value = '';
     form.querySelector('[name="acl_deny_list"]').value = '';
+    form.querySelector('[name="acl_trusted_proxies"]').value = '';
   }
   toggleEditSourceTls();
   showError(document.getElementById('edit-source-error'), '');
@@ -203,10 +209,12 @@ async function submitEditSource(e) {
     return;
   }
   if (fd.get('protocol') === 'https') {
-    const optsResult = await api.setSourceServerOptions(uuid, {
+    // PUT replaces all options: keep the ones this form does not edit (request ID and forwarding headers).
+    const current = await api.getSourceServerOptions(uuid);
+    const optsResult = await api.setSourceServerOptions(uuid, Object.assign({}, current.ok && current.data ? current.data : {}, {
       tls_cert_path: fd.get('tls_cert_path') || '',
       tls_key_path: fd.get('tls_key_path') || ''
-    });
+    }));
     if (!optsResult.ok) {
       showError(errEl, optsResult.error || 'Failed to save TLS options');
       return;
@@ -214,11 +222,13 @@ async function submitEditSource(e) {
   }
   const aclAllow = (fd.get('acl_allow_list') || '').split(/\r?\n/).map(function (x) { return x.trim(); }).filter(Boolean);
   const aclDeny = (fd.get('acl_deny_list') || '').split(/\r?\n/).map(function (x) { return x.trim(); }).filter(Boolean);
+  const aclTrusted = (fd.get('acl_trusted_proxies') || '').split(/\r?\n/).map(function (x) { return x.trim(); }).filter(Boolean);
   const aclResult = await api.setSourceServerACL(uuid, {
     mode: fd.get('acl_mode') || 'off',
     client_ip_header: (fd.get('acl_client_ip_header') || '').trim(),
     allow_list: aclAllow,
-    deny_list: aclDeny
+    deny_list: aclDeny,
+    trusted_proxies: aclTrusted
   });
   if (!aclResult.ok) {
     showError(errEl, aclResult.error || 'Failed to save ACL options');
@@ -289,7 +299,8 @@ async function submitCreateTarget(e) {
     protocol: fd.get('protocol'),
     host: fd.get('host'),
     port: port,
-    base_path: fd.get('base_path') || ''
+    base_path: fd.get('base_path') || '',
+    preserve_host: fd.get('preserve_host') === 'true'
   });
   if (!result.ok) {
     showError(errEl, result.error || 'Request failed');
@@ -314,6 +325,7 @@ async function editTarget(uuid) {
   form.querySelector('[name="host"]').value = t.host || '';
   form.querySelector('[name="port"]').value = t.port || '';
   form.querySelector('[name="base_path"]').value = t.base_path || '';
+  form.querySelector('[name="preserve_host"]').value = t.preserve_host ? 'true' : 'false';
   showError(document.getElementById('edit-target-error'), '');
   document.getElementById('edit-target-modal').classList.remove('hidden');
 }
@@ -333,7 +345,8 @@ async function submitEditTarget(e) {
     protocol: fd.get('protocol'),
     host: fd.get('host'),
     port: port,
-    base_path: fd.get('base_path') || ''
+    base_path: fd.get('base_path') || '',
+    preserve_host: fd.get('preserve_host') === 'true'
   });
   if (!result.ok) {
     showError(errEl, result.error || 'Request failed');
@@ -725,27 +738,13 @@ async function loadStatsSection() {
     set('stats-summary-4xx', '4xx: ' + (typeof d.status_4xx === 'number' ? d.status_4xx : '—'));
     set('stats-summary-5xx', '5xx: ' + (typeof d.status_5xx === 'number' ? d.status_5xx : '—'));
     set('stats-summary-tps', 'TPS (1m): ' + (typeof d.tps_last_minute === 'number' ? d.tps_last_minute : '—'));
+    const byOutcome = d.by_outcome || {};
+    const denied = ['acl_denied', 'auth_denied', 'no_route', 'upstream_error', 'timeout', 'error'].reduce(function (n, k) {
+      return n + (byOutcome[k] || 0);
+    }, 0);
+    set('stats-summary-denied', 'Denied/failed (24h): ' + denied);
   }
-  const listResult = await api.getStats({ limit: 100 });
-  const tbody = document.getElementById('stats-recent-tbody');
-  if (tbody) {
-    if (!listResult.ok) {
-      tbody.innerHTML = '<tr><td colspan="6" class="empty">Failed to load</td></tr>';
-    } else {
-      const list = listResult.data.stats || [];
-      const total = listResult.data.total != null ? listResult.data.total : list.length;
-      if (list.length === 0) {
-        tbody.innerHTML = '<tr><td colspan="6" class="empty">No proxy requests recorded yet.</td></tr>';
-      } else {
-        tbody.innerHTML = list.map(function (s) {
-          const time = s.timestamp ? new Date(s.timestamp).toLocaleString() : '—';
-          const status = s.status_code != null ? s.status_code : '—';
-          const dur = s.duration_ms != null ? s.duration_ms + ' ms' : '—';
-          return '<tr><td>' + escapeHtml(time) + '</td><td>' + escapeHtml(s.method || '') + '</td><td>' + escapeHtml(s.path || '') + '</td><td>' + escapeHtml(String(status)) + '</td><td>' + escapeHtml(String(dur)) + '</td><td>' + escapeHtml(s.client_ip || '') + '</td></tr>';
-        }).join('');
-      }
-    }
-  }
+  await loadStatsRecent();
   const byRouteResult = await api.getStatsByRoute({ limit: 20 });
   const byRouteTbody = document.getElementById('stats-by-route-tbody');
   if (byRouteTbody) {
@@ -778,6 +777,39 @@ async function loadStatsSection() {
       }
     }
   }
+  const byCountryResult = await api.getStatsByCountry({ limit: 20 });
+  const byCountryTbody = document.getElementById('stats-by-country-tbody');
+  if (byCountryTbody) {
+    if (!byCountryResult.ok) {
+      byCountryTbody.innerHTML = '<tr><td colspan="2" class="empty">Failed to load</td></tr>';
+    } else {
+      const items = byCountryResult.data.items || [];
+      if (items.length === 0) {
+        byCountryTbody.innerHTML = '<tr><td colspan="2" class="empty">No data</td></tr>';
+      } else {
+        byCountryTbody.innerHTML = items.map(function (x) {
+          return '<tr><td>' + escapeHtml(x.country || 'Unknown') + '</td><td>' + (x.count != null ? x.count : '—') + '</td></tr>';
+        }).join('');
+      }
+    }
+  }
+  const byASNResult = await api.getStatsByASN({ limit: 20 });
+  const byASNTbody = document.getElementById('stats-by-asn-tbody');
+  if (byASNTbody) {
+    if (!byASNResult.ok) {
+      byASNTbody.innerHTML = '<tr><td colspan="3" class="empty">Failed to load</td></tr>';
+    } else {
+      const items = byASNResult.data.items || [];
+      if (items.length === 0) {
+        byASNTbody.innerHTML = '<tr><td colspan="3" class="empty">No data</td></tr>';
+      } else {
+        byASNTbody.innerHTML = items.map(function (x) {
+          const asn = x.asn ? 'AS' + x.asn : 'Unknown';
+          return '<tr><td>' + escapeHtml(asn) + '</td><td>' + escapeHtml(x.as_org || '') + '</td><td>' + (x.count != null ? x.count : '—') + '</td></tr>';
+        }).join('');
+      }
+    }
+  }
   const bySourceResult = await api.getStatsBySourceServer();
   const bySourceTbody = document.getElementById('stats-by-source-tbody');
   if (bySourceTbody) {
@@ -816,18 +848,114 @@ async function loadStatsSection() {
     if (!tpsResult.ok) {
       tpsContainer.textContent = 'Failed to load';
     } else {
-      const buckets = tpsResult.data.buckets || [];
+      const buckets = (tpsResult.data.buckets || []).filter(function (b) { return b.count > 0; });
       if (buckets.length === 0) {
         tpsContainer.textContent = 'No data for the last hour';
       } else {
         tpsContainer.textContent = buckets.map(function (b) {
-          return (b.at ? new Date(b.at).toLocaleTimeString() : '') + ': ' + (b.count != null ? b.count : 0) + ' req';
+          let text = (b.at ? new Date(b.at).toLocaleTimeString() : '') + ': ' + b.count + ' req';
+          if (b.avg_latency_ms) text += ', ' + b.avg_latency_ms + ' ms avg';
+          if (b.error_rate) text += ', ' + Math.round(b.error_rate * 100) + '% 5xx';
+          return text;
         }).join(' \u2022 ');
       }
     }
   }
 }
 
+// Recent requests: filters from #stats-recent-filters; "Load more" follows next_cursor and appends rows.
+const STATS_RECENT_PAGE = 100;
+let statsRecentCursor = '';
+let statsRecentShown = 0;
+
+async function loadStatsRecent(more) {
+  const tbody = document.getElementById('stats-recent-tbody');
+  const moreButton = document.getElementById('stats-recent-more');
+  const count = document.getElementById('stats-recent-count');
+  if (!tbody) return;
+  const params = { limit: STATS_RECENT_PAGE };
+  const form = document.getElementById('stats-recent-filters');
+  if (form) {
+    new FormData(form).forEach(function (v, k) {
+      params[k] = String(v).trim();
+    });
+  }
+  if (more && statsRecentCursor) params.cursor = statsRecentCursor;
+  const result = await api.getStats(params);
+  if (!result.ok) {
+    if (!more) tbody.innerHTML = '<tr><td colspan="6" class="empty">' + escapeHtml(result.error || 'Failed to load') + '</td></tr>';
+    else alert(result.error || 'Failed to load');
+    return;
+  }
+  const list = result.data.stats || [];
+  statsRecentShown = (more ? statsRecentShown : 0) + list.length;
+  statsRecentCursor = result.data.next_cursor || '';
+  if (moreButton) moreButton.hidden = !statsRecentCursor;
+  const rows = list.map(function (s) {
+    const time = s.timestamp ? new Date(s.timestamp).toLocaleString() : '—';
+    const status = s.status_code != null ? s.status_code : '—';
+    const dur = s.duration_ms != null ? s.duration_ms + ' ms' : '—';
+    return '<tr><td>' + escapeHtml(time) + '</td><td>' + escapeHtml(s.method || '') + '</td><td>' + escapeHtml(s.path || '') + '</td><td>' + escapeHtml(String(status)) + '</td><td>' + escapeHtml(String(dur)) + '</td><td>' + escapeHtml(s.client_ip || '') + '</td></tr>';
+  }).join('');
+  if (more) {
+    tbody.insertAdjacentHTML('beforeend', rows);
+  } else if (list.length === 0) {
+    tbody.innerHTML = '<tr><td colspan="6" class="empty">No matching proxy requests recorded.</td></tr>';
+  } else {
+    tbody.innerHTML = rows;
+  }
+  if (count) count.textContent = statsRecentShown + ' of ' + (result.data.total || 0);
+}
+
+// Live tail: follows /api/stats/live (Server-Sent Events) and keeps the newest STATS_LIVE_ROWS rows.
+const STATS_LIVE_ROWS = 100;
+let statsLiveSource = null;
+
+function toggleStatsLive() {
+  if (statsLiveSource) {
+    stopStatsLive();
+    return;
+  }
+  const tbody = document.getElementById('stats-live-tbody');
+  const status = document.getElementById('stats-live-status');
+  const button = document.getElementById('stats-live-toggle');
+  if (!tbody) return;
+  tbody.innerHTML = '';
+  statsLiveSource = new EventSource('/api/stats/live');
+  if (button) button.textContent = 'Stop';
+  if (status) status.textContent = 'Connecting…';
+  statsLiveSource.onopen = function () {
+    if (status) status.textContent = 'Live';
+  };
+  statsLiveSource.onerror = function () {
+    if (status) status.textContent = 'Disconnected, retrying…';
+  };
+  statsLiveSource.addEventListener('dropped', function (e) {
+    const d = JSON.parse(e.data);
+    if (status) status.textContent = 'Live (missed ' + d.count + ' while busy)';
+  });
+  statsLiveSource.addEventListener('stat', function (e) {
+    const s = JSON.parse(e.data);
+    const time = s.timestamp ? new Date(s.timestamp).toLocaleTimeString() : '—';
+    const statusCode = s.status_code != null ? s.status_code : (s.outcome || '—');
+    const dur = s.duration_ms != null ? s.duration_ms + ' ms' : '—';
+    const row = document.createElement('tr');
+    row.innerHTML = '<td>' + escapeHtml(time) + '</td><td>' + escapeHtml(s.method || '') + '</td><td>' + escapeHtml(s.path || '') + '</td><td>' + escapeHtml(String(statusCode)) + '</td><td>' + escapeHtml(String(dur)) + '</td><td>' + escapeHtml(s.client_ip || '') + '</td>';
+    tbody.insertBefore(row, tbody.firstChild);
+    while (tbody.rows.length > STATS_LIVE_ROWS) tbody.deleteRow(-1);
+  });
+}
+
+function stopStatsLive() {
+  if (!statsLiveSource) return;
+  statsLiveSource.close();
+  statsLiveSource = null;
+  const button = document.getElementById('stats-live-toggle');
+  const status = document.getElementById('stats-live-status');
+  if (button) button.textContent = 'Start';
+  if (status) status.textContent = 'Stopped';
+}
+
 function clearStatsConfirm() {
   if (!confirm('Clear all proxy statistics? This cannot be undone.')) return;
   api.clearStats().then(function (result) {
@@ -859,6 +987,7 @@ function showSection(id) {
   }
   if (pageTitleEl && SECTION_TITLES[id]) pageTitleEl.textContent = SECTION_TITLES[id];
   if (id === 'stats') loadStatsSection();
+  else stopStatsLive();
 }
 
 (function () {
@@ -922,6 +1051,8 @@ window.closeRouteAuthModal = closeRouteAuthModal;
 window.submitRouteAuth = submitRouteAuth;
 window.loadStatsSection = loadStatsSection;
 window.clearStatsConfirm = clearStatsConfirm;
+window.loadStatsRecent = loadStatsRecent;
+window.toggleStatsLive = toggleStatsLive;
 
 // Initial load
 refreshAll();
@@ -2,16 +2,20 @@ package main
 
 import (
 	"context"
-	"log"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"FeatherProxy/app/internal/alerting"
 	"FeatherProxy/app/internal/cache"
 	"FeatherProxy/app/internal/database"
+	"FeatherProxy/app/internal/geoip"
+	"FeatherProxy/app/internal/logging"
+	"FeatherProxy/app/internal/metrics"
 	"FeatherProxy/app/internal/proxy"
 	"FeatherProxy/app/internal/stats"
+	"FeatherProxy/app/internal/tracing"
 	server "FeatherProxy/app/internal/ui_server"
 
 	"github.com/joho/godotenv"
@@ -19,21 +23,33 @@ import (
 
 func main() {
 	_ = godotenv.Load()
+	logging.Configure(logging.ConfigFromEnv())
+	logger := logging.For(logging.App)
+
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:]); err != nil {
+			logger.Error("export failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	db, err := database.NewHandler()
 	if err != nil {
-		log.Fatalf("database: %v", err)
+		logger.Error("database open failed", "error", err)
+		os.Exit(1)
 	}
 	defer func() {
 		if err := db.Close(); err != nil {
-			log.Printf("database close: %v", err)
+			logger.Error("database close failed", "error", err)
 		}
 	}()
 
 	if err := db.AutoMigrate(); err != nil {
-		log.Fatalf("database migrate: %v", err)
+		logger.Error("database migrate failed", "error", err)
+		os.Exit(1)
 	}
-	log.Println("database: connected and migrated")
+	logger.Info("database connected and migrated")
 
 	// Initialize the repository and cache.
 	var repo database.Repository
@@ -42,7 +58,7 @@ func main() {
 	sharedCache, cacheTTL, err = cache.FromEnv()
 
 	if err != nil {
-		log.Printf("cache: %v (cache not enabled)", err)
+		logging.For(logging.Cache).Warn("cache not enabled", "error", err)
 	}
 	repo = database.NewRepository(db.DB(), sharedCache, cacheTTL)
 
@@ -67,20 +83,54 @@ func main() {
 	// Stats service (async recorder for proxy metrics).
 	statsConfig := stats.ConfigFromEnv()
 	statsSvc := stats.NewService(repo, statsConfig)
+	srv.SetLiveFeed(statsSvc.Live())
+	// GeoIP enrichment of recorded stats and country/ASN ACL entries (GEOIP_DB); files are reloaded when they change.
+	geo := geoip.New(geoip.ConfigFromEnv())
+	if geo != nil {
+		statsSvc.SetGeoIP(geo)
+		srv.SetGeoIP(geo)
+		go geo.Run(runCtx)
+	}
 	go func() {
 		statsSvc.Run(runCtx)
 	}()
 
-	// Proxy service (optional stats recorder).
-	proxyService := proxy.NewService(repo, sharedCache, cacheTTL, statsSvc)
+	// Alerting (rules on recorded stats, webhook notifications).
+	alerts := alerting.NewService(repo, alerting.ConfigFromEnv())
+	srv.SetAlerting(alerts)
+	go alerts.Run(runCtx)
+
+	// Tracing (W3C trace context; spans exported over OTLP/HTTP when an endpoint is configured).
+	tracer := tracing.New(tracing.ConfigFromEnv())
+	go tracer.Run(runCtx)
+
+	// Prometheus metrics: on the admin server by default, on a dedicated listener when METRICS_ADDR is set.
+	switch addr := metrics.AddrFromEnv(); addr {
+	case metrics.EndpointAdmin:
+		srv.SetMetricsHandler(metrics.Default.Handler())
+	case metrics.EndpointOff:
+	default:
+		go func() {
+			logger.Info("metrics listening", "url", "http://"+addr+"/metrics")
+			if err := metrics.Serve(runCtx, addr, metrics.Default); err != nil {
+				logger.Error("metrics server failed", "addr", addr, "error", err)
+			}
+		}()
+	}
+
+	// Proxy service (optional stats recorder and tracer).
+	proxyService := proxy.NewService(repo, sharedCache, cacheTTL, statsSvc, tracer)
+	if geo != nil {
+		proxyService.SetGeoIP(geo)
+	}
 
 	go func() {
-		log.Println("server: listening on http://localhost:4545")
+		logging.For(logging.UI).Info("listening", "url", "http://localhost:4545")
 		if err := srv.Run(runCtx); err != nil {
-			log.Printf("server: %v", err)
+			logging.For(logging.UI).Error("server failed", "error", err)
 			cancel() // so proxy exits and process terminates
 		}
-		log.Println("server: stopped")
+		logging.For(logging.UI).Info("server stopped")
 	}()
 
 	// Run proxy under a cancellable context so "reload" from UI can restart it (pick up new source servers).
@@ -95,13 +145,13 @@ func main() {
 		case <-runCtx.Done():
 			proxyCancel()
 			<-proxyDone
-			log.Println("proxy: stopped")
+			logger.Info("proxy stopped")
 			return
 		case <-reloadChan:
-			log.Println("proxy: reload requested, restarting…")
+			logger.Info("proxy reload requested, restarting")
 			proxyCancel()
 			<-proxyDone
-			log.Println("proxy: restarted")
+			logger.Info("proxy restarted")
 		}
 	}
 }